@@ -0,0 +1,324 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package external implements an account backend delegating all signing to a
+// standalone signer process reachable over JSON-RPC.
+package external
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ExternalScheme is the protocol scheme prefixing account and wallet URLs.
+const ExternalScheme = "extapi"
+
+// requestTimeout is the maximum time to wait for the signer to answer a request,
+// including any interactive confirmation by the user.
+const requestTimeout = 5 * time.Minute
+
+// refreshInterval is the minimum time between two background refreshes of the
+// accounts managed by the signer.
+const refreshInterval = time.Minute
+
+// ExternalBackend is an accounts.Backend exposing the single wallet of a remote
+// signer.
+type ExternalBackend struct {
+	signers []accounts.Wallet
+}
+
+// NewExternalBackend creates an account backend connected to the signer listening
+// on the given endpoint.
+func NewExternalBackend(endpoint string) (*ExternalBackend, error) {
+	signer, err := NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalBackend{signers: []accounts.Wallet{signer}}, nil
+}
+
+// Wallets implements accounts.Backend, returning the remote signer.
+func (eb *ExternalBackend) Wallets() []accounts.Wallet {
+	return eb.signers
+}
+
+// Subscribe implements accounts.Backend. The remote signer is configured once
+// and never changes, so no events are ever delivered.
+func (eb *ExternalBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// signTxArgs mirrors the transaction signing request of the remote signer.
+type signTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+	ChainID  *hexutil.Big    `json:"chainId"`
+}
+
+// signTxResponse mirrors the transaction signing result of the remote signer.
+type signTxResponse struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// ExternalSigner is an accounts.Wallet whose keys are held by a remote signer.
+type ExternalSigner struct {
+	client   *rpc.Client
+	endpoint string
+
+	cache      []accounts.Account // Accounts last reported by the signer
+	status     error              // Failure of the last account retrieval, if any
+	refreshed  time.Time          // Time the last account retrieval finished
+	refreshing bool               // Whether an account retrieval is in progress
+	ready      chan struct{}      // Closed once the first account retrieval finished
+	lock       sync.Mutex
+}
+
+// NewExternalSigner connects to the signer listening on the given endpoint.
+func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return newExternalSigner(client, endpoint), nil
+}
+
+// newExternalSigner creates a wallet backed by the signer behind an RPC client.
+func newExternalSigner(client *rpc.Client, endpoint string) *ExternalSigner {
+	return &ExternalSigner{client: client, endpoint: endpoint, ready: make(chan struct{})}
+}
+
+// URL implements accounts.Wallet, returning the endpoint of the signer.
+func (api *ExternalSigner) URL() accounts.URL {
+	return accounts.URL{Scheme: ExternalScheme, Path: api.endpoint}
+}
+
+// Status implements accounts.Wallet, reporting whether the signer was reachable
+// on the last account retrieval.
+func (api *ExternalSigner) Status() (string, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if api.status != nil {
+		return "Unreachable", api.status
+	}
+	return "Online", nil
+}
+
+// Open implements accounts.Wallet, refreshing the accounts known by the signer.
+// Passwords are managed by the signer itself, so the passphrase is ignored.
+func (api *ExternalSigner) Open(passphrase string) error {
+	api.lock.Lock()
+	api.refreshing = true
+	api.lock.Unlock()
+
+	return api.refresh()
+}
+
+// Close implements accounts.Wallet. The connection to the signer is shared by
+// all requests and is kept alive.
+func (api *ExternalSigner) Close() error {
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the accounts managed by the
+// signer. The first call waits for the accounts to be retrieved. As listing might
+// need interactive confirmation on the signer, later calls return the last known
+// accounts straight away, refreshing them in the background.
+func (api *ExternalSigner) Accounts() []accounts.Account {
+	api.lock.Lock()
+	if !api.refreshing && time.Since(api.refreshed) >= refreshInterval {
+		api.refreshing = true
+		go func() {
+			if err := api.refresh(); err != nil {
+				log.Warn("Failed to list external signer accounts", "endpoint", api.endpoint, "err", err)
+			}
+		}()
+	}
+	api.lock.Unlock()
+
+	// Wait for the first retrieval, the cache is meaningless before that
+	<-api.ready
+
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	cpy := make([]accounts.Account, len(api.cache))
+	copy(cpy, api.cache)
+	return cpy
+}
+
+// refresh retrieves the accounts managed by the signer and updates the cache. The
+// caller must have marked the refresh in progress.
+func (api *ExternalSigner) refresh() error {
+	var addresses []common.Address
+	err := api.call(&addresses, "account_list")
+
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	api.refreshing, api.refreshed, api.status = false, time.Now(), err
+	select {
+	case <-api.ready:
+	default:
+		close(api.ready)
+	}
+	if err != nil {
+		return err
+	}
+	api.cache = make([]accounts.Account, 0, len(addresses))
+	for _, addr := range addresses {
+		api.cache = append(api.cache, accounts.Account{Address: addr, URL: api.URL()})
+	}
+	return nil
+}
+
+// Contains implements accounts.Wallet, checking whether the signer manages an
+// account.
+func (api *ExternalSigner) Contains(account accounts.Account) bool {
+	for _, acc := range api.Accounts() {
+		if acc.Address == account.Address && (account.URL == (accounts.URL{}) || account.URL == acc.URL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is not supported by the signer.
+func (api *ExternalSigner) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for the signer.
+func (api *ExternalSigner) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// SignHash implements accounts.Wallet, requesting the signer to sign a digest.
+func (api *ExternalSigner) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := api.call(&signature, "account_signHash", account.Address, hexutil.Bytes(hash)); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// SignText implements accounts.Wallet, requesting the signer to sign the prefixed
+// hash of a message.
+func (api *ExternalSigner) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := api.call(&signature, "account_signData", account.Address, hexutil.Bytes(text)); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// SignTx implements accounts.Wallet, requesting the signer to sign a transaction.
+func (api *ExternalSigner) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := &signTxArgs{
+		From:     account.Address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+	}
+	if chainID != nil {
+		args.ChainID = (*hexutil.Big)(chainID)
+	}
+	var res signTxResponse
+	if err := api.call(&res, "account_signTransaction", args); err != nil {
+		return nil, err
+	}
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(res.Raw, signed); err != nil {
+		return nil, err
+	}
+	// Make sure the signer signed what was requested, by the requested account
+	var signer types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		signer = types.NewEIP155Signer(chainID)
+	}
+	if signer.Hash(signed) != signer.Hash(tx) {
+		return nil, fmt.Errorf("signed transaction mismatch: have %x, want %x", signer.Hash(signed), signer.Hash(tx))
+	}
+	if from, err := types.Sender(signer, signed); err != nil || from != account.Address {
+		return nil, fmt.Errorf("signed transaction sender mismatch: have %x, want %x", from, account.Address)
+	}
+	return signed, nil
+}
+
+// SignTypedData implements accounts.Wallet, requesting the signer to sign the
+// EIP-712 hash of structured data.
+func (api *ExternalSigner) SignTypedData(account accounts.Account, typedData *accounts.TypedData) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := api.call(&signature, "account_signTypedData", account.Address, typedData); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, but is not supported as the
+// passwords are managed by the signer.
+func (api *ExternalSigner) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, but is not supported as the
+// passwords are managed by the signer.
+func (api *ExternalSigner) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, but is not supported as the
+// passwords are managed by the signer.
+func (api *ExternalSigner) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, but is not supported as
+// the passwords are managed by the signer.
+func (api *ExternalSigner) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, typedData *accounts.TypedData) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// call invokes a method of the signer, bounded by the request timeout.
+func (api *ExternalSigner) call(result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	return api.client.CallContext(ctx, result, method, args...)
+}
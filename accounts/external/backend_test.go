@@ -0,0 +1,101 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package external
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SignerService is a remote signer whose account listing blocks until released,
+// as if waiting for the user's confirmation.
+type SignerService struct {
+	release chan []common.Address
+}
+
+func (s *SignerService) List(ctx context.Context) ([]common.Address, error) {
+	return <-s.release, nil
+}
+
+// Tests that the first account listing waits for the remote signer, while later
+// ones return the cached accounts, refreshing them in the background.
+func TestAccountsCached(t *testing.T) {
+	remote := &SignerService{release: make(chan []common.Address)}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", remote); err != nil {
+		t.Fatalf("failed to register signer: %v", err)
+	}
+	defer server.Stop()
+
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	signer := newExternalSigner(client, "test")
+
+	// The first listing must wait for the signer instead of reporting nothing
+	done := make(chan []accounts.Account)
+	go func() { done <- signer.Accounts() }()
+	select {
+	case accs := <-done:
+		t.Fatalf("account listing returned before the signer answered: %v", accs)
+	case <-time.After(100 * time.Millisecond):
+	}
+	remote.release <- []common.Address{{0x01}}
+
+	select {
+	case accs := <-done:
+		if len(accs) != 1 || accs[0].Address != (common.Address{0x01}) {
+			t.Fatalf("accounts mismatch: have %v, want %x", accs, common.Address{0x01})
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("account listing not finished after the signer answered")
+	}
+	if status, err := signer.Status(); err != nil {
+		t.Errorf("status mismatch: have %s (%v), want Online", status, err)
+	}
+	if !signer.Contains(accounts.Account{Address: common.Address{0x01}}) {
+		t.Errorf("listed account not contained")
+	}
+	// Later listings must return the cached accounts while refreshing them
+	signer.lock.Lock()
+	signer.refreshed = time.Time{}
+	signer.lock.Unlock()
+
+	go func() { done <- signer.Accounts() }()
+	select {
+	case accs := <-done:
+		if len(accs) != 1 {
+			t.Errorf("cached account count mismatch: have %d, want 1", len(accs))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("account listing blocked on the signer")
+	}
+	remote.release <- []common.Address{{0x01}, {0x02}}
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		if accs := signer.Accounts(); len(accs) == 2 {
+			return
+		}
+	}
+	t.Fatalf("accounts not refreshed in the background")
+}
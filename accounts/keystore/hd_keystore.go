@@ -0,0 +1,768 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/pborman/uuid"
+	"github.com/tyler-smith/go-bip39"
+	"gopkg.in/fatih/set.v0"
+)
+
+// HDKeyStoreType is the reflect type of a HD keystore backend.
+var HDKeyStoreType = reflect.TypeOf(&HDKeyStore{})
+
+// ErrInvalidMnemonic is returned if a mnemonic to restore a wallet from is not a
+// valid BIP-39 word sequence.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// ErrWalletExists is returned if a mnemonic to restore a wallet from is already
+// stored in the key store.
+var ErrWalletExists = errors.New("wallet already exists")
+
+// hdFilePrefix marks the seed files within the keystore directory.
+const hdFilePrefix = "hd-"
+
+// selfDeriveThrottling is the minimum time between two account discoveries.
+const selfDeriveThrottling = time.Second
+
+// mnemonicEntropy is the number of entropy bits of generated mnemonics (24 words).
+const mnemonicEntropy = 256
+
+// encryptedSeedJSON is the on disk format of an HD wallet, holding the encrypted
+// seed and the accounts pinned to the wallet. It deliberately lacks an address
+// field, so that plain key stores sharing the directory skip it.
+type encryptedSeedJSON struct {
+	Crypto   cryptoJSON      `json:"crypto"`
+	Accounts []hdAccountJSON `json:"hdaccounts"`
+	Id       string          `json:"id"`
+	Version  int             `json:"version"`
+}
+
+// hdAccountJSON is a public record of an account derived from a seed.
+type hdAccountJSON struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+// HDKeyStore is a backend of software hierarchical deterministic wallets, each of
+// them derived from a BIP-39 mnemonic whose seed is stored encrypted in the key
+// store directory. The directory is watched, so that seeds stored by other
+// processes (e.g. geth account newhd) show up while the node is running.
+type HDKeyStore struct {
+	keydir  string
+	scryptN int
+	scryptP int
+
+	wallets  []*hdWallet // Wallets sorted by URL
+	feed     event.Feed  // Wallet feed notifying of arrivals and departures
+	fileC    fileCache   // Seed files seen during the last directory scan
+	watcher  *watcher    // Directory watcher keeping the wallets up to date
+	throttle *time.Timer // Reload throttler if no watcher is running
+	lock     sync.RWMutex
+}
+
+// NewHDKeyStore creates an HD wallet backend for the given directory, loading any
+// seeds already stored within.
+func NewHDKeyStore(keydir string, scryptN, scryptP int) *HDKeyStore {
+	keydir, _ = filepath.Abs(keydir)
+	hub := &HDKeyStore{
+		keydir:  keydir,
+		scryptN: scryptN,
+		scryptP: scryptP,
+		fileC:   fileCache{all: set.NewNonTS()},
+	}
+	hub.watcher = newDirWatcher(keydir, &hub.lock, hub.scanWallets)
+	hub.scanWallets()
+	return hub
+}
+
+// Wallets implements accounts.Backend, returning all the HD wallets stored in the
+// key store directory.
+func (hub *HDKeyStore) Wallets() []accounts.Wallet {
+	hub.maybeReload()
+
+	hub.lock.RLock()
+	defer hub.lock.RUnlock()
+
+	cpy := make([]accounts.Wallet, len(hub.wallets))
+	for i, wallet := range hub.wallets {
+		cpy[i] = wallet
+	}
+	return cpy
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or opening of HD wallets.
+func (hub *HDKeyStore) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return hub.feed.Subscribe(sink)
+}
+
+// NewWallet generates a new random mnemonic and stores the wallet derived from it,
+// encrypted with the passphrase. The mnemonic is returned and should be written
+// down by the user, it is the only way to recover the wallet.
+func (hub *HDKeyStore) NewWallet(passphrase string) (string, accounts.Wallet, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropy)
+	if err != nil {
+		return "", nil, err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return "", nil, err
+	}
+	wallet, err := hub.Restore(mnemonic, passphrase)
+	if err != nil {
+		return "", nil, err
+	}
+	return mnemonic, wallet, nil
+}
+
+// Restore stores the wallet derived from an existing mnemonic, encrypted with the
+// passphrase. The first account of the default derivation path is pinned.
+func (hub *HDKeyStore) Restore(mnemonic string, passphrase string) (accounts.Wallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, ErrInvalidMnemonic
+	}
+	defer zeroBytes(seed)
+
+	path := accounts.DefaultBaseDerivationPath
+	key, err := deriveKey(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	// Refuse to store the same seed multiple times
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	for _, wallet := range hub.wallets {
+		if wallet.Contains(accounts.Account{Address: address}) {
+			return nil, ErrWalletExists
+		}
+	}
+	// Encrypt the seed and store it into a new file
+	encrypted, err := encryptData(seed, passphrase, hub.scryptN, hub.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.NewRandom()
+	wallet := &hdWallet{
+		hub:   hub,
+		url:   accounts.URL{Scheme: KeyStoreScheme, Path: filepath.Join(hub.keydir, fmt.Sprintf("UTC--%s--%s%s", toISO8601(time.Now().UTC()), hdFilePrefix, id))},
+		id:    id.String(),
+		seed:  encrypted,
+		paths: make(map[common.Address]accounts.DerivationPath),
+	}
+	wallet.pin(address, path)
+
+	if err := wallet.store(); err != nil {
+		return nil, err
+	}
+	hub.wallets = append(hub.wallets, wallet)
+	hub.sort()
+
+	go hub.feed.Send(accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+	return wallet, nil
+}
+
+// maybeReload rescans the key store directory if no watcher is running to keep
+// the wallets up to date, starting one and throttling the rescans meanwhile.
+func (hub *HDKeyStore) maybeReload() {
+	hub.lock.Lock()
+
+	if hub.watcher.running {
+		hub.lock.Unlock()
+		return // A watcher is running and will keep the wallets up-to-date.
+	}
+	if hub.throttle == nil {
+		hub.throttle = time.NewTimer(0)
+	} else {
+		select {
+		case <-hub.throttle.C:
+		default:
+			hub.lock.Unlock()
+			return // The wallets were reloaded recently.
+		}
+	}
+	hub.watcher.start()
+	hub.throttle.Reset(minReloadInterval)
+	hub.lock.Unlock()
+	hub.scanWallets()
+}
+
+// scanWallets checks the key store directory for seed files created, removed or
+// modified since the last scan and updates the wallets accordingly. Wallets that
+// are already loaded are kept, only picking up accounts pinned by others.
+func (hub *HDKeyStore) scanWallets() {
+	creates, deletes, updates, err := hub.fileC.scan(hub.keydir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debug("Failed to reload HD wallets", "dir", hub.keydir, "err", err)
+		}
+		return
+	}
+	if creates.Size() == 0 && deletes.Size() == 0 && updates.Size() == 0 {
+		return
+	}
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	var events []accounts.WalletEvent
+	for _, p := range deletes.List() {
+		path := p.(string)
+		for i, wallet := range hub.wallets {
+			if wallet.url.Path == path {
+				wallet.Close()
+				hub.wallets = append(hub.wallets[:i], hub.wallets[i+1:]...)
+				events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
+				break
+			}
+		}
+	}
+	for _, p := range append(creates.List(), updates.List()...) {
+		path := p.(string)
+		if !strings.Contains(filepath.Base(path), "--"+hdFilePrefix) {
+			continue
+		}
+		loaded, err := loadHDWallet(hub, path)
+		if err != nil {
+			log.Warn("Failed to load HD wallet", "file", filepath.Base(path), "err", err)
+			continue
+		}
+		if wallet := hub.find(path); wallet != nil {
+			wallet.merge(loaded)
+			continue
+		}
+		hub.wallets = append(hub.wallets, loaded)
+		events = append(events, accounts.WalletEvent{Wallet: loaded, Kind: accounts.WalletArrived})
+	}
+	hub.sort()
+
+	for _, event := range events {
+		go hub.feed.Send(event)
+	}
+}
+
+// find returns the loaded wallet stored at the given path, if any. The caller
+// must hold the key store lock.
+func (hub *HDKeyStore) find(path string) *hdWallet {
+	for _, wallet := range hub.wallets {
+		if wallet.url.Path == path {
+			return wallet
+		}
+	}
+	return nil
+}
+
+// sort orders the wallets by URL, as required by accounts.Backend.
+func (hub *HDKeyStore) sort() {
+	sort.Slice(hub.wallets, func(i, j int) bool {
+		return hub.wallets[i].url.Cmp(hub.wallets[j].url) < 0
+	})
+}
+
+// hdWallet is a software HD wallet, deriving its accounts from an encrypted seed.
+// The seed is only decrypted and kept in memory while the wallet is open.
+type hdWallet struct {
+	hub *HDKeyStore
+	url accounts.URL
+	id  string
+
+	seed     cryptoJSON                                 // Encrypted seed, as stored on disk
+	unlocked []byte                                     // Decrypted seed while the wallet is open
+	accounts []accounts.Account                         // Accounts pinned to the wallet
+	paths    map[common.Address]accounts.DerivationPath // Derivation paths of the pinned accounts
+
+	deriveNextPath accounts.DerivationPath   // Next derivation path for account auto-discovery
+	deriveChain    ethereum.ChainStateReader // Blockchain state reader to discover used accounts with
+	deriveLast     time.Time                 // Time of the last self-derivation, for throttling
+
+	lock sync.RWMutex
+}
+
+// loadHDWallet reads an HD wallet from a seed file.
+func loadHDWallet(hub *HDKeyStore, path string) (*hdWallet, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var enc encryptedSeedJSON
+	if err := json.Unmarshal(blob, &enc); err != nil {
+		return nil, err
+	}
+	if enc.Version != version {
+		return nil, fmt.Errorf("version not supported: %v", enc.Version)
+	}
+	wallet := &hdWallet{
+		hub:   hub,
+		url:   accounts.URL{Scheme: KeyStoreScheme, Path: path},
+		id:    enc.Id,
+		seed:  enc.Crypto,
+		paths: make(map[common.Address]accounts.DerivationPath),
+	}
+	for _, acc := range enc.Accounts {
+		path, err := accounts.ParseDerivationPath(acc.Path)
+		if err != nil {
+			return nil, err
+		}
+		wallet.pin(common.HexToAddress(acc.Address), path)
+	}
+	return wallet, nil
+}
+
+// merge pins the accounts of a freshly loaded copy of the wallet file, which
+// might have been updated by another process.
+func (w *hdWallet) merge(loaded *hdWallet) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, account := range loaded.accounts {
+		w.pin(account.Address, loaded.paths[account.Address])
+	}
+}
+
+// store writes the encrypted seed and the pinned accounts into the wallet file.
+// The caller must hold the wallet lock or have exclusive access to it.
+func (w *hdWallet) store() error {
+	enc := encryptedSeedJSON{
+		Crypto:  w.seed,
+		Id:      w.id,
+		Version: version,
+	}
+	for _, acc := range w.accounts {
+		enc.Accounts = append(enc.Accounts, hdAccountJSON{
+			Address: acc.Address.Hex(),
+			Path:    w.paths[acc.Address].String(),
+		})
+	}
+	blob, err := json.Marshal(enc)
+	if err != nil {
+		return err
+	}
+	return writeKeyFile(w.url.Path, blob)
+}
+
+// pin starts tracking an account derived from the seed, returning whether it is
+// new. The caller must hold the wallet lock or have exclusive access to it.
+func (w *hdWallet) pin(address common.Address, path accounts.DerivationPath) (accounts.Account, bool) {
+	account := accounts.Account{
+		Address: address,
+		URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+	}
+	if _, ok := w.paths[address]; ok {
+		return account, false
+	}
+	cpy := make(accounts.DerivationPath, len(path))
+	copy(cpy, path)
+
+	w.accounts = append(w.accounts, account)
+	w.paths[address] = cpy
+	return account, true
+}
+
+// URL implements accounts.Wallet, returning the path of the seed file.
+func (w *hdWallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the seed is unlocked.
+func (w *hdWallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.unlocked != nil {
+		return "Unlocked", nil
+	}
+	return "Locked", nil
+}
+
+// Open implements accounts.Wallet, decrypting the seed with the passphrase and
+// keeping it in memory until the wallet is closed. If no passphrase is given and
+// the seed is not encrypted with the empty one, an error is returned requesting
+// the user to supply it.
+func (w *hdWallet) Open(passphrase string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.unlocked != nil {
+		return accounts.ErrWalletAlreadyOpen
+	}
+	seed, err := decryptData(w.seed, passphrase)
+	if err != nil {
+		if passphrase == "" {
+			return accounts.NewAuthNeededError("keystore passphrase")
+		}
+		return err
+	}
+	w.unlocked = seed
+
+	go w.hub.feed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+	return nil
+}
+
+// Close implements accounts.Wallet, wiping the decrypted seed from memory.
+func (w *hdWallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.unlocked != nil {
+		zeroBytes(w.unlocked)
+		w.unlocked = nil
+	}
+	w.deriveChain = nil
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the list of accounts pinned to
+// the wallet. If self-derivation was enabled and the wallet is open, the account
+// list is expanded based on current chain state, throttled to avoid thrashing.
+func (w *hdWallet) Accounts() []accounts.Account {
+	w.selfDerive()
+
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// selfDerive discovers the accounts used on chain following the self-derivation
+// base path, pinning all of them along with the first unused one. The chain is
+// queried without holding the wallet lock, which is only taken to snapshot the
+// derivation state and to publish the results.
+func (w *hdWallet) selfDerive() {
+	w.lock.Lock()
+	if w.unlocked == nil || w.deriveChain == nil || time.Since(w.deriveLast) < selfDeriveThrottling {
+		w.lock.Unlock()
+		return
+	}
+	w.deriveLast = time.Now()
+
+	var (
+		seed  = append([]byte{}, w.unlocked...)
+		start = append(accounts.DerivationPath{}, w.deriveNextPath...)
+		next  = append(accounts.DerivationPath{}, w.deriveNextPath...)
+		chain = w.deriveChain
+	)
+	w.lock.Unlock()
+	defer zeroBytes(seed)
+
+	// Discover the used accounts without blocking the wallet
+	type discovery struct {
+		address common.Address
+		path    accounts.DerivationPath
+		balance *big.Int
+		nonce   uint64
+	}
+	var (
+		ctx   = context.Background()
+		found []discovery
+	)
+	for {
+		key, err := deriveKey(seed, next)
+		if err != nil {
+			log.Warn("HD wallet account derivation failed", "err", err)
+			break
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		zeroKey(key)
+
+		// Check the account's status against the current chain state
+		balance, err := chain.BalanceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet balance retrieval failed", "err", err)
+			break
+		}
+		nonce, err := chain.NonceAt(ctx, address, nil)
+		if err != nil {
+			log.Warn("HD wallet nonce retrieval failed", "err", err)
+			break
+		}
+		found = append(found, discovery{address, append(accounts.DerivationPath{}, next...), balance, nonce})
+
+		// Stop at the first empty account, but only move past it once used
+		if balance.Sign() == 0 && nonce == 0 {
+			break
+		}
+		next[len(next)-1]++
+	}
+	// Publish the results, unless the wallet was closed or reconfigured meanwhile
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.unlocked == nil || w.deriveChain != chain || w.deriveNextPath.String() != start.String() {
+		return
+	}
+	changed := false
+	for _, d := range found {
+		if _, added := w.pin(d.address, d.path); added {
+			log.Info("HD wallet discovered new account", "address", d.address, "path", d.path, "balance", d.balance, "nonce", d.nonce)
+			changed = true
+		}
+	}
+	w.deriveNextPath = next
+
+	if changed {
+		if err := w.store(); err != nil {
+			log.Warn("Failed to store HD wallet accounts", "err", err)
+		}
+	}
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not pinned into this wallet instance.
+func (w *hdWallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts and persisted into the wallet file.
+func (w *hdWallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.unlocked == nil {
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	key, err := deriveKey(w.unlocked, path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	zeroKey(key)
+
+	if !pin {
+		return accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+		}, nil
+	}
+	account, added := w.pin(address, path)
+	if added {
+		if err := w.store(); err != nil {
+			return accounts.Account{}, err
+		}
+	}
+	return account, nil
+}
+
+// SelfDerive implements accounts.Wallet, trying to discover accounts that the
+// user used previously (based on the chain state), but ones that he/she did not
+// explicitly pin to the wallet manually. To avoid chain head monitoring, self
+// derivation only runs during account listing (and even then throttled).
+func (w *hdWallet) SelfDerive(base accounts.DerivationPath, chain ethereum.ChainStateReader) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.deriveNextPath = make(accounts.DerivationPath, len(base))
+	copy(w.deriveNextPath[:], base[:])
+
+	w.deriveChain = chain
+	w.deriveLast = time.Time{}
+}
+
+// key derives the private key of a pinned account, either from the unlocked seed
+// or, if a passphrase is given, from the seed decrypted on the fly.
+func (w *hdWallet) key(account accounts.Account, passphrase *string) (*ecdsa.PrivateKey, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	if passphrase == nil {
+		if w.unlocked == nil {
+			return nil, ErrLocked
+		}
+		return deriveKey(w.unlocked, path)
+	}
+	seed, err := decryptData(w.seed, *passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(seed)
+
+	return deriveKey(seed, path)
+}
+
+// SignHash implements accounts.Wallet, signing the given hash with an account of
+// the open wallet.
+func (w *hdWallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	return w.signHash(account, nil, hash)
+}
+
+// SignText implements accounts.Wallet, signing the prefixed hash of the given
+// text with an account of the open wallet.
+func (w *hdWallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, nil, accounts.TextHash(text))
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with an
+// account of the open wallet.
+func (w *hdWallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.signTx(account, nil, tx, chainID)
+}
+
+// SignTypedData implements accounts.Wallet, signing the EIP-712 hash of the given
+// structured data with an account of the open wallet.
+func (w *hdWallet) SignTypedData(account accounts.Account, typedData *accounts.TypedData) ([]byte, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.signHash(account, nil, hash)
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, signing the given hash with
+// an account of the wallet, decrypting the seed with the passphrase.
+func (w *hdWallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return w.signHash(account, &passphrase, hash)
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, signing the prefixed hash of
+// the given text with an account of the wallet, decrypting the seed with the
+// passphrase.
+func (w *hdWallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.signHash(account, &passphrase, accounts.TextHash(text))
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, signing the given transaction
+// with an account of the wallet, decrypting the seed with the passphrase.
+func (w *hdWallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.signTx(account, &passphrase, tx, chainID)
+}
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, signing the EIP-712 hash
+// of the given structured data with an account of the wallet, decrypting the seed
+// with the passphrase.
+func (w *hdWallet) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, typedData *accounts.TypedData) ([]byte, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.signHash(account, &passphrase, hash)
+}
+
+func (w *hdWallet) signHash(account accounts.Account, passphrase *string, hash []byte) ([]byte, error) {
+	key, err := w.key(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return crypto.Sign(hash, key)
+}
+
+func (w *hdWallet) signTx(account accounts.Account, passphrase *string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.key(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	// Depending on the presence of the chain ID, sign with EIP155 or homestead
+	if chainID != nil {
+		return types.SignTx(tx, types.NewEIP155Signer(chainID), key)
+	}
+	return types.SignTx(tx, types.HomesteadSigner{}, key)
+}
+
+// deriveKey derives the private key at a BIP-32 derivation path from a seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	n := crypto.S256().Params().N
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	if key.Sign() == 0 || key.Cmp(n) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+	for i, index := range path {
+		var data []byte
+		if index >= 0x80000000 {
+			// Hardened child, derived from the private key
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			// Normal child, derived from the compressed public key
+			priv, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&priv.PublicKey)
+		}
+		data = append(data, byte(index>>24), byte(index>>16), byte(index>>8), byte(index))
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("invalid child key at %v", path[:i+1])
+		}
+		key = tweak.Add(tweak, key).Mod(tweak, n)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at %v", path[:i+1])
+		}
+		chainCode = sum[32:]
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// zeroBytes wipes a secret from memory.
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
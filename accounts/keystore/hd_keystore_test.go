@@ -0,0 +1,283 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Well known test mnemonic and the address of its first default account.
+var (
+	testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	testHDAddr   = common.HexToAddress("0x9858EfFD232B4033E47d90003D41EC34EcaEda94")
+)
+
+// Tests that keys are derived according to BIP-32.
+func TestHDDeriveKey(t *testing.T) {
+	seed := bip39.NewSeed(testMnemonic, "")
+
+	key, err := deriveKey(seed, accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	if addr := crypto.PubkeyToAddress(key.PublicKey); addr != testHDAddr {
+		t.Errorf("address mismatch: have %x, want %x", addr, testHDAddr)
+	}
+}
+
+func tmpHDKeyStore(t *testing.T) (string, *HDKeyStore) {
+	dir, err := ioutil.TempDir("", "eth-hdkeystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, NewHDKeyStore(dir, veryLightScryptN, veryLightScryptP)
+}
+
+// Tests that HD wallets are persisted, locked and unlocked as expected.
+func TestHDWalletLifecycle(t *testing.T) {
+	dir, hub := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := hub.Restore(testMnemonic, "foo")
+	if err != nil {
+		t.Fatalf("failed to restore wallet: %v", err)
+	}
+	if _, err := hub.Restore(testMnemonic, "bar"); err != ErrWalletExists {
+		t.Errorf("duplicate restore error mismatch: have %v, want %v", err, ErrWalletExists)
+	}
+	if _, err := hub.Restore("abandon abandon", "bar"); err != ErrInvalidMnemonic {
+		t.Errorf("invalid mnemonic error mismatch: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+	account := wallet.Accounts()[0]
+	if account.Address != testHDAddr {
+		t.Fatalf("account mismatch: have %x, want %x", account.Address, testHDAddr)
+	}
+	// Signing needs the wallet open or the passphrase
+	hash := crypto.Keccak256([]byte("hello"))
+	if _, err := wallet.SignHash(account, hash); err != ErrLocked {
+		t.Errorf("locked signing error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	if _, err := wallet.SignHashWithPassphrase(account, "bar", hash); err != ErrDecrypt {
+		t.Errorf("wrong passphrase error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	sig, err := wallet.SignHashWithPassphrase(account, "foo", hash)
+	if err != nil {
+		t.Fatalf("failed to sign with passphrase: %v", err)
+	}
+	if pub, err := crypto.SigToPub(hash, sig); err != nil || crypto.PubkeyToAddress(*pub) != testHDAddr {
+		t.Errorf("signer mismatch: err %v", err)
+	}
+	if _, err := wallet.Derive(accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000, 0, 1}, true); err != accounts.ErrWalletClosed {
+		t.Errorf("closed derivation error mismatch: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	// Open the wallet and pin a second account
+	if err := wallet.Open(""); err == nil {
+		t.Errorf("opened wallet without passphrase")
+	}
+	if err := wallet.Open("foo"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	if _, err := wallet.SignHash(account, hash); err != nil {
+		t.Errorf("failed to sign with open wallet: %v", err)
+	}
+	derived, err := wallet.Derive(accounts.DerivationPath{0x80000000 + 44, 0x80000000 + 60, 0x80000000, 0, 1}, true)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	wallet.Close()
+
+	if _, err := wallet.SignHash(derived, hash); err != ErrLocked {
+		t.Errorf("closed signing error mismatch: have %v, want %v", err, ErrLocked)
+	}
+	// Reload the wallets from disk and ensure the pinned accounts are retained
+	reloaded := NewHDKeyStore(dir, veryLightScryptN, veryLightScryptP).Wallets()
+	if len(reloaded) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(reloaded))
+	}
+	if accs := reloaded[0].Accounts(); len(accs) != 2 || accs[0] != account || accs[1] != derived {
+		t.Errorf("reloaded accounts mismatch: have %v, want %v", accs, []accounts.Account{account, derived})
+	}
+	// Plain key stores sharing the directory must not pick the seed up
+	if accs := NewKeyStore(dir, veryLightScryptN, veryLightScryptP).Accounts(); len(accs) != 0 {
+		t.Errorf("plain keystore found seed accounts: %v", accs)
+	}
+}
+
+// testChainState is a chain state reader reporting a fixed set of used accounts.
+type testChainState struct {
+	used map[common.Address]bool
+}
+
+func (s *testChainState) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	if s.used[account] {
+		return big.NewInt(1), nil
+	}
+	return new(big.Int), nil
+}
+
+func (s *testChainState) StorageAt(ctx context.Context, account common.Address, key common.Hash, number *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testChainState) CodeAt(ctx context.Context, account common.Address, number *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testChainState) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	return 0, nil
+}
+
+// Tests that accounts used on chain are discovered when self-derivation is on.
+func TestHDWalletSelfDerive(t *testing.T) {
+	dir, hub := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := hub.Restore(testMnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to restore wallet: %v", err)
+	}
+	if err := wallet.Open(""); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	// Mark the first two accounts used, discovery should pin the third too
+	seed := bip39.NewSeed(testMnemonic, "")
+	state := &testChainState{used: make(map[common.Address]bool)}
+	for i := 0; i < 2; i++ {
+		path := append(accounts.DerivationPath{}, accounts.DefaultBaseDerivationPath...)
+		path[len(path)-1] = uint32(i)
+		key, _ := deriveKey(seed, path)
+		state.used[crypto.PubkeyToAddress(key.PublicKey)] = true
+	}
+	wallet.SelfDerive(accounts.DefaultBaseDerivationPath, state)
+
+	if accs := wallet.Accounts(); len(accs) != 3 {
+		t.Errorf("discovered account count mismatch: have %d, want 3", len(accs))
+	}
+}
+
+// Tests that HD wallets stored or removed by another process sharing the key
+// store directory are picked up while the key store is running.
+func TestHDKeyStoreWatch(t *testing.T) {
+	dir, hub := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	events := make(chan accounts.WalletEvent, 4)
+	sub := hub.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	if wallets := hub.Wallets(); len(wallets) != 0 {
+		t.Fatalf("wallet count mismatch: have %d, want 0", len(wallets))
+	}
+	// Store a wallet through a separate key store and wait for it to show up
+	stored, err := NewHDKeyStore(dir, veryLightScryptN, veryLightScryptP).Restore(testMnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to restore wallet: %v", err)
+	}
+	waitForHDWallets := func(want int) []accounts.Wallet {
+		var wallets []accounts.Wallet
+		for d := 200 * time.Millisecond; d < 8*time.Second; d *= 2 {
+			if wallets = hub.Wallets(); len(wallets) == want {
+				return wallets
+			}
+			time.Sleep(d)
+		}
+		t.Fatalf("wallet count mismatch: have %d, want %d", len(wallets), want)
+		return nil
+	}
+	if wallets := waitForHDWallets(1); wallets[0].URL() != stored.URL() || !wallets[0].Contains(accounts.Account{Address: testHDAddr}) {
+		t.Fatalf("wallet mismatch: have %v, want %v", wallets[0].URL(), stored.URL())
+	}
+	select {
+	case ev := <-events:
+		if ev.Kind != accounts.WalletArrived {
+			t.Errorf("event kind mismatch: have %v, want %v", ev.Kind, accounts.WalletArrived)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("wallet arrival not announced")
+	}
+	// Remove the seed file and wait for the wallet to be dropped
+	if err := os.Remove(stored.URL().Path); err != nil {
+		t.Fatalf("failed to remove seed file: %v", err)
+	}
+	waitForHDWallets(0)
+}
+
+// blockingChainState is a chain state reader blocking balance queries until
+// released, reporting every account unused.
+type blockingChainState struct {
+	testChainState
+	queried chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingChainState) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (*big.Int, error) {
+	s.queried <- struct{}{}
+	<-s.release
+	return new(big.Int), nil
+}
+
+// Tests that self-derivation doesn't hold the wallet lock while querying the
+// chain state, so that other wallet operations are not blocked by slow nodes.
+func TestHDWalletSelfDeriveUnlocked(t *testing.T) {
+	dir, hub := tmpHDKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := hub.Restore(testMnemonic, "")
+	if err != nil {
+		t.Fatalf("failed to restore wallet: %v", err)
+	}
+	if err := wallet.Open(""); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	state := &blockingChainState{queried: make(chan struct{}), release: make(chan struct{})}
+	wallet.SelfDerive(accounts.DefaultBaseDerivationPath, state)
+
+	done := make(chan []accounts.Account)
+	go func() { done <- wallet.Accounts() }()
+	<-state.queried
+
+	// The chain is being queried, other operations must not block meanwhile
+	checked := make(chan struct{})
+	go func() {
+		wallet.Status()
+		wallet.Contains(accounts.Account{Address: testHDAddr})
+		if _, err := wallet.SignHash(accounts.Account{Address: testHDAddr}, crypto.Keccak256(nil)); err != nil {
+			t.Errorf("failed to sign during self-derivation: %v", err)
+		}
+		close(checked)
+	}()
+	select {
+	case <-checked:
+	case <-time.After(time.Second):
+		t.Fatalf("wallet blocked during self-derivation")
+	}
+	close(state.release)
+
+	if accs := <-done; len(accs) != 1 || accs[0].Address != testHDAddr {
+		t.Errorf("discovered accounts mismatch: have %v, want %x", accs, testHDAddr)
+	}
+}
@@ -110,6 +110,24 @@ func (w *keystoreWallet) SignTx(account accounts.Account, tx *types.Transaction,
 	return w.keystore.SignTx(account, tx, chainID)
 }
 
+// SignText implements accounts.Wallet, attempting to sign the hash of the given
+// text, prefixed by the Ethereum message prefix, with the given account.
+func (w *keystoreWallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.SignHash(account, accounts.TextHash(text))
+}
+
+// SignTypedData implements accounts.Wallet, attempting to sign the EIP-712 hash
+// of the given structured data with the given account. If the wallet does not
+// wrap this particular account, an error is returned to avoid account leakage
+// (even though in theory we may be able to sign via our shared keystore backend).
+func (w *keystoreWallet) SignTypedData(account accounts.Account, typedData *accounts.TypedData) ([]byte, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.SignHash(account, hash)
+}
+
 // SignHashWithPassphrase implements accounts.Wallet, attempting to sign the
 // given hash with the given account using passphrase as extra authentication.
 func (w *keystoreWallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
@@ -124,6 +142,13 @@ func (w *keystoreWallet) SignHashWithPassphrase(account accounts.Account, passph
 	return w.keystore.SignHashWithPassphrase(account, passphrase, hash)
 }
 
+// SignTextWithPassphrase implements accounts.Wallet, attempting to sign the
+// prefixed hash of the given text with the given account using passphrase as
+// extra authentication.
+func (w *keystoreWallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignHashWithPassphrase(account, passphrase, accounts.TextHash(text))
+}
+
 // SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
 // transaction with the given account using passphrase as extra authentication.
 func (w *keystoreWallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
@@ -137,3 +162,14 @@ func (w *keystoreWallet) SignTxWithPassphrase(account accounts.Account, passphra
 	// Account seems valid, request the keystore to sign
 	return w.keystore.SignTxWithPassphrase(account, passphrase, tx, chainID)
 }
+
+// SignTypedDataWithPassphrase implements accounts.Wallet, attempting to sign the
+// EIP-712 hash of the given structured data with the given account using the
+// passphrase as extra authentication.
+func (w *keystoreWallet) SignTypedDataWithPassphrase(account accounts.Account, passphrase string, typedData *accounts.TypedData) ([]byte, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return w.SignHashWithPassphrase(account, passphrase, hash)
+}
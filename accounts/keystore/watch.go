@@ -19,6 +19,7 @@
 package keystore
 
 import (
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -26,7 +27,9 @@ import (
 )
 
 type watcher struct {
-	ac       *accountCache
+	dir      string      // Directory to watch
+	mu       sync.Locker // Lock of the owning cache, guarding starting and running
+	reload   func()      // Callback rescanning the directory after changes
 	starting bool
 	running  bool
 	ev       chan notify.EventInfo
@@ -34,16 +37,25 @@ type watcher struct {
 }
 
 func newWatcher(ac *accountCache) *watcher {
+	return newDirWatcher(ac.keydir, &ac.mu, func() { ac.scanAccounts() })
+}
+
+// newDirWatcher creates a watcher calling reload whenever the contents of dir
+// change. The lock is the one of the owning cache, held while checking whether
+// the watcher is running.
+func newDirWatcher(dir string, mu sync.Locker, reload func()) *watcher {
 	return &watcher{
-		ac:   ac,
-		ev:   make(chan notify.EventInfo, 10),
-		quit: make(chan struct{}),
+		dir:    dir,
+		mu:     mu,
+		reload: reload,
+		ev:     make(chan notify.EventInfo, 10),
+		quit:   make(chan struct{}),
 	}
 }
 
 // starts the watcher loop in the background.
 // Start a watcher in the background if that's not already in progress.
-// The caller must hold w.ac.mu.
+// The caller must hold w.mu.
 func (w *watcher) start() {
 	if w.starting || w.running {
 		return
@@ -58,14 +70,14 @@ func (w *watcher) close() {
 
 func (w *watcher) loop() {
 	defer func() {
-		w.ac.mu.Lock()
+		w.mu.Lock()
 		w.running = false
 		w.starting = false
-		w.ac.mu.Unlock()
+		w.mu.Unlock()
 	}()
-	logger := log.New("path", w.ac.keydir)
+	logger := log.New("path", w.dir)
 
-	if err := notify.Watch(w.ac.keydir, w.ev, notify.All); err != nil {
+	if err := notify.Watch(w.dir, w.ev, notify.All); err != nil {
 		logger.Trace("Failed to watch keystore folder", "err", err)
 		return
 	}
@@ -73,9 +85,12 @@ func (w *watcher) loop() {
 	logger.Trace("Started watching keystore folder")
 	defer logger.Trace("Stopped watching keystore folder")
 
-	w.ac.mu.Lock()
+	w.mu.Lock()
 	w.running = true
-	w.ac.mu.Unlock()
+	w.mu.Unlock()
+
+	// Pick up any changes made while the watch was being set up
+	w.reload()
 
 	// Wait for file system events and reload.
 	// When an event occurs, the reload call is delayed a bit so that
@@ -101,7 +116,7 @@ func (w *watcher) loop() {
 				rescanTriggered = true
 			}
 		case <-debounce.C:
-			w.ac.scanAccounts()
+			w.reload()
 			rescanTriggered = false
 		}
 	}
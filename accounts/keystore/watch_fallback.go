@@ -21,8 +21,11 @@
 
 package keystore
 
+import "sync"
+
 type watcher struct{ running bool }
 
-func newWatcher(*accountCache) *watcher { return new(watcher) }
-func (*watcher) start()                 {}
-func (*watcher) close()                 {}
+func newWatcher(*accountCache) *watcher                  { return new(watcher) }
+func newDirWatcher(string, sync.Locker, func()) *watcher { return new(watcher) }
+func (*watcher) start()                                  {}
+func (*watcher) close()                                  {}
@@ -0,0 +1,439 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// domainType is the name of the type describing the signing domain.
+const domainType = "EIP712Domain"
+
+// TypedDataField is a single named member of a structured type.
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedDataTypes maps struct type names to their member definitions.
+type TypedDataTypes map[string][]TypedDataField
+
+// TypedData is a structured message to be hashed and signed according to EIP-712,
+// consisting of the type definitions, the signing domain and the message itself.
+type TypedData struct {
+	Types       TypedDataTypes         `json:"types"`
+	PrimaryType string                 `json:"primaryType"`
+	Domain      map[string]interface{} `json:"domain"`
+	Message     map[string]interface{} `json:"message"`
+}
+
+// Validate checks that the type definitions are well formed: the domain and the
+// primary types are defined, all struct and member names are valid identifiers
+// and every member type is either atomic, a defined struct, or an array thereof.
+func (typedData *TypedData) Validate() error {
+	if _, ok := typedData.Types[domainType]; !ok {
+		return fmt.Errorf("missing %s type definition", domainType)
+	}
+	if _, ok := typedData.Types[typedData.PrimaryType]; !ok {
+		return fmt.Errorf("primary type %q undefined", typedData.PrimaryType)
+	}
+	names := make([]string, 0, len(typedData.Types))
+	for name := range typedData.Types {
+		if !isIdentifier(name) {
+			return fmt.Errorf("invalid type name %q", name)
+		}
+		if isAtomicType(name) {
+			return fmt.Errorf("type name %q shadows atomic type", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fields := typedData.Types[name]
+		seen := make(map[string]bool)
+		for _, field := range fields {
+			if !isIdentifier(field.Name) {
+				return fmt.Errorf("type %s: invalid member name %q", name, field.Name)
+			}
+			if seen[field.Name] {
+				return fmt.Errorf("type %s: duplicate member %q", name, field.Name)
+			}
+			seen[field.Name] = true
+
+			base, ok := baseType(field.Type)
+			if !ok {
+				return fmt.Errorf("type %s: malformed array type %q", name, field.Type)
+			}
+			if _, ok := typedData.Types[base]; !ok && !isAtomicType(base) {
+				return fmt.Errorf("type %s: unknown member type %q", name, field.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Hash validates the typed data and calculates the digest to sign, defined as
+// keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)).
+func (typedData *TypedData) Hash() ([]byte, error) {
+	domainSeparator, messageHash, err := typedData.HashParts()
+	if err != nil {
+		return nil, err
+	}
+	raw := append([]byte{0x19, 0x01}, domainSeparator...)
+	return crypto.Keccak256(append(raw, messageHash...)), nil
+}
+
+// HashParts validates the typed data and calculates the two hashes the digest to
+// sign commits to: the domain separator and the hash of the message struct. The
+// latter is nil if the primary type is the domain itself, as such messages only
+// commit to the domain.
+func (typedData *TypedData) HashParts() (domainSeparator []byte, messageHash []byte, err error) {
+	if err := typedData.Validate(); err != nil {
+		return nil, nil, err
+	}
+	domainSeparator, err = typedData.HashStruct(domainType, typedData.Domain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("domain: %v", err)
+	}
+	if typedData.PrimaryType != domainType {
+		messageHash, err = typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+		if err != nil {
+			return nil, nil, fmt.Errorf("message: %v", err)
+		}
+	}
+	return domainSeparator, messageHash, nil
+}
+
+// EncodeType returns the canonical encoding of a struct type, that is its own
+// definition followed by the alphabetically sorted definitions of all the struct
+// types it references.
+func (typedData *TypedData) EncodeType(primaryType string) string {
+	deps := make(map[string]bool)
+	typedData.dependencies(primaryType, deps)
+	delete(deps, primaryType)
+
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buffer bytes.Buffer
+	for _, name := range append([]string{primaryType}, names...) {
+		buffer.WriteString(name)
+		buffer.WriteString("(")
+		for i, field := range typedData.Types[name] {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(field.Type)
+			buffer.WriteString(" ")
+			buffer.WriteString(field.Name)
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.String()
+}
+
+// TypeHash returns the hash of the canonical encoding of a struct type.
+func (typedData *TypedData) TypeHash(primaryType string) []byte {
+	return crypto.Keccak256([]byte(typedData.EncodeType(primaryType)))
+}
+
+// HashStruct returns the hash of a struct value, defined as
+// keccak256(typeHash ‖ encodeData(data)).
+func (typedData *TypedData) HashStruct(primaryType string, data map[string]interface{}) ([]byte, error) {
+	encoded, err := typedData.encodeData(primaryType, data)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(typedData.TypeHash(primaryType), encoded), nil
+}
+
+// dependencies collects the struct types referenced by a type, transitively.
+func (typedData *TypedData) dependencies(primaryType string, found map[string]bool) {
+	if found[primaryType] {
+		return
+	}
+	if _, ok := typedData.Types[primaryType]; !ok {
+		return
+	}
+	found[primaryType] = true
+	for _, field := range typedData.Types[primaryType] {
+		if base, ok := baseType(field.Type); ok {
+			typedData.dependencies(base, found)
+		}
+	}
+}
+
+// encodeData encodes the members of a struct value as a sequence of 32 byte words
+// in the order of the type definition.
+func (typedData *TypedData) encodeData(primaryType string, data map[string]interface{}) ([]byte, error) {
+	fields := typedData.Types[primaryType]
+
+	declared := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		declared[field.Name] = true
+	}
+	for name := range data {
+		if !declared[name] {
+			return nil, fmt.Errorf("%s: undeclared member %q", primaryType, name)
+		}
+	}
+	encoded := make([]byte, 0, 32*len(fields))
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s: missing member %q", primaryType, field.Name)
+		}
+		word, err := typedData.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", primaryType, field.Name, err)
+		}
+		encoded = append(encoded, word...)
+	}
+	return encoded, nil
+}
+
+// encodeValue encodes a single value of the given type into a 32 byte word.
+func (typedData *TypedData) encodeValue(typ string, value interface{}) ([]byte, error) {
+	// Arrays are encoded as the hash of their concatenated encoded elements
+	if strings.HasSuffix(typ, "]") {
+		elem, length, ok := parseArray(typ)
+		if !ok {
+			return nil, fmt.Errorf("malformed array type %q", typ)
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value %v", typ, value)
+		}
+		if length >= 0 && len(items) != length {
+			return nil, fmt.Errorf("array length mismatch: have %d, want %d", len(items), length)
+		}
+		encoded := make([]byte, 0, 32*len(items))
+		for i, item := range items {
+			word, err := typedData.encodeValue(elem, item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			encoded = append(encoded, word...)
+		}
+		return crypto.Keccak256(encoded), nil
+	}
+	// Structs are encoded as their hash
+	if _, ok := typedData.Types[typ]; ok {
+		members, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value %v", typ, value)
+		}
+		return typedData.HashStruct(typ, members)
+	}
+	return encodeAtomic(typ, value)
+}
+
+// encodeAtomic encodes a value of an atomic type into a 32 byte word, hashing the
+// dynamic string and bytes types.
+func encodeAtomic(typ string, value interface{}) ([]byte, error) {
+	switch {
+	case typ == "address":
+		str, ok := value.(string)
+		if !ok || !common.IsHexAddress(str) {
+			return nil, fmt.Errorf("invalid address %v", value)
+		}
+		return common.LeftPadBytes(common.HexToAddress(str).Bytes(), 32), nil
+
+	case typ == "bool":
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool %v", value)
+		}
+		if flag {
+			return math.PaddedBigBytes(common.Big1, 32), nil
+		}
+		return make([]byte, 32), nil
+
+	case typ == "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid string %v", value)
+		}
+		return crypto.Keccak256([]byte(str)), nil
+
+	case typ == "bytes":
+		blob, err := decodeBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(blob), nil
+
+	case strings.HasPrefix(typ, "bytes"):
+		size, _ := strconv.Atoi(typ[len("bytes"):])
+		blob, err := decodeBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(blob) != size {
+			return nil, fmt.Errorf("%s length mismatch: have %d, want %d", typ, len(blob), size)
+		}
+		return common.RightPadBytes(blob, 32), nil
+
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		signed := strings.HasPrefix(typ, "int")
+		bits, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"))
+
+		num, err := decodeInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		min, max := new(big.Int), new(big.Int).Lsh(common.Big1, uint(bits))
+		if signed {
+			max.Rsh(max, 1)
+			min.Neg(max)
+		}
+		if num.Cmp(min) < 0 || num.Cmp(max) >= 0 {
+			return nil, fmt.Errorf("%s out of range: %v", typ, num)
+		}
+		return math.PaddedBigBytes(math.U256(num), 32), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
+
+// decodeBytes converts a hex encoded value into a byte slice.
+func decodeBytes(value interface{}) ([]byte, error) {
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid bytes %v", value)
+	}
+	blob, err := hexutil.Decode(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytes %q: %v", str, err)
+	}
+	return blob, nil
+}
+
+// decodeInteger converts a decimal or hex string, or a JSON number into a big
+// integer. Numbers outside the exactly representable float64 range must be
+// specified as strings.
+func decodeInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case string:
+		str, negative := v, strings.HasPrefix(v, "-")
+		if negative {
+			str = str[1:]
+		}
+		num, ok := math.ParseBig256(str)
+		if !ok || str == "" {
+			return nil, fmt.Errorf("invalid integer %q", v)
+		}
+		if negative {
+			num.Neg(num)
+		}
+		return num, nil
+
+	case json.Number:
+		num, ok := new(big.Int).SetString(v.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		return num, nil
+
+	case float64:
+		if v != float64(int64(v)) || v > 1<<53 || v < -(1<<53) {
+			return nil, fmt.Errorf("invalid or imprecise integer %v, use a string", v)
+		}
+		return big.NewInt(int64(v)), nil
+	}
+	return nil, fmt.Errorf("invalid integer %v", value)
+}
+
+// isAtomicType reports whether a type name is one of the atomic EIP-712 types.
+func isAtomicType(typ string) bool {
+	switch typ {
+	case "address", "bool", "string", "bytes":
+		return true
+	}
+	if strings.HasPrefix(typ, "bytes") {
+		size, err := strconv.Atoi(typ[len("bytes"):])
+		return err == nil && size >= 1 && size <= 32 && typ == "bytes"+strconv.Itoa(size)
+	}
+	for _, prefix := range []string{"uint", "int"} {
+		if strings.HasPrefix(typ, prefix) {
+			bits, err := strconv.Atoi(typ[len(prefix):])
+			return err == nil && bits >= 8 && bits <= 256 && bits%8 == 0 && typ == prefix+strconv.Itoa(bits)
+		}
+	}
+	return false
+}
+
+// parseArray splits an array type into its element type and length, which is -1
+// for dynamically sized arrays.
+func parseArray(typ string) (string, int, bool) {
+	open := strings.LastIndex(typ, "[")
+	if open <= 0 || !strings.HasSuffix(typ, "]") {
+		return "", 0, false
+	}
+	size := typ[open+1 : len(typ)-1]
+	if size == "" {
+		return typ[:open], -1, true
+	}
+	length, err := strconv.Atoi(size)
+	if err != nil || length < 0 || size != strconv.Itoa(length) {
+		return "", 0, false
+	}
+	return typ[:open], length, true
+}
+
+// baseType strips all array suffixes from a type.
+func baseType(typ string) (string, bool) {
+	for strings.HasSuffix(typ, "]") {
+		elem, _, ok := parseArray(typ)
+		if !ok {
+			return "", false
+		}
+		typ = elem
+	}
+	return typ, true
+}
+
+// isIdentifier reports whether a name is a valid Solidity style identifier.
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || c == '$':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
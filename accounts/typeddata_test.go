@@ -0,0 +1,180 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package accounts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The mail example of the EIP-712 specification.
+const testMailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func parseTypedData(t *testing.T, blob string) *TypedData {
+	typedData := new(TypedData)
+	if err := json.Unmarshal([]byte(blob), typedData); err != nil {
+		t.Fatalf("failed to parse typed data: %v", err)
+	}
+	return typedData
+}
+
+// Tests that the hashes and signature of the specification example are reproduced.
+func TestTypedDataHash(t *testing.T) {
+	typedData := parseTypedData(t, testMailTypedData)
+
+	if enc := typedData.EncodeType("Mail"); enc != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("type encoding mismatch: have %s", enc)
+	}
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain)
+	if err != nil {
+		t.Fatalf("failed to hash domain: %v", err)
+	}
+	if have, want := hexutil.Encode(domainSeparator), "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; have != want {
+		t.Errorf("domain separator mismatch: have %s, want %s", have, want)
+	}
+	hash, err := typedData.Hash()
+	if err != nil {
+		t.Fatalf("failed to hash typed data: %v", err)
+	}
+	if have, want := hexutil.Encode(hash), "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; have != want {
+		t.Errorf("hash mismatch: have %s, want %s", have, want)
+	}
+	key, _ := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+	if addr := crypto.PubkeyToAddress(key.PublicKey); addr != common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826") {
+		t.Fatalf("signer mismatch: have %x", addr)
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if have, want := hexutil.Encode(sig[:64]), "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562"; have != want {
+		t.Errorf("signature mismatch: have %s, want %s", have, want)
+	}
+	if sig[64] != 1 {
+		t.Errorf("recovery id mismatch: have %d, want 1", sig[64])
+	}
+}
+
+// Tests that malformed type definitions and values are rejected.
+func TestTypedDataValidation(t *testing.T) {
+	tests := []struct {
+		from, to string
+		err      string
+	}{
+		{`"primaryType": "Mail"`, `"primaryType": "Letter"`, "primary type"},
+		{`"EIP712Domain": [`, `"Domain": [`, "missing EIP712Domain"},
+		{`{"name": "wallet", "type": "address"}`, `{"name": "wallet", "type": "adress"}`, "unknown member type"},
+		{`{"name": "wallet", "type": "address"}`, `{"name": "wallet", "type": "address[x]"}`, "malformed array type"},
+		{`{"name": "wallet", "type": "address"}`, `{"name": "name", "type": "address"}`, "duplicate member"},
+		{`{"name": "wallet", "type": "address"}`, `{"name": "wal let", "type": "address"}`, "invalid member name"},
+		{`{"name": "wallet", "type": "address"}`, `{"name": "wallet", "type": "uint7"}`, "unknown member type"},
+		{`"Person": [`, `"bytes32": [`, "shadows atomic type"},
+		{`"chainId": 1,`, `"chainId": -1,`, "out of range"},
+		{`"chainId": 1,`, `"chainId": 1.5,`, "imprecise integer"},
+		{`"chainId": 1,`, `"chainId": 1, "salt": "0x00",`, "undeclared member"},
+		{`"contents": "Hello, Bob!"`, `"content": "Hello, Bob!"`, "undeclared member"},
+		{`"name": "Bob", `, ``, "missing member"},
+		{`"0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"`, `"0xbBbB"`, "invalid address"},
+	}
+	for i, tt := range tests {
+		if !strings.Contains(testMailTypedData, tt.from) {
+			t.Fatalf("test %d: replaced snippet not found", i)
+		}
+		typedData := parseTypedData(t, strings.Replace(testMailTypedData, tt.from, tt.to, 1))
+		if _, err := typedData.Hash(); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
+
+// Tests the encoding of atomic and array values.
+func TestTypedDataEncodeValue(t *testing.T) {
+	typedData := &TypedData{Types: TypedDataTypes{}}
+
+	tests := []struct {
+		typ   string
+		value interface{}
+		want  string
+	}{
+		{"bool", true, "0x0000000000000000000000000000000000000000000000000000000000000001"},
+		{"int8", "-1", "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{"uint256", "0x10", "0x0000000000000000000000000000000000000000000000000000000000000010"},
+		{"bytes4", "0xdeadbeef", "0xdeadbeef00000000000000000000000000000000000000000000000000000000"},
+		{"bytes", "0x", hexutil.Encode(crypto.Keccak256(nil))},
+		{"uint8[]", []interface{}{float64(1)}, hexutil.Encode(crypto.Keccak256(common.LeftPadBytes([]byte{1}, 32)))},
+	}
+	for i, tt := range tests {
+		enc, err := typedData.encodeValue(tt.typ, tt.value)
+		if err != nil {
+			t.Errorf("test %d: failed to encode %s: %v", i, tt.typ, err)
+			continue
+		}
+		if have := hexutil.Encode(enc); have != tt.want {
+			t.Errorf("test %d: encoding mismatch: have %s, want %s", i, have, tt.want)
+		}
+	}
+	failures := []struct {
+		typ   string
+		value interface{}
+	}{
+		{"uint8", "256"},
+		{"int8", "128"},
+		{"bytes4", "0xdead"},
+		{"uint8[2]", []interface{}{float64(1)}},
+		{"bool", "true"},
+	}
+	for i, tt := range failures {
+		if _, err := typedData.encodeValue(tt.typ, tt.value); err == nil {
+			t.Errorf("failure %d: encoded invalid %s value %v", i, tt.typ, tt.value)
+		}
+	}
+}
@@ -27,11 +27,13 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -51,15 +53,23 @@ const (
 	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and Ethereum address for a given BIP 32 path
 	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an Ethereum transaction after having the user validate the parameters
 	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignMessage      ledgerOpcode = 0x08 // Signs an Ethereum message after having the user validate it
+	ledgerOpSignTypedMessage ledgerOpcode = 0x0c // Signs the hashes of an EIP-712 message after having the user validate them
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
 	ledgerP1ConfirmFetchAddress     ledgerParam1 = 0x01 // Require a user confirmation before returning the address
 	ledgerP1InitTransactionData     ledgerParam1 = 0x00 // First transaction data block for signing
 	ledgerP1ContTransactionData     ledgerParam1 = 0x80 // Subsequent transaction data block for signing
+	ledgerP1InitMessageData         ledgerParam1 = 0x00 // First message data block for signing
+	ledgerP1ContMessageData         ledgerParam1 = 0x80 // Subsequent message data block for signing
 	ledgerP2DiscardAddressChainCode ledgerParam2 = 0x00 // Do not return the chain code along with the address
 	ledgerP2ReturnAddressChainCode  ledgerParam2 = 0x01 // Require a user confirmation before returning the address
 )
 
+// ledgerFlagArbitraryData is the application configuration flag set if the user
+// enabled signing transactions with contract data in the Ethereum app settings.
+const ledgerFlagArbitraryData = 0x01
+
 // errLedgerReplyInvalidHeader is the error message returned by a Ledger data exchange
 // if the device replies with a mismatching header. This usually means the device
 // is in browser mode.
@@ -73,6 +83,7 @@ var errLedgerInvalidVersionReply = errors.New("ledger: invalid version reply")
 type ledgerDriver struct {
 	device  io.ReadWriter // USB device connection to communicate through
 	version [3]byte       // Current version of the Ledger firmware (zero if app is offline)
+	flags   uint32        // Configuration flags of the Ethereum app (atomic access, refreshed by heartbeats)
 	browser bool          // Flag whether the Ledger is in browser mode (reply channel mismatch)
 	failure error         // Any failure that would make the device unusable
 	log     log.Logger    // Contextual logger to tag the ledger with its id
@@ -97,9 +108,18 @@ func (w *ledgerDriver) Status() (string, error) {
 	if w.offline() {
 		return "Ethereum app offline", w.failure
 	}
+	if !w.contractData() {
+		return fmt.Sprintf("Ethereum app v%d.%d.%d online, contract data disabled", w.version[0], w.version[1], w.version[2]), w.failure
+	}
 	return fmt.Sprintf("Ethereum app v%d.%d.%d online", w.version[0], w.version[1], w.version[2]), w.failure
 }
 
+// contractData returns whether the user enabled signing transactions with contract
+// data in the Ethereum app settings, as of the last check.
+func (w *ledgerDriver) contractData() bool {
+	return atomic.LoadUint32(&w.flags)&ledgerFlagArbitraryData != 0
+}
+
 // offline returns whether the wallet and the Ethereum app is offline or not.
 //
 // The method assumes that the state lock is held!
@@ -122,9 +142,12 @@ func (w *ledgerDriver) Open(device io.ReadWriter, passphrase string) error {
 		return nil
 	}
 	// Try to resolve the Ethereum app's version, will fail prior to v1.0.2
-	if w.version, err = w.ledgerVersion(); err != nil {
+	var flags byte
+	if w.version, flags, err = w.ledgerVersion(); err != nil {
 		w.version = [3]byte{1, 0, 0} // Assume worst case, can't verify if v1.0.0 or v1.0.1
+		flags = ledgerFlagArbitraryData
 	}
+	atomic.StoreUint32(&w.flags, uint32(flags))
 	return nil
 }
 
@@ -132,16 +155,22 @@ func (w *ledgerDriver) Open(device io.ReadWriter, passphrase string) error {
 // the Ledger driver.
 func (w *ledgerDriver) Close() error {
 	w.browser, w.version = false, [3]byte{}
+	atomic.StoreUint32(&w.flags, 0)
 	return nil
 }
 
 // Heartbeat implements usbwallet.driver, performing a sanity check against the
-// Ledger to see if it's still online.
+// Ledger to see if it's still online. The configuration flags are refreshed too,
+// as the user may toggle the app settings at any time.
 func (w *ledgerDriver) Heartbeat() error {
-	if _, err := w.ledgerVersion(); err != nil && err != errLedgerInvalidVersionReply {
+	_, flags, err := w.ledgerVersion()
+	if err != nil && err != errLedgerInvalidVersionReply {
 		w.failure = err
 		return err
 	}
+	if err == nil {
+		atomic.StoreUint32(&w.flags, uint32(flags))
+	}
 	return nil
 }
 
@@ -166,12 +195,48 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	if chainID != nil && w.version[0] <= 1 && w.version[1] <= 0 && w.version[2] <= 2 {
 		return common.Address{}, nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing this transaction, please update to v1.0.3 at least", w.version[0], w.version[1], w.version[2])
 	}
+	// Transactions with contract data are rejected by the device unless allowed
+	if len(tx.Data()) > 0 && !w.contractData() {
+		return common.Address{}, nil, errors.New("Ledger contract data signing disabled, please enable it in the Ethereum app settings")
+	}
 	// All infos gathered and metadata checks out, request signing
 	return w.ledgerSign(path, tx, chainID)
 }
 
+// SignText implements usbwallet.driver, sending the message to the Ledger and
+// waiting for the user to confirm or deny signing it.
+func (w *ledgerDriver) SignText(path accounts.DerivationPath, text []byte) (common.Address, []byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return common.Address{}, nil, accounts.ErrWalletClosed
+	}
+	// Ensure the wallet is capable of signing messages
+	if w.version[0] <= 1 && w.version[1] <= 0 && w.version[2] <= 7 {
+		return common.Address{}, nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing messages, please update to v1.0.8 at least", w.version[0], w.version[1], w.version[2])
+	}
+	return w.ledgerSignText(path, text)
+}
+
+// SignTypedMessage implements usbwallet.driver, sending the hashes of the typed
+// data to the Ledger and waiting for the user to confirm or deny signing them.
+func (w *ledgerDriver) SignTypedMessage(path accounts.DerivationPath, domainHash []byte, messageHash []byte) (common.Address, []byte, error) {
+	// If the Ethereum app doesn't run, abort
+	if w.offline() {
+		return common.Address{}, nil, accounts.ErrWalletClosed
+	}
+	// Ensure the wallet is capable of signing typed data
+	if w.version[0] < 1 || (w.version[0] == 1 && w.version[1] < 5) {
+		return common.Address{}, nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing typed data, please update to v1.5.0 at least", w.version[0], w.version[1], w.version[2])
+	}
+	// The device signs a domain separator and a message hash, nothing less
+	if len(domainHash) != 32 || len(messageHash) != 32 {
+		return common.Address{}, nil, errors.New("Ledger can only sign typed data with both a domain and a message")
+	}
+	return w.ledgerSignTypedMessage(path, domainHash, messageHash)
+}
+
 // ledgerVersion retrieves the current version of the Ethereum wallet app running
-// on the Ledger wallet.
+// on the Ledger wallet, along with its configuration flags.
 //
 // The version retrieval protocol is defined as follows:
 //
@@ -187,19 +252,19 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 //   Application major version                          | 1 byte
 //   Application minor version                          | 1 byte
 //   Application patch version                          | 1 byte
-func (w *ledgerDriver) ledgerVersion() ([3]byte, error) {
+func (w *ledgerDriver) ledgerVersion() ([3]byte, byte, error) {
 	// Send the request and wait for the response
 	reply, err := w.ledgerExchange(ledgerOpGetConfiguration, 0, 0, nil)
 	if err != nil {
-		return [3]byte{}, err
+		return [3]byte{}, 0, err
 	}
 	if len(reply) != 4 {
-		return [3]byte{}, errLedgerInvalidVersionReply
+		return [3]byte{}, 0, errLedgerInvalidVersionReply
 	}
 	// Cache the version for future reference
 	var version [3]byte
 	copy(version[:], reply[1:])
-	return version, nil
+	return version, reply[0], nil
 }
 
 // ledgerDerive retrieves the currently active Ethereum address from a Ledger
@@ -365,6 +430,151 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// ledgerSignText sends a message to the Ledger wallet, and waits for the user to
+// confirm or deny signing it.
+//
+// The message signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 08  | 00: first message data block
+//               80: subsequent message data block
+//                  | 00 | variable | variable
+//
+// Where the input for the first message block (first 255 bytes) is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Message length (big endian)                      | 4 bytes
+//   Message chunk                                    | arbitrary
+//
+// And the input for subsequent message blocks (first 255 bytes) are:
+//
+//   Description           | Length
+//   ----------------------+----------
+//   Message chunk         | arbitrary
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignText(derivationPath []uint32, text []byte) (common.Address, []byte, error) {
+	// Flatten the derivation path and the message length into the Ledger request
+	payload := make([]byte, 1+4*len(derivationPath)+4, 1+4*len(derivationPath)+4+len(text))
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	binary.BigEndian.PutUint32(payload[1+4*len(derivationPath):], uint32(len(text)))
+	payload = append(payload, text...)
+
+	// Send the request and wait for the response
+	var (
+		op    = ledgerP1InitMessageData
+		reply []byte
+		err   error
+	)
+	for len(payload) > 0 {
+		// Calculate the size of the next data chunk
+		chunk := 255
+		if chunk > len(payload) {
+			chunk = len(payload)
+		}
+		// Send the chunk over, ensuring it's processed correctly
+		reply, err = w.ledgerExchange(ledgerOpSignMessage, op, 0, payload[:chunk])
+		if err != nil {
+			return common.Address{}, nil, err
+		}
+		// Shift the payload and ensure subsequent chunks are marked as such
+		payload = payload[chunk:]
+		op = ledgerP1ContMessageData
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	signature, err := ledgerSignature(reply)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	sender, err := recoverText(text, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return sender, signature, nil
+}
+
+// ledgerSignTypedMessage sends the hashes of an EIP-712 message to the Ledger
+// wallet, and waits for the user to confirm or deny signing them.
+//
+// The typed message signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 0C  | 00 | 00 | variable | variable
+//
+// Where the input is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Domain separator                                 | 32 bytes
+//   Message hash                                     | 32 bytes
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignTypedMessage(derivationPath []uint32, domainHash []byte, messageHash []byte) (common.Address, []byte, error) {
+	// Flatten the derivation path and the hashes into the Ledger request
+	payload := make([]byte, 1+4*len(derivationPath), 1+4*len(derivationPath)+64)
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	payload = append(payload, domainHash...)
+	payload = append(payload, messageHash...)
+
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpSignTypedMessage, 0, 0, payload)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	signature, err := ledgerSignature(reply)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	hash := crypto.Keccak256(append(append([]byte{0x19, 0x01}, domainHash...), messageHash...))
+	pubkey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), signature, nil
+}
+
+// ledgerSignature converts a [V || R || S] message signature returned by the
+// Ledger into the [R || S || V] format with V being 0 or 1.
+func ledgerSignature(reply []byte) ([]byte, error) {
+	if len(reply) != 65 {
+		return nil, errors.New("reply lacks signature")
+	}
+	if reply[0] != 27 && reply[0] != 28 {
+		return nil, fmt.Errorf("invalid signature V byte: %d", reply[0])
+	}
+	return append(reply[1:], reply[0]-27), nil
+}
+
 // ledgerExchange performs a data exchange with the Ledger wallet, sending it a
 // message and retrieving the response.
 //
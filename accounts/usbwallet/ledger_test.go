@@ -0,0 +1,291 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package usbwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ledgerAPDU is a command received by the simulated Ledger device.
+type ledgerAPDU struct {
+	ins  ledgerOpcode
+	p1   ledgerParam1
+	p2   ledgerParam2
+	data []byte
+}
+
+// testLedger is a simulated Ledger device, reassembling the HID packets written to
+// it into APDU commands and answering them with the replies of a handler.
+type testLedger struct {
+	t       *testing.T
+	handler func(apdu ledgerAPDU) []byte
+
+	packets [][]byte     // HID packets written to the device
+	pending []byte       // APDU being reassembled from the packets
+	apdus   []ledgerAPDU // Commands received by the device
+	replies bytes.Buffer // HID packets waiting to be read
+}
+
+func (d *testLedger) Write(packet []byte) (int, error) {
+	if len(packet) > 64 {
+		d.t.Fatalf("packet %d: oversized HID packet: have %d bytes, want at most 64", len(d.packets), len(packet))
+	}
+	if !bytes.Equal(packet[:3], []byte{0x01, 0x01, 0x05}) {
+		d.t.Fatalf("packet %d: transport header mismatch: have %x, want 010105", len(d.packets), packet[:3])
+	}
+	seq := int(binary.BigEndian.Uint16(packet[3:5]))
+	if seq == 0 {
+		d.pending = append([]byte{}, packet[5:]...)
+	} else {
+		d.pending = append(d.pending, packet[5:]...)
+	}
+	d.packets = append(d.packets, append([]byte{}, packet...))
+
+	// If the APDU was fully received, process it
+	if size := int(binary.BigEndian.Uint16(d.pending)); len(d.pending) >= 2+size {
+		cmd := d.pending[2 : 2+size]
+		if cmd[0] != 0xe0 || int(cmd[4]) != len(cmd)-5 {
+			d.t.Fatalf("malformed APDU: %x", cmd)
+		}
+		apdu := ledgerAPDU{ins: ledgerOpcode(cmd[1]), p1: ledgerParam1(cmd[2]), p2: ledgerParam2(cmd[3]), data: cmd[5:]}
+		d.apdus = append(d.apdus, apdu)
+		d.reply(append(d.handler(apdu), 0x90, 0x00))
+	}
+	return len(packet), nil
+}
+
+// reply frames a response into HID packets to be read from the device.
+func (d *testLedger) reply(data []byte) {
+	payload := make([]byte, 2, 2+len(data))
+	binary.BigEndian.PutUint16(payload, uint16(len(data)))
+	payload = append(payload, data...)
+
+	for seq := 0; len(payload) > 0; seq++ {
+		packet := make([]byte, 64)
+		copy(packet, []byte{0x01, 0x01, 0x05})
+		binary.BigEndian.PutUint16(packet[3:], uint16(seq))
+		payload = payload[copy(packet[5:], payload):]
+		d.replies.Write(packet)
+	}
+}
+
+func (d *testLedger) Read(buf []byte) (int, error) {
+	return d.replies.Read(buf)
+}
+
+// Tests that APDUs exceeding a single HID packet are split into sequentially
+// numbered packets and are reassembled correctly.
+func TestLedgerExchangeChunking(t *testing.T) {
+	device := &testLedger{t: t, handler: func(apdu ledgerAPDU) []byte { return bytes.Repeat([]byte{0xaa}, 100) }}
+	driver := &ledgerDriver{device: device, log: log.New()}
+
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	reply, err := driver.ledgerExchange(ledgerOpSignMessage, ledgerP1ContMessageData, 0, data)
+	if err != nil {
+		t.Fatalf("failed to exchange data: %v", err)
+	}
+	if !bytes.Equal(reply, bytes.Repeat([]byte{0xaa}, 100)) {
+		t.Errorf("reply mismatch: have %x, want %d x aa", reply, 100)
+	}
+	// 7 bytes of APDU header and 200 bytes of data in 59 byte packet payloads
+	if len(device.packets) != 4 {
+		t.Errorf("packet count mismatch: have %d, want %d", len(device.packets), 4)
+	}
+	for i, packet := range device.packets {
+		if seq := binary.BigEndian.Uint16(packet[3:5]); seq != uint16(i) {
+			t.Errorf("packet %d: sequence mismatch: have %d, want %d", i, seq, i)
+		}
+	}
+	if len(device.apdus) != 1 {
+		t.Fatalf("APDU count mismatch: have %d, want %d", len(device.apdus), 1)
+	}
+	if apdu := device.apdus[0]; apdu.ins != ledgerOpSignMessage || apdu.p1 != ledgerP1ContMessageData || !bytes.Equal(apdu.data, data) {
+		t.Errorf("APDU mismatch: have %x/%x/%x, want %x/%x/%x", apdu.ins, apdu.p1, apdu.data, ledgerOpSignMessage, ledgerP1ContMessageData, data)
+	}
+}
+
+// Tests that messages are streamed to the Ledger for signing in 255 byte chunks,
+// framed with the derivation path and message length, and that the returned
+// signature is verified against the Ethereum prefixed hash of the message.
+func TestLedgerSignTextChunking(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	text := bytes.Repeat([]byte("ledger"), 100)
+
+	device := &testLedger{t: t}
+	device.handler = func(apdu ledgerAPDU) []byte {
+		// Only answer with the signature once the whole message arrived
+		var received int
+		for _, apdu := range device.apdus {
+			received += len(apdu.data)
+		}
+		if received < 1+4*len(accounts.DefaultBaseDerivationPath)+4+len(text) {
+			return nil
+		}
+		sig, err := crypto.Sign(accounts.TextHash(text), key)
+		if err != nil {
+			t.Fatalf("failed to sign message: %v", err)
+		}
+		return append([]byte{sig[64] + 27}, sig[:64]...)
+	}
+	driver := &ledgerDriver{device: device, version: [3]byte{1, 0, 8}, log: log.New()}
+
+	signer, signature, err := driver.SignText(accounts.DefaultBaseDerivationPath, text)
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	if want := crypto.PubkeyToAddress(key.PublicKey); signer != want {
+		t.Errorf("signer mismatch: have %x, want %x", signer, want)
+	}
+	if len(signature) != 65 || signature[64] > 1 {
+		t.Errorf("signature not in [R || S || V] format: %x", signature)
+	}
+	// Ensure the message was chunked and framed correctly
+	var payload []byte
+	for i, apdu := range device.apdus {
+		if apdu.ins != ledgerOpSignMessage {
+			t.Errorf("chunk %d: opcode mismatch: have %x, want %x", i, apdu.ins, ledgerOpSignMessage)
+		}
+		want := ledgerP1ContMessageData
+		if i == 0 {
+			want = ledgerP1InitMessageData
+		}
+		if apdu.p1 != want {
+			t.Errorf("chunk %d: P1 mismatch: have %x, want %x", i, apdu.p1, want)
+		}
+		if i < len(device.apdus)-1 && len(apdu.data) != 255 {
+			t.Errorf("chunk %d: size mismatch: have %d, want %d", i, len(apdu.data), 255)
+		}
+		payload = append(payload, apdu.data...)
+	}
+	if len(device.apdus) != 3 {
+		t.Errorf("chunk count mismatch: have %d, want %d", len(device.apdus), 3)
+	}
+	frame := []byte{byte(len(accounts.DefaultBaseDerivationPath))}
+	for _, component := range accounts.DefaultBaseDerivationPath {
+		frame = append(frame, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(frame[len(frame)-4:], component)
+	}
+	frame = append(frame, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(frame[len(frame)-4:], uint32(len(text)))
+
+	if want := append(frame, text...); !bytes.Equal(payload, want) {
+		t.Errorf("payload mismatch:\nhave %x\nwant %x", payload, want)
+	}
+}
+
+// Tests that the Ethereum app configuration flags are refreshed by heartbeats, so
+// toggling contract data signing on the device is picked up without reopening.
+func TestLedgerHeartbeatFlags(t *testing.T) {
+	flags := byte(0)
+
+	device := &testLedger{t: t, handler: func(apdu ledgerAPDU) []byte {
+		if apdu.ins != ledgerOpGetConfiguration {
+			t.Errorf("unexpected opcode: have %x, want %x", apdu.ins, ledgerOpGetConfiguration)
+		}
+		return []byte{flags, 1, 0, 8}
+	}}
+	driver := &ledgerDriver{device: device, version: [3]byte{1, 0, 8}, log: log.New()}
+
+	for i, want := range []bool{false, true, false} {
+		if want {
+			flags = ledgerFlagArbitraryData
+		} else {
+			flags = 0
+		}
+		if err := driver.Heartbeat(); err != nil {
+			t.Fatalf("heartbeat %d: failed: %v", i, err)
+		}
+		if have := driver.contractData(); have != want {
+			t.Errorf("heartbeat %d: contract data mismatch: have %v, want %v", i, have, want)
+		}
+	}
+}
+
+// Tests that the hashes of typed data are sent to the Ledger framed with the
+// derivation path, and that the returned signature is verified against the
+// EIP-712 digest.
+func TestLedgerSignTypedMessage(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	domainHash, messageHash := crypto.Keccak256([]byte("domain")), crypto.Keccak256([]byte("message"))
+
+	device := &testLedger{t: t, handler: func(apdu ledgerAPDU) []byte {
+		hash := crypto.Keccak256(append(append([]byte{0x19, 0x01}, domainHash...), messageHash...))
+		sig, err := crypto.Sign(hash, key)
+		if err != nil {
+			t.Fatalf("failed to sign hash: %v", err)
+		}
+		return append([]byte{sig[64] + 27}, sig[:64]...)
+	}}
+	driver := &ledgerDriver{device: device, version: [3]byte{1, 5, 0}, log: log.New()}
+
+	signer, signature, err := driver.SignTypedMessage(accounts.DefaultBaseDerivationPath, domainHash, messageHash)
+	if err != nil {
+		t.Fatalf("failed to sign typed message: %v", err)
+	}
+	if want := crypto.PubkeyToAddress(key.PublicKey); signer != want {
+		t.Errorf("signer mismatch: have %x, want %x", signer, want)
+	}
+	if len(signature) != 65 || signature[64] > 1 {
+		t.Errorf("signature not in [R || S || V] format: %x", signature)
+	}
+	if len(device.apdus) != 1 {
+		t.Fatalf("APDU count mismatch: have %d, want %d", len(device.apdus), 1)
+	}
+	want := []byte{byte(len(accounts.DefaultBaseDerivationPath))}
+	for _, component := range accounts.DefaultBaseDerivationPath {
+		want = append(want, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(want[len(want)-4:], component)
+	}
+	want = append(append(want, domainHash...), messageHash...)
+
+	if apdu := device.apdus[0]; apdu.ins != ledgerOpSignTypedMessage || !bytes.Equal(apdu.data, want) {
+		t.Errorf("APDU mismatch: have %x/%x, want %x/%x", apdu.ins, apdu.data, ledgerOpSignTypedMessage, want)
+	}
+	// Older apps don't support typed data, domain only messages can't be signed
+	driver.version = [3]byte{1, 4, 9}
+	if _, _, err := driver.SignTypedMessage(accounts.DefaultBaseDerivationPath, domainHash, messageHash); err == nil {
+		t.Errorf("typed message signed by unsupported app version")
+	}
+	driver.version = [3]byte{1, 5, 0}
+	if _, _, err := driver.SignTypedMessage(accounts.DefaultBaseDerivationPath, domainHash, nil); err == nil {
+		t.Errorf("typed message without message hash signed")
+	}
+}
+
+// Tests that message signatures with a malformed V byte are rejected instead of
+// being adjusted into garbage.
+func TestLedgerSignTextInvalidV(t *testing.T) {
+	for _, v := range []byte{0, 1, 26, 29} {
+		device := &testLedger{t: t, handler: func(apdu ledgerAPDU) []byte {
+			return append([]byte{v}, make([]byte, 64)...)
+		}}
+		driver := &ledgerDriver{device: device, version: [3]byte{1, 0, 8}, log: log.New()}
+
+		if _, _, err := driver.SignText(accounts.DefaultBaseDerivationPath, []byte("ledger")); err == nil {
+			t.Errorf("V byte %d: signature accepted", v)
+		}
+	}
+}
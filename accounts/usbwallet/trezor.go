@@ -41,19 +41,29 @@ import (
 // encoded passphrase.
 var ErrTrezorPINNeeded = errors.New("trezor: pin needed")
 
+// ErrTrezorPassphraseNeeded is returned if opening the trezor requires a passphrase
+// to unlock the seed. In this case, the calling application should ask the user
+// for it and send it back in a subsequent open call.
+var ErrTrezorPassphraseNeeded = errors.New("trezor: passphrase needed")
+
 // errTrezorReplyInvalidHeader is the error message returned by a Trezor data exchange
 // if the device replies with a mismatching header. This usually means the device
 // is in browser mode.
 var errTrezorReplyInvalidHeader = errors.New("trezor: invalid reply header")
 
+// errTrezorTypedData is the error message returned when requesting a Trezor to sign
+// EIP-712 typed data, which its firmware cannot display for confirmation.
+var errTrezorTypedData = errors.New("trezor: typed data signing not supported by the firmware")
+
 // trezorDriver implements the communication with a Trezor hardware wallet.
 type trezorDriver struct {
-	device  io.ReadWriter // USB device connection to communicate through
-	version [3]uint32     // Current version of the Trezor firmware
-	label   string        // Current textual label of the Trezor device
-	pinwait bool          // Flags whether the device is waiting for PIN entry
-	failure error         // Any failure that would make the device unusable
-	log     log.Logger    // Contextual logger to tag the trezor with its id
+	device   io.ReadWriter // USB device connection to communicate through
+	version  [3]uint32     // Current version of the Trezor firmware
+	label    string        // Current textual label of the Trezor device
+	pinwait  bool          // Flags whether the device is waiting for PIN entry
+	passwait bool          // Flags whether the device is waiting for passphrase entry
+	failure  error         // Any failure that would make the device unusable
+	log      log.Logger    // Contextual logger to tag the trezor with its id
 }
 
 // newTrezorDriver creates a new instance of a Trezor USB protocol driver.
@@ -75,21 +85,38 @@ func (w *trezorDriver) Status() (string, error) {
 	if w.pinwait {
 		return fmt.Sprintf("Trezor v%d.%d.%d '%s' waiting for PIN", w.version[0], w.version[1], w.version[2], w.label), w.failure
 	}
+	if w.passwait {
+		return fmt.Sprintf("Trezor v%d.%d.%d '%s' waiting for passphrase", w.version[0], w.version[1], w.version[2], w.label), w.failure
+	}
 	return fmt.Sprintf("Trezor v%d.%d.%d '%s' online", w.version[0], w.version[1], w.version[2], w.label), w.failure
 }
 
 // Open implements usbwallet.driver, attempting to initialize the connection to
-// the Trezor hardware wallet. Initializing the Trezor is a two phase operation:
-//  * The first phase is to initialize the connection and read the wallet's
-//    features. This phase is invoked is the provided passphrase is empty. The
-//    device will display the pinpad as a result and will return an appropriate
-//    error to notify the user that a second open phase is needed.
-//  * The second phase is to unlock access to the Trezor, which is done by the
-//    user actually providing a passphrase mapping a keyboard keypad to the pin
-//    number of the user (shuffled according to the pinpad displayed).
+// the Trezor hardware wallet. Initializing the Trezor is a multi phase operation:
+//   - The first phase is to initialize the connection and read the wallet's
+//     features. This phase is invoked is the provided passphrase is empty. The
+//     device will display the pinpad as a result and will return an appropriate
+//     error to notify the user that a second open phase is needed.
+//   - The second phase is to unlock access to the Trezor, which is done by the
+//     user actually providing a passphrase mapping a keyboard keypad to the pin
+//     number of the user (shuffled according to the pinpad displayed).
+//   - If the device has passphrase protection enabled, an appropriate error is
+//     returned after the PIN is accepted and the third phase is to send over the
+//     actual passphrase unlocking the seed. The empty passphrase is valid in this
+//     phase, unlocking the standard wallet.
 func (w *trezorDriver) Open(device io.ReadWriter, passphrase string) error {
 	w.device, w.failure = device, nil
 
+	// Phase 3 requested with actual passphrase entry (empty for the standard wallet)
+	if w.passwait {
+		w.passwait = false
+
+		if _, err := w.trezorExchange(&trezor.PassphraseAck{Passphrase: &passphrase}, new(trezor.Success)); err != nil {
+			w.failure = err
+			return err
+		}
+		return nil
+	}
 	// If phase 1 is requested, init the connection and wait for user callback
 	if passphrase == "" {
 		// If we're already waiting for a PIN entry, insta-return
@@ -104,25 +131,37 @@ func (w *trezorDriver) Open(device io.ReadWriter, passphrase string) error {
 		w.version = [3]uint32{features.GetMajorVersion(), features.GetMinorVersion(), features.GetPatchVersion()}
 		w.label = features.GetLabel()
 
-		// Do a manual ping, forcing the device to ask for its PIN
-		askPin := true
-		res, err := w.trezorExchange(&trezor.Ping{PinProtection: &askPin}, new(trezor.PinMatrixRequest), new(trezor.Success))
+		// Do a manual ping, forcing the device to ask for its PIN and passphrase
+		askPin, askPass := true, true
+		res, err := w.trezorExchange(&trezor.Ping{PinProtection: &askPin, PassphraseProtection: &askPass}, new(trezor.PinMatrixRequest), new(trezor.PassphraseRequest), new(trezor.Success))
 		if err != nil {
 			return err
 		}
-		// Only return the PIN request if the device wasn't unlocked until now
-		if res == 1 {
-			return nil // Device responded with trezor.Success
+		// Only return the PIN or passphrase request if the device wasn't unlocked until now
+		switch res {
+		case 0:
+			w.pinwait = true
+			return ErrTrezorPINNeeded
+		case 1:
+			w.passwait = true
+			return ErrTrezorPassphraseNeeded
 		}
-		w.pinwait = true
-		return ErrTrezorPINNeeded
+		return nil // Device responded with trezor.Success
 	}
 	// Phase 2 requested with actual PIN entry
-	w.pinwait = false
+	if w.pinwait {
+		w.pinwait = false
 
-	if _, err := w.trezorExchange(&trezor.PinMatrixAck{Pin: &passphrase}, new(trezor.Success)); err != nil {
-		w.failure = err
-		return err
+		res, err := w.trezorExchange(&trezor.PinMatrixAck{Pin: &passphrase}, new(trezor.PassphraseRequest), new(trezor.Success))
+		if err != nil {
+			w.failure = err
+			return err
+		}
+		if res == 0 {
+			w.passwait = true
+			return ErrTrezorPassphraseNeeded
+		}
+		return nil
 	}
 	return nil
 }
@@ -130,7 +169,7 @@ func (w *trezorDriver) Open(device io.ReadWriter, passphrase string) error {
 // Close implements usbwallet.driver, cleaning up and metadata maintained within
 // the Trezor driver.
 func (w *trezorDriver) Close() error {
-	w.version, w.label, w.pinwait = [3]uint32{}, "", false
+	w.version, w.label, w.pinwait, w.passwait = [3]uint32{}, "", false, false
 	return nil
 }
 
@@ -159,6 +198,22 @@ func (w *trezorDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.trezorSign(path, tx, chainID)
 }
 
+// SignText implements usbwallet.driver, sending the message to the Trezor and
+// waiting for the user to confirm or deny signing it.
+func (w *trezorDriver) SignText(path accounts.DerivationPath, text []byte) (common.Address, []byte, error) {
+	if w.device == nil {
+		return common.Address{}, nil, accounts.ErrWalletClosed
+	}
+	return w.trezorSignText(path, text)
+}
+
+// SignTypedMessage implements usbwallet.driver, however the Trezor firmware is
+// not able to display typed data for confirmation, so this method will always
+// return an error.
+func (w *trezorDriver) SignTypedMessage(path accounts.DerivationPath, domainHash []byte, messageHash []byte) (common.Address, []byte, error) {
+	return common.Address{}, nil, errTrezorTypedData
+}
+
 // trezorDerive sends a derivation request to the Trezor device and returns the
 // Ethereum address located on that path.
 func (w *trezorDriver) trezorDerive(derivationPath []uint32) (common.Address, error) {
@@ -235,6 +290,30 @@ func (w *trezorDriver) trezorSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// trezorSignText sends the message to the Trezor wallet, and waits for the user
+// to confirm or deny signing it.
+func (w *trezorDriver) trezorSignText(derivationPath []uint32, text []byte) (common.Address, []byte, error) {
+	response := new(trezor.EthereumMessageSignature)
+	if _, err := w.trezorExchange(&trezor.EthereumSignMessage{AddressN: derivationPath, Message: text}, response); err != nil {
+		return common.Address{}, nil, err
+	}
+	// Extract the Ethereum signature and do a sanity validation
+	signature := response.GetSignature()
+	if len(signature) != 65 || signature[64] < 27 {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	signature = append(signature[:64:64], signature[64]-27)
+
+	sender, err := recoverText(text, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if reported := common.BytesToAddress(response.GetAddress()); len(response.GetAddress()) > 0 && reported != sender {
+		return common.Address{}, nil, fmt.Errorf("signer mismatch: reported %s, recovered %s", reported.Hex(), sender.Hex())
+	}
+	return sender, signature, nil
+}
+
 // trezorExchange performs a data exchange with the Trezor wallet, sending it a
 // message and retrieving the response. If multiple responses are possible, the
 // method will also return the index of the destination object used.
@@ -0,0 +1,121 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package usbwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/usbwallet/internal/trezor"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/golang/protobuf/proto"
+)
+
+// trezorMessage is a message received by the simulated Trezor device.
+type trezorMessage struct {
+	kind uint16
+	data []byte
+}
+
+// testTrezor is a simulated Trezor device, reassembling the HID chunks written to
+// it into protobuf messages and answering them with the replies of a handler.
+type testTrezor struct {
+	t       *testing.T
+	handler func(msg trezorMessage) proto.Message
+
+	pending  []byte          // Message being reassembled from the chunks
+	messages []trezorMessage // Messages received by the device
+	replies  bytes.Buffer    // HID chunks waiting to be read
+}
+
+func (d *testTrezor) Write(chunk []byte) (int, error) {
+	if len(chunk) != 64 || chunk[0] != 0x3f {
+		d.t.Fatalf("malformed HID chunk: %x", chunk)
+	}
+	d.pending = append(d.pending, chunk[1:]...)
+
+	// If the message was fully received, process it
+	if size := int(binary.BigEndian.Uint32(d.pending[4:8])); len(d.pending) >= 8+size {
+		msg := trezorMessage{kind: binary.BigEndian.Uint16(d.pending[2:4]), data: d.pending[8 : 8+size]}
+		d.messages, d.pending = append(d.messages, msg), nil
+		d.reply(d.handler(msg))
+	}
+	return len(chunk), nil
+}
+
+// reply frames a response message into HID chunks to be read from the device.
+func (d *testTrezor) reply(msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		d.t.Fatalf("failed to encode reply: %v", err)
+	}
+	payload := make([]byte, 8, 8+len(data))
+	copy(payload, []byte{0x23, 0x23})
+	binary.BigEndian.PutUint16(payload[2:], trezor.Type(msg))
+	binary.BigEndian.PutUint32(payload[4:], uint32(len(data)))
+	payload = append(payload, data...)
+
+	for len(payload) > 0 {
+		chunk := make([]byte, 64)
+		chunk[0] = 0x3f
+		payload = payload[copy(chunk[1:], payload):]
+		d.replies.Write(chunk)
+	}
+}
+
+func (d *testTrezor) Read(buf []byte) (int, error) {
+	return d.replies.Read(buf)
+}
+
+// Tests that a passphrase protected Trezor can be opened with the empty passphrase
+// of the standard wallet once the device asked for it.
+func TestTrezorOpenEmptyPassphrase(t *testing.T) {
+	device := &testTrezor{t: t, handler: func(msg trezorMessage) proto.Message {
+		switch msg.kind {
+		case trezor.Type(new(trezor.Initialize)):
+			return &trezor.Features{Label: proto.String("test")}
+		case trezor.Type(new(trezor.Ping)):
+			return new(trezor.PassphraseRequest)
+		case trezor.Type(new(trezor.PassphraseAck)):
+			return new(trezor.Success)
+		}
+		t.Fatalf("unexpected message: %s", trezor.Name(msg.kind))
+		return nil
+	}}
+	driver := &trezorDriver{log: log.New()}
+
+	if err := driver.Open(device, ""); err != ErrTrezorPassphraseNeeded {
+		t.Fatalf("first open error mismatch: have %v, want %v", err, ErrTrezorPassphraseNeeded)
+	}
+	if err := driver.Open(device, ""); err != nil {
+		t.Fatalf("failed to open with empty passphrase: %v", err)
+	}
+	if len(device.messages) != 3 {
+		t.Fatalf("message count mismatch: have %d, want %d", len(device.messages), 3)
+	}
+	ack := new(trezor.PassphraseAck)
+	if err := proto.Unmarshal(device.messages[2].data, ack); err != nil {
+		t.Fatalf("failed to decode passphrase ack: %v", err)
+	}
+	if ack.Passphrase == nil || *ack.Passphrase != "" {
+		t.Errorf("passphrase mismatch: have %v, want empty", ack.Passphrase)
+	}
+	if status, _ := driver.Status(); status != "Trezor v0.0.0 'test' online" {
+		t.Errorf("status mismatch: have %q", status)
+	}
+}
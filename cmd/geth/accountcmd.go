@@ -72,7 +72,8 @@ passwordfile as argument containing the wallet password in plaintext.`,
 		Description: `
 
 Manage accounts, list all existing accounts, import a private key into a new
-account, create a new account or update an existing account.
+account, create a new account or update an existing account, create or restore
+an HD wallet from a mnemonic.
 
 It supports interactive mode, when you are prompted for password as well as
 non-interactive mode where passwords are supplied via a given password file.
@@ -186,6 +187,50 @@ Note:
 As you can directly copy your encrypted accounts to another ethereum instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "newhd",
+				Usage:  "Create a new hierarchical deterministic wallet",
+				Action: utils.MigrateFlags(accountCreateHD),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				Description: `
+    geth account newhd
+
+Creates a new HD wallet from a random BIP-39 mnemonic, prints the mnemonic and
+the address of the first account (derived at m/44'/60'/0'/0/0).
+
+Write the mnemonic down and keep it safe, it is the only way to restore all the
+accounts of the wallet should the keystore be lost.
+
+The wallet seed is saved in encrypted format, you are prompted for a passphrase.
+Further accounts are derived and discovered once the wallet is opened in a
+running node via personal.openWallet.
+`,
+			},
+			{
+				Name:   "restorehd",
+				Usage:  "Restore a hierarchical deterministic wallet from a mnemonic",
+				Action: utils.MigrateFlags(accountRestoreHD),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				ArgsUsage: "[<mnemonicFile>]",
+				Description: `
+    geth account restorehd [<mnemonicFile>]
+
+Restores an HD wallet from a BIP-39 mnemonic, read from <mnemonicFile> or
+prompted for if no file is given, and prints the address of the first account.
+
+The wallet seed is saved in encrypted format, you are prompted for a passphrase.
 `,
 			},
 		},
@@ -377,3 +422,46 @@ func accountImport(ctx *cli.Context) error {
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+// accountCreateHD generates a new HD wallet from a random mnemonic.
+func accountCreateHD(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	passphrase := getPassPhrase("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	hub := stack.AccountManager().Backends(keystore.HDKeyStoreType)[0].(*keystore.HDKeyStore)
+	mnemonic, wallet, err := hub.NewWallet(passphrase)
+	if err != nil {
+		utils.Fatalf("Failed to create wallet: %v", err)
+	}
+	fmt.Printf("Mnemonic: %s\n", mnemonic)
+	fmt.Printf("Address: {%x}\n", wallet.Accounts()[0].Address)
+	return nil
+}
+
+// accountRestoreHD restores an HD wallet from an existing mnemonic.
+func accountRestoreHD(ctx *cli.Context) error {
+	var mnemonic string
+	if file := ctx.Args().First(); file != "" {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Could not read mnemonic file: %v", err)
+		}
+		mnemonic = string(blob)
+	} else {
+		input, err := console.Stdin.PromptPassword("Mnemonic: ")
+		if err != nil {
+			utils.Fatalf("Failed to read mnemonic: %v", err)
+		}
+		mnemonic = input
+	}
+	stack, _ := makeConfigNode(ctx)
+	passphrase := getPassPhrase("Your restored wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	hub := stack.AccountManager().Backends(keystore.HDKeyStoreType)[0].(*keystore.HDKeyStore)
+	wallet, err := hub.Restore(mnemonic, passphrase)
+	if err != nil {
+		utils.Fatalf("Could not restore the wallet: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", wallet.Accounts()[0].Address)
+	return nil
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -169,6 +170,21 @@ Remove blockchain and state databases`,
 The arguments are interpreted as block numbers or hashes.
 Use "ethereum dump 0" to dump the genesis block.`,
 	}
+	rebuildLogIndexCommand = cli.Command{
+		Action:    utils.MigrateFlags(rebuildLogIndex),
+		Name:      "rebuild-logindex",
+		Usage:     "Regenerate the exact log index used by --logindex",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Discards the address and topic index of logs maintained with --logindex and
+regenerates it for the entire local chain. Running nodes build the index in the
+background too, this command is useful to fix up a corrupted index offline.`,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -373,7 +389,7 @@ func copyDb(ctx *cli.Context) error {
 	chain, chainDb := utils.MakeChain(ctx, stack)
 
 	syncmode := *utils.GlobalTextMarshaler(ctx, utils.SyncModeFlag.Name).(*downloader.SyncMode)
-	dl := downloader.New(syncmode, chainDb, new(event.TypeMux), chain, nil, nil)
+	dl := downloader.New(syncmode, nil, chainDb, new(event.TypeMux), chain, nil, nil)
 
 	// Create a source peer to satisfy downloader requests from
 	db, err := ethdb.NewLDBDatabase(ctx.Args().First(), ctx.GlobalInt(utils.CacheFlag.Name), 256)
@@ -466,6 +482,21 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+// rebuildLogIndex regenerates the exact log index for the entire local chain.
+func rebuildLogIndex(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	if err := eth.RebuildLogIndex(chain, chainDb); err != nil {
+		utils.Fatalf("Log index rebuild failed: %v", err)
+	}
+	fmt.Printf("Log index rebuilt in %v\n", time.Since(start))
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -0,0 +1,252 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/les"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/protocols"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/swarm/network"
+	"github.com/ethereum/go-ethereum/swarm/network/stream"
+)
+
+// msgType describes a known message of a sub-protocol. The zero type means the
+// payload is decoded as a generic RLP structure.
+type msgType struct {
+	name string
+	typ  reflect.Type
+}
+
+// protocolSpec describes the messages of a known sub-protocol.
+type protocolSpec struct {
+	lengths  map[uint]uint64 // Number of message codes by protocol version
+	messages map[uint64]msgType
+}
+
+// blockOrigin is the origin of a header query, either a hash or a number.
+type blockOrigin struct {
+	Hash   *common.Hash    `json:"hash,omitempty"`
+	Number *hexutil.Uint64 `json:"number,omitempty"`
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (o *blockOrigin) DecodeRLP(s *rlp.Stream) error {
+	_, size, _ := s.Kind()
+	if size == common.HashLength {
+		o.Hash = new(common.Hash)
+		return s.Decode(o.Hash)
+	}
+	number, err := s.Uint()
+	o.Number = (*hexutil.Uint64)(&number)
+	return err
+}
+
+// headerQuery is a header retrieval request of the eth and les protocols.
+type headerQuery struct {
+	Origin  blockOrigin
+	Amount  uint64
+	Skip    uint64
+	Reverse bool
+}
+
+// block is the network representation of a block.
+type block struct {
+	Header *types.Header
+	Txs    []*types.Transaction
+	Uncles []*types.Header
+}
+
+// keyValue is an entry of the les handshake and announcement parameters.
+type keyValue struct {
+	Key   string
+	Value rlp.RawValue
+}
+
+// MarshalJSON implements json.Marshaler, hex encoding the raw value.
+func (kv keyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{kv.Key: hexutil.Bytes(kv.Value)})
+}
+
+// typeOf returns the reflect type of the value pointed to by ptr.
+func typeOf(ptr interface{}) reflect.Type {
+	return reflect.TypeOf(ptr).Elem()
+}
+
+var ethSpec = &protocolSpec{
+	lengths: map[uint]uint64{eth.ProtocolVersions[0]: eth.ProtocolLengths[0], eth.ProtocolVersions[1]: eth.ProtocolLengths[1]},
+	messages: map[uint64]msgType{
+		eth.StatusMsg: {"Status", typeOf(new(struct {
+			ProtocolVersion uint32
+			NetworkId       uint64
+			TD              *big.Int
+			CurrentBlock    common.Hash
+			GenesisBlock    common.Hash
+		}))},
+		eth.NewBlockHashesMsg: {"NewBlockHashes", typeOf(new([]struct {
+			Hash   common.Hash
+			Number uint64
+		}))},
+		eth.TxMsg:              {"Transactions", typeOf(new([]*types.Transaction))},
+		eth.GetBlockHeadersMsg: {"GetBlockHeaders", typeOf(new(headerQuery))},
+		eth.BlockHeadersMsg:    {"BlockHeaders", typeOf(new([]*types.Header))},
+		eth.GetBlockBodiesMsg:  {"GetBlockBodies", typeOf(new([]common.Hash))},
+		eth.BlockBodiesMsg:     {"BlockBodies", typeOf(new([]*types.Body))},
+		eth.NewBlockMsg: {"NewBlock", typeOf(new(struct {
+			Block block
+			TD    *big.Int
+		}))},
+		eth.GetNodeDataMsg: {"GetNodeData", typeOf(new([]common.Hash))},
+		eth.NodeDataMsg:    {"NodeData", typeOf(new([]hexutil.Bytes))},
+		eth.GetReceiptsMsg: {"GetReceipts", typeOf(new([]common.Hash))},
+		eth.ReceiptsMsg:    {"Receipts", typeOf(new([][]*types.Receipt))},
+	},
+}
+
+var lesSpec = &protocolSpec{
+	lengths: les.ProtocolLengths,
+	messages: map[uint64]msgType{
+		les.StatusMsg: {"Status", typeOf(new([]keyValue))},
+		les.AnnounceMsg: {"Announce", typeOf(new(struct {
+			Hash       common.Hash
+			Number     uint64
+			Td         *big.Int
+			ReorgDepth uint64
+			Update     []keyValue
+		}))},
+		les.GetBlockHeadersMsg: {"GetBlockHeaders", typeOf(new(struct {
+			ReqID uint64
+			Query headerQuery
+		}))},
+		les.BlockHeadersMsg: {"BlockHeaders", typeOf(new(struct {
+			ReqID, BV uint64
+			Headers   []*types.Header
+		}))},
+		les.GetBlockBodiesMsg: {"GetBlockBodies", typeOf(new(struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}))},
+		les.BlockBodiesMsg: {"BlockBodies", typeOf(new(struct {
+			ReqID, BV uint64
+			Bodies    []*types.Body
+		}))},
+		les.GetReceiptsMsg: {"GetReceipts", typeOf(new(struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}))},
+		les.ReceiptsMsg: {"Receipts", typeOf(new(struct {
+			ReqID, BV uint64
+			Receipts  [][]*types.Receipt
+		}))},
+		les.GetProofsV1Msg:         {name: "GetProofsV1"},
+		les.ProofsV1Msg:            {name: "ProofsV1"},
+		les.GetCodeMsg:             {name: "GetCode"},
+		les.CodeMsg:                {name: "Code"},
+		les.SendTxMsg:              {"SendTx", typeOf(new([]*types.Transaction))},
+		les.GetHeaderProofsMsg:     {name: "GetHeaderProofs"},
+		les.HeaderProofsMsg:        {name: "HeaderProofs"},
+		les.GetProofsV2Msg:         {name: "GetProofsV2"},
+		les.ProofsV2Msg:            {name: "ProofsV2"},
+		les.GetHelperTrieProofsMsg: {name: "GetHelperTrieProofs"},
+		les.HelperTrieProofsMsg:    {name: "HelperTrieProofs"},
+		les.SendTxV2Msg:            {name: "SendTxV2"},
+		les.GetTxStatusMsg:         {name: "GetTxStatus"},
+		les.TxStatusMsg:            {name: "TxStatus"},
+	},
+}
+
+// swarmSpec creates a protocol description from a swarm protocol spec, where the
+// message codes are the indices of the message types.
+func swarmSpec(spec *protocols.Spec) *protocolSpec {
+	messages := make(map[uint64]msgType)
+	for code, msg := range spec.Messages {
+		typ := reflect.TypeOf(msg)
+		messages[uint64(code)] = msgType{typ.Name(), typ}
+	}
+	return &protocolSpec{
+		lengths:  map[uint]uint64{spec.Version: spec.Length()},
+		messages: messages,
+	}
+}
+
+// knownProtocols are the sub-protocols whose messages can be decoded.
+var knownProtocols = map[string]*protocolSpec{
+	eth.ProtocolName:           ethSpec,
+	"les":                      lesSpec,
+	network.BzzSpec.Name:       swarmSpec(network.BzzSpec),
+	network.DiscoverySpec.Name: swarmSpec(network.DiscoverySpec),
+	stream.Spec.Name:           swarmSpec(stream.Spec),
+}
+
+// protocolLength returns the number of message codes of a sub-protocol version,
+// or zero if unknown.
+func protocolLength(name string, version uint) uint64 {
+	if spec := knownProtocols[name]; spec != nil {
+		return spec.lengths[version]
+	}
+	return 0
+}
+
+// decodeMsg decodes the payload of a captured message, returning the name of the
+// message and its decoded content. Unknown messages are decoded as generic RLP.
+func decodeMsg(record *p2p.CaptureRecord) (string, interface{}, error) {
+	var typ msgType
+	if spec := knownProtocols[record.Protocol]; spec != nil {
+		typ = spec.messages[record.Code]
+	}
+	if typ.name == "" {
+		typ.name = fmt.Sprintf("0x%02x", record.Code)
+	}
+	if typ.typ == nil {
+		var content interface{}
+		if err := rlp.DecodeBytes(record.Payload, &content); err != nil {
+			return typ.name, nil, err
+		}
+		return typ.name, generic(content), nil
+	}
+	content := reflect.New(typ.typ)
+	if err := rlp.DecodeBytes(record.Payload, content.Interface()); err != nil {
+		return typ.name, nil, err
+	}
+	return typ.name, content.Interface(), nil
+}
+
+// generic converts a generically decoded RLP structure into a printable form,
+// with all strings hex encoded.
+func generic(content interface{}) interface{} {
+	switch content := content.(type) {
+	case []byte:
+		return hexutil.Bytes(content)
+	case []interface{}:
+		list := make([]interface{}, len(content))
+		for i, item := range content {
+			list[i] = generic(item)
+		}
+		return list
+	default:
+		return content
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/les"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that captured messages of known protocols are decoded into their typed
+// representation, and everything else into generic RLP structures.
+func TestDecodeMsg(t *testing.T) {
+	encode := func(val interface{}) []byte {
+		blob, err := rlp.EncodeToBytes(val)
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", val, err)
+		}
+		return blob
+	}
+	number, hash := hexutil.Uint64(100), common.Hash{1}
+
+	tests := []struct {
+		protocol string
+		code     uint64
+		payload  []byte
+		name     string
+		content  interface{}
+	}{
+		// Header queries by number and by hash
+		{
+			eth.ProtocolName, eth.GetBlockHeadersMsg, encode([]interface{}{uint64(100), uint64(5), uint64(0), false}),
+			"GetBlockHeaders", &headerQuery{Origin: blockOrigin{Number: &number}, Amount: 5},
+		},
+		{
+			eth.ProtocolName, eth.GetBlockHeadersMsg, encode([]interface{}{hash, uint64(5), uint64(1), true}),
+			"GetBlockHeaders", &headerQuery{Origin: blockOrigin{Hash: &hash}, Amount: 5, Skip: 1, Reverse: true},
+		},
+		// Key-value lists of the les handshake
+		{
+			"les", les.StatusMsg, encode([]interface{}{[]interface{}{"protocolVersion", uint64(2)}}),
+			"Status", &[]keyValue{{Key: "protocolVersion", Value: rlp.RawValue{0x02}}},
+		},
+		// Unknown messages and protocols
+		{
+			eth.ProtocolName, 0x99, encode([]interface{}{[]byte{1, 2}, []interface{}{[]byte{3}}}),
+			"0x99", []interface{}{hexutil.Bytes{1, 2}, []interface{}{hexutil.Bytes{3}}},
+		},
+		{
+			"foo", 0x01, encode([]byte{0xff}),
+			"0x01", hexutil.Bytes{0xff},
+		},
+	}
+	for i, tt := range tests {
+		name, content, err := decodeMsg(&p2p.CaptureRecord{Protocol: tt.protocol, Code: tt.code, Payload: tt.payload})
+		if err != nil {
+			t.Errorf("test %d: failed to decode message: %v", i, err)
+			continue
+		}
+		if name != tt.name {
+			t.Errorf("test %d: name mismatch: have %s, want %s", i, name, tt.name)
+		}
+		if !reflect.DeepEqual(content, tt.content) {
+			t.Errorf("test %d: content mismatch: have %#v, want %#v", i, content, tt.content)
+		}
+	}
+	// Malformed payloads must be reported along with the message name
+	name, _, err := decodeMsg(&p2p.CaptureRecord{Protocol: eth.ProtocolName, Code: eth.TxMsg, Payload: []byte{0xc1}})
+	if err == nil {
+		t.Errorf("malformed payload decoded")
+	}
+	if name != "Transactions" {
+		t.Errorf("name mismatch: have %s, want %s", name, "Transactions")
+	}
+}
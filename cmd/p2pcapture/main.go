@@ -0,0 +1,289 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// p2pcapture decodes and replays devp2p message captures, as recorded by nodes
+// running with the --netcapture flag.
+//
+// Dumping a capture decodes the known eth, les and swarm messages:
+//
+//     $ p2pcapture dump capture/3b2c...-1530000000.rlpx
+//
+// Replaying a capture connects to a node, taking the role of the captured peer,
+// and sends it the messages originally received from that peer:
+//
+//     $ p2pcapture replay --protocol eth enode://... capture/3b2c...-1530000000.rlpx
+//
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func main() {
+	log.Root().SetHandler(log.LvlFilterHandler(log.LvlWarn, log.StreamHandler(os.Stderr, log.TerminalFormat(false))))
+
+	app := cli.NewApp()
+	app.Usage = "devp2p message capture decoder and replayer"
+	app.Commands = []cli.Command{
+		{
+			Name:      "dump",
+			Usage:     "decode the messages of a capture",
+			ArgsUsage: "<capture>",
+			Action:    dumpCapture,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "protocol",
+					Usage: "only dump the messages of the given sub-protocol",
+				},
+				cli.BoolFlag{
+					Name:  "raw",
+					Usage: "print the raw payloads instead of decoding them",
+				},
+			},
+		},
+		{
+			Name:      "replay",
+			Usage:     "replay the messages received from the captured peer against a node",
+			ArgsUsage: "<enode> <capture>",
+			Action:    replayCapture,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "protocol",
+					Usage: "sub-protocol to replay",
+					Value: "eth",
+				},
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "private key to connect with (hex encoded, random if empty)",
+				},
+				cli.BoolFlag{
+					Name:  "fast",
+					Usage: "send the messages without the original delays between them",
+				},
+				cli.DurationFlag{
+					Name:  "linger",
+					Usage: "time to wait for replies after the last message",
+					Value: 5 * time.Second,
+				},
+			},
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func dumpCapture(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	reader, err := p2p.OpenCapture(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := reader.Header
+	fmt.Printf("Peer:  %s\n", header.ID)
+	fmt.Printf("Name:  %s\n", header.Name)
+	fmt.Printf("Caps:  %v\n", header.Caps)
+	fmt.Printf("Start: %v (inbound: %v)\n\n", time.Unix(0, int64(header.Start)), header.Inbound)
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if proto := ctx.String("protocol"); proto != "" && record.Protocol != proto {
+			continue
+		}
+		printRecord(os.Stdout, record, time.Duration(record.Time-header.Start), ctx.Bool("raw"))
+	}
+}
+
+// printRecord prints a captured message along with its decoded content.
+func printRecord(w io.Writer, record *p2p.CaptureRecord, offset time.Duration, raw bool) {
+	dir := ">>"
+	if record.Received {
+		dir = "<<"
+	}
+	name, content, err := decodeMsg(record)
+	fmt.Fprintf(w, "%12v %s %s/%d %s (code 0x%02x, %d bytes)\n", offset, dir, record.Protocol, record.Version, name, record.Code, record.Size)
+
+	switch {
+	case raw:
+		fmt.Fprintf(w, "    %x\n", record.Payload)
+	case err != nil:
+		fmt.Fprintf(w, "    undecodable: %v\n    %x\n", err, record.Payload)
+	default:
+		out, _ := json.MarshalIndent(content, "    ", "  ")
+		fmt.Fprintf(w, "    %s\n", out)
+	}
+}
+
+func replayCapture(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	node, err := discover.ParseNode(ctx.Args()[0])
+	if err != nil {
+		return fmt.Errorf("invalid enode: %v", err)
+	}
+	header, records, err := p2p.ReadCapture(ctx.Args()[1])
+	if err != nil {
+		return err
+	}
+	// Gather the messages to replay and the protocol version to use
+	var (
+		name    = ctx.String("protocol")
+		replay  []*p2p.CaptureRecord
+		version uint
+		length  uint64
+	)
+	for _, record := range records {
+		if record.Protocol != name {
+			continue
+		}
+		version = record.Version
+		if record.Code >= length {
+			length = record.Code + 1
+		}
+		if record.Received {
+			replay = append(replay, record)
+		}
+	}
+	if len(replay) == 0 {
+		return fmt.Errorf("no %s messages received from peer %x in capture", name, header.ID[:8])
+	}
+	if known := protocolLength(name, version); known > length {
+		length = known
+	}
+	key, err := crypto.GenerateKey()
+	if hex := ctx.String("key"); hex != "" {
+		key, err = crypto.HexToECDSA(hex)
+	}
+	if err != nil {
+		return err
+	}
+	// Connect to the node and replay the messages over the protocol
+	var (
+		connected = make(chan struct{}, 1)
+		done      = make(chan error, 1)
+	)
+	server := &p2p.Server{
+		Config: p2p.Config{
+			PrivateKey:  key,
+			MaxPeers:    1,
+			NoDiscovery: true,
+			Protocols: []p2p.Protocol{{
+				Name:    name,
+				Version: version,
+				Length:  length,
+				Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+					select {
+					case connected <- struct{}{}:
+					default:
+					}
+					err := replayMsgs(rw, replay, ctx.Bool("fast"), ctx.Duration("linger"))
+					done <- err
+					return err
+				},
+			}},
+		},
+	}
+	if err := server.Start(); err != nil {
+		return err
+	}
+	defer server.Stop()
+
+	fmt.Printf("Replaying %d %s/%d messages to %x\n", len(replay), name, version, node.ID[:8])
+	server.AddPeer(node)
+
+	// Only time out the connection attempt, the replay may take arbitrarily long
+	select {
+	case <-connected:
+	case <-time.After(time.Minute):
+		return errors.New("timed out connecting to node")
+	}
+	return <-done
+}
+
+// replayMsgs sends the captured messages over a protocol connection, printing the
+// replies of the remote node as they arrive.
+func replayMsgs(rw p2p.MsgReadWriter, records []*p2p.CaptureRecord, fast bool, linger time.Duration) error {
+	var (
+		start = time.Now()
+		errc  = make(chan error, 1)
+	)
+	go func() {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				errc <- err
+				return
+			}
+			payload, err := ioutil.ReadAll(msg.Payload)
+			if err != nil {
+				errc <- err
+				return
+			}
+			reply := &p2p.CaptureRecord{
+				Received: true,
+				Protocol: records[0].Protocol,
+				Version:  records[0].Version,
+				Code:     msg.Code,
+				Size:     msg.Size,
+				Payload:  payload,
+			}
+			printRecord(os.Stdout, reply, time.Since(start), false)
+		}
+	}()
+	for i, record := range records {
+		if !fast && i > 0 {
+			time.Sleep(time.Duration(record.Time - records[i-1].Time))
+		}
+		sent := *record
+		sent.Received = false
+		printRecord(os.Stdout, &sent, time.Since(start), false)
+
+		if err := rw.WriteMsg(p2p.Msg{Code: record.Code, Size: record.Size, Payload: bytes.NewReader(record.Payload)}); err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(linger):
+		return nil
+	}
+}
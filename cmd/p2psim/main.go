@@ -34,6 +34,14 @@
 //     $ p2psim node connect node01 node02
 //     Connected node01 to node02
 //
+// Scenarios describe a sequence of actions along with the expected outcome of
+// each, failing with a non-zero exit code if an expectation isn't met:
+//
+//     $ p2psim scenario churn.json
+//     PASS  #0 create, expect up       1.2s
+//     PASS  #1 connect, expect peers   340ms
+//     FAIL  #2 churn, expect peers     30s  (node03 node07)
+//
 package main
 
 import (
@@ -44,6 +52,7 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -103,6 +112,12 @@ func main() {
 			Usage:  "load a network snapshot from stdin",
 			Action: loadSnapshot,
 		},
+		{
+			Name:      "scenario",
+			Usage:     "run a scenario from a JSON file",
+			ArgsUsage: "<file>",
+			Action:    runScenario,
+		},
 		{
 			Name:   "node",
 			Usage:  "manage simulation nodes",
@@ -179,8 +194,63 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "link",
+			Usage:  "manage simulated link conditions",
+			Action: showLink,
+			Subcommands: []cli.Command{
+				{
+					Name:      "show",
+					ArgsUsage: "[<node> <peer>]",
+					Usage:     "show the conditions of a link, or the default ones",
+					Action:    showLink,
+				},
+				{
+					Name:      "set",
+					ArgsUsage: "[<node> <peer>]",
+					Usage:     "set the conditions of a link, or the default ones",
+					Action:    setLink,
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "latency",
+							Usage: "one way link latency",
+						},
+						cli.DurationFlag{
+							Name:  "jitter",
+							Usage: "maximum random delay added to the latency",
+						},
+						cli.Uint64Flag{
+							Name:  "bandwidth",
+							Usage: "link bandwidth in bytes per second (0 = unlimited)",
+						},
+						cli.Float64Flag{
+							Name:  "loss",
+							Usage: "probability of a write being lost and retransmitted",
+						},
+						cli.BoolFlag{
+							Name:  "down",
+							Usage: "take the link down",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "partition",
+			ArgsUsage: "<nodes> <nodes> [<nodes>...]",
+			Usage:     "partition the network into groups of nodes (comma separated)",
+			Action:    partitionNetwork,
+		},
+		{
+			Name:   "heal",
+			Usage:  "restore the links taken down by partitions",
+			Action: healNetwork,
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	app.Run(os.Args)
 }
 
 func showNetwork(ctx *cli.Context) error {
@@ -246,6 +316,125 @@ func loadSnapshot(ctx *cli.Context) error {
 	return client.LoadSnapshot(snap)
 }
 
+func runScenario(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scenario := &simulations.Scenario{}
+	if err := json.NewDecoder(file).Decode(scenario); err != nil {
+		return err
+	}
+	result, err := client.RunScenario(scenario)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(ctx.App.Writer, 1, 2, 2, ' ', 0)
+	for _, step := range result.Steps {
+		status := "PASS"
+		if step.Error != "" {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v", status, step.Name, time.Duration(step.Duration))
+		if len(step.Failed) > 0 {
+			fmt.Fprintf(w, "\t(%s)", strings.Join(step.Failed, " "))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	if result.Failed() {
+		return fmt.Errorf("scenario failed: %s", result.Error)
+	}
+	return nil
+}
+
+func showLink(ctx *cli.Context) error {
+	args := ctx.Args()
+	var (
+		link *adapters.LinkConfig
+		err  error
+	)
+	switch len(args) {
+	case 0:
+		link, err = client.GetDefaultLink()
+	case 2:
+		link, err = client.GetLink(args[0], args[1])
+	default:
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(ctx.App.Writer, 1, 2, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintf(w, "LATENCY\t%v\n", link.Latency)
+	fmt.Fprintf(w, "JITTER\t%v\n", link.Jitter)
+	fmt.Fprintf(w, "BANDWIDTH\t%d\n", link.Bandwidth)
+	fmt.Fprintf(w, "LOSS\t%v\n", link.Loss)
+	fmt.Fprintf(w, "DOWN\t%v\n", link.Down)
+	return nil
+}
+
+func setLink(ctx *cli.Context) error {
+	args := ctx.Args()
+	link := &adapters.LinkConfig{
+		Latency:   ctx.Duration("latency"),
+		Jitter:    ctx.Duration("jitter"),
+		Bandwidth: ctx.Uint64("bandwidth"),
+		Loss:      ctx.Float64("loss"),
+		Down:      ctx.Bool("down"),
+	}
+	if err := link.Validate(); err != nil {
+		return err
+	}
+	switch len(args) {
+	case 0:
+		if err := client.SetDefaultLink(link); err != nil {
+			return err
+		}
+		fmt.Fprintln(ctx.App.Writer, "Updated default link")
+	case 2:
+		if err := client.SetLink(args[0], args[1], link); err != nil {
+			return err
+		}
+		fmt.Fprintln(ctx.App.Writer, "Updated link between", args[0], "and", args[1])
+	default:
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	return nil
+}
+
+func partitionNetwork(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) < 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	groups := make([][]string, len(args))
+	for i, arg := range args {
+		groups[i] = strings.Split(arg, ",")
+	}
+	if err := client.Partition(groups); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Partitioned network into", len(groups), "groups")
+	return nil
+}
+
+func healNetwork(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	if err := client.Heal(); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Healed network")
+	return nil
+}
+
 func listNodes(ctx *cli.Context) error {
 	if len(ctx.Args()) != 0 {
 		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
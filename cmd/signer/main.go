@@ -0,0 +1,259 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// signer is a standalone daemon owning the account keys, signing requests of
+// remote clients as permitted by a rule set or confirmed by the user.
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+
+var (
+	keystoreFlag = cli.StringFlag{
+		Name:  "keystore",
+		Value: filepath.Join(node.DefaultDataDir(), "keystore"),
+		Usage: "Directory for the keystore",
+	}
+	noUSBFlag = cli.BoolFlag{
+		Name:  "nousb",
+		Usage: "Disables monitoring for and managing USB hardware wallets",
+	}
+	lightKDFFlag = cli.BoolFlag{
+		Name:  "lightkdf",
+		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
+	}
+	rulesFlag = cli.StringFlag{
+		Name:  "rules",
+		Usage: "JSON file with the rules auto-approving or rejecting requests (default = ask for everything)",
+	}
+	spendsFlag = cli.StringFlag{
+		Name:  "spends",
+		Value: filepath.Join(node.DefaultDataDir(), "signer", "spends.json"),
+		Usage: "File persisting the transfers counting towards the daily limits across restarts",
+	}
+	chainRPCFlag = cli.StringFlag{
+		Name:  "chainrpc",
+		Usage: "RPC endpoint of a node to discover the used hardware wallet accounts with (default = first account only)",
+	}
+	chainIDFlag = cli.Int64Flag{
+		Name:  "chainid",
+		Value: 1,
+		Usage: "Chain id to sign transactions for, unless specified by the request",
+	}
+	rpcAddrFlag = cli.StringFlag{
+		Name:  "rpcaddr",
+		Value: "localhost",
+		Usage: "HTTP-RPC server listening interface",
+	}
+	rpcPortFlag = cli.IntFlag{
+		Name:  "rpcport",
+		Value: 8550,
+		Usage: "HTTP-RPC server listening port",
+	}
+	rpcVHostsFlag = cli.StringFlag{
+		Name:  "rpcvhosts",
+		Value: "localhost",
+		Usage: "Comma separated list of virtual hostnames from which to accept requests",
+	}
+	verbosityFlag = cli.IntFlag{
+		Name:  "verbosity",
+		Value: 3,
+		Usage: "Logging verbosity: 0=silent, 1=error, 2=warn, 3=info, 4=debug, 5=detail",
+	}
+)
+
+var app = utils.NewApp(gitCommit, "a standalone Ethereum account signer")
+
+func init() {
+	app.Flags = []cli.Flag{
+		keystoreFlag,
+		noUSBFlag,
+		lightKDFFlag,
+		rulesFlag,
+		spendsFlag,
+		chainRPCFlag,
+		chainIDFlag,
+		rpcAddrFlag,
+		rpcPortFlag,
+		rpcVHostsFlag,
+		verbosityFlag,
+	}
+	app.Action = signerd
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// signerd starts the signing service and blocks until interrupted.
+func signerd(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(true)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	// Load the rules deciding about the requests without asking the user
+	var rules *signer.Rules
+	if path := ctx.String(rulesFlag.Name); path != "" {
+		var err error
+		if rules, err = signer.LoadRules(path); err != nil {
+			return fmt.Errorf("failed to load rules: %v", err)
+		}
+		log.Info("Loaded signing rules", "path", path)
+	}
+	journal := ctx.String(spendsFlag.Name)
+	if err := os.MkdirAll(filepath.Dir(journal), 0700); err != nil {
+		return fmt.Errorf("failed to create spends directory: %v", err)
+	}
+	engine, err := signer.NewRuleEngine(rules, journal)
+	if err != nil {
+		return fmt.Errorf("failed to load spends: %v", err)
+	}
+	// Assemble the account manager owning all the keys
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if ctx.Bool(lightKDFFlag.Name) {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+	backends := []accounts.Backend{
+		keystore.NewKeyStore(ctx.String(keystoreFlag.Name), scryptN, scryptP),
+	}
+	if !ctx.Bool(noUSBFlag.Name) {
+		if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
+			log.Warn("Failed to start Ledger hub, disabling", "err", err)
+		} else {
+			backends = append(backends, ledgerhub)
+		}
+		if trezorhub, err := usbwallet.NewTrezorHub(); err != nil {
+			log.Warn("Failed to start Trezor hub, disabling", "err", err)
+		} else {
+			backends = append(backends, trezorhub)
+		}
+	}
+	am := accounts.NewManager(backends...)
+	defer am.Close()
+
+	// Open the hardware wallets as they appear, deriving their accounts
+	var chain ethereum.ChainStateReader
+	if endpoint := ctx.String(chainRPCFlag.Name); endpoint != "" {
+		client, err := rpc.Dial(endpoint)
+		if err != nil {
+			return fmt.Errorf("failed to connect to chain: %v", err)
+		}
+		defer client.Close()
+		chain = ethclient.NewClient(client)
+	}
+	events := make(chan accounts.WalletEvent, 16)
+	sub := am.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	for _, wallet := range am.Wallets() {
+		if err := wallet.Open(""); err != nil {
+			log.Warn("Failed to open wallet", "url", wallet.URL(), "err", err)
+		}
+	}
+	go trackWallets(events, sub, chain)
+
+	// Expose the signing API over HTTP
+	api := signer.NewSignerAPI(big.NewInt(ctx.Int64(chainIDFlag.Name)), am, engine, newCommandlineUI())
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("account", api); err != nil {
+		return err
+	}
+	defer srv.Stop()
+
+	endpoint := fmt.Sprintf("%s:%d", ctx.String(rpcAddrFlag.Name), ctx.Int(rpcPortFlag.Name))
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	var vhosts []string
+	for _, host := range strings.Split(ctx.String(rpcVHostsFlag.Name), ",") {
+		vhosts = append(vhosts, strings.TrimSpace(host))
+	}
+	go rpc.NewHTTPServer(nil, vhosts, srv).Serve(listener)
+	log.Info("Signer endpoint opened", "url", fmt.Sprintf("http://%s", endpoint))
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	defer signal.Stop(sigc)
+	<-sigc
+
+	log.Info("Signer shutting down")
+	return nil
+}
+
+// trackWallets opens the wallets arriving while the signer is running and derives
+// the accounts of the opened ones, either discovering the used accounts on the
+// chain if available, or pinning the first account of the default path.
+func trackWallets(events chan accounts.WalletEvent, sub event.Subscription, chain ethereum.ChainStateReader) {
+	for {
+		select {
+		case event := <-events:
+			switch event.Kind {
+			case accounts.WalletArrived:
+				if err := event.Wallet.Open(""); err != nil {
+					log.Warn("New wallet appeared, failed to open", "url", event.Wallet.URL(), "err", err)
+				}
+			case accounts.WalletOpened:
+				status, _ := event.Wallet.Status()
+				log.Info("New wallet appeared", "url", event.Wallet.URL(), "status", status)
+
+				base := accounts.DefaultBaseDerivationPath
+				if event.Wallet.URL().Scheme == "ledger" {
+					base = accounts.DefaultLedgerBaseDerivationPath
+				}
+				if chain != nil {
+					event.Wallet.SelfDerive(base, chain)
+				} else if _, err := event.Wallet.Derive(base, true); err != nil && err != accounts.ErrNotSupported {
+					log.Warn("Failed to derive wallet account", "url", event.Wallet.URL(), "err", err)
+				}
+			case accounts.WalletDropped:
+				log.Info("Old wallet dropped", "url", event.Wallet.URL())
+				event.Wallet.Close()
+			}
+		case <-sub.Err():
+			return
+		}
+	}
+}
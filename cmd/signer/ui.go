@@ -0,0 +1,99 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/console"
+	"github.com/ethereum/go-ethereum/signer"
+)
+
+// commandlineUI asks the user on the terminal about the requests the rules did
+// not decide. The signer serializes the requests, so no locking is needed.
+type commandlineUI struct {
+	prompter console.UserPrompter
+}
+
+func newCommandlineUI() *commandlineUI {
+	return &commandlineUI{prompter: console.Stdin}
+}
+
+// ApproveListing implements signer.UI.
+func (ui *commandlineUI) ApproveListing(addresses []common.Address) (bool, error) {
+	fmt.Println("-------- Account listing request --------")
+	for _, addr := range addresses {
+		fmt.Printf("  %s\n", addr.Hex())
+	}
+	return ui.prompter.PromptConfirm("Disclose the accounts above?")
+}
+
+// ApproveTx implements signer.UI.
+func (ui *commandlineUI) ApproveTx(args *signer.SendTxArgs) (bool, error) {
+	fmt.Println("-------- Transaction signing request --------")
+	to := "<contract creation>"
+	if args.To != nil {
+		to = args.To.Hex()
+	}
+	fmt.Printf("from:     %s\n", args.From.Hex())
+	fmt.Printf("to:       %s\n", to)
+	fmt.Printf("value:    %v wei\n", (*big.Int)(&args.Value))
+	fmt.Printf("gas:      %d\n", uint64(args.Gas))
+	fmt.Printf("gasprice: %v wei\n", (*big.Int)(&args.GasPrice))
+	fmt.Printf("nonce:    %d\n", uint64(args.Nonce))
+	if len(args.Data) > 0 {
+		fmt.Printf("data:     %x\n", []byte(args.Data))
+	}
+	return ui.prompter.PromptConfirm("Sign the transaction above?")
+}
+
+// ApproveSignData implements signer.UI.
+func (ui *commandlineUI) ApproveSignData(from common.Address, data []byte, text bool) (bool, error) {
+	if text {
+		fmt.Println("-------- Message signing request --------")
+		fmt.Printf("from:    %s\n", from.Hex())
+		fmt.Printf("message: %q\n", data)
+	} else {
+		fmt.Println("-------- Hash signing request --------")
+		fmt.Printf("from: %s\n", from.Hex())
+		fmt.Printf("hash: %x\n", data)
+	}
+	return ui.prompter.PromptConfirm("Sign the data above?")
+}
+
+// ApproveSignTypedData implements signer.UI.
+func (ui *commandlineUI) ApproveSignTypedData(from common.Address, typedData *accounts.TypedData) (bool, error) {
+	fmt.Println("-------- Typed data signing request --------")
+	fmt.Printf("from: %s\n", from.Hex())
+
+	domain, _ := json.MarshalIndent(typedData.Domain, "", "  ")
+	fmt.Printf("domain: %s\n", domain)
+
+	message, _ := json.MarshalIndent(typedData.Message, "", "  ")
+	fmt.Printf("%s: %s\n", typedData.PrimaryType, message)
+
+	return ui.prompter.PromptConfirm("Sign the data above?")
+}
+
+// Passphrase implements signer.UI.
+func (ui *commandlineUI) Passphrase(account accounts.Account) (string, error) {
+	return ui.prompter.PromptPassword(fmt.Sprintf("Passphrase for %s: ", account.Address.Hex()))
+}
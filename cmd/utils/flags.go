@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/instant"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -126,6 +127,10 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "RPC endpoint of an external signer to use as an account backend",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -147,6 +152,10 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	DeveloperInstantFlag = cli.BoolFlag{
+		Name:  "dev.instant",
+		Usage: "Seal blocks in developer mode instantly on new transactions or on demand via dev_mine",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -170,11 +179,33 @@ var (
 		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
 		Value: &defaultSyncMode,
 	}
+	SyncCheckpointFlag = cli.StringFlag{
+		Name:  "synccheckpoint",
+		Usage: "Trusted block to fast sync to without verifying the chain below it (<number>:<hash>)",
+	}
 	GCModeFlag = cli.StringFlag{
 		Name:  "gcmode",
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	StateCheckpointFlag = cli.Uint64Flag{
+		Name:  "statecheckpoint",
+		Usage: "Number of blocks between states persisted in full by a pruned node (0 = disabled)",
+	}
+	StateRegenLimitFlag = cli.Uint64Flag{
+		Name:  "stateregen",
+		Usage: "Maximum number of blocks debug and tracing requests may re-execute for regenerating historical state",
+		Value: eth.DefaultConfig.StateRegenLimit,
+	}
+	StateCallLimitFlag = cli.Uint64Flag{
+		Name:  "stateregencall",
+		Usage: "Maximum number of blocks state queries (e.g. eth_call) may re-execute for regenerating historical state (0 = disabled)",
+		Value: eth.DefaultConfig.StateCallLimit,
+	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain an exact log address and topic index to speed up log filtering",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -249,6 +280,10 @@ var (
 		Usage: "Disk journal for local transaction to survive node restarts",
 		Value: core.DefaultTxPoolConfig.Journal,
 	}
+	TxPoolSnapshotFlag = cli.StringFlag{
+		Name:  "txpool.snapshot",
+		Usage: "Disk snapshot of remote transactions to survive node restarts (empty = disabled)",
+	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
 		Usage: "Time interval to regenerate the local transaction journal",
@@ -289,6 +324,26 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolSenderRateFlag = cli.Uint64Flag{
+		Name:  "txpool.senderrate",
+		Usage: "Maximum number of remote transactions admitted per sender per interval (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.SenderRate,
+	}
+	TxPoolSenderIntervalFlag = cli.DurationFlag{
+		Name:  "txpool.senderinterval",
+		Usage: "Time interval over which the sender rate allowance is refilled",
+		Value: eth.DefaultConfig.TxPool.SenderInterval,
+	}
+	TxPoolRecipientSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.recipientslots",
+		Usage: "Maximum number of pooled transactions per recipient contract, admitting remote ones (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.RecipientSlots,
+	}
+	TxPoolPricePremiumFlag = cli.Uint64Flag{
+		Name:  "txpool.pricepremium",
+		Usage: "Price premium percentage over the pool floor required when the pool is nearly full (0 = none)",
+		Value: eth.DefaultConfig.TxPool.PricePremium,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -339,6 +394,11 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerRecommitIntervalFlag = cli.DurationFlag{
+		Name:  "miner.recommit",
+		Usage: "Time interval to recreate the block being mined with new transactions (0 = disabled)",
+		Value: eth.DefaultConfig.MinerRecommit,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -499,6 +559,10 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	NetCaptureFlag = DirectoryFlag{
+		Name:  "netcapture",
+		Usage: "Directory to record the messages exchanged with each peer into (disabled if empty)",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -858,6 +922,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	if ctx.GlobalIsSet(NetCaptureFlag.Name) {
+		cfg.CaptureDir = ctx.GlobalString(NetCaptureFlag.Name)
+	}
 
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -896,6 +963,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -917,6 +987,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.GlobalString(TxPoolSnapshotFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -938,6 +1011,18 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSenderRateFlag.Name) {
+		cfg.SenderRate = ctx.GlobalUint64(TxPoolSenderRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSenderIntervalFlag.Name) {
+		cfg.SenderInterval = ctx.GlobalDuration(TxPoolSenderIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolRecipientSlotsFlag.Name) {
+		cfg.RecipientSlots = ctx.GlobalUint64(TxPoolRecipientSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPricePremiumFlag.Name) {
+		cfg.PricePremium = ctx.GlobalUint64(TxPoolPricePremiumFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1031,6 +1116,16 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	case ctx.GlobalBool(LightModeFlag.Name):
 		cfg.SyncMode = downloader.LightSync
 	}
+	if ctx.GlobalIsSet(SyncCheckpointFlag.Name) {
+		if cfg.SyncMode != downloader.FastSync {
+			Fatalf("--%s requires fast sync", SyncCheckpointFlag.Name)
+		}
+		checkpoint, err := downloader.ParseCheckpoint(ctx.GlobalString(SyncCheckpointFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", SyncCheckpointFlag.Name, err)
+		}
+		cfg.SyncCheckpoint = checkpoint
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -1051,6 +1146,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 
+	if ctx.GlobalIsSet(StateCheckpointFlag.Name) {
+		cfg.StateCheckpoint = ctx.GlobalUint64(StateCheckpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateRegenLimitFlag.Name) {
+		cfg.StateRegenLimit = ctx.GlobalUint64(StateRegenLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateCallLimitFlag.Name) {
+		cfg.StateCallLimit = ctx.GlobalUint64(StateCallLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
@@ -1066,6 +1174,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerRecommitIntervalFlag.Name) {
+		cfg.MinerRecommit = ctx.GlobalDuration(MinerRecommitIntervalFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1102,7 +1213,11 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		}
 		log.Info("Using developer account", "address", developer.Address)
 
-		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		if ctx.GlobalBool(DeveloperInstantFlag.Name) {
+			cfg.Genesis = core.DeveloperInstantGenesisBlock(developer.Address)
+		} else {
+			cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		}
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
 			cfg.GasPrice = big.NewInt(1)
 		}
@@ -1223,6 +1338,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var engine consensus.Engine
 	if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
+	} else if config.Instant != nil {
+		engine = instant.New(config.Instant)
 	} else {
 		engine = ethash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
@@ -1243,6 +1360,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+
+		StateCheckpoint: ctx.GlobalUint64(StateCheckpointFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
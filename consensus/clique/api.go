@@ -17,12 +17,20 @@
 package clique
 
 import (
+	"errors"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// statusBlocks is the number of recent blocks the signer activity is reported on.
+const statusBlocks = 64
+
+// errInvalidExpiry is returned if a proposal expiry is not an explicit block number.
+var errInvalidExpiry = errors.New("proposal expiry must be a block number")
+
 // API is a user facing RPC API to allow controlling the signer and voting
 // mechanisms of the proof-of-authority scheme.
 type API struct {
@@ -88,14 +96,129 @@ func (api *API) GetSignersAtHash(hash common.Hash) ([]common.Address, error) {
 	return snap.signers(), nil
 }
 
+// ProposalStatus is the current voting state of an authorization change.
+type ProposalStatus struct {
+	Authorize bool             `json:"authorize"` // Whether the vote is about authorizing or kicking someone
+	Votes     int              `json:"votes"`     // Number of votes until now wanting to pass the proposal
+	Threshold int              `json:"threshold"` // Number of votes needed for the proposal to pass
+	Voters    []common.Address `json:"voters"`    // Signers that voted on the account, in chronological order
+}
+
+// GetTally retrieves the voting state of all the open proposals at a given block.
+func (api *API) GetTally(number *rpc.BlockNumber) (map[common.Address]*ProposalStatus, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	// Ensure we have an actually valid block and return the tally from its snapshot
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	tally := make(map[common.Address]*ProposalStatus)
+	for address, votes := range snap.Tally {
+		tally[address] = &ProposalStatus{
+			Authorize: votes.Authorize,
+			Votes:     votes.Votes,
+			Threshold: len(snap.Signers)/2 + 1,
+			Voters:    []common.Address{},
+		}
+	}
+	for _, vote := range snap.Votes {
+		if status, ok := tally[vote.Address]; ok {
+			status.Voters = append(status.Voters, vote.Signer)
+		}
+	}
+	return tally, nil
+}
+
+// SignerStatus is the sealing activity of a single signer over recent blocks.
+type SignerStatus struct {
+	Inturn    int    `json:"inturn"`    // Number of blocks sealed in-turn
+	OutOfTurn int    `json:"outOfTurn"` // Number of blocks sealed out-of-turn
+	LastBlock uint64 `json:"lastBlock"` // Most recent block sealed by the signer (0 = none)
+}
+
+// Status is the sealing activity of the signers over recent blocks.
+type Status struct {
+	Blocks        uint64                           `json:"blocks"`        // Number of recent blocks inspected
+	InturnPercent float64                          `json:"inturnPercent"` // Percentage of blocks sealed in-turn
+	Signers       map[common.Address]*SignerStatus `json:"signers"`       // Activity of the current (and recent) signers
+}
+
+// Status retrieves the sealing activity of the signers over the last
+// statusBlocks blocks of the chain.
+func (api *API) Status() (*Status, error) {
+	header := api.chain.CurrentHeader()
+	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Signers: make(map[common.Address]*SignerStatus)}
+	for _, signer := range snap.signers() {
+		status.Signers[signer] = new(SignerStatus)
+	}
+	// Iterate over the recent blocks (skipping the unsigned genesis) and tally the sealers
+	inturn := 0
+	for number := header.Number.Uint64(); number > 0 && status.Blocks < statusBlocks; number-- {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		signer, err := api.clique.Author(header)
+		if err != nil {
+			return nil, err
+		}
+		activity, ok := status.Signers[signer]
+		if !ok {
+			activity = new(SignerStatus)
+			status.Signers[signer] = activity
+		}
+		if header.Difficulty.Cmp(diffInTurn) == 0 {
+			activity.Inturn++
+			inturn++
+		} else {
+			activity.OutOfTurn++
+		}
+		if activity.LastBlock == 0 {
+			activity.LastBlock = number
+		}
+		status.Blocks++
+	}
+	if status.Blocks > 0 {
+		status.InturnPercent = float64(100*inturn) / float64(status.Blocks)
+	}
+	return status, nil
+}
+
 // Proposals returns the current proposals the node tries to uphold and vote on.
 func (api *API) Proposals() map[common.Address]bool {
 	api.clique.lock.RLock()
 	defer api.clique.lock.RUnlock()
 
 	proposals := make(map[common.Address]bool)
-	for address, auth := range api.clique.proposals {
-		proposals[address] = auth
+	for address, proposal := range api.clique.proposals {
+		proposals[address] = proposal.Authorize
+	}
+	return proposals
+}
+
+// ProposalDetails returns the current proposals the node tries to uphold and
+// vote on, together with the block after which they expire.
+func (api *API) ProposalDetails() map[common.Address]*Proposal {
+	api.clique.lock.RLock()
+	defer api.clique.lock.RUnlock()
+
+	proposals := make(map[common.Address]*Proposal)
+	for address, proposal := range api.clique.proposals {
+		cpy := *proposal
+		proposals[address] = &cpy
 	}
 	return proposals
 }
@@ -106,7 +229,22 @@ func (api *API) Propose(address common.Address, auth bool) {
 	api.clique.lock.Lock()
 	defer api.clique.lock.Unlock()
 
-	api.clique.proposals[address] = auth
+	api.clique.proposals[address] = &Proposal{Authorize: auth}
+	storeProposals(api.clique.db, api.clique.proposals)
+}
+
+// ProposeWithExpiry injects a new authorization proposal that the signer will
+// attempt to push through until the given block, stopping to vote on it after.
+func (api *API) ProposeWithExpiry(address common.Address, auth bool, expiry rpc.BlockNumber) error {
+	if expiry < 0 {
+		return errInvalidExpiry
+	}
+	api.clique.lock.Lock()
+	defer api.clique.lock.Unlock()
+
+	api.clique.proposals[address] = &Proposal{Authorize: auth, Expiry: uint64(expiry.Int64())}
+	storeProposals(api.clique.db, api.clique.proposals)
+	return nil
 }
 
 // Discard drops a currently running proposal, stopping the signer from casting
@@ -116,4 +254,5 @@ func (api *API) Discard(address common.Address) {
 	defer api.clique.lock.Unlock()
 
 	delete(api.clique.proposals, address)
+	storeProposals(api.clique.db, api.clique.proposals)
 }
@@ -201,7 +201,7 @@ type Clique struct {
 	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
 	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 
-	proposals map[common.Address]bool // Current list of proposals we are pushing
+	proposals map[common.Address]*Proposal // Current list of proposals we are pushing
 
 	signer common.Address // Ethereum address of the signing key
 	signFn SignerFn       // Signer function to authorize hashes with
@@ -225,7 +225,7 @@ func New(config *params.CliqueConfig, db ethdb.Database) *Clique {
 		db:         db,
 		recents:    recents,
 		signatures: signatures,
-		proposals:  make(map[common.Address]bool),
+		proposals:  loadProposals(db),
 	}
 }
 
@@ -516,25 +516,35 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 		return err
 	}
 	if number%c.config.Epoch != 0 {
-		c.lock.RLock()
+		c.lock.Lock()
 
-		// Gather all the proposals that make sense voting on
+		// Drop any expired proposals and gather the ones that make sense voting on
 		addresses := make([]common.Address, 0, len(c.proposals))
-		for address, authorize := range c.proposals {
-			if snap.validVote(address, authorize) {
+		expired := false
+		for address, proposal := range c.proposals {
+			if proposal.expired(number) {
+				log.Info("Clique proposal expired", "address", address, "authorize", proposal.Authorize, "expiry", proposal.Expiry)
+				delete(c.proposals, address)
+				expired = true
+				continue
+			}
+			if snap.validVote(address, proposal.Authorize) {
 				addresses = append(addresses, address)
 			}
 		}
+		if expired {
+			storeProposals(c.db, c.proposals)
+		}
 		// If there's pending proposals, cast a vote on them
 		if len(addresses) > 0 {
 			header.Coinbase = addresses[rand.Intn(len(addresses))]
-			if c.proposals[header.Coinbase] {
+			if c.proposals[header.Coinbase].Authorize {
 				copy(header.Nonce[:], nonceAuthVote)
 			} else {
 				copy(header.Nonce[:], nonceDropVote)
 			}
 		}
-		c.lock.RUnlock()
+		c.lock.Unlock()
 	}
 	// Set the correct difficulty
 	header.Difficulty = CalcDifficulty(snap, c.signer)
@@ -0,0 +1,74 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// proposalsKey is the database key under which the local proposals are stored.
+var proposalsKey = []byte("clique-proposals")
+
+// Proposal is a local authorization change the signer keeps voting on until it
+// is discarded or expires.
+type Proposal struct {
+	Authorize bool   `json:"authorize"`        // Whether to authorize or deauthorize the voted account
+	Expiry    uint64 `json:"expiry,omitempty"` // Block number after which to stop voting (0 = never)
+}
+
+// expired returns whether the proposal should not be voted on any more in the
+// block with the given number.
+func (p *Proposal) expired(number uint64) bool {
+	return p.Expiry != 0 && number > p.Expiry
+}
+
+// loadProposals retrieves the local proposals persisted in the database. Any
+// error is logged and results in an empty proposal set.
+func loadProposals(db ethdb.Database) map[common.Address]*Proposal {
+	proposals := make(map[common.Address]*Proposal)
+	if db == nil {
+		return proposals
+	}
+	blob, err := db.Get(proposalsKey)
+	if err != nil {
+		return proposals
+	}
+	if err := json.Unmarshal(blob, &proposals); err != nil {
+		log.Warn("Failed to decode clique proposals", "err", err)
+		return make(map[common.Address]*Proposal)
+	}
+	return proposals
+}
+
+// storeProposals persists the local proposals into the database.
+func storeProposals(db ethdb.Database, proposals map[common.Address]*Proposal) {
+	if db == nil {
+		return
+	}
+	blob, err := json.Marshal(proposals)
+	if err != nil {
+		log.Error("Failed to encode clique proposals", "err", err)
+		return
+	}
+	if err := db.Put(proposalsKey, blob); err != nil {
+		log.Error("Failed to store clique proposals", "err", err)
+	}
+}
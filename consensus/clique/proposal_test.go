@@ -0,0 +1,174 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that local proposals survive an engine restart and that discarding them
+// is persisted too.
+func TestProposalPersistence(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	var (
+		authorize   = common.HexToAddress("0x01")
+		deauthorize = common.HexToAddress("0x02")
+	)
+	engine := New(&params.CliqueConfig{Epoch: 30000}, db)
+	api := &API{clique: engine}
+	if err := api.ProposeWithExpiry(authorize, true, 100); err != nil {
+		t.Fatalf("failed to propose authorization: %v", err)
+	}
+	api.Propose(deauthorize, false)
+	if err := api.ProposeWithExpiry(deauthorize, true, rpc.PendingBlockNumber); err != errInvalidExpiry {
+		t.Fatalf("invalid expiry error mismatch: have %v, want %v", err, errInvalidExpiry)
+	}
+	want := map[common.Address]*Proposal{
+		authorize:   {Authorize: true, Expiry: 100},
+		deauthorize: {Authorize: false},
+	}
+	restored := &API{clique: New(&params.CliqueConfig{Epoch: 30000}, db)}
+	if have := restored.ProposalDetails(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("restored proposals mismatch: have %v, want %v", have, want)
+	}
+	if have := restored.Proposals(); !reflect.DeepEqual(have, map[common.Address]bool{authorize: true, deauthorize: false}) {
+		t.Fatalf("restored proposal flags mismatch: have %v", have)
+	}
+	// Discard a proposal and ensure it's gone after a restart too
+	api.Discard(authorize)
+	delete(want, authorize)
+
+	if have := (&API{clique: New(&params.CliqueConfig{Epoch: 30000}, db)}).ProposalDetails(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("restored proposals mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that proposals expire only after their expiry block.
+func TestProposalExpiry(t *testing.T) {
+	tests := []struct {
+		expiry  uint64
+		number  uint64
+		expired bool
+	}{
+		{0, 1000000, false},
+		{10, 9, false},
+		{10, 10, false},
+		{10, 11, true},
+	}
+	for i, tt := range tests {
+		if expired := (&Proposal{Expiry: tt.expiry}).expired(tt.number); expired != tt.expired {
+			t.Errorf("test %d: expiry mismatch: have %v, want %v", i, expired, tt.expired)
+		}
+	}
+}
+
+// proposalChainReader is a consensus.ChainReader serving a fixed set of headers,
+// with the last one being the head of the chain.
+type proposalChainReader struct {
+	testerChainReader
+	headers []*types.Header
+}
+
+func (r *proposalChainReader) CurrentHeader() *types.Header {
+	return r.headers[len(r.headers)-1]
+}
+
+func (r *proposalChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	for _, header := range r.headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+// Tests that expired proposals are dropped, and not voted on, when preparing a
+// block past their expiry.
+func TestProposalExpiryVoting(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	var (
+		signer  = common.HexToAddress("0x01")
+		expired = common.HexToAddress("0x02")
+		live    = common.HexToAddress("0x03")
+		parent  = &types.Header{Number: big.NewInt(9), Time: big.NewInt(0)}
+	)
+	engine := New(&params.CliqueConfig{Epoch: 30000}, db)
+	engine.recents.Add(parent.Hash(), newSnapshot(engine.config, engine.signatures, 9, parent.Hash(), []common.Address{signer, live}))
+
+	api := &API{clique: engine}
+	api.ProposeWithExpiry(expired, true, 9)
+	api.ProposeWithExpiry(live, false, 10)
+
+	header := &types.Header{Number: big.NewInt(10), ParentHash: parent.Hash()}
+	if err := engine.Prepare(&proposalChainReader{headers: []*types.Header{parent}}, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	if header.Coinbase != live || !bytes.Equal(header.Nonce[:], nonceDropVote) {
+		t.Errorf("vote mismatch: have %x (nonce %x), want drop vote on %x", header.Coinbase, header.Nonce, live)
+	}
+	want := map[common.Address]bool{live: false}
+	if have := api.Proposals(); !reflect.DeepEqual(have, want) {
+		t.Errorf("proposals mismatch: have %v, want %v", have, want)
+	}
+	if have := (&API{clique: New(&params.CliqueConfig{Epoch: 30000}, db)}).Proposals(); !reflect.DeepEqual(have, want) {
+		t.Errorf("restored proposals mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that the vote tally reports the votes cast on each open proposal, along
+// with the signers that cast them and the number of votes needed to pass.
+func TestProposalTally(t *testing.T) {
+	var (
+		signers = []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+		added   = common.HexToAddress("0x04")
+		head    = &types.Header{Number: big.NewInt(5)}
+	)
+	engine := New(&params.CliqueConfig{Epoch: 30000}, nil)
+
+	snap := newSnapshot(engine.config, engine.signatures, 5, head.Hash(), signers)
+	snap.cast(added, true)
+	snap.Votes = append(snap.Votes, &Vote{Signer: signers[0], Block: 3, Address: added, Authorize: true})
+	snap.cast(signers[2], false)
+	snap.Votes = append(snap.Votes, &Vote{Signer: signers[1], Block: 4, Address: signers[2], Authorize: false})
+	snap.cast(added, true)
+	snap.Votes = append(snap.Votes, &Vote{Signer: signers[1], Block: 5, Address: added, Authorize: true})
+	engine.recents.Add(head.Hash(), snap)
+
+	api := &API{chain: &proposalChainReader{headers: []*types.Header{head}}, clique: engine}
+	tally, err := api.GetTally(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve tally: %v", err)
+	}
+	want := map[common.Address]*ProposalStatus{
+		added:      {Authorize: true, Votes: 2, Threshold: 2, Voters: []common.Address{signers[0], signers[1]}},
+		signers[2]: {Authorize: false, Votes: 1, Threshold: 2, Voters: []common.Address{signers[1]}},
+	}
+	if !reflect.DeepEqual(tally, want) {
+		t.Errorf("tally mismatch: have %v, want %v", tally, want)
+	}
+}
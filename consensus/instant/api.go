@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package instant
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// rewindableChain is the chain functionality needed to revert to a snapshot.
+type rewindableChain interface {
+	SetHead(head uint64) error
+	CurrentBlock() *types.Block
+	PostChainEvents(events []interface{}, logs []*types.Log)
+}
+
+// API is a user facing RPC API to allow controlling block production and the
+// chain state of an instant sealing development chain.
+type API struct {
+	chain   consensus.ChainReader
+	instant *Instant
+}
+
+// Mine requests the next block to be sealed, even if it contains no transactions.
+func (api *API) Mine() {
+	api.instant.requestSeal()
+}
+
+// SetNextBlockTimestamp forces the timestamp of the next sealed block. It must
+// be larger than the timestamp of the current head.
+func (api *API) SetNextBlockTimestamp(timestamp hexutil.Uint64) error {
+	if head := api.chain.CurrentHeader(); uint64(timestamp) <= head.Time.Uint64() {
+		return fmt.Errorf("timestamp %d not after current head's %d", timestamp, head.Time)
+	}
+	api.instant.lock.Lock()
+	defer api.instant.lock.Unlock()
+
+	api.instant.timestamp = uint64(timestamp)
+	return nil
+}
+
+// Snapshot records the current head of the chain and returns an identifier that
+// can be used to revert back to it.
+func (api *API) Snapshot() hexutil.Uint64 {
+	head := api.chain.CurrentHeader()
+
+	api.instant.lock.Lock()
+	defer api.instant.lock.Unlock()
+
+	id := api.instant.nextID
+	api.instant.nextID++
+	api.instant.snapshots = append(api.instant.snapshots, snapshot{id: id, number: head.Number.Uint64(), hash: head.Hash()})
+
+	return hexutil.Uint64(id)
+}
+
+// Revert rewinds the chain to the head recorded by the given snapshot. The
+// snapshot and all the ones taken after it are invalidated.
+func (api *API) Revert(id hexutil.Uint64) error {
+	chain, ok := api.chain.(rewindableChain)
+	if !ok {
+		return errors.New("chain rewinding not supported")
+	}
+	api.instant.lock.Lock()
+	defer api.instant.lock.Unlock()
+
+	// Find the requested snapshot and drop it along with all newer ones
+	index := -1
+	for i, snap := range api.instant.snapshots {
+		if snap.id == uint64(id) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("unknown snapshot %d", id)
+	}
+	snap := api.instant.snapshots[index]
+	api.instant.snapshots = api.instant.snapshots[:index]
+
+	// Ensure the snapshot is still on the canonical chain and rewind to it
+	if header := api.chain.GetHeaderByNumber(snap.number); header == nil || header.Hash() != snap.hash {
+		return fmt.Errorf("snapshot %d block #%d [%x…] no longer canonical", id, snap.number, snap.hash[:4])
+	}
+	if err := chain.SetHead(snap.number); err != nil {
+		return err
+	}
+	api.instant.timestamp = 0
+	api.instant.dropSealRequest()
+
+	// Notify the transaction pool and miner of the new head
+	chain.PostChainEvents([]interface{}{core.ChainHeadEvent{Block: chain.CurrentBlock()}}, nil)
+	return nil
+}
@@ -0,0 +1,281 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package instant implements an on-demand consensus engine for development
+// chains, sealing blocks as soon as transactions arrive or when explicitly asked.
+package instant
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// difficulty is the fixed difficulty of every instantly sealed block.
+	difficulty = big.NewInt(1)
+
+	// uncleHash is the hash of the empty uncle list, uncles being meaningless here.
+	uncleHash = types.CalcUncleHash(nil)
+)
+
+// Various error messages to mark blocks invalid.
+var (
+	// errUnknownBlock is returned when attempting to verify or seal a block that
+	// cannot be part of the local blockchain (e.g. genesis).
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is not strictly
+	// larger than the timestamp of its parent.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errExtraTooLong is returned if the extra-data of a block exceeds the limit.
+	errExtraTooLong = errors.New("extra-data too long")
+
+	// errInvalidGasUsed is returned if a block consumes more gas than its limit.
+	errInvalidGasUsed = errors.New("invalid gas used")
+)
+
+// snapshot is a reference to a canonical chain head that can be reverted to.
+type snapshot struct {
+	id     uint64      // Identifier handed out to the user
+	number uint64      // Number of the head block at the time of the snapshot
+	hash   common.Hash // Hash of the head block at the time of the snapshot
+}
+
+// Instant is a consensus engine for development chains. It does not do any
+// real sealing, rather it releases blocks containing transactions right away,
+// and empty blocks only when explicitly requested.
+type Instant struct {
+	config *params.InstantConfig // Consensus engine configuration parameters
+
+	mine chan struct{} // Notification channel for explicit sealing requests
+
+	timestamp uint64     // Timestamp to force on the next sealed block (0 = wall clock)
+	snapshots []snapshot // Chain heads the user may revert to, in creation order
+	nextID    uint64     // Identifier of the next chain snapshot
+	lock      sync.Mutex // Protects the timestamp and snapshot fields
+}
+
+// New creates an instant sealing consensus engine.
+func New(config *params.InstantConfig) *Instant {
+	return &Instant{
+		config: config,
+		mine:   make(chan struct{}, 1),
+		nextID: 1,
+	}
+}
+
+// Author implements consensus.Engine, returning the header's coinbase as the
+// address of the account that minted the block.
+func (i *Instant) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (i *Instant) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return i.verifyHeader(chain, header, nil)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (i *Instant) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for index, header := range headers {
+			err := i.verifyHeader(chain, header, headers[:index])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database.
+func (i *Instant) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Ensure the header fields are the ones set by the engine
+	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
+		return errExtraTooLong
+	}
+	if header.Difficulty == nil || header.Difficulty.Cmp(difficulty) != 0 {
+		return errInvalidDifficulty
+	}
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	if header.GasUsed > header.GasLimit {
+		return errInvalidGasUsed
+	}
+	// Ensure the block links to a known parent and is younger than it
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if header.Time.Cmp(parent.Time) <= 0 {
+		return errInvalidTimestamp
+	}
+	// If all checks passed, validate any special fields for hard forks
+	return misc.VerifyForkHashes(chain.Config(), header, false)
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (i *Instant) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine. Instantly sealed blocks carry no seal,
+// so only the genesis block is rejected.
+func (i *Instant) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if header.Number.Sign() == 0 {
+		return errUnknownBlock
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (i *Instant) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = common.Hash{}
+	header.Difficulty = new(big.Int).Set(difficulty)
+
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	// Apply any user requested timestamp, ensuring it's still after the parent
+	i.lock.Lock()
+	if i.timestamp != 0 {
+		header.Time = new(big.Int).SetUint64(i.timestamp)
+	}
+	i.lock.Unlock()
+
+	if header.Time == nil || header.Time.Cmp(parent.Time) <= 0 {
+		header.Time = new(big.Int).Add(parent.Time, common.Big1)
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given, and returns the final block.
+func (i *Instant) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = uncleHash
+
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Seal implements consensus.Engine. Blocks with transactions are released right
+// away, whereas empty ones are held back until sealing is explicitly requested.
+func (i *Instant) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	if header.Number.Sign() == 0 {
+		return nil, errUnknownBlock
+	}
+	if len(block.Transactions()) == 0 {
+		select {
+		case <-stop:
+			return nil, nil
+		case <-i.mine:
+		}
+	} else {
+		// The block fulfills any pending request too, don't let it seal another
+		i.dropSealRequest()
+	}
+	// Block released, consume any user requested timestamp it used up
+	i.lock.Lock()
+	if i.timestamp != 0 && header.Time.Uint64() >= i.timestamp {
+		i.timestamp = 0
+	}
+	i.lock.Unlock()
+
+	return block.WithSeal(header), nil
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have, which is always 1.
+func (i *Instant) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(difficulty)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling block production and chain state.
+func (i *Instant) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "dev",
+		Version:   "1.0",
+		Service:   &API{chain: chain, instant: i},
+		Public:    false,
+	}}
+}
+
+// requestSeal signals the engine to seal the next block even if it's empty. At
+// most one request is queued up, any surplus ones are dropped.
+func (i *Instant) requestSeal() {
+	select {
+	case i.mine <- struct{}{}:
+	default:
+	}
+}
+
+// dropSealRequest discards any queued up sealing request, so that it doesn't
+// release an empty block after the one it was meant for.
+func (i *Instant) dropSealRequest() {
+	select {
+	case <-i.mine:
+	default:
+	}
+}
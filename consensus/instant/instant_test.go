@@ -0,0 +1,166 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package instant
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestChain creates a blockchain sealed by a fresh instant engine along with
+// a number of generated blocks not yet inserted into it.
+func newTestChain(t *testing.T, n int) (*core.BlockChain, *Instant, []*types.Block) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := (&core.Genesis{Config: params.AllInstantProtocolChanges}).MustCommit(db)
+
+	engine := New(params.AllInstantProtocolChanges.Instant)
+	blocks, _ := core.GenerateChain(params.AllInstantProtocolChanges, genesis, engine, db, n, nil)
+
+	chain, err := core.NewBlockChain(db, nil, params.AllInstantProtocolChanges, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return chain, engine, blocks
+}
+
+// Tests that blocks with transactions are sealed right away, whereas empty ones
+// wait for an explicit request.
+func TestSealing(t *testing.T) {
+	engine := New(new(params.InstantConfig))
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+
+	// Empty blocks must be held back until requested
+	sealed := make(chan *types.Block, 1)
+	go func() {
+		block, _ := engine.Seal(nil, types.NewBlockWithHeader(header), make(chan struct{}))
+		sealed <- block
+	}()
+	select {
+	case <-sealed:
+		t.Fatalf("empty block sealed without request")
+	case <-time.After(50 * time.Millisecond):
+	}
+	(&API{instant: engine}).Mine()
+	select {
+	case block := <-sealed:
+		if block == nil || block.NumberU64() != 1 {
+			t.Fatalf("sealed block mismatch: have %v, want #1", block)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("empty block not sealed on request")
+	}
+	// Aborting an empty seal should return without a block
+	stop := make(chan struct{})
+	close(stop)
+	if block, err := engine.Seal(nil, types.NewBlockWithHeader(header), stop); block != nil || err != nil {
+		t.Fatalf("aborted seal mismatch: have %v/%v, want nil/nil", block, err)
+	}
+	// Blocks with transactions need no request
+	tx := types.NewTransaction(0, common.Address{}, new(big.Int), 21000, new(big.Int), nil)
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil)
+	if sealed, err := engine.Seal(nil, block, make(chan struct{})); sealed == nil || err != nil {
+		t.Fatalf("non-empty block sealing failed: %v", err)
+	}
+}
+
+// Tests that a sealing request is fulfilled by the next sealed block, even if it
+// contains transactions, and is not left over to release a later empty one.
+func TestSealRequestConsumed(t *testing.T) {
+	engine := New(new(params.InstantConfig))
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(1)}
+
+	(&API{instant: engine}).Mine()
+
+	tx := types.NewTransaction(0, common.Address{}, new(big.Int), 21000, new(big.Int), nil)
+	if sealed, err := engine.Seal(nil, types.NewBlock(header, []*types.Transaction{tx}, nil, nil), make(chan struct{})); sealed == nil || err != nil {
+		t.Fatalf("non-empty block sealing failed: %v", err)
+	}
+	stop := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+	if block, _ := engine.Seal(nil, types.NewBlockWithHeader(header), stop); block != nil {
+		t.Fatalf("empty block sealed by stale request")
+	}
+}
+
+// Tests that instantly sealed chains can be imported and reverted to snapshots.
+func TestSnapshotRevert(t *testing.T) {
+	chain, engine, blocks := newTestChain(t, 5)
+	defer chain.Stop()
+
+	api := &API{chain: chain, instant: engine}
+	if _, err := chain.InsertChain(blocks[:3]); err != nil {
+		t.Fatalf("failed to insert initial blocks: %v", err)
+	}
+	id := api.Snapshot()
+	if _, err := chain.InsertChain(blocks[3:]); err != nil {
+		t.Fatalf("failed to insert remaining blocks: %v", err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 5 {
+		t.Fatalf("chain head mismatch: have #%d, want #5", head)
+	}
+	if err := api.Revert(id); err != nil {
+		t.Fatalf("failed to revert to snapshot: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[2].Hash() {
+		t.Fatalf("reverted head mismatch: have #%d [%x], want #3 [%x]", head.NumberU64(), head.Hash(), blocks[2].Hash())
+	}
+	if err := api.Revert(id); err == nil {
+		t.Fatalf("reverted to consumed snapshot")
+	}
+}
+
+// Tests that the next block timestamp can be forced, but only into the future.
+func TestNextBlockTimestamp(t *testing.T) {
+	chain, engine, _ := newTestChain(t, 0)
+	defer chain.Stop()
+
+	api := &API{chain: chain, instant: engine}
+	if err := api.SetNextBlockTimestamp(0); err == nil {
+		t.Fatalf("timestamp before head accepted")
+	}
+	if err := api.SetNextBlockTimestamp(1000); err != nil {
+		t.Fatalf("failed to set next timestamp: %v", err)
+	}
+	genesis := chain.Genesis()
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Time: big.NewInt(5)}
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	if header.Time.Uint64() != 1000 {
+		t.Fatalf("prepared timestamp mismatch: have %v, want 1000", header.Time)
+	}
+	tx := types.NewTransaction(0, common.Address{}, new(big.Int), 21000, new(big.Int), nil)
+	if _, err := engine.Seal(chain, types.NewBlock(header, []*types.Transaction{tx}, nil, nil), nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	// Timestamp consumed, subsequent blocks should follow the parent
+	header = &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1), Time: big.NewInt(5)}
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	if header.Time.Uint64() != 5 {
+		t.Fatalf("prepared timestamp mismatch: have %v, want 5", header.Time)
+	}
+}
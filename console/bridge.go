@@ -85,7 +85,8 @@ func (b *bridge) NewAccount(call otto.FunctionCall) (response otto.Value) {
 }
 
 // OpenWallet is a wrapper around personal.openWallet which can interpret and
-// react to certain error messages, such as the Trezor PIN matrix request.
+// react to certain error messages, such as the Trezor PIN matrix and passphrase
+// requests.
 func (b *bridge) OpenWallet(call otto.FunctionCall) (response otto.Value) {
 	// Make sure we have an wallet specified to open
 	if !call.Argument(0).IsString() {
@@ -104,27 +105,37 @@ func (b *bridge) OpenWallet(call otto.FunctionCall) (response otto.Value) {
 	if err == nil {
 		return val
 	}
-	// Wallet open failed, report error unless it's a PIN entry
-	if !strings.HasSuffix(err.Error(), usbwallet.ErrTrezorPINNeeded.Error()) {
-		throwJSException(err.Error())
-	}
-	// Trezor PIN matrix input requested, display the matrix to the user and fetch the data
-	fmt.Fprintf(b.printer, "Look at the device for number positions\n\n")
-	fmt.Fprintf(b.printer, "7 | 8 | 9\n")
-	fmt.Fprintf(b.printer, "--+---+--\n")
-	fmt.Fprintf(b.printer, "4 | 5 | 6\n")
-	fmt.Fprintf(b.printer, "--+---+--\n")
-	fmt.Fprintf(b.printer, "1 | 2 | 3\n\n")
+	// Wallet open failed, keep prompting while the device requests a PIN or a passphrase
+	for {
+		switch {
+		case strings.HasSuffix(err.Error(), usbwallet.ErrTrezorPINNeeded.Error()):
+			// Trezor PIN matrix input requested, display the matrix to the user and fetch the data
+			fmt.Fprintf(b.printer, "Look at the device for number positions\n\n")
+			fmt.Fprintf(b.printer, "7 | 8 | 9\n")
+			fmt.Fprintf(b.printer, "--+---+--\n")
+			fmt.Fprintf(b.printer, "4 | 5 | 6\n")
+			fmt.Fprintf(b.printer, "--+---+--\n")
+			fmt.Fprintf(b.printer, "1 | 2 | 3\n\n")
 
-	if input, err := b.prompter.PromptPassword("Please enter current PIN: "); err != nil {
-		throwJSException(err.Error())
-	} else {
-		passwd, _ = otto.ToValue(input)
-	}
-	if val, err = call.Otto.Call("jeth.openWallet", nil, wallet, passwd); err != nil {
-		throwJSException(err.Error())
+			if input, err := b.prompter.PromptPassword("Please enter current PIN: "); err != nil {
+				throwJSException(err.Error())
+			} else {
+				passwd, _ = otto.ToValue(input)
+			}
+		case strings.HasSuffix(err.Error(), usbwallet.ErrTrezorPassphraseNeeded.Error()):
+			// Trezor passphrase requested to unlock the seed, fetch it from the user
+			if input, err := b.prompter.PromptPassword("Please enter your passphrase: "); err != nil {
+				throwJSException(err.Error())
+			} else {
+				passwd, _ = otto.ToValue(input)
+			}
+		default:
+			throwJSException(err.Error())
+		}
+		if val, err = call.Otto.Call("jeth.openWallet", nil, wallet, passwd); err == nil {
+			return val
+		}
 	}
-	return val
 }
 
 // UnlockAccount is a wrapper around the personal.unlockAccount RPC method that
@@ -69,6 +69,8 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+
+	StateCheckpoint uint64 // Number of blocks between states persisted in full to bound historical state regeneration (0 = disabled)
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -913,6 +915,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
 		bc.triegc.Push(root, -float32(block.NumberU64()))
 
+		// Persist a state checkpoint every now and then, so the state of any historical
+		// block can be regenerated by re-executing a bounded number of blocks
+		if interval := bc.cacheConfig.StateCheckpoint; interval > 0 && block.NumberU64()%interval == 0 {
+			if err := triedb.Commit(root, false); err != nil {
+				return NonStatTy, err
+			}
+		}
+
 		if current := block.NumberU64(); current > triesInMemory {
 			// Find the next state trie we need to commit
 			header := bc.GetHeaderByNumber(current - triesInMemory)
@@ -1464,6 +1474,34 @@ func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (i
 	return bc.hc.InsertHeaderChain(chain, whFunc, start)
 }
 
+// InsertTrustedHeaderChain inserts a header chain vouched for by a trusted
+// descendant (e.g. a checkpoint block) into the local chain. Contrary to
+// InsertHeaderChain, only the hash links between the headers are checked, not
+// the consensus rules. The first header's parent must already be known.
+func (bc *BlockChain) InsertTrustedHeaderChain(chain []*types.Header) (int, error) {
+	start := time.Now()
+	if i, err := bc.hc.ValidateTrustedHeaderChain(chain); err != nil {
+		return i, err
+	}
+
+	// Make sure only one thread manipulates the chain at once
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	whFunc := func(header *types.Header) error {
+		bc.mu.Lock()
+		defer bc.mu.Unlock()
+
+		_, err := bc.hc.WriteHeader(header)
+		return err
+	}
+
+	return bc.hc.InsertHeaderChain(chain, whFunc, start)
+}
+
 // writeHeader writes a header into the local chain, given that its parent is
 // already known. If the total difficulty of the newly inserted header becomes
 // greater than the current known TD, the canonical chain is re-routed.
@@ -563,6 +563,34 @@ func testInsertNonceError(t *testing.T, full bool) {
 	}
 }
 
+// Tests that trusted header chains are inserted without consensus verification,
+// but still rejected if they are not hash linked.
+func TestInsertTrustedHeaderChain(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 0, false)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	headers := makeHeaderChain(blockchain.CurrentHeader(), 16, ethash.NewFaker(), db, 0)
+
+	// Fail the verification of every header, which must not affect trusted ones
+	blockchain.engine = ethash.NewFakeFailer(headers[0].Number.Uint64())
+	blockchain.hc.engine = blockchain.engine
+
+	broken := append([]*types.Header{}, headers[:8]...)
+	broken[4], broken[5] = broken[5], broken[4]
+	if _, err := blockchain.InsertTrustedHeaderChain(broken); err == nil {
+		t.Fatalf("non contiguous trusted header chain inserted")
+	}
+	if _, err := blockchain.InsertTrustedHeaderChain(headers); err != nil {
+		t.Fatalf("failed to insert trusted header chain: %v", err)
+	}
+	if head := blockchain.CurrentHeader(); head.Hash() != headers[len(headers)-1].Hash() {
+		t.Fatalf("head header mismatch: have #%d [%x…], want #%d", head.Number, head.Hash().Bytes()[:4], len(headers))
+	}
+}
+
 // Tests that fast importing a block chain produces the same chain data as the
 // classical full block processing.
 func TestFastVsFullChains(t *testing.T) {
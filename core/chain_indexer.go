@@ -122,6 +122,16 @@ func (c *ChainIndexer) AddKnownSectionHead(section uint64, shead common.Hash) {
 	c.setValidSections(section + 1)
 }
 
+// DropSections discards all the sections processed so far, forcing the indexer
+// to regenerate its index from scratch. It should be called before Start.
+func (c *ChainIndexer) DropSections() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.setValidSections(0)
+	c.cascadedHead = 0
+}
+
 // Start creates a goroutine to feed chain head events into the indexer for
 // cascading background processing. Children do not need to be started, they
 // are notified about new events by their parents.
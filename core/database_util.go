@@ -26,6 +26,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -49,6 +50,8 @@ var (
 	headFastKey   = []byte("LastFast")
 	trieSyncKey   = []byte("TrieSync")
 
+	trieSyncFrontierKey = []byte("TrieSyncFrontier")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`).
 	headerPrefix        = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	tdSuffix            = []byte("t") // headerPrefix + num (uint64 big endian) + hash + tdSuffix -> td
@@ -58,12 +61,17 @@ var (
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 	lookupPrefix        = []byte("l") // lookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix     = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix      = []byte("P") // logIndexPrefix + section (uint64 big endian) + hash + key hash -> block offsets (uint16 big endian)
+
+	checkpointHeaderPrefix = []byte("C") // checkpointHeaderPrefix + num (uint64 big endian) -> header staged by checkpoint sync
+	trieSyncFrontierPrefix = []byte("F") // trieSyncFrontierPrefix + index (uint64 big endian) -> trie node retrieved but not committed by a state sync
 
 	preimagePrefix = "secure-key-"              // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexPrefix       = []byte("iP") // LogIndexPrefix is the data table of the log index chain indexer to track its progress
 
 	// used by old db, now only used for conversion
 	oldReceiptsPrefix = []byte("receipts-")
@@ -157,6 +165,53 @@ func GetTrieSyncProgress(db DatabaseReader) uint64 {
 	return new(big.Int).SetBytes(data).Uint64()
 }
 
+// trieSyncFrontier is the database representation of the frontier of an
+// interrupted state trie sync, the nodes themselves being stored one by one
+// under trieSyncFrontierPrefix.
+type trieSyncFrontier struct {
+	Root  common.Hash
+	Nodes uint64
+}
+
+// trieSyncFrontierNodeKey = trieSyncFrontierPrefix + index (uint64 big endian)
+func trieSyncFrontierNodeKey(index uint64) []byte {
+	return append(append([]byte{}, trieSyncFrontierPrefix...), encodeBlockNumber(index)...)
+}
+
+// getTrieSyncFrontierMeta retrieves the state root and the number of nodes of
+// the stored state trie sync frontier.
+func getTrieSyncFrontierMeta(db DatabaseReader) (common.Hash, uint64) {
+	data, _ := db.Get(trieSyncFrontierKey)
+	if len(data) == 0 {
+		return common.Hash{}, 0
+	}
+	var frontier trieSyncFrontier
+	if err := rlp.DecodeBytes(data, &frontier); err != nil {
+		log.Error("Invalid trie sync frontier RLP", "err", err)
+		return common.Hash{}, 0
+	}
+	return frontier.Root, frontier.Nodes
+}
+
+// GetTrieSyncFrontier retrieves the state root and the retrieved but not yet
+// committed trie nodes of the last interrupted state trie sync.
+func GetTrieSyncFrontier(db DatabaseReader) (common.Hash, [][]byte) {
+	root, count := getTrieSyncFrontierMeta(db)
+	if count == 0 {
+		return root, nil
+	}
+	nodes := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		node, _ := db.Get(trieSyncFrontierNodeKey(i))
+		if len(node) == 0 {
+			log.Error("Missing trie sync frontier node", "index", i)
+			break
+		}
+		nodes = append(nodes, node)
+	}
+	return root, nodes
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
@@ -179,6 +234,22 @@ func GetHeader(db DatabaseReader, hash common.Hash, number uint64) *types.Header
 	return header
 }
 
+// GetCheckpointHeader retrieves a header staged during a checkpoint sync, nil if
+// none found. The hash of the header is not part of the key, so it must be checked
+// against the expected one by the caller.
+func GetCheckpointHeader(db DatabaseReader, number uint64) *types.Header {
+	data, _ := db.Get(append(append([]byte{}, checkpointHeaderPrefix...), encodeBlockNumber(number)...))
+	if len(data) == 0 {
+		return nil
+	}
+	header := new(types.Header)
+	if err := rlp.Decode(bytes.NewReader(data), header); err != nil {
+		log.Error("Invalid staged header RLP", "number", number, "err", err)
+		return nil
+	}
+	return header
+}
+
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(hash, number))
@@ -352,6 +423,29 @@ func GetBloomBits(db DatabaseReader, bit uint, section uint64, head common.Hash)
 	return db.Get(key)
 }
 
+// LogIndexKey returns the posting list key of the log index for the given log
+// address and first topic. Either of them may be nil to look up all the logs
+// matching the other one only. If both are nil, the key of the marker written
+// for each completed section is returned.
+func LogIndexKey(address *common.Address, topic *common.Hash) common.Hash {
+	switch {
+	case address == nil && topic == nil:
+		return common.Hash{}
+	case address == nil:
+		return crypto.Keccak256Hash([]byte("t"), topic[:])
+	case topic == nil:
+		return crypto.Keccak256Hash([]byte("a"), address[:])
+	default:
+		return crypto.Keccak256Hash([]byte("p"), address[:], topic[:])
+	}
+}
+
+// GetLogIndex retrieves the block offsets within the given section that contain
+// logs belonging to the given posting list key.
+func GetLogIndex(db DatabaseReader, key common.Hash, section uint64, head common.Hash) ([]byte, error) {
+	return db.Get(logIndexKey(key, section, head))
+}
+
 // WriteCanonicalHash stores the canonical hash for the given block number.
 func WriteCanonicalHash(db ethdb.Putter, hash common.Hash, number uint64) error {
 	key := append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...)
@@ -394,6 +488,42 @@ func WriteTrieSyncProgress(db ethdb.Putter, count uint64) error {
 	return nil
 }
 
+// WriteTrieSyncFrontier stores the retrieved but not yet committed trie nodes of
+// a state trie sync, allowing it to resume without retrieving them again. The
+// nodes are stored one by one, written in batches, replacing any previously
+// stored frontier.
+func WriteTrieSyncFrontier(db ethdb.Database, root common.Hash, nodes [][]byte) error {
+	_, prev := getTrieSyncFrontierMeta(db)
+
+	batch := db.NewBatch()
+	for i, node := range nodes {
+		if err := batch.Put(trieSyncFrontierNodeKey(uint64(i)), node); err != nil {
+			log.Crit("Failed to store trie sync frontier node", "err", err)
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to store trie sync frontier nodes", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	data, err := rlp.EncodeToBytes(&trieSyncFrontier{Root: root, Nodes: uint64(len(nodes))})
+	if err != nil {
+		return err
+	}
+	if err := batch.Put(trieSyncFrontierKey, data); err != nil {
+		log.Crit("Failed to store trie sync frontier", "err", err)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to store trie sync frontier", "err", err)
+	}
+	// Drop the nodes of a larger previous frontier, not referenced any more
+	for i := uint64(len(nodes)); i < prev; i++ {
+		db.Delete(trieSyncFrontierNodeKey(i))
+	}
+	return nil
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db ethdb.Putter, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
@@ -414,6 +544,20 @@ func WriteHeader(db ethdb.Putter, header *types.Header) error {
 	return nil
 }
 
+// WriteCheckpointHeader stages a header retrieved during a checkpoint sync, until
+// it is linked to the local chain and can be inserted.
+func WriteCheckpointHeader(db ethdb.Putter, header *types.Header) error {
+	data, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return err
+	}
+	key := append(append([]byte{}, checkpointHeaderPrefix...), encodeBlockNumber(header.Number.Uint64())...)
+	if err := db.Put(key, data); err != nil {
+		log.Crit("Failed to store staged header", "err", err)
+	}
+	return nil
+}
+
 // WriteBody serializes the body of a block into the database.
 func WriteBody(db ethdb.Putter, hash common.Hash, number uint64, body *types.Body) error {
 	data, err := rlp.EncodeToBytes(body)
@@ -513,6 +657,21 @@ func WriteBloomBits(db ethdb.Putter, bit uint, section uint64, head common.Hash,
 	}
 }
 
+// WriteLogIndex writes the block offsets within the given section that contain
+// logs belonging to the given posting list key.
+func WriteLogIndex(db ethdb.Putter, key common.Hash, section uint64, head common.Hash, offsets []byte) {
+	if err := db.Put(logIndexKey(key, section, head), offsets); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
+
+// logIndexKey = logIndexPrefix + section (uint64 big endian) + head + key
+func logIndexKey(key common.Hash, section uint64, head common.Hash) []byte {
+	enc := append(append(append(logIndexPrefix, make([]byte, 8)...), head.Bytes()...), key.Bytes()...)
+	binary.BigEndian.PutUint64(enc[1:], section)
+	return enc
+}
+
 // DeleteCanonicalHash removes the number to hash canonical mapping.
 func DeleteCanonicalHash(db DatabaseDeleter, number uint64) {
 	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), numSuffix...))
@@ -524,6 +683,21 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
 }
 
+// DeleteTrieSyncFrontier removes the frontier of a finished state trie sync,
+// along with all its stored nodes.
+func DeleteTrieSyncFrontier(db ethdb.Database) {
+	_, count := getTrieSyncFrontierMeta(db)
+	for i := uint64(0); i < count; i++ {
+		db.Delete(trieSyncFrontierNodeKey(i))
+	}
+	db.Delete(trieSyncFrontierKey)
+}
+
+// DeleteCheckpointHeader removes a staged checkpoint sync header.
+func DeleteCheckpointHeader(db DatabaseDeleter, number uint64) {
+	db.Delete(append(append([]byte{}, checkpointHeaderPrefix...), encodeBlockNumber(number)...))
+}
+
 // DeleteBody removes all block body data associated with a hash.
 func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 	db.Delete(append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
@@ -286,6 +286,60 @@ func TestHeadStorage(t *testing.T) {
 	}
 }
 
+// Tests that the state trie sync frontier is stored node by node, and that its
+// nodes are removed when it's replaced or deleted.
+func TestTrieSyncFrontierStorage(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+
+	if root, nodes := GetTrieSyncFrontier(db); root != (common.Hash{}) || nodes != nil {
+		t.Fatalf("Non frontier returned from pristine database: %x, %d nodes", root, len(nodes))
+	}
+	// Store a frontier spanning multiple batches and check it's retrievable
+	nodes := make([][]byte, 4)
+	for i := range nodes {
+		nodes[i] = bytes.Repeat([]byte{byte(i + 1)}, ethdb.IdealBatchSize/2)
+	}
+	root := common.HexToHash("0x01")
+	if err := WriteTrieSyncFrontier(db, root, nodes); err != nil {
+		t.Fatalf("Failed to write frontier: %v", err)
+	}
+	for i := range nodes {
+		if ok, _ := db.Has(trieSyncFrontierNodeKey(uint64(i))); !ok {
+			t.Fatalf("Frontier node %d not stored separately", i)
+		}
+	}
+	if have, stored := GetTrieSyncFrontier(db); have != root || len(stored) != len(nodes) {
+		t.Fatalf("Frontier mismatch: have %x with %d nodes, want %x with %d nodes", have, len(stored), root, len(nodes))
+	} else {
+		for i := range nodes {
+			if !bytes.Equal(stored[i], nodes[i]) {
+				t.Fatalf("Frontier node %d mismatch", i)
+			}
+		}
+	}
+	// Replace the frontier with a smaller one and check the stale nodes are dropped
+	root = common.HexToHash("0x02")
+	if err := WriteTrieSyncFrontier(db, root, nodes[:1]); err != nil {
+		t.Fatalf("Failed to rewrite frontier: %v", err)
+	}
+	if have, stored := GetTrieSyncFrontier(db); have != root || len(stored) != 1 || !bytes.Equal(stored[0], nodes[0]) {
+		t.Fatalf("Rewritten frontier mismatch: have %x with %d nodes, want %x with 1 node", have, len(stored), root)
+	}
+	for i := 1; i < len(nodes); i++ {
+		if ok, _ := db.Has(trieSyncFrontierNodeKey(uint64(i))); ok {
+			t.Fatalf("Stale frontier node %d not deleted", i)
+		}
+	}
+	// Delete the frontier and check nothing's left of it
+	DeleteTrieSyncFrontier(db)
+	if root, nodes := GetTrieSyncFrontier(db); root != (common.Hash{}) || nodes != nil {
+		t.Fatalf("Deleted frontier returned: %x, %d nodes", root, len(nodes))
+	}
+	if db.Len() != 0 {
+		t.Fatalf("Database entries left after deleting frontier: %d", db.Len())
+	}
+}
+
 // Tests that positional lookup metadata can be stored and retrieved.
 func TestLookupStorage(t *testing.T) {
 	db, _ := ethdb.NewMemDatabase()
@@ -24,6 +24,39 @@ import (
 // TxPreEvent is posted when a transaction enters the transaction pool.
 type TxPreEvent struct{ Tx *types.Transaction }
 
+// TxEventType is the kind of status change a transaction went through in the
+// transaction pool.
+type TxEventType string
+
+const (
+	TxEventAdded    TxEventType = "added"    // Transaction entered the pool
+	TxEventReplaced TxEventType = "replaced" // Transaction replaced an older one with the same nonce
+	TxEventPromoted TxEventType = "promoted" // Transaction became executable
+	TxEventDemoted  TxEventType = "demoted"  // Transaction became non-executable
+	TxEventDropped  TxEventType = "dropped"  // Transaction was removed from the pool
+	TxEventMined    TxEventType = "mined"    // Transaction was included in the chain head
+)
+
+// TxEventReason is the cause of a transaction being dropped from the pool.
+type TxEventReason string
+
+const (
+	TxReasonUnderpriced       TxEventReason = "underpriced"       // Outbid by better paying transactions
+	TxReasonNonceTooLow       TxEventReason = "nonceTooLow"       // Nonce already used by another included transaction
+	TxReasonInsufficientFunds TxEventReason = "insufficientFunds" // Sender cannot pay for the transaction any more
+	TxReasonAccountLimit      TxEventReason = "accountLimit"      // Sender exceeded its allowance of queued transactions
+	TxReasonLifetime          TxEventReason = "lifetime"          // Transaction was queued for too long
+	TxReasonOverflow          TxEventReason = "overflow"          // Pool exceeded its global capacity
+)
+
+// TxPoolEvent is posted when a transaction changes its status in the pool.
+type TxPoolEvent struct {
+	Type   TxEventType
+	Tx     *types.Transaction
+	Old    *types.Transaction // Transaction replaced by Tx (only for TxEventReplaced)
+	Reason TxEventReason      // Cause of the removal (only for TxEventDropped)
+}
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
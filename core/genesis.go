@@ -371,6 +371,17 @@ func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
 	}
 }
 
+// DeveloperInstantGenesisBlock returns the 'geth --dev --dev.instant' genesis
+// block, sealing blocks on demand instead of relying on a signer.
+func DeveloperInstantGenesisBlock(faucet common.Address) *Genesis {
+	config := *params.AllInstantProtocolChanges
+
+	genesis := DeveloperGenesisBlock(0, faucet)
+	genesis.Config = &config
+	genesis.ExtraData = nil
+	return genesis
+}
+
 func decodePrealloc(data string) GenesisAlloc {
 	var p []struct{ Addr, Balance *big.Int }
 	if err := rlp.NewStream(strings.NewReader(data), 0).Decode(&p); err != nil {
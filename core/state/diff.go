@@ -0,0 +1,213 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// BalanceDiff is the balance of an account before and after a state transition.
+type BalanceDiff struct {
+	From *hexutil.Big `json:"from"`
+	To   *hexutil.Big `json:"to"`
+}
+
+// NonceDiff is the nonce of an account before and after a state transition.
+type NonceDiff struct {
+	From hexutil.Uint64 `json:"from"`
+	To   hexutil.Uint64 `json:"to"`
+}
+
+// CodeDiff is the code of an account before and after a state transition.
+type CodeDiff struct {
+	From hexutil.Bytes `json:"from"`
+	To   hexutil.Bytes `json:"to"`
+}
+
+// StorageDiff is the value of a storage slot before and after a state transition.
+type StorageDiff struct {
+	From common.Hash `json:"from"`
+	To   common.Hash `json:"to"`
+}
+
+// AccountDiff contains the modifications a state transition made to a single
+// account. Fields that were not modified are left nil.
+type AccountDiff struct {
+	Created bool `json:"created,omitempty"` // Account did not exist before the transition
+	Deleted bool `json:"deleted,omitempty"` // Account does not exist after the transition
+
+	Balance *BalanceDiff                `json:"balance,omitempty"`
+	Nonce   *NonceDiff                  `json:"nonce,omitempty"`
+	Code    *CodeDiff                   `json:"code,omitempty"`
+	Storage map[common.Hash]StorageDiff `json:"storage,omitempty"`
+}
+
+// accountOrigin collects the pre-transition values of a single account from
+// the journal. Only the first recorded value of each field is kept, since any
+// later one was produced by the transition itself.
+type accountOrigin struct {
+	reset bool         // Whether the account was (re)created during the transition
+	prev  *stateObject // Object replaced by the (re)creation, nil if none existed
+
+	balance *big.Int
+	nonce   *uint64
+	code    []byte
+	hasCode bool
+
+	storage map[common.Hash]common.Hash // Pre-transition values of the known slots
+	slots   map[common.Hash]struct{}    // All the slots written during the transition
+}
+
+// StateDiff returns the exact modifications done to the state since the journal
+// was last cleared, i.e. since the last call to Finalise, IntermediateRoot or
+// Commit. Reverted changes are not included. Storage slots are reported only if
+// they were written to, even for deleted accounts.
+//
+// The deleteEmptyObjects flag must match the one that will be passed to Finalise.
+// An account is reported as deleted only if it suicided, or if it was touched
+// while empty and Finalise will remove it as mandated by EIP158.
+func (self *StateDB) StateDiff(deleteEmptyObjects bool) map[common.Address]*AccountDiff {
+	// Walk the journal and gather the original value of every modified field
+	origins := make(map[common.Address]*accountOrigin)
+	origin := func(addr common.Address) *accountOrigin {
+		if o, ok := origins[addr]; ok {
+			return o
+		}
+		o := &accountOrigin{
+			storage: make(map[common.Hash]common.Hash),
+			slots:   make(map[common.Hash]struct{}),
+		}
+		origins[addr] = o
+		return o
+	}
+	for _, entry := range self.journal.entries {
+		switch ch := entry.(type) {
+		case createObjectChange:
+			origin(*ch.account).reset = true
+		case touchChange:
+			origin(*ch.account)
+		case resetObjectChange:
+			if o := origin(ch.prev.address); !o.reset {
+				o.reset, o.prev = true, ch.prev
+			}
+		case suicideChange:
+			if o := origin(*ch.account); !o.reset && o.balance == nil {
+				o.balance = ch.prevbalance
+			}
+		case balanceChange:
+			if o := origin(*ch.account); !o.reset && o.balance == nil {
+				o.balance = ch.prev
+			}
+		case nonceChange:
+			if o := origin(*ch.account); !o.reset && o.nonce == nil {
+				nonce := ch.prev
+				o.nonce = &nonce
+			}
+		case codeChange:
+			if o := origin(*ch.account); !o.reset && !o.hasCode {
+				o.code, o.hasCode = ch.prevcode, true
+			}
+		case storageChange:
+			o := origin(*ch.account)
+			if _, ok := o.slots[ch.key]; !ok {
+				o.slots[ch.key] = struct{}{}
+				if !o.reset {
+					o.storage[ch.key] = ch.prevalue
+				}
+			}
+		}
+	}
+	// Compare the original values against the live ones
+	diff := make(map[common.Address]*AccountDiff)
+	for addr, o := range origins {
+		obj := self.stateObjects[addr]
+		if obj == nil {
+			continue
+		}
+		// Mirror the deletion rules of Finalise, which only considers the dirty accounts
+		_, dirty := self.journal.dirties[addr]
+		deleted := obj.suicided || (deleteEmptyObjects && dirty && obj.empty())
+		created := o.reset && o.prev == nil
+		if created && deleted {
+			continue
+		}
+		// Fields not modified by the transition still hold their original values in
+		// the live object, unless the account was replaced altogether
+		base := obj
+		if o.reset {
+			base = o.prev
+		}
+		var (
+			preBalance, postBalance = new(big.Int), new(big.Int)
+			preNonce, postNonce     uint64
+			preCode, postCode       []byte
+		)
+		if base != nil {
+			preBalance, preNonce = base.Balance(), base.Nonce()
+			if !o.hasCode {
+				preCode = base.Code(self.db)
+			}
+		}
+		if o.balance != nil {
+			preBalance = o.balance
+		}
+		if o.nonce != nil {
+			preNonce = *o.nonce
+		}
+		if o.hasCode {
+			preCode = o.code
+		}
+		if !deleted {
+			postBalance, postNonce, postCode = obj.Balance(), obj.Nonce(), obj.Code(self.db)
+		}
+		// Assemble the account diff from the fields that actually changed
+		account := &AccountDiff{Created: created, Deleted: deleted && !created}
+		if preBalance.Cmp(postBalance) != 0 {
+			account.Balance = &BalanceDiff{From: (*hexutil.Big)(preBalance), To: (*hexutil.Big)(postBalance)}
+		}
+		if preNonce != postNonce {
+			account.Nonce = &NonceDiff{From: hexutil.Uint64(preNonce), To: hexutil.Uint64(postNonce)}
+		}
+		if !bytes.Equal(preCode, postCode) {
+			account.Code = &CodeDiff{From: preCode, To: postCode}
+		}
+		for key := range o.slots {
+			pre, ok := o.storage[key]
+			if !ok && o.prev != nil {
+				pre = o.prev.GetState(self.db, key)
+			}
+			var post common.Hash
+			if !deleted {
+				post = obj.GetState(self.db, key)
+			}
+			if pre != post {
+				if account.Storage == nil {
+					account.Storage = make(map[common.Hash]StorageDiff)
+				}
+				account.Storage[key] = StorageDiff{From: pre, To: post}
+			}
+		}
+		if account.Created || account.Deleted || account.Balance != nil || account.Nonce != nil || account.Code != nil || account.Storage != nil {
+			diff[addr] = account
+		}
+	}
+	return diff
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the state diff reports the original and final values of all the
+// modified fields, ignoring reverted and no-op changes.
+func TestStateDiff(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	var (
+		sender   = common.BytesToAddress([]byte{0x01})
+		contract = common.BytesToAddress([]byte{0x02})
+		fresh    = common.BytesToAddress([]byte{0x03})
+		reverted = common.BytesToAddress([]byte{0x04})
+		slot     = common.BytesToHash([]byte{0x01})
+		noop     = common.BytesToHash([]byte{0x02})
+	)
+	// Create a base state and flush it out of the journal
+	state.SetBalance(sender, big.NewInt(100))
+	state.SetNonce(sender, 1)
+	state.SetCode(contract, []byte{0x60, 0x00})
+	state.SetState(contract, slot, common.HexToHash("0xaa"))
+	state.SetState(contract, noop, common.HexToHash("0xbb"))
+	root, _ := state.Commit(false)
+	state.Reset(root)
+
+	// Run a few modifications, some of which cancel out or get reverted
+	state.SubBalance(sender, big.NewInt(30))
+	state.SubBalance(sender, big.NewInt(20))
+	state.SetNonce(sender, 2)
+	state.SetState(contract, slot, common.HexToHash("0xcc"))
+	state.SetState(contract, noop, common.HexToHash("0xdd"))
+	state.SetState(contract, noop, common.HexToHash("0xbb"))
+	state.AddBalance(fresh, big.NewInt(50))
+
+	snapshot := state.Snapshot()
+	state.AddBalance(reverted, big.NewInt(1))
+	state.SetState(contract, slot, common.HexToHash("0xee"))
+	state.RevertToSnapshot(snapshot)
+
+	diff := state.StateDiff(true)
+	if len(diff) != 3 {
+		t.Fatalf("modified account count mismatch: have %d, want %d", len(diff), 3)
+	}
+	if acc := diff[sender]; acc.Balance == nil || acc.Balance.From.ToInt().Int64() != 100 || acc.Balance.To.ToInt().Int64() != 50 {
+		t.Errorf("sender balance mismatch: have %+v, want 100 -> 50", acc.Balance)
+	}
+	if acc := diff[sender]; acc.Nonce == nil || acc.Nonce.From != 1 || acc.Nonce.To != 2 {
+		t.Errorf("sender nonce mismatch: have %+v, want 1 -> 2", acc.Nonce)
+	}
+	if acc := diff[contract]; acc.Balance != nil || acc.Nonce != nil || acc.Code != nil {
+		t.Errorf("contract account fields modified: %+v", acc)
+	}
+	if acc := diff[contract]; len(acc.Storage) != 1 || acc.Storage[slot] != (StorageDiff{common.HexToHash("0xaa"), common.HexToHash("0xcc")}) {
+		t.Errorf("contract storage mismatch: have %+v, want single slot 0xaa -> 0xcc", acc.Storage)
+	}
+	if acc := diff[fresh]; !acc.Created || acc.Balance == nil || acc.Balance.From.ToInt().Sign() != 0 || acc.Balance.To.ToInt().Int64() != 50 {
+		t.Errorf("fresh account mismatch: have %+v, want created with 0 -> 50 balance", acc)
+	}
+	// Ensure finalising the state clears the diff
+	state.Finalise(true)
+	if diff := state.StateDiff(true); len(diff) != 0 {
+		t.Errorf("diff not cleared after finalise: %v", diff)
+	}
+}
+
+// Tests that suicided accounts are reported as deleted with their last known values.
+func TestStateDiffSuicide(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.BytesToAddress([]byte{0x01})
+	state.SetBalance(addr, big.NewInt(10))
+	state.SetCode(addr, []byte{0x00})
+	root, _ := state.Commit(false)
+	state.Reset(root)
+
+	state.Suicide(addr)
+
+	acc := state.StateDiff(true)[addr]
+	if acc == nil || !acc.Deleted {
+		t.Fatalf("account not reported as deleted: %+v", acc)
+	}
+	if acc.Balance == nil || acc.Balance.From.ToInt().Int64() != 10 || acc.Balance.To.ToInt().Sign() != 0 {
+		t.Errorf("balance mismatch: have %+v, want 10 -> 0", acc.Balance)
+	}
+	if acc.Code == nil || len(acc.Code.From) != 1 || len(acc.Code.To) != 0 {
+		t.Errorf("code mismatch: have %+v, want 0x00 -> empty", acc.Code)
+	}
+}
+
+// Tests that touched empty accounts are only reported as deleted if EIP158 removes
+// them, and that missing accounts touched under EIP158 are not reported at all.
+func TestStateDiffTouchedEmpty(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	state, _ := New(common.Hash{}, NewDatabase(db))
+
+	var (
+		empty   = common.BytesToAddress([]byte{0x01})
+		missing = common.BytesToAddress([]byte{0x02})
+		drained = common.BytesToAddress([]byte{0x03})
+	)
+	// Create a pre-EIP158 state with an empty account in it
+	state.CreateAccount(empty)
+	state.SetBalance(drained, big.NewInt(10))
+	root, _ := state.Commit(false)
+
+	// Without EIP158, touching empty accounts keeps them (or creates them)
+	state.Reset(root)
+	state.AddBalance(empty, new(big.Int))
+	state.AddBalance(missing, new(big.Int))
+
+	diff := state.StateDiff(false)
+	if acc := diff[empty]; acc != nil {
+		t.Errorf("touched empty account reported without EIP158: %+v", acc)
+	}
+	if acc := diff[missing]; acc == nil || !acc.Created || acc.Deleted {
+		t.Errorf("touched missing account mismatch: have %+v, want created", acc)
+	}
+	// With EIP158, only the existing empty account is removed
+	state.Reset(root)
+	state.AddBalance(empty, new(big.Int))
+	state.AddBalance(missing, new(big.Int))
+	state.SubBalance(drained, big.NewInt(10))
+
+	diff = state.StateDiff(true)
+	if acc := diff[empty]; acc == nil || !acc.Deleted || acc.Created {
+		t.Errorf("touched empty account mismatch: have %+v, want deleted", acc)
+	}
+	if acc := diff[missing]; acc != nil {
+		t.Errorf("touched missing account reported: %+v", acc)
+	}
+	if acc := diff[drained]; acc == nil || !acc.Deleted || acc.Balance == nil || acc.Balance.From.ToInt().Int64() != 10 {
+		t.Errorf("drained account mismatch: have %+v, want deleted with 10 -> 0 balance", acc)
+	}
+	// Touches reverted along with the rest of the call are not reported either
+	state.Reset(root)
+	snapshot := state.Snapshot()
+	state.AddBalance(empty, new(big.Int))
+	state.RevertToSnapshot(snapshot)
+	if diff := state.StateDiff(true); len(diff) != 0 {
+		t.Errorf("reverted touch reported: %v", diff)
+	}
+}
@@ -474,6 +474,16 @@ func (self *StateDB) Copy() *StateDB {
 		state.stateObjects[addr] = self.stateObjects[addr].deepCopy(state)
 		state.stateObjectsDirty[addr] = struct{}{}
 	}
+	// Above, we don't copy the actual journal. This means that objects finalised
+	// since the last commit (and copies of copies) are only tracked as dirty
+	// objects, with their changes not yet flushed to the database. Carry them over
+	// too, otherwise the copy would silently revert them to their committed state.
+	for addr := range self.stateObjectsDirty {
+		if _, exist := state.stateObjects[addr]; !exist {
+			state.stateObjects[addr] = self.stateObjects[addr].deepCopy(state)
+			state.stateObjectsDirty[addr] = struct{}{}
+		}
+	}
 	for hash, logs := range self.logs {
 		state.logs[hash] = make([]*types.Log, len(logs))
 		copy(state.logs[hash], logs)
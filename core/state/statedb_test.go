@@ -168,6 +168,32 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+// Tests that copying a statedb carries over the objects finalised since the last
+// commit, whose changes are not yet flushed to the database.
+func TestCopyFinalisedObjects(t *testing.T) {
+	db, _ := ethdb.NewMemDatabase()
+	orig, _ := New(common.Hash{}, NewDatabase(db))
+
+	addr := common.BytesToAddress([]byte{0x01})
+	slot := common.BytesToHash([]byte{0x01})
+
+	orig.SetBalance(addr, big.NewInt(1))
+	orig.SetState(addr, slot, common.HexToHash("0xaa"))
+	orig.Finalise(true)
+
+	copy := orig.Copy()
+	if balance := copy.GetBalance(addr); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("copied balance mismatch: have %v, want 1", balance)
+	}
+	if value := copy.GetState(addr, slot); value != common.HexToHash("0xaa") {
+		t.Fatalf("copied storage mismatch: have %x, want 0xaa", value)
+	}
+	// Copies of copies must retain the finalised objects too
+	if root, want := copy.Copy().IntermediateRoot(true), orig.IntermediateRoot(true); root != want {
+		t.Fatalf("copied state root mismatch: have %x, want %x", root, want)
+	}
+}
+
 func TestSnapshotRandom(t *testing.T) {
 	config := &quick.Config{MaxCount: 1000}
 	err := quick.Check((*snapshotTest).run, config)
@@ -0,0 +1,156 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// poolPressureThreshold is the percentage of the global pool capacity above which
+// remote transactions need to pay the configured premium over the pool floor.
+const poolPressureThreshold = 80
+
+// tokenBucket is a per sender admission allowance, refilled linearly over time.
+type tokenBucket struct {
+	tokens float64   // Number of transactions the sender may still submit
+	last   time.Time // Time the bucket was last refilled
+}
+
+// senderLimiter rate limits the transactions admitted from individual senders
+// using a token bucket per account.
+type senderLimiter struct {
+	rate     float64       // Number of tokens refilled per interval (and bucket capacity)
+	interval time.Duration // Time interval to fully refill a bucket in
+	buckets  map[common.Address]*tokenBucket
+}
+
+// newSenderLimiter creates a limiter allowing rate transactions per interval from
+// any single sender.
+func newSenderLimiter(rate uint64, interval time.Duration) *senderLimiter {
+	return &senderLimiter{
+		rate:     float64(rate),
+		interval: interval,
+		buckets:  make(map[common.Address]*tokenBucket),
+	}
+}
+
+// allow refills the sender's bucket and checks whether it holds a token, returning
+// whether the transaction may be admitted. The token is not consumed until take
+// is called, so transactions failing later checks don't use up the allowance.
+func (l *senderLimiter) allow(addr common.Address, now time.Time) bool {
+	bucket := l.buckets[addr]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: l.rate, last: now}
+		l.buckets[addr] = bucket
+	}
+	bucket.tokens += l.rate * float64(now.Sub(bucket.last)) / float64(l.interval)
+	if bucket.tokens > l.rate {
+		bucket.tokens = l.rate
+	}
+	bucket.last = now
+
+	return bucket.tokens >= 1
+}
+
+// take consumes a token from the sender's bucket, previously checked by allow.
+func (l *senderLimiter) take(addr common.Address) {
+	if bucket := l.buckets[addr]; bucket != nil && bucket.tokens >= 1 {
+		bucket.tokens--
+	}
+}
+
+// prune drops all the buckets that were fully refilled since their last use, as
+// they are indistinguishable from fresh ones.
+func (l *senderLimiter) prune(now time.Time) {
+	for addr, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= l.interval {
+			delete(l.buckets, addr)
+		}
+	}
+}
+
+// admit checks a remote transaction against the configured admission policies:
+// the per recipient pool cap, the price premium over the pool floor when the
+// pool is under pressure and the sender rate limit. The rate limit is checked
+// last, its token only being taken once the transaction is actually pooled.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) admit(from common.Address, tx *types.Transaction) error {
+	// Ensure the recipient contract doesn't hog the pool
+	if to := tx.To(); to != nil && pool.config.RecipientSlots > 0 && pool.currentState.GetCodeSize(*to) > 0 {
+		if pool.recipients[*to] >= pool.config.RecipientSlots {
+			recipientCappedTxCounter.Inc(1)
+			return ErrRecipientCapped
+		}
+	}
+	// Ensure the transaction pays the premium if the pool is filling up
+	if pool.config.PricePremium > 0 {
+		capacity := pool.config.GlobalSlots + pool.config.GlobalQueue
+		if uint64(len(pool.all))*100 >= capacity*poolPressureThreshold {
+			if cheapest := pool.priced.Cheapest(); cheapest != nil {
+				floor := new(big.Int).Mul(cheapest.GasPrice(), big.NewInt(int64(100+pool.config.PricePremium)))
+				if new(big.Int).Mul(tx.GasPrice(), big.NewInt(100)).Cmp(floor) < 0 {
+					premiumTxCounter.Inc(1)
+					return ErrPremiumUnderpriced
+				}
+			}
+		}
+	}
+	// Ensure the sender is within its rate allowance
+	if pool.limiter != nil && !pool.limiter.allow(from, time.Now()) {
+		rateLimitedTxCounter.Inc(1)
+		return ErrSenderRateLimited
+	}
+	return nil
+}
+
+// storeTx inserts a transaction into the set of all known ones, counting it
+// towards the allowance of its recipient. Storing an already known transaction
+// (e.g. one demoted back into the queue) is a noop.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) storeTx(tx *types.Transaction) {
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		return
+	}
+	pool.all[hash] = tx
+	if to := tx.To(); to != nil {
+		pool.recipients[*to]++
+	}
+}
+
+// forgetTx deletes a transaction from the set of all known ones, releasing its
+// slot from the allowance of its recipient.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) forgetTx(hash common.Hash) {
+	tx := pool.all[hash]
+	if tx == nil {
+		return
+	}
+	delete(pool.all, hash)
+	if to := tx.To(); to != nil {
+		if pool.recipients[*to]--; pool.recipients[*to] == 0 {
+			delete(pool.recipients, *to)
+		}
+	}
+}
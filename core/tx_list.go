@@ -461,6 +461,18 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 	if local.containsTx(tx) {
 		return false
 	}
+	// Check if the transaction is underpriced or not
+	cheapest := l.Cheapest()
+	if cheapest == nil {
+		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
+		return false
+	}
+	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+}
+
+// Cheapest returns the lowest priced transaction in the pool, or nil if the pool
+// is empty.
+func (l *txPricedList) Cheapest() *types.Transaction {
 	// Discard stale price points if found at the heap start
 	for len(*l.items) > 0 {
 		head := []*types.Transaction(*l.items)[0]
@@ -469,15 +481,9 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 			heap.Pop(l.items)
 			continue
 		}
-		break
-	}
-	// Check if the transaction is underpriced or not
-	if len(*l.items) == 0 {
-		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
-		return false
+		return head
 	}
-	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	return nil
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
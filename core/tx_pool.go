@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"os"
 	"sort"
 	"sync"
 	"time"
@@ -78,13 +79,30 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrSenderRateLimited is returned if a remote sender exceeds its allowance
+	// of transactions per time interval.
+	ErrSenderRateLimited = errors.New("sender rate limited")
+
+	// ErrRecipientCapped is returned if a transaction targets a contract which
+	// already has the maximum number of pooled transactions sent to it.
+	ErrRecipientCapped = errors.New("recipient pool limit reached")
+
+	// ErrPremiumUnderpriced is returned if a transaction doesn't pay the required
+	// premium over the cheapest pooled transaction while the pool is filling up.
+	ErrPremiumUnderpriced = errors.New("transaction underpriced for pool pressure")
 )
 
 var (
 	evictionInterval    = time.Minute     // Time interval to check for evictable transactions
+	privateRetention    = time.Hour       // Time to remember private transactions after leaving the pool
 	statsReportInterval = 8 * time.Second // Time interval to report transaction pool stats
 )
 
+// maxQueuedEvents is the maximum number of status change events buffered for
+// slow subscribers before new ones are discarded.
+const maxQueuedEvents = 4096
+
 var (
 	// Metrics for the pending pool
 	pendingDiscardCounter   = metrics.NewRegisteredCounter("txpool/pending/discard", nil)
@@ -101,6 +119,14 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+
+	// Metrics for the admission policies
+	rateLimitedTxCounter     = metrics.NewRegisteredCounter("txpool/admission/ratelimited", nil)
+	recipientCappedTxCounter = metrics.NewRegisteredCounter("txpool/admission/recipientcap", nil)
+	premiumTxCounter         = metrics.NewRegisteredCounter("txpool/admission/premium", nil)
+
+	// Metrics for the status change events
+	droppedEventCounter = metrics.NewRegisteredCounter("txpool/events/dropped", nil) // Discarded due to a full event queue
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -128,6 +154,7 @@ type TxPoolConfig struct {
 	NoLocals  bool          // Whether local transaction handling should be disabled
 	Journal   string        // Journal of local transactions to survive node restarts
 	Rejournal time.Duration // Time interval to regenerate the local transaction journal
+	Snapshot  string        // Snapshot of remote transactions to survive node restarts (empty = disabled)
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -138,6 +165,11 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	SenderRate     uint64        // Maximum number of remote transactions admitted per sender per interval (0 = unlimited)
+	SenderInterval time.Duration // Time interval over which the sender rate allowance is refilled
+	RecipientSlots uint64        // Maximum number of pooled transactions per recipient contract, admitting remote ones (0 = unlimited)
+	PricePremium   uint64        // Minimum price premium percentage over the pool floor required under pressure (0 = none)
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -155,6 +187,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	SenderInterval: time.Minute,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -173,6 +207,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool price bump", "provided", conf.PriceBump, "updated", DefaultTxPoolConfig.PriceBump)
 		conf.PriceBump = DefaultTxPoolConfig.PriceBump
 	}
+	if conf.SenderRate > 0 && conf.SenderInterval < time.Second {
+		log.Warn("Sanitizing invalid txpool sender interval", "provided", conf.SenderInterval, "updated", time.Second)
+		conf.SenderInterval = time.Second
+	}
 	return conf
 }
 
@@ -189,6 +227,7 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	eventFeed    event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
@@ -202,11 +241,20 @@ type TxPool struct {
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
-	pending map[common.Address]*txList         // All currently processable transactions
-	queue   map[common.Address]*txList         // Queued but non-processable transactions
-	beats   map[common.Address]time.Time       // Last heartbeat from each known account
-	all     map[common.Hash]*types.Transaction // All transactions to allow lookups
-	priced  *txPricedList                      // All transactions sorted by price
+	pending    map[common.Address]*txList         // All currently processable transactions
+	queue      map[common.Address]*txList         // Queued but non-processable transactions
+	beats      map[common.Address]time.Time       // Last heartbeat from each known account
+	all        map[common.Hash]*types.Transaction // All transactions to allow lookups
+	recipients map[common.Address]uint64          // Number of pooled transactions per recipient
+	priced     *txPricedList                      // All transactions sorted by price
+	private    map[common.Hash]time.Time          // Transactions not to be propagated to untrusted peers, last seen in the pool
+	limiter    *senderLimiter                     // Rate limiter of remote senders (nil = unlimited)
+
+	eventQueue []TxPoolEvent // Status change events not yet delivered, in order
+	eventLock  sync.Mutex    // Protects the event queue, independent of the pool lock
+	eventWake  chan struct{} // Notification channel for newly queued events
+
+	mined map[common.Hash]struct{} // Transactions included by the chain head being reset to
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -229,10 +277,16 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         make(map[common.Hash]*types.Transaction),
+		recipients:  make(map[common.Address]uint64),
+		private:     make(map[common.Hash]time.Time),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		eventWake:   make(chan struct{}, 1),
 	}
 	pool.locals = newAccountSet(pool.signer)
+	if config.SenderRate > 0 {
+		pool.limiter = newSenderLimiter(config.SenderRate, config.SenderInterval)
+	}
 	pool.priced = newTxPricedList(&pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
@@ -247,12 +301,17 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote transaction snapshotting is enabled, reload and revalidate them
+	if config.Snapshot != "" {
+		pool.loadSnapshot()
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
-	// Start the event loop and return
-	pool.wg.Add(1)
+	// Start the event loops and return
+	pool.wg.Add(2)
 	go pool.loop()
+	go pool.eventLoop()
 
 	return pool
 }
@@ -320,10 +379,16 @@ func (pool *TxPool) loop() {
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
-						pool.removeTx(tx.Hash())
+						pool.dropTx(tx.Hash(), TxReasonLifetime)
 					}
 				}
 			}
+			// Forget about the rate limits of senders with full allowance
+			if pool.limiter != nil {
+				pool.limiter.prune(time.Now())
+			}
+			// Forget about private transactions that left the pool long ago
+			pool.prunePrivate(time.Now())
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
@@ -339,6 +404,56 @@ func (pool *TxPool) loop() {
 	}
 }
 
+// eventLoop delivers the queued transaction status change events to the event
+// subscribers in order, without blocking the pool on slow consumers.
+func (pool *TxPool) eventLoop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case <-pool.eventWake:
+			pool.eventLock.Lock()
+			events := pool.eventQueue
+			pool.eventQueue = nil
+			pool.eventLock.Unlock()
+
+			for _, ev := range events {
+				pool.eventFeed.Send(ev)
+			}
+		case <-pool.chainHeadSub.Err():
+			return
+		}
+	}
+}
+
+// notify queues a transaction status change event for delivery to subscribers.
+// If the subscribers fall too far behind, the event is discarded.
+func (pool *TxPool) notify(typ TxEventType, tx *types.Transaction, old *types.Transaction, reason TxEventReason) {
+	pool.eventLock.Lock()
+	if len(pool.eventQueue) >= maxQueuedEvents {
+		pool.eventLock.Unlock()
+		droppedEventCounter.Inc(1)
+		return
+	}
+	pool.eventQueue = append(pool.eventQueue, TxPoolEvent{Type: typ, Tx: tx, Old: old, Reason: reason})
+	pool.eventLock.Unlock()
+
+	select {
+	case pool.eventWake <- struct{}{}:
+	default:
+	}
+}
+
+// notifyStale reports a transaction dropped due to its nonce being used up,
+// either as mined if the new chain head included it, or as dropped otherwise.
+func (pool *TxPool) notifyStale(tx *types.Transaction) {
+	if _, ok := pool.mined[tx.Hash()]; ok {
+		pool.notify(TxEventMined, tx, nil, "")
+		return
+	}
+	pool.notify(TxEventDropped, tx, nil, TxReasonNonceTooLow)
+}
+
 // lockedReset is a wrapper around reset to allow calling it in a thread safe
 // manner. This method is only ever used in the tester!
 func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
@@ -352,7 +467,7 @@ func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
 // of the transaction pool is valid with regard to the chain state.
 func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// If we're reorging an old state, reinject all dropped transactions
-	var reinject types.Transactions
+	var reinject, included types.Transactions
 
 	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
 		// If the reorg is too deep, avoid doing it (will happen during fast sync)
@@ -363,7 +478,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 			log.Debug("Skipping deep transaction reorg", "depth", depth)
 		} else {
 			// Reorg seems shallow enough to pull in all transactions into memory
-			var discarded, included types.Transactions
+			var discarded types.Transactions
 
 			var (
 				rem = pool.chain.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
@@ -397,7 +512,18 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 			}
 			reinject = types.TxDifference(discarded, included)
 		}
+	} else if oldHead != nil {
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			included = block.Transactions()
+		}
 	}
+	// Track the newly included transactions to report them as mined
+	pool.mined = make(map[common.Hash]struct{}, len(included))
+	for _, tx := range included {
+		pool.mined[tx.Hash()] = struct{}{}
+	}
+	defer func() { pool.mined = nil }()
+
 	// Initialize the internal state to the current head
 	if newHead == nil {
 		newHead = pool.chain.CurrentBlock().Header() // Special case during testing
@@ -413,7 +539,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsUnmetered(reinject)
 
 	// validate the pool of pending transactions, this will remove
 	// any transactions that have been included in the block or
@@ -443,15 +569,87 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.config.Snapshot != "" {
+		pool.saveSnapshot()
+	}
 	log.Info("Transaction pool stopped")
 }
 
+// saveSnapshot dumps the remote transactions of the pool to disk, bounded by the
+// global pending and queued limits. Local transactions are covered by the journal,
+// and private ones are never persisted.
+func (pool *TxPool) saveSnapshot() {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var txs types.Transactions
+	gather := func(lists map[common.Address]*txList, limit uint64) {
+		count := uint64(0)
+		for addr, list := range lists {
+			if pool.locals.contains(addr) {
+				continue
+			}
+			for _, tx := range list.Flatten() {
+				if count >= limit {
+					return
+				}
+				if _, ok := pool.private[tx.Hash()]; ok {
+					break // Skip the rest to avoid nonce gaps
+				}
+				txs = append(txs, tx)
+				count++
+			}
+		}
+	}
+	gather(pool.pending, pool.config.GlobalSlots)
+	gather(pool.queue, pool.config.GlobalQueue)
+
+	if err := saveTxSnapshot(pool.config.Snapshot, txs); err != nil {
+		log.Warn("Failed to save transaction pool snapshot", "err", err)
+		return
+	}
+	log.Info("Saved transaction pool snapshot", "transactions", len(txs))
+}
+
+// loadSnapshot reinjects the remote transactions persisted on the last shutdown,
+// revalidating them against the current state. The snapshot is deleted after
+// loading, so a crash doesn't reinject stale transactions on the next startup.
+func (pool *TxPool) loadSnapshot() {
+	txs, err := loadTxSnapshot(pool.config.Snapshot, int(pool.config.GlobalSlots+pool.config.GlobalQueue))
+	if err != nil {
+		log.Warn("Failed to load transaction pool snapshot", "err", err)
+	}
+	if len(txs) == 0 {
+		return
+	}
+	pool.mu.Lock()
+	errs := pool.addTxsUnmetered(txs)
+	pool.mu.Unlock()
+
+	dropped := 0
+	for _, err := range errs {
+		if err != nil {
+			dropped++
+		}
+	}
+	if err := os.Remove(pool.config.Snapshot); err != nil {
+		log.Warn("Failed to delete transaction pool snapshot", "err", err)
+	}
+	log.Info("Loaded transaction pool snapshot", "transactions", len(txs), "dropped", dropped)
+}
+
 // SubscribeTxPreEvent registers a subscription of TxPreEvent and
 // starts sending event to the given channel.
 func (pool *TxPool) SubscribeTxPreEvent(ch chan<- TxPreEvent) event.Subscription {
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeTxPoolEvents registers a subscription of TxPoolEvent, reporting all
+// the status changes of the transactions in the pool.
+func (pool *TxPool) SubscribeTxPoolEvents(ch chan<- TxPoolEvent) event.Subscription {
+	return pool.scope.Track(pool.eventFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -468,7 +666,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
-		pool.removeTx(tx.Hash())
+		pool.dropTx(tx.Hash(), TxReasonUnderpriced)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
@@ -535,6 +733,18 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// Locals retrieves the accounts currently considered local by the pool.
+func (pool *TxPool) Locals() []common.Address {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	locals := make([]common.Address, 0, len(pool.locals.accounts))
+	for addr := range pool.locals.accounts {
+		locals = append(locals, addr)
+	}
+	return locals
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -617,6 +827,17 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		invalidTxCounter.Inc(1)
 		return false, err
 	}
+	// If the remote transaction violates the admission policies, discard it
+	from, _ := types.Sender(pool.signer, tx) // already validated
+
+	metered := false
+	if !local && !pool.locals.contains(from) {
+		if err := pool.admit(from, tx); err != nil {
+			log.Trace("Discarding inadmissible transaction", "hash", hash, "err", err)
+			return false, err
+		}
+		metered = pool.limiter != nil
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(len(pool.all)) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
@@ -630,11 +851,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
-			pool.removeTx(tx.Hash())
+			pool.dropTx(tx.Hash(), TxReasonUnderpriced)
 		}
 	}
 	// If the transaction is replacing an already pending one, do directly
-	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
 		inserted, old := list.Add(tx, pool.config.PriceBump)
@@ -644,14 +864,19 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		}
 		// New transaction is better, replace old one
 		if old != nil {
-			delete(pool.all, old.Hash())
+			pool.forgetTx(old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+
+			pool.notify(TxEventReplaced, tx, old, "")
 		}
-		pool.all[tx.Hash()] = tx
+		pool.storeTx(tx)
 		pool.priced.Put(tx)
 		pool.journalTx(from, tx)
 
+		if metered {
+			pool.limiter.take(from)
+		}
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// We've directly injected a replacement transaction, notify subsystems
@@ -670,6 +895,12 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	}
 	pool.journalTx(from, tx)
 
+	if metered {
+		pool.limiter.take(from)
+	}
+	if !replace {
+		pool.notify(TxEventAdded, tx, nil, "")
+	}
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replace, nil
 }
@@ -691,22 +922,28 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.forgetTx(old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+
+		pool.notify(TxEventReplaced, tx, old, "")
 	}
-	pool.all[hash] = tx
+	pool.storeTx(tx)
 	pool.priced.Put(tx)
 	return old != nil, nil
 }
 
 // journalTx adds the specified transaction to the local disk journal if it is
-// deemed to have been sent from a local account.
+// deemed to have been sent from a local account. Private transactions are never
+// journaled, as they would be reinjected as public ones on startup.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	// Only journal if it's enabled and the transaction is local
 	if pool.journal == nil || !pool.locals.contains(from) {
 		return
 	}
+	if _, ok := pool.private[tx.Hash()]; ok {
+		return
+	}
 	if err := pool.journal.insert(tx); err != nil {
 		log.Warn("Failed to journal local transaction", "err", err)
 	}
@@ -725,28 +962,31 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	inserted, old := list.Add(tx, pool.config.PriceBump)
 	if !inserted {
 		// An older transaction was better, discard this
-		delete(pool.all, hash)
+		pool.forgetTx(hash)
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.notify(TxEventDropped, tx, nil, TxReasonUnderpriced)
 		return
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
-		delete(pool.all, old.Hash())
+		pool.forgetTx(old.Hash())
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.notify(TxEventReplaced, tx, old, "")
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all[hash] == nil {
-		pool.all[hash] = tx
+		pool.storeTx(tx)
 		pool.priced.Put(tx)
 	}
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.beats[addr] = time.Now()
 	pool.pendingState.SetNonce(addr, tx.Nonce()+1)
 
+	pool.notify(TxEventPromoted, tx, nil, "")
 	go pool.txFeed.Send(TxPreEvent{tx})
 }
 
@@ -757,6 +997,56 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return pool.addTx(tx, !pool.config.NoLocals)
 }
 
+// AddPrivate enqueues a single transaction into the pool if it is valid, marking
+// it as local and private. Private transactions are only included by the local
+// miner or propagated to trusted peers, never broadcast to the network at large.
+func (pool *TxPool) AddPrivate(tx *types.Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	// Mark the transaction private before any promotion announces it
+	hash := tx.Hash()
+	if pool.all[hash] != nil {
+		return fmt.Errorf("known transaction: %x", hash)
+	}
+	pool.private[hash] = time.Now()
+
+	replace, err := pool.add(tx, !pool.config.NoLocals)
+	if err != nil {
+		delete(pool.private, hash)
+		return err
+	}
+	if !replace {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		pool.promoteExecutables([]common.Address{from})
+	}
+	return nil
+}
+
+// prunePrivate forgets about the private transactions that left the pool more
+// than privateRetention ago. Marks are retained for a while after inclusion, so
+// that transactions reinjected by a chain reorg are not broadcast to the network.
+func (pool *TxPool) prunePrivate(now time.Time) {
+	for hash, seen := range pool.private {
+		switch {
+		case pool.all[hash] != nil:
+			pool.private[hash] = now
+		case now.Sub(seen) > privateRetention:
+			delete(pool.private, hash)
+		}
+	}
+}
+
+// IsPrivate returns whether a transaction was submitted privately, and should
+// thus not be propagated to untrusted peers.
+func (pool *TxPool) IsPrivate(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, ok := pool.private[hash]
+	return ok
+}
+
 // AddRemote enqueues a single transaction into the pool if it is valid. If the
 // sender is not among the locally tracked ones, full pricing constraints will
 // apply.
@@ -831,6 +1121,17 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
 	return errs
 }
 
+// addTxsUnmetered attempts to queue a batch of remote transactions already admitted
+// once (reorged out or snapshotted ones), bypassing the sender rate limits. It
+// assumes the transaction pool lock is already held.
+func (pool *TxPool) addTxsUnmetered(txs []*types.Transaction) []error {
+	limiter := pool.limiter
+	pool.limiter = nil
+	defer func() { pool.limiter = limiter }()
+
+	return pool.addTxsLocked(txs, false)
+}
+
 // Status returns the status (unknown/pending/queued) of a batch of transactions
 // identified by their hashes.
 func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
@@ -860,6 +1161,15 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all[hash]
 }
 
+// dropTx removes a single transaction from the pool, reporting the reason of
+// the removal to the event subscribers.
+func (pool *TxPool) dropTx(hash common.Hash, reason TxEventReason) {
+	if tx, ok := pool.all[hash]; ok {
+		pool.notify(TxEventDropped, tx, nil, reason)
+		pool.removeTx(hash)
+	}
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash) {
@@ -871,7 +1181,7 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 	addr, _ := types.Sender(pool.signer, tx) // already validated during insertion
 
 	// Remove it from the list of known transactions
-	delete(pool.all, hash)
+	pool.forgetTx(hash)
 	pool.priced.Removed()
 
 	// Remove the transaction from the pending lists and reset the account nonce
@@ -885,6 +1195,7 @@ func (pool *TxPool) removeTx(hash common.Hash) {
 			// Postpone any invalidated transactions
 			for _, tx := range invalids {
 				pool.enqueueTx(tx.Hash(), tx)
+				pool.notify(TxEventDemoted, tx, nil, "")
 			}
 			// Update the account nonce if needed
 			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -923,17 +1234,19 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		for _, tx := range list.Forward(pool.currentState.GetNonce(addr)) {
 			hash := tx.Hash()
 			log.Trace("Removed old queued transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.forgetTx(hash)
 			pool.priced.Removed()
+			pool.notifyStale(tx)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.forgetTx(hash)
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
+			pool.notify(TxEventDropped, tx, nil, TxReasonInsufficientFunds)
 		}
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
@@ -945,9 +1258,10 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		if !pool.locals.contains(addr) {
 			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
 				hash := tx.Hash()
-				delete(pool.all, hash)
+				pool.forgetTx(hash)
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
+				pool.notify(TxEventDropped, tx, nil, TxReasonAccountLimit)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 		}
@@ -990,8 +1304,9 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 						for _, tx := range list.Cap(list.Len() - 1) {
 							// Drop the transaction from the global pools too
 							hash := tx.Hash()
-							delete(pool.all, hash)
+							pool.forgetTx(hash)
 							pool.priced.Removed()
+							pool.notify(TxEventDropped, tx, nil, TxReasonOverflow)
 
 							// Update the account nonce to the dropped transaction
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i]) > nonce {
@@ -1012,8 +1327,9 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 					for _, tx := range list.Cap(list.Len() - 1) {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
-						delete(pool.all, hash)
+						pool.forgetTx(hash)
 						pool.priced.Removed()
+						pool.notify(TxEventDropped, tx, nil, TxReasonOverflow)
 
 						// Update the account nonce to the dropped transaction
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -1052,7 +1368,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Drop all transactions if they are less than the overflow
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
-					pool.removeTx(tx.Hash())
+					pool.dropTx(tx.Hash(), TxReasonOverflow)
 				}
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
@@ -1061,7 +1377,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			// Otherwise drop only last few transactions
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
-				pool.removeTx(txs[i].Hash())
+				pool.dropTx(txs[i].Hash(), TxReasonOverflow)
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
@@ -1081,22 +1397,25 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range list.Forward(nonce) {
 			hash := tx.Hash()
 			log.Trace("Removed old pending transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.forgetTx(hash)
 			pool.priced.Removed()
+			pool.notifyStale(tx)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
-			delete(pool.all, hash)
+			pool.forgetTx(hash)
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
+			pool.notify(TxEventDropped, tx, nil, TxReasonInsufficientFunds)
 		}
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
 			pool.enqueueTx(hash, tx)
+			pool.notify(TxEventDemoted, tx, nil, "")
 		}
 		// If there's a gap in front, warn (should never happen) and postpone all transactions
 		if list.Len() > 0 && list.txs.Get(nonce) == nil {
@@ -1104,6 +1423,7 @@ func (pool *TxPool) demoteUnexecutables() {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
 				pool.enqueueTx(hash, tx)
+				pool.notify(TxEventDemoted, tx, nil, "")
 			}
 		}
 		// Delete the entire queue entry if it became empty.
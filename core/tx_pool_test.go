@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -78,12 +79,16 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 }
 
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
+	return setupTxPoolWithConfig(testTxPoolConfig)
+}
+
+func setupTxPoolWithConfig(config TxPoolConfig) (*TxPool, *ecdsa.PrivateKey) {
 	diskdb, _ := ethdb.NewMemDatabase()
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(diskdb))
 	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
 
 	key, _ := crypto.GenerateKey()
-	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
 
 	return pool, key
 }
@@ -101,6 +106,21 @@ func validateTxPoolInternals(pool *TxPool) error {
 	if priced := pool.priced.items.Len() - pool.priced.stales; priced != pending+queued {
 		return fmt.Errorf("total priced transaction count %d != %d pending + %d queued", priced, pending, queued)
 	}
+	// Ensure the per recipient transaction counts are consistent with the pool
+	recipients := make(map[common.Address]uint64)
+	for _, tx := range pool.all {
+		if to := tx.To(); to != nil {
+			recipients[*to]++
+		}
+	}
+	if len(recipients) != len(pool.recipients) {
+		return fmt.Errorf("recipient count mismatch: have %d, want %d", len(pool.recipients), len(recipients))
+	}
+	for to, count := range recipients {
+		if pool.recipients[to] != count {
+			return fmt.Errorf("recipient %x transaction count mismatch: have %d, want %d", to, pool.recipients[to], count)
+		}
+	}
 	// Ensure the next nonce to assign is the correct one
 	for addr, txs := range pool.pending {
 		// Find the last transaction
@@ -1681,6 +1701,517 @@ func TestTransactionStatusCheck(t *testing.T) {
 	}
 }
 
+// Tests that the status changes of pooled transactions are reported in order on
+// the event feed, along with the reasons of any removals.
+func TestTransactionPoolEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxPoolEvent, 16)
+	sub := pool.SubscribeTxPoolEvents(events)
+	defer sub.Unsubscribe()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+
+	// Add a transaction, replace it and price it out of the pool
+	tx0 := pricedTransaction(0, 100000, big.NewInt(1), key)
+	tx1 := pricedTransaction(0, 100000, big.NewInt(2), key)
+
+	if err := pool.AddRemote(tx0); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(tx1); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	pool.SetGasPrice(big.NewInt(3))
+
+	want := []TxPoolEvent{
+		{Type: TxEventAdded, Tx: tx0},
+		{Type: TxEventPromoted, Tx: tx0},
+		{Type: TxEventReplaced, Tx: tx1, Old: tx0},
+		{Type: TxEventDropped, Tx: tx1, Reason: TxReasonUnderpriced},
+	}
+	for i, exp := range want {
+		select {
+		case ev := <-events:
+			if ev.Type != exp.Type || ev.Tx != exp.Tx || ev.Old != exp.Old || ev.Reason != exp.Reason {
+				t.Fatalf("event %d mismatch: have %s/%x/%s, want %s/%x/%s", i, ev.Type, ev.Tx.Hash(), ev.Reason, exp.Type, exp.Tx.Hash(), exp.Reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d not fired", i)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event: %s/%x", ev.Type, ev.Tx.Hash())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// minedBlockChain is a test chain returning a fixed block for any lookup, to
+// simulate transactions being included by the chain head.
+type minedBlockChain struct {
+	*testBlockChain
+	block *types.Block
+}
+
+func (bc *minedBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.block
+}
+
+// Tests that transactions included by a new chain head are reported as mined,
+// whereas ones superseded by other transactions are reported as dropped.
+func TestTransactionPoolEventsMined(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	account, _ := deriveSender(transaction(0, 0, key))
+	stale, _ := deriveSender(transaction(0, 0, other))
+
+	statedb := pool.chain.(*testBlockChain).statedb
+	statedb.AddBalance(account, big.NewInt(1000000000))
+	statedb.AddBalance(stale, big.NewInt(1000000000))
+	pool.lockedReset(nil, nil)
+
+	events := make(chan TxPoolEvent, 16)
+	sub := pool.SubscribeTxPoolEvents(events)
+	defer sub.Unsubscribe()
+
+	mined := transaction(0, 100000, key)
+	superseded := transaction(0, 100000, other)
+	for i, err := range pool.AddRemotes([]*types.Transaction{mined, superseded}) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// Include one of the transactions in a new head, consuming both nonces
+	statedb.SetNonce(account, 1)
+	statedb.SetNonce(stale, 1)
+
+	oldHead := &types.Header{Number: big.NewInt(0)}
+	newHead := &types.Header{Number: big.NewInt(1), ParentHash: oldHead.Hash(), GasLimit: 1000000}
+	pool.chain = &minedBlockChain{pool.chain.(*testBlockChain), types.NewBlock(newHead, types.Transactions{mined}, nil, nil)}
+	pool.lockedReset(oldHead, newHead)
+
+	want := map[common.Hash]TxPoolEvent{
+		mined.Hash():      {Type: TxEventMined},
+		superseded.Hash(): {Type: TxEventDropped, Reason: TxReasonNonceTooLow},
+	}
+	for len(want) > 0 {
+		select {
+		case ev := <-events:
+			if ev.Type == TxEventAdded || ev.Type == TxEventPromoted {
+				continue // Delivered from the insertions
+			}
+			exp, ok := want[ev.Tx.Hash()]
+			if !ok {
+				t.Fatalf("unexpected event: %s/%x", ev.Type, ev.Tx.Hash())
+			}
+			if ev.Type != exp.Type || ev.Reason != exp.Reason {
+				t.Fatalf("event %x mismatch: have %s/%s, want %s/%s", ev.Tx.Hash(), ev.Type, ev.Reason, exp.Type, exp.Reason)
+			}
+			delete(want, ev.Tx.Hash())
+		case <-time.After(time.Second):
+			t.Fatalf("events not fired: %d missing", len(want))
+		}
+	}
+}
+
+// Tests that the event queue is capped, discarding events if the subscribers
+// fall too far behind.
+func TestTransactionPoolEventsLimit(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	pool := &TxPool{eventWake: make(chan struct{}, 1)}
+	tx := transaction(0, 100000, key)
+
+	for i := 0; i < maxQueuedEvents+10; i++ {
+		pool.notify(TxEventAdded, tx, nil, "")
+	}
+	if queued := len(pool.eventQueue); queued != maxQueuedEvents {
+		t.Fatalf("queued event count mismatch: have %d, want %d", queued, maxQueuedEvents)
+	}
+}
+
+// Tests that privately submitted transactions are tracked as such, with their
+// senders treated as local accounts.
+func TestTransactionPrivate(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+
+	private := pricedTransaction(0, 100000, big.NewInt(1), key)
+	public := pricedTransaction(1, 100000, big.NewInt(1), key)
+
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddPrivate(private); err == nil {
+		t.Fatalf("added known private transaction")
+	}
+	if err := pool.AddRemote(public); err != nil {
+		t.Fatalf("failed to add public transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want 2", pending)
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Errorf("private transaction not tracked as private")
+	}
+	if pool.IsPrivate(public.Hash()) {
+		t.Errorf("public transaction tracked as private")
+	}
+	if !pool.locals.contains(account) {
+		t.Errorf("private transaction sender not marked local")
+	}
+}
+
+// reorgBlockChain is a test chain serving blocks by hash, allowing reorgs to be
+// simulated for the transaction pool.
+type reorgBlockChain struct {
+	*testBlockChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *reorgBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.blocks[hash]
+}
+
+// Tests that private transactions included in a block and reinjected into the
+// pool by a chain reorg are still tracked as private, and thus not broadcast.
+func TestTransactionPrivateReorg(t *testing.T) {
+	t.Parallel()
+
+	diskdb, _ := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(diskdb))
+	chain := &reorgBlockChain{&testBlockChain{statedb, 1000000, new(event.Feed)}, make(map[common.Hash]*types.Block)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, chain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	statedb.AddBalance(account, big.NewInt(1000000000))
+
+	private := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	// Create a chain including the private transaction, and a longer one without
+	block := func(parent *types.Block, extra string, txs types.Transactions) *types.Block {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:   chain.gasLimit,
+			Extra:      []byte(extra),
+		}
+		block := types.NewBlock(header, txs, nil, nil)
+		chain.blocks[block.Hash()] = block
+		return block
+	}
+	genesis := chain.CurrentBlock()
+	chain.blocks[genesis.Hash()] = genesis
+
+	mined := block(genesis, "a", types.Transactions{private})
+	side := block(block(genesis, "b", nil), "b", nil)
+
+	// Include the private transaction and wait a while for the pool to prune
+	statedb.SetNonce(account, 1)
+	pool.lockedReset(genesis.Header(), mined.Header())
+	if pending, _ := pool.Stats(); pending != 0 {
+		t.Fatalf("pending transactions mismatch after inclusion: have %d, want 0", pending)
+	}
+	now := time.Now()
+
+	pool.mu.Lock()
+	pool.prunePrivate(now.Add(privateRetention / 2))
+	pool.mu.Unlock()
+
+	// Reorg the private transaction back into the pool and check it's still private
+	statedb.SetNonce(account, 0)
+	pool.lockedReset(mined.Header(), side.Header())
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatch after reorg: have %d, want 1", pending)
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Fatalf("reinjected private transaction not tracked as private")
+	}
+	// Ensure private marks are only forgotten long after leaving the pool
+	pool.mu.Lock()
+	pool.prunePrivate(now.Add(2 * privateRetention))
+	pool.mu.Unlock()
+
+	if !pool.IsPrivate(private.Hash()) {
+		t.Fatalf("pooled private transaction forgotten")
+	}
+	statedb.SetNonce(account, 1)
+	pool.lockedReset(side.Header(), block(side, "b", types.Transactions{private}).Header())
+
+	pool.mu.Lock()
+	pool.prunePrivate(now.Add(3*privateRetention + time.Second))
+	pool.mu.Unlock()
+
+	if pool.IsPrivate(private.Hash()) {
+		t.Fatalf("private transaction remembered long after inclusion")
+	}
+}
+
+// Tests that remote transactions are snapshotted on shutdown and revalidated on
+// startup, bounded by the global pool limits.
+func TestTransactionSnapshot(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary directory for the snapshot
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(dir, "snapshot.rlp")
+	config.GlobalSlots = 1
+	config.GlobalQueue = 3
+
+	pool, local := setupTxPoolWithConfig(config)
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a local transaction, two pending and a queued remote ones
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	for _, nonce := range []uint64{0, 1, 3} {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), remote)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", nonce, err)
+		}
+	}
+	pool.Stop()
+
+	// Restart the pool and ensure only the remotes within the limits got reloaded
+	pool = NewTxPool(config, params.TestChainConfig, pool.chain)
+	defer pool.Stop()
+
+	pending, queued := pool.Stats()
+	if pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	if _, err := os.Stat(config.Snapshot); !os.IsNotExist(err) {
+		t.Fatalf("snapshot not deleted after loading: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that snapshotted remote transactions are reloaded on startup regardless
+// of the sender rate limits, as they were already admitted once.
+func TestTransactionSnapshotUnmetered(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary directory for the snapshot
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testTxPoolConfig
+	config.Snapshot = filepath.Join(dir, "snapshot.rlp")
+	config.SenderRate = 2
+	config.SenderInterval = time.Hour
+
+	pool, key := setupTxPoolWithConfig(config)
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), key)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", nonce, err)
+		}
+	}
+	pool.Stop()
+
+	// Restart the pool and ensure the reload didn't consume the sender's allowance
+	pool = NewTxPool(config, params.TestChainConfig, pool.chain)
+	defer pool.Stop()
+
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	for nonce := uint64(2); nonce < 4; nonce++ {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(1), key)); err != nil {
+			t.Fatalf("failed to add remote transaction %d after reload: %v", nonce, err)
+		}
+	}
+}
+
+// Tests that remote senders are rate limited according to their token allowance,
+// while local ones are exempt.
+func TestTransactionSenderRateLimiting(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.SenderRate = 2
+	config.SenderInterval = time.Hour
+
+	pool, local := setupTxPoolWithConfig(config)
+	defer pool.Stop()
+
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	for i := uint64(0); i < 3; i++ {
+		err := pool.AddRemote(pricedTransaction(i, 100000, big.NewInt(1), remote))
+		if i < 2 && err != nil {
+			t.Fatalf("remote transaction %d: failed to add: %v", i, err)
+		}
+		if i == 2 && err != ErrSenderRateLimited {
+			t.Fatalf("remote transaction %d: error mismatch: have %v, want %v", i, err, ErrSenderRateLimited)
+		}
+		if err := pool.AddLocal(pricedTransaction(i, 100000, big.NewInt(1), local)); err != nil {
+			t.Fatalf("local transaction %d: failed to add: %v", i, err)
+		}
+	}
+	// Refilled allowances should admit new transactions
+	pool.limiter.buckets[crypto.PubkeyToAddress(remote.PublicKey)].last = time.Now().Add(-time.Hour)
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add transaction after refill: %v", err)
+	}
+}
+
+// Tests that remote transactions rejected after passing the admission checks don't
+// use up the rate allowance of their senders.
+func TestTransactionSenderRateLimitingRejects(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.SenderRate = 2
+	config.SenderInterval = time.Hour
+
+	pool, key := setupTxPoolWithConfig(config)
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100001, big.NewInt(1), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(1, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add transaction after rejected replacement: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), key)); err != ErrSenderRateLimited {
+		t.Fatalf("rate limit error mismatch: have %v, want %v", err, ErrSenderRateLimited)
+	}
+}
+
+// Tests that the number of pooled transactions targeting a contract is capped,
+// counting both queued ones and ones within the same batch, while transfers to
+// plain accounts are not.
+func TestTransactionRecipientLimiting(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.RecipientSlots = 2
+
+	pool, _ := setupTxPoolWithConfig(config)
+	defer pool.Stop()
+
+	contract, account := common.Address{0x01}, common.Address{0x02}
+	pool.currentState.SetCode(contract, []byte{0x00})
+
+	call := func(nonce uint64, to common.Address, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Add a queued and a pending call along with an overflowing one in one batch
+	calls := []*types.Transaction{call(1, contract, keys[0]), call(0, contract, keys[1]), call(0, contract, keys[2])}
+	for i, err := range pool.AddRemotes(calls) {
+		if i < 2 && err != nil {
+			t.Fatalf("contract call %d: failed to add: %v", i, err)
+		}
+		if i == 2 && err != ErrRecipientCapped {
+			t.Fatalf("contract call %d: error mismatch: have %v, want %v", i, err, ErrRecipientCapped)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool content mismatch: have %d/%d pending/queued, want 1/1", pending, queued)
+	}
+	for i, key := range keys {
+		if err := pool.AddRemote(call(2, account, key)); err != nil {
+			t.Fatalf("transfer %d: failed to add: %v", i, err)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Removing a call should free up a slot for the contract
+	pool.mu.Lock()
+	pool.removeTx(calls[1].Hash())
+	pool.mu.Unlock()
+
+	if err := pool.AddRemote(calls[2]); err != nil {
+		t.Fatalf("failed to add contract call after removal: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that remote transactions need to pay a premium over the pool floor once
+// the pool fills up.
+func TestTransactionPricePremium(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 4
+	config.GlobalQueue = 1
+	config.PricePremium = 50
+
+	pool, key := setupTxPoolWithConfig(config)
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Fill the pool up to the pressure threshold
+	for i := uint64(0); i < 4; i++ {
+		if err := pool.AddRemote(pricedTransaction(i, 100000, big.NewInt(10), key)); err != nil {
+			t.Fatalf("transaction %d: failed to add: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(pricedTransaction(4, 100000, big.NewInt(14), key)); err != ErrPremiumUnderpriced {
+		t.Fatalf("premium error mismatch: have %v, want %v", err, ErrPremiumUnderpriced)
+	}
+	if err := pool.AddRemote(pricedTransaction(4, 100000, big.NewInt(15), key)); err != nil {
+		t.Fatalf("failed to add premium paying transaction: %v", err)
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
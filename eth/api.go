@@ -24,6 +24,7 @@ import (
 	"math/big"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -198,6 +199,80 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return uint64(api.e.miner.HashRate())
 }
 
+// SetRecommitInterval sets the interval in milliseconds at which the block being
+// mined is recreated to include newly arrived transactions. Zero disables it.
+func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
+	api.e.miner.SetRecommitInterval(time.Duration(interval) * time.Millisecond)
+}
+
+// GetWorkStats returns the expected fee revenue, gas usage and transaction count
+// of the block currently being mined.
+func (api *PrivateMinerAPI) GetWorkStats() (map[string]interface{}, error) {
+	stats := api.e.miner.WorkStats()
+	if stats == nil {
+		return nil, fmt.Errorf("no mining work available")
+	}
+	return map[string]interface{}{
+		"number":    hexutil.Uint64(stats.Number),
+		"fees":      (*hexutil.Big)(stats.Fees),
+		"gasUsed":   hexutil.Uint64(stats.GasUsed),
+		"gasLimit":  hexutil.Uint64(stats.GasLimit),
+		"txCount":   hexutil.Uint(stats.TxCount),
+		"createdAt": stats.CreatedAt.Unix(),
+	}, nil
+}
+
+// SetTxOrdering sets the policy selecting the transactions included into mined
+// blocks. Supported policies are "price" (default), "local" to prioritise local
+// accounts, and "fair" to cap the transactions per account to accountCap.
+func (api *PrivateMinerAPI) SetTxOrdering(policy string, accountCap *int) error {
+	var ordering miner.TxOrdering
+	switch policy {
+	case "price":
+		ordering = miner.NewPriceOrdering()
+	case "local":
+		ordering = miner.NewLocalsFirstOrdering(api.e.TxPool())
+	case "fair":
+		if accountCap == nil {
+			return fmt.Errorf("fair ordering requires an account cap")
+		}
+		ordering = miner.NewFairOrdering(*accountCap)
+	default:
+		return fmt.Errorf("unknown transaction ordering policy %q", policy)
+	}
+	api.e.miner.SetTxOrdering(ordering)
+	return nil
+}
+
+// TxOrdering returns the name of the active transaction ordering policy.
+func (api *PrivateMinerAPI) TxOrdering() string {
+	return api.e.miner.TxOrdering().Name()
+}
+
+// SendBundle submits a list of RLP encoded signed transactions to be included
+// atomically, in order, into a block no later than maxBlock. The returned hash
+// identifies the bundle for cancellation.
+func (api *PrivateMinerAPI) SendBundle(encodedTxs []hexutil.Bytes, maxBlock *rpc.BlockNumber) (common.Hash, error) {
+	txs := make(types.Transactions, len(encodedTxs))
+	for i, encoded := range encodedTxs {
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(encoded, tx); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid bundle transaction %d: %v", i, err)
+		}
+		txs[i] = tx
+	}
+	var deadline uint64
+	if maxBlock != nil && *maxBlock >= 0 {
+		deadline = uint64(*maxBlock)
+	}
+	return api.e.miner.SendBundle(txs, deadline)
+}
+
+// CancelBundle drops a pending bundle, returning whether it was found.
+func (api *PrivateMinerAPI) CancelBundle(hash common.Hash) bool {
+	return api.e.miner.CancelBundle(hash)
+}
+
 // PrivateAdminAPI is the collection of Ethereum full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -368,10 +443,12 @@ type storageEntry struct {
 
 // StorageRangeAt returns the storage at the given block height and transaction index.
 func (api *PrivateDebugAPI) StorageRangeAt(ctx context.Context, blockHash common.Hash, txIndex int, contractAddress common.Address, keyStart hexutil.Bytes, maxResult int) (StorageRangeResult, error) {
-	_, _, statedb, err := api.computeTxEnv(blockHash, txIndex, 0)
+	_, _, statedb, release, err := api.computeTxEnv(ctx, blockHash, txIndex, 0)
 	if err != nil {
 		return StorageRangeResult{}, err
 	}
+	defer release()
+
 	st := statedb.StorageTrie(contractAddress)
 	if st == nil {
 		return StorageRangeResult{}, fmt.Errorf("account %x doesn't exist", contractAddress)
@@ -43,12 +43,15 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     1,
-	LightPeers:    100,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   5 * time.Minute,
-	GasPrice:      big.NewInt(18 * params.Shannon),
+	NetworkId:       1,
+	LightPeers:      100,
+	DatabaseCache:   768,
+	TrieCache:       256,
+	TrieTimeout:     5 * time.Minute,
+	StateRegenLimit: 1024,
+	StateCallLimit:  0,
+	GasPrice:        big.NewInt(18 * params.Shannon),
+	MinerRecommit:   3 * time.Second,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -83,6 +86,9 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// Trusted block to fast sync to, skipping chain verification below it
+	SyncCheckpoint *downloader.Checkpoint `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -94,11 +100,21 @@ type Config struct {
 	TrieCache          int
 	TrieTimeout        time.Duration
 
+	// Historical state options for pruned nodes
+	StateCheckpoint uint64 // Number of blocks between states persisted in full (0 = disabled)
+	StateRegenLimit uint64 // Maximum number of blocks debug and tracing requests may re-execute to regenerate a state
+	StateCallLimit  uint64 // Maximum number of blocks state queries (e.g. eth_call) may re-execute to regenerate a state (0 = disabled)
+
+	// Log filtering options
+	LogIndex bool // Maintain an exact address and topic index of logs for eth_getLogs
+
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
 	MinerThreads int            `toml:",omitempty"`
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
+	// Interval to recreate the mining work with newly arrived transactions
+	MinerRecommit time.Duration
 
 	// Ethash options
 	Ethash ethash.Config
@@ -0,0 +1,207 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ethEntry is the "eth" ENR entry which advertises the chain a node is on.
+type ethEntry struct {
+	ForkID forkID
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ethEntry) ENRKey() string {
+	return "eth"
+}
+
+// forkID is the chain fork identifier of EIP-2124, made up of the checksum of the
+// genesis hash and all the already passed fork blocks, and the next fork block.
+type forkID struct {
+	Hash [4]byte // CRC32 checksum of the genesis hash and the passed fork blocks
+	Next uint64  // Block number of the next upcoming fork, or 0 if none is known
+}
+
+// gatherForks returns the distinct non-genesis fork blocks of a chain config in
+// ascending order.
+func gatherForks(config *params.ChainConfig) []uint64 {
+	forks := []*big.Int{
+		config.HomesteadBlock,
+		config.EIP150Block,
+		config.EIP155Block,
+		config.EIP158Block,
+		config.ByzantiumBlock,
+		config.ConstantinopleBlock,
+	}
+	if config.DAOForkSupport {
+		forks = append(forks, config.DAOForkBlock)
+	}
+	var numbers []uint64
+	for _, fork := range forks {
+		if fork != nil && fork.Sign() > 0 {
+			numbers = append(numbers, fork.Uint64())
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	for i := 1; i < len(numbers); i++ {
+		if numbers[i] == numbers[i-1] {
+			numbers = append(numbers[:i], numbers[i+1:]...)
+			i--
+		}
+	}
+	return numbers
+}
+
+// checksumUpdate extends a fork checksum with the block number of a passed fork.
+func checksumUpdate(hash [4]byte, fork uint64) [4]byte {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], fork)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Update(binary.BigEndian.Uint32(hash[:]), crc32.IEEETable, blob[:]))
+	return sum
+}
+
+// checksums returns the fork checksums of a chain config, the i-th one covering
+// the genesis hash and the first i fork blocks.
+func checksums(genesis common.Hash, forks []uint64) [][4]byte {
+	sums := make([][4]byte, len(forks)+1)
+	binary.BigEndian.PutUint32(sums[0][:], crc32.ChecksumIEEE(genesis[:]))
+	for i, fork := range forks {
+		sums[i+1] = checksumUpdate(sums[i], fork)
+	}
+	return sums
+}
+
+// newForkID calculates the fork identifier of a chain at the given head block.
+// Nodes on different networks or with different fork rules end up with different
+// identifiers, while forks scheduled in the future only change the next field.
+func newForkID(config *params.ChainConfig, genesis common.Hash, head uint64) forkID {
+	var (
+		forks = gatherForks(config)
+		sums  = checksums(genesis, forks)
+	)
+	for i, fork := range forks {
+		if head < fork {
+			return forkID{Hash: sums[i], Next: fork}
+		}
+	}
+	return forkID{Hash: sums[len(forks)]}
+}
+
+// forkLoop keeps the fork identifier advertised in the local node record up to
+// date, re-signing the record through update whenever the chain head crosses a
+// fork transition.
+func (pm *ProtocolManager) forkLoop(update func(...enr.Entry) error) {
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	headSub := pm.blockchain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	genesis := pm.blockchain.Genesis().Hash()
+	advertise := func(head uint64) {
+		fork := newForkID(pm.chainconfig, genesis, head)
+		if fork == pm.fork {
+			return
+		}
+		if err := update(ethEntry{ForkID: fork}); err != nil {
+			log.Warn("Failed to advertise new fork identifier", "hash", fmt.Sprintf("%x", fork.Hash), "next", fork.Next, "err", err)
+			return
+		}
+		log.Debug("Advertising new fork identifier", "hash", fmt.Sprintf("%x", fork.Hash), "next", fork.Next)
+		pm.fork = fork
+	}
+	// Catch up with any head change since the protocol manager was created
+	advertise(pm.blockchain.CurrentHeader().Number.Uint64())
+
+	for {
+		select {
+		case ev := <-headCh:
+			advertise(ev.Block.NumberU64())
+
+		case <-headSub.Err():
+			return
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
+// dialCandidate returns a dial filter accepting nodes whose advertised fork
+// identifier is compatible with the local chain at the current head, following
+// the validation rules of EIP-2124. Nodes with unknown records are accepted too,
+// since they might simply not support node records yet.
+func dialCandidate(config *params.ChainConfig, genesis common.Hash, head func() uint64) func(discover.NodeID, *enr.Record) bool {
+	var (
+		forks = gatherForks(config)
+		sums  = checksums(genesis, forks)
+	)
+	forks = append(forks, math.MaxUint64) // Sentinel for the last, not yet known fork
+
+	return func(_ discover.NodeID, record *enr.Record) bool {
+		if record == nil {
+			return true
+		}
+		var entry ethEntry
+		if err := record.Load(&entry); err != nil {
+			return false
+		}
+		id, head := entry.ForkID, head()
+
+		// Find the first unpassed local fork and compare the remote state to it
+		for i, fork := range forks {
+			if head >= fork {
+				continue
+			}
+			// Same passed forks, reject if the remote expects a fork we've passed
+			if sums[i] == id.Hash {
+				return id.Next == 0 || head < id.Next
+			}
+			// Remote is behind, accept if its next fork is the one we passed
+			for j := 0; j < i; j++ {
+				if sums[j] == id.Hash {
+					return forks[j] == id.Next
+				}
+			}
+			// Remote is ahead, accept if it passed our known future forks
+			for j := i + 1; j < len(sums); j++ {
+				if sums[j] == id.Hash {
+					return true
+				}
+			}
+			return false
+		}
+		return false
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the fork identifier only covers the passed forks and advertises the
+// next one, matching the mainnet test vectors of EIP-2124.
+func TestForkID(t *testing.T) {
+	tests := []struct {
+		head uint64
+		want forkID
+	}{
+		{0, forkID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{1149999, forkID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}},
+		{1150000, forkID{Hash: [4]byte{0x97, 0xc2, 0xc3, 0x4c}, Next: 1920000}},
+		{1920000, forkID{Hash: [4]byte{0x91, 0xd1, 0xf9, 0x48}, Next: 2463000}},
+		{2463000, forkID{Hash: [4]byte{0x7a, 0x64, 0xda, 0x13}, Next: 2675000}},
+		{2675000, forkID{Hash: [4]byte{0x3e, 0xdd, 0x5b, 0x10}, Next: 4370000}},
+		{4370000, forkID{Hash: [4]byte{0xa0, 0x0b, 0xc3, 0x24}, Next: 0}},
+	}
+	for i, tt := range tests {
+		if have := newForkID(params.MainnetChainConfig, params.MainnetGenesisHash, tt.head); have != tt.want {
+			t.Errorf("test %d: fork identifier mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+	mainnet := newForkID(params.MainnetChainConfig, params.MainnetGenesisHash, 4370000)
+	if id := newForkID(params.MainnetChainConfig, params.TestnetGenesisHash, 4370000); id == mainnet {
+		t.Errorf("different genesis produced same identifier %x", id)
+	}
+	classic := *params.MainnetChainConfig
+	classic.DAOForkSupport = false
+	if id := newForkID(&classic, params.MainnetGenesisHash, 4370000); id == mainnet {
+		t.Errorf("different fork rules produced same identifier %x", id)
+	}
+	// Scheduling a future fork must only change the next fork block
+	scheduled := *params.MainnetChainConfig
+	scheduled.ConstantinopleBlock = big.NewInt(7280000)
+	if id := newForkID(&scheduled, params.MainnetGenesisHash, 4370000); id.Hash != mainnet.Hash || id.Next != 7280000 {
+		t.Errorf("future fork mismatch: have %x, want %x/%d", id, mainnet.Hash, 7280000)
+	}
+}
+
+// Tests that dial candidates are filtered by the compatibility of their advertised
+// fork identifier, following the validation rules of EIP-2124.
+func TestDialCandidate(t *testing.T) {
+	record := func(entries ...enr.Entry) *enr.Record {
+		key, _ := crypto.GenerateKey()
+
+		r := new(enr.Record)
+		for _, e := range entries {
+			r.Set(e)
+		}
+		if err := r.Sign(key); err != nil {
+			t.Fatalf("failed to sign record: %v", err)
+		}
+		return r
+	}
+	var (
+		spurious  = [4]byte{0x3e, 0xdd, 0x5b, 0x10}
+		byzantium = [4]byte{0xa0, 0x0b, 0xc3, 0x24}
+	)
+	tests := []struct {
+		head   uint64
+		record *enr.Record
+		want   bool
+	}{
+		// Nodes without records are accepted, nodes without eth entries aren't
+		{0, nil, true},
+		{0, record(), false},
+
+		// Same passed forks, with the remote not knowing or knowing the next fork
+		{2675000, record(ethEntry{ForkID: forkID{Hash: spurious}}), true},
+		{2675000, record(ethEntry{ForkID: forkID{Hash: spurious, Next: 4370000}}), true},
+
+		// Same passed forks, but the remote expects a fork we've already passed
+		{7987396, record(ethEntry{ForkID: forkID{Hash: byzantium, Next: 7280000}}), false},
+
+		// Remote is behind, accepted only if aware of the fork we've passed
+		{4370000, record(ethEntry{ForkID: forkID{Hash: spurious, Next: 4370000}}), true},
+		{4370000, record(ethEntry{ForkID: forkID{Hash: spurious}}), false},
+
+		// Remote is ahead on a fork we know about
+		{2675000, record(ethEntry{ForkID: forkID{Hash: byzantium}}), true},
+
+		// Remote is on an unknown chain
+		{4370000, record(ethEntry{ForkID: forkID{Hash: [4]byte{0xaf, 0xec, 0x6b, 0x27}}}), false},
+	}
+	for i, tt := range tests {
+		head := tt.head
+		filter := dialCandidate(params.MainnetChainConfig, params.MainnetGenesisHash, func() uint64 { return head })
+		if have := filter(discover.NodeID{}, tt.record); have != tt.want {
+			t.Errorf("test %d: filter mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that the fork identifier advertised in the local node record is updated
+// whenever the chain head crosses a fork transition, and only then.
+func TestForkLoop(t *testing.T) {
+	var (
+		engine = ethash.NewFaker()
+		db, _  = ethdb.NewMemDatabase()
+		gspec  = &core.Genesis{
+			Config: &params.ChainConfig{HomesteadBlock: big.NewInt(2), EIP150Block: big.NewInt(4)},
+		}
+		genesis       = gspec.MustCommit(db)
+		blockchain, _ = core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 5, nil)
+
+	pm, err := NewProtocolManager(gspec.Config, downloader.FullSync, nil, DefaultConfig.NetworkId, new(event.TypeMux), new(testTxPool), engine, blockchain, db)
+	if err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	pm.Start(1000)
+	defer pm.Stop()
+
+	updates := make(chan forkID, len(blocks))
+	go pm.forkLoop(func(entries ...enr.Entry) error {
+		updates <- entries[0].(ethEntry).ForkID
+		return nil
+	})
+	for _, block := range blocks {
+		if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("failed to insert block #%d: %v", block.NumberU64(), err)
+		}
+		switch number := block.NumberU64(); number {
+		case 2, 4:
+			want := newForkID(gspec.Config, genesis.Hash(), number)
+			select {
+			case have := <-updates:
+				if have != want {
+					t.Fatalf("block #%d: advertised fork identifier mismatch: have %x, want %x", number, have, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("block #%d: fork identifier not updated", number)
+			}
+		default:
+			select {
+			case have := <-updates:
+				t.Fatalf("block #%d: unexpected fork identifier update %x", number, have)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+}
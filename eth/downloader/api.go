@@ -51,6 +51,12 @@ func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAP
 	return api
 }
 
+// StateSyncProgress returns detailed statistics about the state trie download of
+// fast sync, or nil if no state was downloaded since startup.
+func (api *PublicDownloaderAPI) StateSyncProgress() *StateSyncProgress {
+	return api.d.StateSyncProgress()
+}
+
 // eventLoop runs an loop until the event mux closes. It will install and uninstall new
 // sync subscriptions and broadcasts sync status updates to the installed sync subscriptions.
 func (api *PublicDownloaderAPI) eventLoop() {
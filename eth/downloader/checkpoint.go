@@ -0,0 +1,263 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errCheckpointUnavailable = errors.New("peer doesn't have the trusted checkpoint")
+	errInvalidCheckpoint     = errors.New("trusted checkpoint doesn't link to the local chain")
+)
+
+// Checkpoint is a block trusted by the operator to be part of the canonical chain.
+// Fast syncing to a checkpoint retrieves the header chain backwards from it down
+// to the local chain, verifying only the hash links instead of the consensus
+// rules, and starts the state sync at the checkpoint block.
+type Checkpoint struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// ParseCheckpoint parses a checkpoint in the <number>:<hash> format.
+func ParseCheckpoint(s string) (*Checkpoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid checkpoint %q, want <number>:<hash>", s)
+	}
+	number, err := strconv.ParseUint(parts[0], 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkpoint number: %v", err)
+	}
+	if number == 0 {
+		return nil, errors.New("checkpoint cannot be the genesis block")
+	}
+	var hash common.Hash
+	if err := hash.UnmarshalText([]byte(parts[1])); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint hash: %v", err)
+	}
+	return &Checkpoint{Number: number, Hash: hash}, nil
+}
+
+// String implements fmt.Stringer.
+func (c *Checkpoint) String() string {
+	return fmt.Sprintf("%d:%x", c.Number, c.Hash)
+}
+
+// checkpointPending returns whether the node is fast syncing and has not yet
+// reached the trusted checkpoint, if any.
+func (d *Downloader) checkpointPending() bool {
+	return d.mode == FastSync && d.checkpoint != nil && d.blockchain.CurrentFastBlock().NumberU64() < d.checkpoint.Number
+}
+
+// syncToCheckpoint runs a fast sync cycle up to the trusted checkpoint. Contrary
+// to a regular sync, the target is not the head of the remote peer and the pivot
+// block doesn't move: it is the checkpoint itself.
+func (d *Downloader) syncToCheckpoint(p *peerConnection) error {
+	head, origin, err := d.fetchCheckpointChain(p)
+	if err != nil {
+		return err
+	}
+	height := head.Number.Uint64()
+
+	d.syncStatsLock.Lock()
+	if d.syncStatsChainHeight <= origin || d.syncStatsChainOrigin > origin {
+		d.syncStatsChainOrigin = origin
+	}
+	d.syncStatsChainHeight = height
+	d.syncStatsLock.Unlock()
+
+	d.committed = 0
+
+	// The header chain is already retrieved, feed it into the regular pipeline
+	d.queue.Prepare(origin+1, d.mode)
+	if d.syncInitHook != nil {
+		d.syncInitHook(origin, height)
+	}
+	fetchers := []func() error{
+		func() error { return d.fetchCheckpointHeaders(origin+1, height) },
+		func() error { return d.fetchBodies(origin + 1) },
+		func() error { return d.fetchReceipts(origin + 1) },
+		func() error { return d.processHeaders(origin+1, height, nil) },
+		func() error { return d.processFastSyncContent(head, true) },
+	}
+	return d.spawnSync(fetchers)
+}
+
+// fetchCheckpointChain retrieves the header chain from the trusted checkpoint
+// backwards until it links up with a block in the local chain, checking only that
+// each header is the parent of the previous one. The headers are staged in the
+// database, so an interrupted retrieval is resumed instead of restarted.
+//
+// The method returns the checkpoint header and the number of the local block the
+// chain links up to.
+func (d *Downloader) fetchCheckpointChain(p *peerConnection) (*types.Header, uint64, error) {
+	var (
+		ceil   = d.blockchain.CurrentFastBlock().NumberU64()
+		hash   = d.checkpoint.Hash
+		number = d.checkpoint.Number
+		head   *types.Header
+	)
+	// linked checks whether a header's parent is a fully available local block
+	linked := func(header *types.Header) bool {
+		parent := header.Number.Uint64() - 1
+		return parent <= ceil && d.blockchain.HasBlock(header.ParentHash, parent)
+	}
+	// staged verifies a header against the expected hash chain
+	staged := func(header *types.Header) error {
+		if header.Hash() != hash || header.Number.Uint64() != number {
+			return errInvalidChain
+		}
+		if head == nil {
+			head = header
+		}
+		if !linked(header) && number == 1 {
+			log.Error("Trusted checkpoint on different chain", "checkpoint", d.checkpoint, "genesis", header.ParentHash)
+			return errInvalidCheckpoint
+		}
+		return nil
+	}
+	// Skip over any headers staged by a previous sync cycle
+	for header := core.GetCheckpointHeader(d.stateDB, number); header != nil && header.Hash() == hash; header = core.GetCheckpointHeader(d.stateDB, number) {
+		if err := staged(header); err != nil {
+			return nil, 0, err
+		}
+		if linked(header) {
+			return head, number - 1, nil
+		}
+		hash, number = header.ParentHash, number-1
+	}
+	if number < d.checkpoint.Number {
+		p.log.Debug("Resuming checkpoint header retrieval", "number", number, "hash", hash)
+	} else {
+		p.log.Debug("Retrieving checkpoint header chain", "number", number, "hash", hash)
+	}
+	var (
+		start  = time.Now()
+		logged = start
+	)
+	for {
+		headers, err := d.fetchCheckpointBatch(p, hash)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(headers) == 0 {
+			if head == nil {
+				return nil, 0, errCheckpointUnavailable
+			}
+			return nil, 0, errEmptyHeaderSet
+		}
+		batch := d.stateDB.NewBatch()
+		for _, header := range headers {
+			if err := staged(header); err != nil {
+				return nil, 0, err
+			}
+			core.WriteCheckpointHeader(batch, header)
+
+			if linked(header) {
+				if err := batch.Write(); err != nil {
+					return nil, 0, err
+				}
+				p.log.Debug("Checkpoint header chain linked", "origin", number-1, "elapsed", common.PrettyDuration(time.Since(start)))
+				return head, number - 1, nil
+			}
+			hash, number = header.ParentHash, number-1
+		}
+		if err := batch.Write(); err != nil {
+			return nil, 0, err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Retrieving checkpoint header chain", "number", number, "checkpoint", d.checkpoint.Number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+}
+
+// fetchCheckpointBatch requests a batch of headers from the remote peer, going
+// backwards from the one with the given hash, and waits for the response.
+func (d *Downloader) fetchCheckpointBatch(p *peerConnection, hash common.Hash) ([]*types.Header, error) {
+	go p.peer.RequestHeadersByHash(hash, MaxHeaderFetch, 0, true)
+
+	request := time.Now()
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelHeaderFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			headerReqTimer.UpdateSince(request)
+			return packet.(*headerPack).headers, nil
+
+		case <-timeout:
+			p.log.Debug("Checkpoint header request timed out", "elapsed", ttl)
+			headerTimeoutMeter.Mark(1)
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// fetchCheckpointHeaders feeds the staged checkpoint header chain in the [from,
+// to] range into the header processor. The headers are left in the staging area
+// until the processor has inserted them, so an interrupted sync can resume.
+func (d *Downloader) fetchCheckpointHeaders(from uint64, to uint64) error {
+	log.Debug("Feeding checkpoint headers", "from", from, "to", to)
+	defer log.Debug("Checkpoint header feeding terminated")
+
+	for from <= to {
+		headers := make([]*types.Header, 0, maxHeadersProcess)
+		for number := from; number <= to && len(headers) < maxHeadersProcess; number++ {
+			header := core.GetCheckpointHeader(d.stateDB, number)
+			if header == nil {
+				return fmt.Errorf("missing staged checkpoint header #%d", number)
+			}
+			headers = append(headers, header)
+		}
+		select {
+		case d.headerProcCh <- headers:
+		case <-d.cancelCh:
+			return errCancelHeaderFetch
+		}
+		from += uint64(len(headers))
+	}
+	select {
+	case d.headerProcCh <- nil:
+		return nil
+	case <-d.cancelCh:
+		return errCancelHeaderFetch
+	}
+}
@@ -17,6 +17,7 @@
 package downloader
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -70,6 +71,9 @@ type downloadTester struct {
 
 	peerMissingStates map[string]map[common.Hash]bool // State entries that fast sync should not return
 
+	trustedHeaders int   // Number of headers inserted below a trusted checkpoint
+	trustedFailure error // Error to fail the insertion of trusted headers with (nil = succeed)
+
 	lock sync.RWMutex
 }
 
@@ -96,7 +100,7 @@ func newTester() *downloadTester {
 	tester.stateDb, _ = ethdb.NewMemDatabase()
 	tester.stateDb.Put(genesis.Root().Bytes(), []byte{0x00})
 
-	tester.downloader = New(FullSync, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
+	tester.downloader = New(FullSync, nil, tester.stateDb, new(event.TypeMux), tester, nil, tester.dropPeer)
 
 	return tester
 }
@@ -330,6 +334,20 @@ func (dl *downloadTester) InsertHeaderChain(headers []*types.Header, checkFreq i
 	return len(headers), nil
 }
 
+// InsertTrustedHeaderChain injects a new batch of headers below a trusted
+// checkpoint into the simulated chain.
+func (dl *downloadTester) InsertTrustedHeaderChain(headers []*types.Header) (int, error) {
+	dl.lock.Lock()
+	if dl.trustedFailure != nil {
+		dl.lock.Unlock()
+		return 0, dl.trustedFailure
+	}
+	dl.trustedHeaders += len(headers)
+	dl.lock.Unlock()
+
+	return dl.InsertHeaderChain(headers, 0)
+}
+
 // InsertChain injects a new batch of blocks into the simulated chain.
 func (dl *downloadTester) InsertChain(blocks types.Blocks) (int, error) {
 	dl.lock.Lock()
@@ -444,7 +462,7 @@ func (dl *downloadTester) newSlowPeer(id string, version int, hashes []common.Ha
 }
 
 // dropPeer simulates a hard peer removal from the connection pool.
-func (dl *downloadTester) dropPeer(id string) {
+func (dl *downloadTester) dropPeer(id string, reason error) {
 	dl.lock.Lock()
 	defer dl.lock.Unlock()
 
@@ -521,8 +539,12 @@ func (dlp *downloadTesterPeer) RequestHeadersByNumber(origin uint64, amount int,
 	hashes := dlp.dl.peerHashes[dlp.id]
 	headers := dlp.dl.peerHeaders[dlp.id]
 	result := make([]*types.Header, 0, amount)
-	for i := 0; i < amount && len(hashes)-int(origin)-1-i*(skip+1) >= 0; i++ {
-		if header, ok := headers[hashes[len(hashes)-int(origin)-1-i*(skip+1)]]; ok {
+	step := -(skip + 1)
+	if reverse {
+		step = skip + 1
+	}
+	for i := 0; i < amount && len(hashes)-int(origin)-1+i*step >= 0 && len(hashes)-int(origin)-1+i*step < len(hashes); i++ {
+		if header, ok := headers[hashes[len(hashes)-int(origin)-1+i*step]]; ok {
 			result = append(result, header)
 		}
 	}
@@ -1753,3 +1775,128 @@ func testDeliverHeadersHang(t *testing.T, protocol int, mode SyncMode) {
 		tester.downloader.peers.peers["peer"].peer.(*floodingTestPeer).pend.Wait()
 	}
 }
+
+// Tests that fast syncing to a trusted checkpoint retrieves the chain up to the
+// checkpoint only, after which regular syncing takes over. Checkpoints that are
+// not part of the peer's chain must fail the sync.
+func TestCheckpointSync63(t *testing.T) { testCheckpointSync(t, 63) }
+func TestCheckpointSync64(t *testing.T) { testCheckpointSync(t, 64) }
+
+func testCheckpointSync(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain to download and a checkpoint half way into it
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+
+	number := uint64(targetBlocks / 2)
+	checkpoint := hashes[len(hashes)-1-int(number)]
+
+	// Sync with a checkpoint not on the peer's chain and ensure it fails
+	tester.downloader.checkpoint = &Checkpoint{Number: number, Hash: common.Hash{0xff}}
+	if err := tester.sync("peer", nil, FastSync); err != errInvalidChain {
+		t.Fatalf("unknown checkpoint error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	// Sync with a valid checkpoint but fail the header insertion, ensuring the
+	// staged headers are kept for the next attempt
+	tester.downloader.checkpoint = &Checkpoint{Number: number, Hash: checkpoint}
+	tester.trustedFailure = errors.New("insertion failed")
+	if err := tester.sync("peer", nil, FastSync); err != errInvalidChain {
+		t.Fatalf("failed insertion error mismatch: have %v, want %v", err, errInvalidChain)
+	}
+	for i := uint64(1); i <= number; i++ {
+		if header := core.GetCheckpointHeader(tester.stateDb, i); header == nil {
+			t.Fatalf("staged header #%d removed before insertion", i)
+		}
+	}
+	// Sync with a valid checkpoint and ensure the chain stops right there
+	tester.trustedFailure = nil
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise to checkpoint: %v", err)
+	}
+	if tester.trustedHeaders != int(number) {
+		t.Fatalf("trusted header insertion mismatch: have %d, want %d", tester.trustedHeaders, number)
+	}
+	if head := tester.CurrentBlock(); head.Hash() != checkpoint {
+		t.Fatalf("head block mismatch: have #%d [%x…], want #%d [%x…]", head.NumberU64(), head.Hash().Bytes()[:4], number, checkpoint[:4])
+	}
+	if hs := len(tester.ownHeaders); hs != int(number)+1 {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, number+1)
+	}
+	if rs := len(tester.ownReceipts); rs != int(number)+1 {
+		t.Fatalf("synchronised receipts mismatch: have %v, want %v", rs, number+1)
+	}
+	for i := uint64(1); i <= number; i++ {
+		if header := core.GetCheckpointHeader(tester.stateDb, i); header != nil {
+			t.Fatalf("staged header #%d not removed", i)
+		}
+	}
+	// Continue with a regular sync past the checkpoint
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise past checkpoint: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+}
+
+// Tests that the frontier of an interrupted state sync is persisted and restored
+// by subsequent syncs, and that switching roots is reported as healing.
+func TestStateSyncFrontier(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	hashes, _, blocks, _ := tester.makeChain(16, 0, tester.genesis, nil, false)
+	roots := []common.Hash{blocks[hashes[8]].Root(), blocks[hashes[0]].Root()}
+
+	// Retrieve the root node of a state and interrupt the sync
+	sync := newStateSync(tester.downloader, roots[0])
+	missing := sync.sched.Missing(1)
+	if len(missing) != 1 || missing[0] != roots[0] {
+		t.Fatalf("unexpected first request: %x", missing)
+	}
+	blob, err := tester.peerDb.Get(roots[0].Bytes())
+	if err != nil {
+		t.Fatalf("failed to retrieve state root: %v", err)
+	}
+	if _, _, err := sync.processNodeData(blob); err != nil {
+		t.Fatalf("failed to process state root: %v", err)
+	}
+	if err := sync.commit(true); err != nil {
+		t.Fatalf("failed to commit state sync: %v", err)
+	}
+	root, nodes := core.GetTrieSyncFrontier(tester.stateDb)
+	if root != roots[0] || len(nodes) != 1 || !bytes.Equal(nodes[0], blob) {
+		t.Fatalf("persisted frontier mismatch: root %x, %d nodes", root, len(nodes))
+	}
+	// Restart the sync and ensure the root node is not requested again
+	sync = newStateSync(tester.downloader, roots[0])
+	sync.restore()
+
+	for _, hash := range sync.sched.Missing(0) {
+		if hash == roots[0] {
+			t.Fatalf("restored state root requested again")
+		}
+	}
+	progress := tester.downloader.StateSyncProgress()
+	if progress == nil || progress.Root != roots[0] || progress.Healing {
+		t.Fatalf("progress mismatch after resume: %+v", progress)
+	}
+	if progress.Coverage != sync.sched.Coverage() || progress.Pending == 0 {
+		t.Fatalf("progress stats mismatch after resume: %+v", progress)
+	}
+	// Switch over to a different root after some progress, which should heal
+	tester.downloader.syncStatsState.processed = 1
+
+	sync = newStateSync(tester.downloader, roots[1])
+	sync.restore()
+
+	if progress := tester.downloader.StateSyncProgress(); progress.Root != roots[1] || !progress.Healing {
+		t.Fatalf("progress mismatch after root change: %+v", progress)
+	}
+}
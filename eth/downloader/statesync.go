@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
@@ -48,6 +49,10 @@ func (req *stateReq) timedOut() bool {
 	return req.response == nil
 }
 
+// frontierSaveInterval is the minimum time between persisting the frontier of a
+// running state sync.
+const frontierSaveInterval = time.Minute
+
 // stateSyncStats is a collection of progress stats to report during a state trie
 // sync to RPC requests as well as to display in user logs.
 type stateSyncStats struct {
@@ -55,6 +60,58 @@ type stateSyncStats struct {
 	duplicate  uint64 // Number of state entries downloaded twice
 	unexpected uint64 // Number of non-requested state entries received
 	pending    uint64 // Number of still pending state entries
+	bytes      uint64 // Number of state bytes downloaded since startup
+
+	root     common.Hash // State root currently (or last) being synced
+	healing  bool        // Whether an older downloaded state is being patched up to root
+	coverage float64     // Fraction of the account trie key space retrieved for root
+	written  uint64      // Number of state entries written for root since startup
+}
+
+// StateSyncProgress is a detailed report on the state trie download of a fast
+// sync, including the estimated amount of work still remaining.
+type StateSyncProgress struct {
+	Root       common.Hash    `json:"root"`       // State root currently (or last) being synced
+	Healing    bool           `json:"healing"`    // Whether an older state is being patched up to the root
+	Processed  hexutil.Uint64 `json:"processed"`  // Number of state entries written in total
+	Pending    hexutil.Uint64 `json:"pending"`    // Number of state entries known to be missing
+	Remaining  hexutil.Uint64 `json:"remaining"`  // Estimated number of state entries still missing
+	Duplicate  hexutil.Uint64 `json:"duplicate"`  // Number of state entries downloaded twice
+	Unexpected hexutil.Uint64 `json:"unexpected"` // Number of non-requested state entries received
+	Bytes      hexutil.Uint64 `json:"bytes"`      // Number of state bytes downloaded since startup
+	Coverage   float64        `json:"coverage"`   // Fraction of the account trie already retrieved
+}
+
+// StateSyncProgress retrieves the detailed progress of the state trie download,
+// or nil if no state was downloaded since startup.
+func (d *Downloader) StateSyncProgress() *StateSyncProgress {
+	d.syncStatsLock.RLock()
+	defer d.syncStatsLock.RUnlock()
+
+	stats := d.syncStatsState
+	if stats.root == (common.Hash{}) {
+		return nil
+	}
+	// Extrapolate the remaining entries from the ones written for the current root
+	// and the key space they cover. Storage tries skew it, but they are also evenly
+	// spread over the accounts.
+	remaining := stats.pending
+	if stats.coverage > 0 && stats.coverage < 1 {
+		if estimate := uint64(float64(stats.written) * (1 - stats.coverage) / stats.coverage); estimate > remaining {
+			remaining = estimate
+		}
+	}
+	return &StateSyncProgress{
+		Root:       stats.root,
+		Healing:    stats.healing,
+		Processed:  hexutil.Uint64(stats.processed),
+		Pending:    hexutil.Uint64(stats.pending),
+		Remaining:  hexutil.Uint64(remaining),
+		Duplicate:  hexutil.Uint64(stats.duplicate),
+		Unexpected: hexutil.Uint64(stats.unexpected),
+		Bytes:      hexutil.Uint64(stats.bytes),
+		Coverage:   stats.coverage,
+	}
 }
 
 // syncState starts downloading state with the given root hash.
@@ -101,6 +158,10 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			req.timer.Stop()
 			req.peer.SetNodeDataIdle(len(req.items))
 		}
+		// Responses not yet handed to the sync are dropped too, release their peers
+		for _, req := range finished {
+			req.peer.SetNodeDataIdle(len(req.items))
+		}
 	}()
 	// Run the state sync.
 	go s.run()
@@ -212,7 +273,8 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 // stateSync schedules requests for downloading a particular state trie defined
 // by a given state root.
 type stateSync struct {
-	d *Downloader // Downloader instance to access and manage current peerset
+	d    *Downloader // Downloader instance to access and manage current peerset
+	root common.Hash // State root being synced
 
 	sched  *trie.TrieSync             // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
@@ -220,6 +282,7 @@ type stateSync struct {
 
 	numUncommitted   int
 	bytesUncommitted int
+	saved            time.Time // Time the frontier was last persisted
 
 	deliver    chan *stateReq // Delivery channel multiplexing peer responses
 	cancel     chan struct{}  // Channel to signal a termination request
@@ -239,6 +302,8 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
+		root:    root,
+		saved:   time.Now(),
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
@@ -285,6 +350,8 @@ func (s *stateSync) loop() (err error) {
 			err = cerr
 		}
 	}()
+	// Pick up the frontier of any previously interrupted sync
+	s.restore()
 
 	// Keep assigning new tasks until the sync completes or aborts
 	for s.sched.Pending() > 0 {
@@ -310,7 +377,7 @@ func (s *stateSync) loop() (err error) {
 				// 2 items are the minimum requested, if even that times out, we've no use of
 				// this peer at the moment.
 				log.Warn("Stalling state sync, dropping peer", "peer", req.peer.id)
-				s.d.dropPeer(req.peer.id)
+				s.d.dropPeer(req.peer.id, errStallingPeer)
 			}
 			// Process all the received blobs and check for stale delivery
 			if err = s.process(req); err != nil {
@@ -323,19 +390,78 @@ func (s *stateSync) loop() (err error) {
 	return nil
 }
 
+// restore feeds the frontier of a previously interrupted state sync into the
+// scheduler, and resets the progress stats if the sync root changed.
+func (s *stateSync) restore() {
+	root, nodes := core.GetTrieSyncFrontier(s.d.stateDB)
+	if len(nodes) > 0 {
+		start := time.Now()
+
+		results := make([]trie.SyncResult, len(nodes))
+		for i, blob := range nodes {
+			s.keccak.Reset()
+			s.keccak.Write(blob)
+			s.keccak.Sum(results[i].Hash[:0])
+			results[i].Data = blob
+		}
+		restored, err := s.sched.Restore(results)
+		if err != nil {
+			log.Warn("Failed to restore state sync frontier", "err", err)
+		} else {
+			log.Info("Restored state sync frontier", "nodes", restored, "stale", len(nodes)-restored, "elapsed", common.PrettyDuration(time.Since(start)))
+		}
+	}
+	s.d.syncStatsLock.Lock()
+	defer s.d.syncStatsLock.Unlock()
+
+	if stats := &s.d.syncStatsState; stats.root != s.root {
+		// If a different state was being downloaded, it's patched up from now on
+		prev := stats.root
+		if prev == (common.Hash{}) {
+			prev = root
+		}
+		stats.healing = stats.processed > 0 && prev != (common.Hash{}) && prev != s.root
+		stats.root, stats.written = s.root, 0
+	}
+	s.d.syncStatsState.pending = uint64(s.sched.Pending())
+	s.d.syncStatsState.coverage = s.sched.Coverage()
+}
+
 func (s *stateSync) commit(force bool) error {
 	if !force && s.bytesUncommitted < ethdb.IdealBatchSize {
 		return nil
 	}
 	start := time.Now()
 	b := s.d.stateDB.NewBatch()
-	if written, err := s.sched.Commit(b); written == 0 || err != nil {
+	written, err := s.sched.Commit(b)
+	if err != nil {
 		return err
 	}
-	if err := b.Write(); err != nil {
-		return fmt.Errorf("DB write error: %v", err)
+	if written > 0 {
+		if err := b.Write(); err != nil {
+			return fmt.Errorf("DB write error: %v", err)
+		}
 	}
-	s.updateStats(s.numUncommitted, 0, 0, time.Since(start))
+	// Every now and then persist the frontier after the data, so a restarted
+	// sync doesn't have to retrieve all the pending intermediate nodes again
+	if s.sched.Pending() > 0 && (force || time.Since(s.saved) > frontierSaveInterval) {
+		retrieved := s.sched.Retrieved()
+		nodes := make([][]byte, len(retrieved))
+		for i, result := range retrieved {
+			nodes[i] = result.Data
+		}
+		if err := core.WriteTrieSyncFrontier(s.d.stateDB, s.root, nodes); err != nil {
+			return err
+		}
+		s.saved = time.Now()
+	}
+	if s.sched.Pending() == 0 {
+		core.DeleteTrieSyncFrontier(s.d.stateDB)
+	}
+	if written == 0 {
+		return nil
+	}
+	s.updateStats(s.numUncommitted, 0, 0, 0, time.Since(start))
 	s.numUncommitted = 0
 	s.bytesUncommitted = 0
 	return nil
@@ -400,11 +526,11 @@ func (s *stateSync) fillTasks(n int, req *stateReq) {
 // delivered.
 func (s *stateSync) process(req *stateReq) error {
 	// Collect processing stats and update progress if valid data was received
-	duplicate, unexpected := 0, 0
+	duplicate, unexpected, size := 0, 0, 0
 
 	defer func(start time.Time) {
-		if duplicate > 0 || unexpected > 0 {
-			s.updateStats(0, duplicate, unexpected, time.Since(start))
+		if duplicate > 0 || unexpected > 0 || size > 0 {
+			s.updateStats(0, duplicate, unexpected, size, time.Since(start))
 		}
 	}(time.Now())
 
@@ -412,6 +538,8 @@ func (s *stateSync) process(req *stateReq) error {
 	progress := false
 
 	for _, blob := range req.response {
+		size += len(blob)
+
 		prog, hash, err := s.processNodeData(blob)
 		switch err {
 		case nil:
@@ -463,7 +591,7 @@ func (s *stateSync) processNodeData(blob []byte) (bool, common.Hash, error) {
 
 // updateStats bumps the various state sync progress counters and displays a log
 // message for the user to see.
-func (s *stateSync) updateStats(written, duplicate, unexpected int, duration time.Duration) {
+func (s *stateSync) updateStats(written, duplicate, unexpected, bytes int, duration time.Duration) {
 	s.d.syncStatsLock.Lock()
 	defer s.d.syncStatsLock.Unlock()
 
@@ -471,6 +599,9 @@ func (s *stateSync) updateStats(written, duplicate, unexpected int, duration tim
 	s.d.syncStatsState.processed += uint64(written)
 	s.d.syncStatsState.duplicate += uint64(duplicate)
 	s.d.syncStatsState.unexpected += uint64(unexpected)
+	s.d.syncStatsState.bytes += uint64(bytes)
+	s.d.syncStatsState.written += uint64(written)
+	s.d.syncStatsState.coverage = s.sched.Coverage()
 
 	if written > 0 || duplicate > 0 || unexpected > 0 {
 		log.Info("Imported new state entries", "count", written, "elapsed", common.PrettyDuration(duration), "processed", s.d.syncStatsState.processed, "pending", s.d.syncStatsState.pending, "retry", len(s.tasks), "duplicate", s.d.syncStatsState.duplicate, "unexpected", s.d.syncStatsState.unexpected)
@@ -28,9 +28,11 @@ import (
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -229,6 +231,58 @@ func (api *PublicFilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, er
 	return rpcSub, nil
 }
 
+// NewBlockReceipts creates a subscription that fires for each new canonical block,
+// delivering it together with all its transaction receipts and logs in a single
+// notification. On chain reorganisations a notification with the removed flag set
+// is sent for each dropped block first, newest first, followed by the blocks of the
+// new canonical chain in ascending order.
+func (api *PublicFilterAPI) NewBlockReceipts(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			chainCh = make(chan core.ChainEvent, 16)
+			sideCh  = make(chan core.ChainSideEvent, 16)
+		)
+		chainSub := api.backend.SubscribeChainEvent(chainCh)
+		defer chainSub.Unsubscribe()
+		sideSub := api.backend.SubscribeChainSideEvent(sideCh)
+		defer sideSub.Unsubscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tracker := newChainTracker(api.backend, func(block *types.Block, removed bool) {
+			notification, err := newBlockReceipts(ctx, api.backend, block, removed)
+			if err != nil {
+				log.Warn("Failed to assemble block receipts", "number", block.Number(), "hash", block.Hash(), "err", err)
+				return
+			}
+			notifier.Notify(rpcSub.ID, notification)
+		})
+		for {
+			select {
+			case ev := <-chainCh:
+				if err := tracker.newHead(ctx, ev.Block); err != nil {
+					log.Warn("Failed to track chain head", "number", ev.Block.Number(), "hash", ev.Hash, "err", err)
+				}
+			case ev := <-sideCh:
+				tracker.sideBlock(ev.Block)
+			case <-rpcSub.Err(): // client send an unsubscribe request
+				return
+			case <-notifier.Closed(): // connection dropped
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -267,6 +321,72 @@ func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc
 	return rpcSub, nil
 }
 
+// LogsFrom creates a subscription that first replays all logs matching the given
+// filter criteria starting at the cursor block, then continues seamlessly with new
+// logs as they are mined. The block ranges of the criteria are ignored.
+//
+// Every log carries the hash of its block, which can be used as the cursor to
+// resume the subscription after a reconnect. The logs of the cursor block itself
+// are delivered again, so clients should skip the ones already processed by log
+// index. If the cursor block was meanwhile reorged out of the chain, the logs of
+// all the stale blocks down to the common ancestor are sent as removed first. If
+// the replay fails (e.g. it cannot keep up with new logs), the subscription ends
+// with the error and should be resumed from the last block received.
+func (api *PublicFilterAPI) LogsFrom(ctx context.Context, crit FilterCriteria, from LogCursor) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	// Subscribe to live logs before looking up the head to never miss a block
+	var (
+		query       = ethereum.FilterQuery{Addresses: crit.Addresses, Topics: crit.Topics}
+		matchedLogs = make(chan []*types.Log)
+	)
+	logsSub, err := api.events.SubscribeLogs(query, matchedLogs)
+	if err != nil {
+		return nil, err
+	}
+	header, err := api.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if header == nil {
+		logsSub.Unsubscribe()
+		if err == nil {
+			err = errors.New("unknown head block")
+		}
+		return nil, err
+	}
+	head := header.Number.Uint64()
+
+	start, removed, err := resolveLogCursor(ctx, api.backend, crit, from, head)
+	if err != nil {
+		logsSub.Unsubscribe()
+		return nil, err
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		defer logsSub.Unsubscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-rpcSub.Err(): // client send an unsubscribe request
+			case <-notifier.Closed(): // connection dropped
+			}
+			cancel()
+		}()
+		notify := func(log *types.Log) {
+			notifier.Notify(rpcSub.ID, log)
+		}
+		if err := resumeLogs(ctx, api.backend, crit, start, head, removed, matchedLogs, notify); err != nil && err != context.Canceled {
+			// Terminate the subscription so the client can resume from its last block
+			log.Warn("Resumed log subscription failed", "err", err)
+			notifier.Fail(rpcSub.ID, err)
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // FilterCriteria represents a request to create a new filter.
 //
 // TODO(karalabe): Kill this in favor of ethereum.FilterQuery.
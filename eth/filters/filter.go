@@ -18,7 +18,9 @@ package filters
 
 import (
 	"context"
+	"encoding/binary"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -33,11 +35,13 @@ type Backend interface {
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
 	HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error)
+	GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error)
 	GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error)
 	GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error)
 
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
@@ -45,6 +49,14 @@ type Backend interface {
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
+// LogIndexBackend is an optional extension of Backend, implemented by nodes that
+// maintain an exact index from log addresses and first topics to the blocks that
+// contain them. Filters use it in preference to the bloom bits when available.
+type LogIndexBackend interface {
+	// LogIndexStatus returns the section size and the number of indexed sections.
+	LogIndexStatus() (uint64, uint64)
+}
+
 // Filter can be used to retrieve and filter logs.
 type Filter struct {
 	backend Backend
@@ -54,7 +66,8 @@ type Filter struct {
 	addresses  []common.Address
 	topics     [][]common.Hash
 
-	matcher *bloombits.Matcher
+	matcher  *bloombits.Matcher
+	postings []common.Hash // Log index keys to look up, nil if the index is unusable
 }
 
 // New creates a new filter which uses a bloom filter on blocks to figure out whether
@@ -89,9 +102,40 @@ func New(backend Backend, begin, end int64, addresses []common.Address, topics [
 		topics:    topics,
 		db:        backend.ChainDb(),
 		matcher:   bloombits.NewMatcher(size, filters),
+		postings:  logIndexKeys(addresses, topics),
 	}
 }
 
+// logIndexKeys returns the log index posting lists whose union contains all the
+// blocks with logs matching the given address and first topic criteria. If the
+// criteria don't restrict either, nil is returned as the index is of no use.
+func logIndexKeys(addresses []common.Address, topics [][]common.Hash) []common.Hash {
+	var first []common.Hash
+	if len(topics) > 0 {
+		first = topics[0]
+	}
+	var keys []common.Hash
+	switch {
+	case len(addresses) == 0 && len(first) == 0:
+		return nil
+	case len(first) == 0:
+		for i := range addresses {
+			keys = append(keys, core.LogIndexKey(&addresses[i], nil))
+		}
+	case len(addresses) == 0:
+		for i := range first {
+			keys = append(keys, core.LogIndexKey(nil, &first[i]))
+		}
+	default:
+		for i := range addresses {
+			for j := range first {
+				keys = append(keys, core.LogIndexKey(&addresses[i], &first[j]))
+			}
+		}
+	}
+	return keys
+}
+
 // Logs searches the blockchain for matching log entries, returning all from the
 // first block that contains matches, updating the start of the filter accordingly.
 func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
@@ -109,18 +153,34 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
+	// Gather all exactly indexed logs, then bloom indexed ones, and finish with
+	// non indexed ones
 	var (
 		logs []*types.Log
 		err  error
 	)
+	if backend, ok := f.backend.(LogIndexBackend); ok && f.postings != nil {
+		size, sections := backend.LogIndexStatus()
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				logs, err = f.postedLogs(ctx, size, end)
+			} else {
+				logs, err = f.postedLogs(ctx, size, indexed-1)
+			}
+			if err != nil {
+				return logs, err
+			}
+		}
+	}
 	size, sections := f.backend.BloomStatus()
-	if indexed := sections * size; indexed > uint64(f.begin) {
+	if indexed := sections * size; indexed > uint64(f.begin) && uint64(f.begin) <= end {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
@@ -177,6 +237,60 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
+// postedLogs returns the logs matching the filter criteria based on the exact log
+// index. If a section is missing from the index (e.g. reorged meanwhile), the
+// method stops and leaves the rest of the range to the other search methods.
+func (f *Filter) postedLogs(ctx context.Context, size uint64, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	for section := uint64(f.begin) / size; section <= end/size; section++ {
+		// Collect the candidate blocks of the section from all posting lists
+		head := core.GetCanonicalHash(f.db, (section+1)*size-1)
+		if _, err := core.GetLogIndex(f.db, core.LogIndexKey(nil, nil), section, head); err != nil {
+			return logs, nil
+		}
+		var offsets []int
+		for _, key := range f.postings {
+			blob, _ := core.GetLogIndex(f.db, key, section, head) // missing means empty
+			for i := 0; i+1 < len(blob); i += 2 {
+				offsets = append(offsets, int(binary.BigEndian.Uint16(blob[i:])))
+			}
+		}
+		sort.Ints(offsets)
+
+		// Retrieve the logs of each distinct candidate block in range
+		for i, offset := range offsets {
+			if i > 0 && offsets[i-1] == offset {
+				continue
+			}
+			number := section*size + uint64(offset)
+			if number < uint64(f.begin) || number > end {
+				continue
+			}
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			logs = append(logs, found...)
+		}
+		if next := (section + 1) * size; next <= end {
+			f.begin = int64(next)
+		} else {
+			f.begin = int64(end) + 1
+		}
+		select {
+		case <-ctx.Done():
+			return logs, ctx.Err()
+		default:
+		}
+	}
+	return logs, nil
+}
+
 // indexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -56,7 +56,7 @@ func BenchmarkFilters(b *testing.B) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1      = crypto.PubkeyToAddress(key1.PublicKey)
 		addr2      = common.BytesToAddress([]byte("jeff"))
@@ -121,7 +121,7 @@ func TestFilters(t *testing.T) {
 		rmLogsFeed = new(event.Feed)
 		logsFeed   = new(event.Feed)
 		chainFeed  = new(event.Feed)
-		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed}
+		backend    = &testBackend{mux, db, 0, txFeed, rmLogsFeed, logsFeed, chainFeed, new(event.Feed)}
 		key1, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr       = crypto.PubkeyToAddress(key1.PublicKey)
 
@@ -241,3 +241,88 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+// logIndexBackend is a test backend that also reports an exact log index.
+type logIndexBackend struct {
+	*testBackend
+	size, sections uint64
+}
+
+func (b *logIndexBackend) LogIndexStatus() (uint64, uint64) {
+	return b.size, b.sections
+}
+
+// Tests that filters use the exact log index if available, falling back to the
+// other search methods for the rest of the range.
+func TestFiltersLogIndex(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &logIndexBackend{
+			testBackend: &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)},
+			size:        16,
+		}
+		addr  = common.BytesToAddress([]byte("indexed"))
+		topic = common.BytesToHash([]byte("topic"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 40, func(i int, gen *core.BlockGen) {
+		switch i + 1 {
+		case 3, 5, 20:
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{topic}}}
+			gen.AddUncheckedReceipt(receipt)
+		}
+	})
+	for i, block := range chain {
+		for _, receipt := range receipts[i] {
+			for _, log := range receipt.Logs {
+				log.BlockHash, log.BlockNumber = block.Hash(), block.NumberU64()
+			}
+		}
+		core.WriteBlock(db, block)
+		core.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		core.WriteHeadBlockHash(db, block.Hash())
+		core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Index only block 3 of the first section, so it's visible whether the index
+	// was used (block 5 is left out deliberately). The second section is claimed
+	// to be indexed, but is missing from the database.
+	head := core.GetCanonicalHash(db, 15)
+	core.WriteLogIndex(db, core.LogIndexKey(nil, nil), 0, head, []byte{})
+	core.WriteLogIndex(db, core.LogIndexKey(&addr, nil), 0, head, []byte{0, 3})
+	core.WriteLogIndex(db, core.LogIndexKey(&addr, &topic), 0, head, []byte{0, 3})
+	backend.sections = 2
+
+	tests := []struct {
+		addresses []common.Address
+		topics    [][]common.Hash
+		want      []uint64
+	}{
+		{[]common.Address{addr}, nil, []uint64{3, 20}},
+		{[]common.Address{addr}, [][]common.Hash{{topic}}, []uint64{3, 20}},
+		{nil, [][]common.Hash{{topic}}, []uint64{20}}, // no topic-only postings, section empty
+		{nil, [][]common.Hash{nil, nil}, []uint64{}},  // wildcards only, index unusable
+		{nil, nil, []uint64{3, 5, 20}},                // no criteria, index unusable
+	}
+	for i, tt := range tests {
+		logs, err := New(backend, 0, -1, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Errorf("test %d: filtering failed: %v", i, err)
+			continue
+		}
+		have := []uint64{}
+		for _, log := range logs {
+			have = append(have, log.BlockNumber)
+		}
+		if len(have) != len(tt.want) {
+			t.Errorf("test %d: block mismatch: have %v, want %v", i, have, tt.want)
+			continue
+		}
+		for j := range have {
+			if have[j] != tt.want[j] {
+				t.Errorf("test %d: block mismatch: have %v, want %v", i, have, tt.want)
+				break
+			}
+		}
+	}
+}
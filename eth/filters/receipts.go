@@ -0,0 +1,149 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+)
+
+// chainTrackLimit is the maximum number of recently notified blocks to remember
+// for detecting reorgs. Blocks dropped from deeper reorgs are not retracted.
+const chainTrackLimit = 256
+
+// blockReceipts is a notification of the newBlockReceipts subscription, carrying
+// a block with all its transactions, receipts and logs.
+type blockReceipts struct {
+	Removed  bool                     `json:"removed"`
+	Block    map[string]interface{}   `json:"block"`
+	Receipts []map[string]interface{} `json:"receipts"`
+}
+
+// newBlockReceipts assembles the notification of a block, retrieving its receipts.
+func newBlockReceipts(ctx context.Context, backend Backend, block *types.Block, removed bool) (*blockReceipts, error) {
+	receipts, err := backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := ethapi.RPCMarshalBlock(block, true, true)
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+
+	notification := &blockReceipts{
+		Removed:  removed,
+		Block:    fields,
+		Receipts: make([]map[string]interface{}, 0, len(receipts)),
+	}
+	for i, receipt := range receipts {
+		if i >= len(txs) {
+			break
+		}
+		notification.Receipts = append(notification.Receipts, ethapi.RPCMarshalReceipt(receipt, txs[i], block.Hash(), block.NumberU64(), uint64(i)))
+	}
+	return notification, nil
+}
+
+// chainTracker follows the canonical chain based on chain and side events,
+// reporting each new canonical block and each block dropped by a reorg exactly
+// once, in an order that can be applied sequentially by the recipient.
+//
+// Chain events are not fired for the blocks a reorg makes canonical (only for
+// the new head), so the tracker fills in the gaps by walking the parents of
+// each new head until reaching a block it already reported.
+type chainTracker struct {
+	backend Backend
+	blocks  []*types.Block // Recently reported canonical blocks, oldest first
+	report  func(block *types.Block, removed bool)
+}
+
+// newChainTracker creates a tracker reporting blocks to the given callback.
+func newChainTracker(backend Backend, report func(*types.Block, bool)) *chainTracker {
+	return &chainTracker{backend: backend, report: report}
+}
+
+// newHead handles a chain event, reporting the new head along with any other
+// blocks that became canonical, after retracting the ones that were dropped.
+func (t *chainTracker) newHead(ctx context.Context, head *types.Block) error {
+	// Collect the new blocks, down to the first one already reported
+	var added []*types.Block
+	for block := head; block != nil && !t.reported(block); {
+		added = append(added, block)
+		if len(t.blocks) == 0 || block.NumberU64() <= t.blocks[0].NumberU64() {
+			break // reorg deeper than what's tracked, don't go any further
+		}
+		parent, err := t.backend.GetBlock(ctx, block.ParentHash())
+		if err != nil {
+			return err
+		}
+		block = parent
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	// Retract all reported blocks not on the new chain, then report the new ones
+	t.rewind(added[len(added)-1].NumberU64())
+	for i := len(added) - 1; i >= 0; i-- {
+		if n := len(t.blocks); n > 0 && t.blocks[n-1].NumberU64()+1 != added[i].NumberU64() {
+			t.blocks = t.blocks[:0] // gap in the chain, restart tracking
+		}
+		t.blocks = append(t.blocks, added[i])
+		t.report(added[i], false)
+	}
+	if len(t.blocks) > chainTrackLimit {
+		t.blocks = append(t.blocks[:0], t.blocks[len(t.blocks)-chainTrackLimit:]...)
+	}
+	return nil
+}
+
+// sideBlock handles a chain side event. If it's about a previously reported
+// block that's not canonical any more, it and all its descendants are retracted.
+func (t *chainTracker) sideBlock(block *types.Block) {
+	if !t.reported(block) {
+		return
+	}
+	if core.GetCanonicalHash(t.backend.ChainDb(), block.NumberU64()) == block.Hash() {
+		return
+	}
+	t.rewind(block.NumberU64())
+}
+
+// reported checks whether the given block was reported as canonical.
+func (t *chainTracker) reported(block *types.Block) bool {
+	if len(t.blocks) == 0 {
+		return false
+	}
+	first := t.blocks[0].NumberU64()
+	if number := block.NumberU64(); number < first || number >= first+uint64(len(t.blocks)) {
+		return false
+	}
+	return t.blocks[block.NumberU64()-first].Hash() == block.Hash()
+}
+
+// rewind retracts all the reported blocks with a number of at least the given
+// one, newest first.
+func (t *chainTracker) rewind(number uint64) {
+	for len(t.blocks) > 0 && t.blocks[len(t.blocks)-1].NumberU64() >= number {
+		block := t.blocks[len(t.blocks)-1]
+		t.blocks = t.blocks[:len(t.blocks)-1]
+		t.report(block, true)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Tests that the chain tracker reports new canonical blocks in order, and that
+// reorgs retract the dropped blocks before reporting the new ones.
+func TestChainTracker(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		addr    = common.BytesToAddress([]byte("tracker"))
+	)
+	chain, side := resumeTestChain(t, db, addr)
+
+	type report struct {
+		hash    common.Hash
+		removed bool
+	}
+	var reports []report
+	tracker := newChainTracker(backend, func(block *types.Block, removed bool) {
+		reports = append(reports, report{block.Hash(), removed})
+	})
+	check := func(step string, want ...report) {
+		if len(reports) != len(want) {
+			t.Fatalf("%s: report count mismatch: have %d, want %d", step, len(reports), len(want))
+		}
+		for i := range want {
+			if reports[i] != want[i] {
+				t.Errorf("%s: report %d mismatch: have %x (removed %v), want %x (removed %v)", step, i, reports[i].hash, reports[i].removed, want[i].hash, want[i].removed)
+			}
+		}
+		reports = nil
+	}
+	canonical := func(blocks ...*types.Block) {
+		for _, block := range blocks {
+			core.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		}
+	}
+	// Extend the chain block by block
+	for _, block := range chain[:5] {
+		if err := tracker.newHead(context.Background(), block); err != nil {
+			t.Fatalf("failed to track head %d: %v", block.NumberU64(), err)
+		}
+	}
+	check("extend", report{chain[0].Hash(), false}, report{chain[1].Hash(), false}, report{chain[2].Hash(), false}, report{chain[3].Hash(), false}, report{chain[4].Hash(), false})
+
+	// Reorg to the side chain, only announcing its head
+	canonical(side...)
+	if err := tracker.newHead(context.Background(), side[1]); err != nil {
+		t.Fatalf("failed to track side head: %v", err)
+	}
+	check("reorg", report{chain[4].Hash(), true}, report{side[0].Hash(), false}, report{side[1].Hash(), false})
+
+	// Side events for never reported blocks are ignored, stale reported ones retracted
+	tracker.sideBlock(chain[4])
+	check("unreported side")
+
+	canonical(chain[4:7]...)
+	tracker.sideBlock(side[1])
+	check("stale side", report{side[1].Hash(), true})
+
+	// Reorg back to the canonical chain, skipping a few announcements
+	if err := tracker.newHead(context.Background(), chain[6]); err != nil {
+		t.Fatalf("failed to track canonical head: %v", err)
+	}
+	check("reorg back", report{side[0].Hash(), true}, report{chain[4].Hash(), false}, report{chain[5].Hash(), false}, report{chain[6].Hash(), false})
+
+	// Announcing an already reported block is a noop
+	if err := tracker.newHead(context.Background(), chain[5]); err != nil {
+		t.Fatalf("failed to track old head: %v", err)
+	}
+	check("duplicate")
+}
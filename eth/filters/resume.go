@@ -0,0 +1,245 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// replayBatchBlocks is the number of blocks searched for historical logs at
+	// once while catching up a resumed log subscription.
+	replayBatchBlocks = 4096
+
+	// replayDedupBlocks is the number of blocks below the live cutoff whose
+	// replayed logs are tracked for de-duplication against live ones. Reorgs of
+	// older blocks are forwarded as is.
+	replayDedupBlocks = 128
+
+	// maxPendingLogs is the number of live logs queued up during a replay before
+	// the resumed subscription is aborted.
+	maxPendingLogs = 16384
+)
+
+var (
+	errUnknownCursor = errors.New("unknown cursor block")
+	errPendingCursor = errors.New("cannot resume from pending block")
+	errReplayTooSlow = errors.New("too many live logs during replay")
+)
+
+// logKey uniquely identifies a log within the chain.
+type logKey struct {
+	block common.Hash // Hash of the block containing the log
+	index uint        // Index of the log within the block
+}
+
+// LogCursor is the starting point of a resumable log subscription. It is either
+// a block number (or one of the "earliest" and "latest" tags), or the hash of a
+// block, usually the one of the last log received before a disconnect.
+type LogCursor struct {
+	Number *rpc.BlockNumber
+	Hash   *common.Hash
+}
+
+// UnmarshalJSON sets *c fields from either a block hash or a block number.
+func (c *LogCursor) UnmarshalJSON(input []byte) error {
+	var hash common.Hash
+	if err := hash.UnmarshalJSON(input); err == nil {
+		c.Hash, c.Number = &hash, nil
+		return nil
+	}
+	var number rpc.BlockNumber
+	if err := number.UnmarshalJSON(input); err != nil {
+		return err
+	}
+	if number == rpc.PendingBlockNumber {
+		return errPendingCursor
+	}
+	c.Hash, c.Number = nil, &number
+	return nil
+}
+
+// resolveLogCursor converts a cursor into the first canonical block to replay
+// logs from. If the cursor is a block that has since been reorged out of the
+// canonical chain, the logs matching crit in all the blocks up to the common
+// ancestor are returned, flagged as removed.
+func resolveLogCursor(ctx context.Context, backend Backend, crit FilterCriteria, cursor LogCursor, head uint64) (uint64, []*types.Log, error) {
+	// Block numbers are easy, just replay from there on
+	if cursor.Hash == nil {
+		switch {
+		case cursor.Number == nil || *cursor.Number == rpc.LatestBlockNumber:
+			return head, nil, nil
+		case *cursor.Number == rpc.PendingBlockNumber:
+			return 0, nil, errPendingCursor
+		}
+		return uint64(*cursor.Number), nil, nil
+	}
+	// Block hashes need to be checked for reorgs, retracting stale logs
+	var (
+		db      = backend.ChainDb()
+		hash    = *cursor.Hash
+		number  = core.GetBlockNumber(db, hash)
+		removed []*types.Log
+	)
+	header := core.GetHeader(db, hash, number)
+	if header == nil {
+		return 0, nil, errUnknownCursor
+	}
+	if core.GetCanonicalHash(db, number) == hash {
+		return number, nil, nil
+	}
+	for core.GetCanonicalHash(db, number) != hash {
+		logs, err := blockLogs(ctx, backend, hash, crit)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, log := range logs {
+			stale := *log
+			stale.Removed = true
+			removed = append(removed, &stale)
+		}
+		if hash, number = header.ParentHash, number-1; number == 0 {
+			break // genesis is always canonical
+		}
+		if header = core.GetHeader(db, hash, number); header == nil {
+			return 0, nil, errUnknownCursor
+		}
+	}
+	// The common ancestor was fully delivered before, continue from its child
+	return number + 1, removed, nil
+}
+
+// blockLogs retrieves the logs of a single block that match the filter criteria.
+func blockLogs(ctx context.Context, backend Backend, hash common.Hash, crit FilterCriteria) ([]*types.Log, error) {
+	logsList, err := backend.GetLogs(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	var unfiltered []*types.Log
+	for _, logs := range logsList {
+		unfiltered = append(unfiltered, logs...)
+	}
+	return filterLogs(unfiltered, nil, nil, crit.Addresses, crit.Topics), nil
+}
+
+// resumeLogs delivers the removed logs of a resolved cursor, replays the canonical
+// logs in the [start, head] range matching crit, and then switches over to the
+// live logs arriving on the given channel. The live channel must be subscribed
+// before head is retrieved to avoid gaps, and is drained during the replay so
+// the event system is never blocked.
+//
+// Live logs overlapping with the last replayDedupBlocks blocks of the replayed
+// range are de-duplicated log by log: new logs already delivered are dropped, as
+// are removals of logs never delivered. At most maxPendingLogs live logs are
+// queued up while the replay is running.
+//
+// The method returns when the context is cancelled or the replay fails.
+func resumeLogs(ctx context.Context, backend Backend, crit FilterCriteria, start, head uint64, removed []*types.Log, live <-chan []*types.Log, notify func(*types.Log)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	replayed, done := make(chan []*types.Log), make(chan error, 1)
+	go func() {
+		done <- replayLogs(ctx, backend, crit, start, head, replayed)
+	}()
+	var (
+		delivered = make(map[logKey]bool) // Recent logs up to head currently delivered
+		pending   [][]*types.Log          // Live logs queued up during replay
+		queued    int                     // Number of logs in the pending batches
+	)
+	// dedup reports whether a log is within the de-duplicated range of blocks
+	dedup := func(log *types.Log) bool {
+		return log.BlockNumber <= head && log.BlockNumber+replayDedupBlocks > head
+	}
+	forward := func(logs []*types.Log) {
+		for _, log := range logs {
+			if dedup(log) {
+				key := logKey{log.BlockHash, log.Index}
+				if log.Removed != delivered[key] {
+					continue
+				}
+				delivered[key] = !log.Removed
+			}
+			notify(log)
+		}
+	}
+	for _, log := range removed {
+		notify(log)
+	}
+	for {
+		select {
+		case logs := <-replayed:
+			for _, log := range logs {
+				if dedup(log) {
+					delivered[logKey{log.BlockHash, log.Index}] = true
+				}
+				notify(log)
+			}
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			done = nil
+			for _, logs := range pending {
+				forward(logs)
+			}
+			pending, queued = nil, 0
+
+		case logs := <-live:
+			if done != nil {
+				if queued += len(logs); queued > maxPendingLogs {
+					return errReplayTooSlow
+				}
+				pending = append(pending, logs)
+			} else {
+				forward(logs)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// replayLogs searches the canonical chain between start and head (inclusive) for
+// logs matching the filter criteria, feeding them in batches into the sink.
+func replayLogs(ctx context.Context, backend Backend, crit FilterCriteria, start, head uint64, sink chan<- []*types.Log) error {
+	for begin := start; begin <= head; begin += replayBatchBlocks {
+		end := begin + replayBatchBlocks - 1
+		if end > head {
+			end = head
+		}
+		logs, err := New(backend, int64(begin), int64(end), crit.Addresses, crit.Topics).Logs(ctx)
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			continue
+		}
+		select {
+		case sink <- logs:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
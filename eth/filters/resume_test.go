@@ -0,0 +1,315 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// resumeTestChain creates a canonical chain with logs in blocks 2, 5 and 8, and
+// a stale side chain forking off at block 4 with a log in its block 5.
+func resumeTestChain(t *testing.T, db ethdb.Database, addr common.Address) ([]*types.Block, []*types.Block) {
+	withLogs := func(numbers ...int) func(int, *core.BlockGen) {
+		return func(i int, gen *core.BlockGen) {
+			for _, n := range numbers {
+				if i+1 == n {
+					receipt := types.NewReceipt(nil, false, 0)
+					receipt.Logs = []*types.Log{{Address: addr}}
+					gen.AddUncheckedReceipt(receipt)
+				}
+			}
+		}
+	}
+	write := func(blocks []*types.Block, receipts []types.Receipts, canonical bool) {
+		for i, block := range blocks {
+			for _, receipt := range receipts[i] {
+				for _, log := range receipt.Logs {
+					log.BlockHash, log.BlockNumber = block.Hash(), block.NumberU64()
+				}
+			}
+			core.WriteBlock(db, block)
+			if err := core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i]); err != nil {
+				t.Fatalf("failed to write receipts: %v", err)
+			}
+			if canonical {
+				core.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+				core.WriteHeadBlockHash(db, block.Hash())
+			}
+		}
+	}
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 10, withLogs(2, 5, 8))
+	write(chain, receipts, true)
+
+	side, receipts := core.GenerateChain(params.TestChainConfig, chain[3], ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+		withLogs(1)(i, gen)
+	})
+	write(side, receipts, false)
+
+	return chain, side
+}
+
+// Tests that log cursors are parsed from both block numbers and hashes.
+func TestLogCursorUnmarshal(t *testing.T) {
+	var cursor LogCursor
+	if err := json.Unmarshal([]byte(`"0x10"`), &cursor); err != nil {
+		t.Fatalf("failed to parse number cursor: %v", err)
+	}
+	if cursor.Hash != nil || cursor.Number == nil || *cursor.Number != 16 {
+		t.Errorf("number cursor mismatch: %+v", cursor)
+	}
+	hash := common.HexToHash("0x0102030405060708091011121314151617181920212223242526272829303132")
+	if err := json.Unmarshal([]byte(`"`+hash.Hex()+`"`), &cursor); err != nil {
+		t.Fatalf("failed to parse hash cursor: %v", err)
+	}
+	if cursor.Number != nil || cursor.Hash == nil || *cursor.Hash != hash {
+		t.Errorf("hash cursor mismatch: %+v", cursor)
+	}
+	if err := json.Unmarshal([]byte(`"pending"`), &cursor); err != errPendingCursor {
+		t.Errorf("pending cursor error mismatch: have %v, want %v", err, errPendingCursor)
+	}
+}
+
+// Tests that cursors are resolved to the correct starting block, retracting the
+// logs of stale blocks if the cursor was reorged out.
+func TestResolveLogCursor(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		addr    = common.BytesToAddress([]byte("resume"))
+		crit    = FilterCriteria{Addresses: []common.Address{addr}}
+	)
+	chain, side := resumeTestChain(t, db, addr)
+
+	number, latest := rpc.BlockNumber(3), rpc.LatestBlockNumber
+	canonical, stale, unknown := chain[6].Hash(), side[1].Hash(), common.Hash{0xff}
+
+	tests := []struct {
+		cursor  LogCursor
+		start   uint64
+		removed []common.Hash
+		err     error
+	}{
+		{cursor: LogCursor{Number: &number}, start: 3},
+		{cursor: LogCursor{Number: &latest}, start: 10},
+		{cursor: LogCursor{Hash: &canonical}, start: 7},
+		{cursor: LogCursor{Hash: &stale}, start: 5, removed: []common.Hash{side[0].Hash()}},
+		{cursor: LogCursor{Hash: &unknown}, err: errUnknownCursor},
+	}
+	for i, tt := range tests {
+		start, removed, err := resolveLogCursor(context.Background(), backend, crit, tt.cursor, 10)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if start != tt.start {
+			t.Errorf("test %d: start mismatch: have %d, want %d", i, start, tt.start)
+		}
+		if len(removed) != len(tt.removed) {
+			t.Errorf("test %d: removed log count mismatch: have %d, want %d", i, len(removed), len(tt.removed))
+			continue
+		}
+		for j, log := range removed {
+			if !log.Removed || log.BlockHash != tt.removed[j] {
+				t.Errorf("test %d: removed log %d mismatch: %+v", i, j, log)
+			}
+		}
+	}
+}
+
+// Tests that resumed subscriptions replay historical logs and then switch over
+// to live ones, without duplicating or dropping anything in between.
+func TestResumeLogs(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		addr    = common.BytesToAddress([]byte("resume"))
+		crit    = FilterCriteria{Addresses: []common.Address{addr}}
+	)
+	chain, side := resumeTestChain(t, db, addr)
+
+	logsOf := func(block *types.Block, removed bool) []*types.Log {
+		var logs []*types.Log
+		for _, receipt := range core.GetBlockReceipts(db, block.Hash(), block.NumberU64()) {
+			for _, log := range receipt.Logs {
+				log.Removed = removed
+				logs = append(logs, log)
+			}
+		}
+		return logs
+	}
+	// Queue up live events overlapping the replayed range (pretending the head
+	// was block 8 when the subscription was made), before the replay finishes.
+	live := make(chan []*types.Log, 4)
+	live <- logsOf(chain[7], false)                                                   // block 8 already replayed, must be skipped
+	live <- logsOf(side[0], true)                                                     // stale block 5 never delivered, must be skipped
+	live <- logsOf(chain[7], true)                                                    // block 8 reorged out, must be forwarded
+	live <- []*types.Log{{Address: addr, BlockHash: chain[8].Hash(), BlockNumber: 9}} // new block, must be forwarded
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		notified    = make(chan *types.Log, 16)
+		done        = make(chan error)
+	)
+	defer cancel()
+
+	removed := logsOf(side[0], true)
+	go func() {
+		done <- resumeLogs(ctx, backend, crit, 3, 8, removed, live, func(log *types.Log) { notified <- log })
+	}()
+	want := []struct {
+		hash    common.Hash
+		removed bool
+	}{
+		{side[0].Hash(), true},
+		{chain[4].Hash(), false},
+		{chain[7].Hash(), false},
+		{chain[7].Hash(), true},
+		{chain[8].Hash(), false},
+	}
+	for i, w := range want {
+		select {
+		case log := <-notified:
+			if log.BlockHash != w.hash || log.Removed != w.removed {
+				t.Fatalf("log %d mismatch: have %x (removed %v), want %x (removed %v)", i, log.BlockHash, log.Removed, w.hash, w.removed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("log %d: timeout", i)
+		}
+	}
+	select {
+	case log := <-notified:
+		t.Fatalf("unexpected log: %+v", log)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("resume error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
+
+// Tests that only live logs of the recent blocks of the replayed range are checked
+// against the replayed ones, older reorgs being forwarded as is.
+func TestResumeLogsDedupWindow(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		addr    = common.BytesToAddress([]byte("resume"))
+		head    = uint64(1000)
+	)
+	// Removals of blocks never replayed, one older than the de-duplicated range
+	live := make(chan []*types.Log, 2)
+	live <- []*types.Log{{Address: addr, BlockHash: common.Hash{1}, BlockNumber: head - replayDedupBlocks, Removed: true}}
+	live <- []*types.Log{{Address: addr, BlockHash: common.Hash{2}, BlockNumber: head - replayDedupBlocks + 1, Removed: true}}
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		notified    = make(chan *types.Log, 16)
+		done        = make(chan error)
+	)
+	defer cancel()
+
+	go func() {
+		done <- resumeLogs(ctx, backend, FilterCriteria{}, head+1, head, nil, live, func(log *types.Log) { notified <- log })
+	}()
+	select {
+	case log := <-notified:
+		if log.BlockHash != (common.Hash{1}) {
+			t.Fatalf("log mismatch: have %x, want %x", log.BlockHash, common.Hash{1})
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout")
+	}
+	select {
+	case log := <-notified:
+		t.Fatalf("unexpected log: %+v", log)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("resume error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
+
+// Tests that live logs within the de-duplicated range are tracked individually, so
+// that blocks with several logs and their reorgs are forwarded in full.
+func TestResumeLogsDedupMultiple(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		backend = &testBackend{new(event.TypeMux), db, 0, new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed), new(event.Feed)}
+		addr    = common.BytesToAddress([]byte("resume"))
+		head    = uint64(1000)
+	)
+	logsOf := func(hash common.Hash, removed bool, indexes ...uint) []*types.Log {
+		var logs []*types.Log
+		for _, index := range indexes {
+			logs = append(logs, &types.Log{Address: addr, BlockHash: hash, BlockNumber: head - 1, Index: index, Removed: removed})
+		}
+		return logs
+	}
+	live := make(chan []*types.Log, 4)
+	live <- logsOf(common.Hash{1}, false, 0, 1, 2) // new block, must be forwarded in full
+	live <- logsOf(common.Hash{1}, true, 0, 1, 2)  // block reorged out, must be forwarded in full
+	live <- logsOf(common.Hash{2}, false, 0, 1)    // replacement block, must be forwarded in full
+	live <- logsOf(common.Hash{1}, true, 1)        // already removed, must be skipped
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		notified    = make(chan *types.Log, 16)
+		done        = make(chan error)
+	)
+	defer cancel()
+
+	go func() {
+		done <- resumeLogs(ctx, backend, FilterCriteria{}, head+1, head, nil, live, func(log *types.Log) { notified <- log })
+	}()
+	want := append(append(logsOf(common.Hash{1}, false, 0, 1, 2), logsOf(common.Hash{1}, true, 0, 1, 2)...), logsOf(common.Hash{2}, false, 0, 1)...)
+	for i, w := range want {
+		select {
+		case log := <-notified:
+			if log.BlockHash != w.BlockHash || log.Index != w.Index || log.Removed != w.Removed {
+				t.Fatalf("log %d mismatch: have %x/%d (removed %v), want %x/%d (removed %v)", i, log.BlockHash, log.Index, log.Removed, w.BlockHash, w.Index, w.Removed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("log %d: timeout", i)
+		}
+	}
+	select {
+	case log := <-notified:
+		t.Fatalf("unexpected log: %+v", log)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("resume error mismatch: have %v, want %v", err, context.Canceled)
+	}
+}
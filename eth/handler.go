@@ -38,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -49,6 +50,9 @@ const (
 	// txChanSize is the size of channel listening to TxPreEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
 )
 
 var (
@@ -73,6 +77,7 @@ type ProtocolManager struct {
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
 	maxPeers    int
+	fork        forkID // Fork identifier advertised in the local node record
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
@@ -98,7 +103,7 @@ type ProtocolManager struct {
 
 // NewProtocolManager returns a new Ethereum sub protocol manager. The Ethereum sub protocol manages peers capable
 // with the Ethereum network.
-func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
+func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, checkpoint *downloader.Checkpoint, networkId uint64, mux *event.TypeMux, txpool txPool, engine consensus.Engine, blockchain *core.BlockChain, chaindb ethdb.Database) (*ProtocolManager, error) {
 	// Create the protocol manager with the base fields
 	manager := &ProtocolManager{
 		networkId:   networkId,
@@ -121,6 +126,14 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		manager.fastSync = uint32(1)
 	}
 	// Initiate a sub-protocol for every implemented version we can handle
+	// Advertise our chain in the node record and only dial nodes on the same one
+	var (
+		genesis = blockchain.Genesis().Hash()
+		head    = func() uint64 { return blockchain.CurrentHeader().Number.Uint64() }
+		fork    = newForkID(config, genesis, head())
+	)
+	manager.fork = fork
+
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
@@ -153,13 +166,15 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 				}
 				return nil
 			},
+			Attributes:    []enr.Entry{ethEntry{ForkID: fork}},
+			DialCandidate: dialCandidate(config, genesis, head),
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, checkpoint, chaindb, manager.eventMux, blockchain, nil, manager.syncDropPeer)
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -176,7 +191,7 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.misbehavingPeer)
 
 	return manager, nil
 }
@@ -200,6 +215,27 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// syncDropPeer is the callback of the downloader for peers failing a sync. Only
+// peers that delivered invalid data are penalized, slow or useless ones are just
+// dropped.
+func (pm *ProtocolManager) syncDropPeer(id string, reason error) {
+	if downloader.InvalidData(reason) {
+		pm.misbehavingPeer(id)
+		return
+	}
+	pm.removePeer(id)
+}
+
+// misbehavingPeer is the callback of the fetcher for peers that delivered invalid
+// data. The peer is penalized before being dropped, so that repeat offenders get
+// banned from reconnecting.
+func (pm *ProtocolManager) misbehavingPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Peer.Penalize(p2p.SeverityMedium, "delivered invalid data")
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -718,6 +754,17 @@ func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction)
 	// Broadcast transaction to a batch of peers not knowing about it
 	peers := pm.peers.PeersWithoutTx(hash)
 	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
+
+	// Private transactions are only forwarded to trusted peers
+	if pm.txpool.IsPrivate(hash) {
+		trusted := peers[:0]
+		for _, peer := range peers {
+			if peer.Trusted() {
+				trusted = append(trusted, peer)
+			}
+		}
+		peers = trusted
+	}
 	for _, peer := range peers {
 		peer.SendTransactions(types.Transactions{tx})
 	}
@@ -0,0 +1,159 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// logIndexConfirms is the number of confirmation blocks before a log index
+	// section is considered probably final and its posting lists are written.
+	logIndexConfirms = 256
+
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// index sections. It's useful during chain upgrades to prevent disk overload.
+	logIndexThrottling = 100 * time.Millisecond
+)
+
+// LogIndexer implements a core.ChainIndexer, building up an exact index from log
+// addresses and first topics to the blocks containing them. Contrary to the bloom
+// bits, the index has no false positives, so popular contracts don't cause a lot
+// of needless receipt retrievals when filtering.
+type LogIndexer struct {
+	size uint64 // section size to generate posting lists for
+
+	db       ethdb.Database           // database instance to write index data and metadata into
+	postings map[common.Hash][]uint16 // block offsets within the section for each posting key
+
+	section uint64      // Section is the section number being processed currently
+	head    common.Hash // Head is the hash of the last header processed
+}
+
+// NewLogIndexer returns a chain indexer that generates log posting lists for the
+// canonical chain for exact logs filtering.
+func NewLogIndexer(db ethdb.Database, size uint64) *core.ChainIndexer {
+	backend := &LogIndexer{
+		db:   db,
+		size: size,
+	}
+	table := ethdb.NewTable(db, string(core.LogIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, logIndexConfirms, logIndexThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+func (b *LogIndexer) Reset(section uint64, lastSectionHead common.Hash) error {
+	b.postings, b.section, b.head = make(map[common.Hash][]uint16), section, common.Hash{}
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new header's
+// receipts into the index.
+func (b *LogIndexer) Process(header *types.Header) {
+	var (
+		hash   = header.Hash()
+		number = header.Number.Uint64()
+		offset = uint16(number - b.section*b.size)
+	)
+	for _, receipt := range core.GetBlockReceipts(b.db, hash, number) {
+		for _, log := range receipt.Logs {
+			address := log.Address
+			b.post(core.LogIndexKey(&address, nil), offset)
+			if len(log.Topics) > 0 {
+				topic := log.Topics[0]
+				b.post(core.LogIndexKey(nil, &topic), offset)
+				b.post(core.LogIndexKey(&address, &topic), offset)
+			}
+		}
+	}
+	b.head = hash
+}
+
+// post adds a block offset to a posting list, unless already present. Headers are
+// processed sequentially, so it's enough to check the last item.
+func (b *LogIndexer) post(key common.Hash, offset uint16) {
+	list := b.postings[key]
+	if len(list) > 0 && list[len(list)-1] == offset {
+		return
+	}
+	b.postings[key] = append(list, offset)
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the log index section
+// and writing it out into the database.
+func (b *LogIndexer) Commit() error {
+	batch := b.db.NewBatch()
+
+	core.WriteLogIndex(batch, core.LogIndexKey(nil, nil), b.section, b.head, []byte{})
+	for key, list := range b.postings {
+		blob := make([]byte, 2*len(list))
+		for i, offset := range list {
+			binary.BigEndian.PutUint16(blob[2*i:], offset)
+		}
+		core.WriteLogIndex(batch, key, b.section, b.head, blob)
+
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
+
+// RebuildLogIndex discards the existing log index and regenerates it for the
+// entire canonical chain, blocking until all sections are processed.
+func RebuildLogIndex(chain *core.BlockChain, db ethdb.Database) error {
+	indexer := NewLogIndexer(db, params.BloomBitsBlocks)
+	defer indexer.Close()
+
+	indexer.DropSections()
+
+	head := chain.CurrentHeader().Number.Uint64()
+	if head+1 < logIndexConfirms {
+		return nil
+	}
+	target := (head + 1 - logIndexConfirms) / params.BloomBitsBlocks
+	indexer.Start(chain)
+
+	var (
+		start  = time.Now()
+		logged = start
+	)
+	for {
+		sections, _, _ := indexer.Sections()
+		if sections >= target {
+			log.Info("Rebuilt log index", "sections", sections, "elapsed", common.PrettyDuration(time.Since(start)))
+			return nil
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Rebuilding log index", "sections", sections, "target", target, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the log indexer generates exact posting lists for log addresses,
+// first topics and their combinations.
+func TestLogIndexer(t *testing.T) {
+	var (
+		db, _   = ethdb.NewMemDatabase()
+		genesis = new(core.Genesis).MustCommit(db)
+
+		addr1, addr2   = common.Address{0x01}, common.Address{0x02}
+		topic1, topic2 = common.Hash{0x01}, common.Hash{0x02}
+	)
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 16, func(i int, gen *core.BlockGen) {
+		var logs []*types.Log
+		switch i + 1 {
+		case 2:
+			logs = []*types.Log{{Address: addr1, Topics: []common.Hash{topic1}}, {Address: addr1, Topics: []common.Hash{topic1}}}
+		case 5:
+			logs = []*types.Log{{Address: addr2, Topics: []common.Hash{topic1, topic2}}}
+		case 9:
+			logs = []*types.Log{{Address: addr1}, {Address: addr2, Topics: []common.Hash{topic2}}}
+		default:
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = logs
+		gen.AddUncheckedReceipt(receipt)
+	})
+	for i, block := range blocks {
+		core.WriteBlock(db, block)
+		core.WriteBlockReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Index the first section and verify the posting lists
+	indexer := &LogIndexer{db: db, size: 16}
+	indexer.Reset(0, common.Hash{})
+	indexer.Process(genesis.Header())
+	for _, block := range blocks[:15] {
+		indexer.Process(block.Header())
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit section: %v", err)
+	}
+	head := blocks[14].Hash()
+
+	tests := []struct {
+		address *common.Address
+		topic   *common.Hash
+		blocks  []byte
+	}{
+		{nil, nil, []byte{}},
+		{&addr1, nil, []byte{0, 2, 0, 9}},
+		{&addr2, nil, []byte{0, 5, 0, 9}},
+		{nil, &topic1, []byte{0, 2, 0, 5}},
+		{nil, &topic2, []byte{0, 9}},
+		{&addr1, &topic1, []byte{0, 2}},
+		{&addr2, &topic2, []byte{0, 9}},
+		{&addr1, &topic2, nil},
+	}
+	for i, tt := range tests {
+		blob, err := core.GetLogIndex(db, core.LogIndexKey(tt.address, tt.topic), 0, head)
+		if tt.blocks == nil {
+			if err == nil {
+				t.Errorf("test %d: unexpected posting list: %x", i, blob)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: posting list missing: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(blob, tt.blocks) {
+			t.Errorf("test %d: posting list mismatch: have %x, want %x", i, blob, tt.blocks)
+		}
+	}
+}
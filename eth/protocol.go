@@ -106,6 +106,10 @@ type txPool interface {
 	// SubscribeTxPreEvent should return an event subscription of
 	// TxPreEvent and send events to the given channel.
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
+
+	// IsPrivate should return whether a transaction may only be propagated to
+	// trusted peers.
+	IsPrivate(hash common.Hash) bool
 }
 
 // statusData is the network packet for the status message.
@@ -0,0 +1,285 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// regenCacheSize is the memory allowance for the tries regenerated on top of the
+// persistent state, above which the least recently used roots are released.
+const regenCacheSize = 128 * 1024 * 1024
+
+// errStateUnavailable is returned if no ancestor of a block has its state available
+// within the allowed number of blocks to re-execute.
+var errStateUnavailable = errors.New("required historical state unavailable")
+
+// stateRegenerator recreates the state of historical blocks on a pruned node by
+// walking back to the nearest ancestor with an available state - a persisted
+// state checkpoint or a previously regenerated root - and re-executing the blocks
+// on top of it.
+//
+// The roots generated along the way are kept referenced in a dedicated in-memory
+// trie database, so requests around the same historical blocks only need to
+// replay a handful of blocks.
+type stateRegenerator struct {
+	chain      *core.BlockChain
+	db         ethdb.Database
+	checkpoint uint64             // Number of blocks between persisted state checkpoints (0 = disabled)
+	cacheSize  common.StorageSize // Memory allowance for the regenerated tries
+
+	database state.Database                // State database holding the regenerated tries
+	roots    []common.Hash                 // Regenerated roots, least recently used first
+	cached   map[common.Hash]bool          // Set of regenerated roots for fast lookups
+	held     map[common.Hash]int           // Number of handed out states of each cached root
+	pending  map[common.Hash]chan struct{} // Regenerations in progress, closed when finished
+	lock     sync.Mutex                    // Protects the cache, not held while re-executing blocks
+}
+
+// newStateRegenerator creates a historical state regenerator on top of a chain.
+func newStateRegenerator(chain *core.BlockChain, db ethdb.Database, checkpoint uint64) *stateRegenerator {
+	return &stateRegenerator{
+		chain:      chain,
+		db:         db,
+		checkpoint: checkpoint,
+		cacheSize:  regenCacheSize,
+		database:   state.NewDatabase(db),
+		cached:     make(map[common.Hash]bool),
+		held:       make(map[common.Hash]int),
+		pending:    make(map[common.Hash]chan struct{}),
+	}
+}
+
+// StateAt returns the state associated with a block header, regenerating it by
+// re-executing at most limit blocks if it's not available locally. The returned
+// release function must be called when the state is not used any more, so that
+// its root can be dropped from the cache.
+//
+// Blocks are re-executed without holding the regenerator lock, only concurrent
+// requests for the same state wait for each other. The context is checked between
+// blocks, aborting the regeneration if the request is cancelled.
+func (r *stateRegenerator) StateAt(ctx context.Context, header *types.Header, limit uint64) (*state.StateDB, func(), error) {
+	// If the chain has the state fully available, use that
+	if statedb, err := r.chain.StateAt(header.Root); err == nil {
+		return statedb, func() {}, nil
+	}
+	// Wait for any regeneration of the same state to finish
+	r.lock.Lock()
+	for {
+		done, ok := r.pending[header.Root]
+		if !ok {
+			break
+		}
+		r.lock.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		r.lock.Lock()
+	}
+	// Otherwise walk back until an ancestor with available state is found
+	var (
+		database = r.database
+		headers  []*types.Header
+		base     *types.Header
+		statedb  *state.StateDB
+		err      error
+	)
+	for current := header; ; {
+		if statedb, err = state.New(current.Root, database); err == nil {
+			base = current
+			break
+		}
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			r.lock.Unlock()
+			return nil, nil, err
+		}
+		if uint64(len(headers)) >= limit || current.Number.Uint64() == 0 {
+			r.lock.Unlock()
+			return nil, nil, errStateUnavailable
+		}
+		headers = append(headers, current)
+
+		if current = r.chain.GetHeader(current.ParentHash, current.Number.Uint64()-1); current == nil {
+			r.lock.Unlock()
+			return nil, nil, fmt.Errorf("missing ancestor of block #%d", headers[len(headers)-1].Number)
+		}
+	}
+	if len(headers) == 0 {
+		if r.cached[header.Root] {
+			r.touch(header.Root)
+		}
+		release := r.hold(header.Root)
+		r.lock.Unlock()
+		return statedb, release, nil
+	}
+	// State was available at historical point, claim the regeneration and keep the
+	// state being built upon from being released while replaying without the lock
+	done := make(chan struct{})
+	r.pending[header.Root] = done
+	parent := r.hold(base.Root)
+	r.lock.Unlock()
+
+	defer func() {
+		parent()
+
+		r.lock.Lock()
+		delete(r.pending, header.Root)
+		close(done)
+		r.lock.Unlock()
+	}()
+	var (
+		start  = time.Now()
+		logged time.Time
+	)
+	for i := len(headers) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		// Print progress logs if long enough time elapsed
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Regenerating historical state", "block", headers[i].Number, "target", header.Number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		// Retrieve the next block to regenerate and process it
+		block := r.chain.GetBlock(headers[i].Hash(), headers[i].Number.Uint64())
+		if block == nil {
+			return nil, nil, fmt.Errorf("block #%d [%x…] not found", headers[i].Number, headers[i].Hash().Bytes()[:4])
+		}
+		if _, _, _, err := r.chain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+			return nil, nil, err
+		}
+		// Finalize the state so any modifications are written to the trie
+		root, err := statedb.Commit(r.chain.Config().IsEIP158(block.Number()))
+		if err != nil {
+			return nil, nil, err
+		}
+		if root != block.Root() {
+			return nil, nil, fmt.Errorf("regenerated state root mismatch for block #%d: have %x, want %x", block.NumberU64(), root, block.Root())
+		}
+		if err := statedb.Reset(root); err != nil {
+			return nil, nil, err
+		}
+		// Persist the checkpoints missed by the chain, cache everything else
+		if r.checkpoint > 0 && block.NumberU64()%r.checkpoint == 0 {
+			if err := database.TrieDB().Commit(root, false); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		r.lock.Lock()
+		if r.database == database {
+			r.touch(root)
+
+			held := r.hold(root)
+			r.lock.Unlock()
+			parent()
+			parent = held
+		} else {
+			r.lock.Unlock()
+		}
+	}
+	r.lock.Lock()
+	release := r.hold(header.Root)
+	r.shrink()
+	r.lock.Unlock()
+
+	log.Info("Historical state regenerated", "block", header.Number, "replayed", len(headers), "elapsed", common.PrettyDuration(time.Since(start)), "cache", database.TrieDB().Size())
+	return statedb, release, nil
+}
+
+// touch marks a regenerated root as the most recently used one, referencing it
+// in the trie database if it's not yet tracked.
+func (r *stateRegenerator) touch(root common.Hash) {
+	if r.cached[root] {
+		for i, cached := range r.roots {
+			if cached == root {
+				r.roots = append(r.roots[:i], r.roots[i+1:]...)
+				break
+			}
+		}
+	} else {
+		r.database.TrieDB().Reference(root, common.Hash{})
+		r.cached[root] = true
+	}
+	r.roots = append(r.roots, root)
+}
+
+// hold marks a cached root as in use by a handed out state until the returned
+// function is called, preventing the root from being released in the meantime.
+func (r *stateRegenerator) hold(root common.Hash) func() {
+	if !r.cached[root] {
+		return func() {} // Persisted on disk, nothing to protect
+	}
+	r.held[root]++
+
+	var (
+		database = r.database
+		once     sync.Once
+	)
+	return func() {
+		once.Do(func() {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+
+			// Roots of a discarded database are not tracked any more
+			if r.database != database {
+				return
+			}
+			if r.held[root]--; r.held[root] <= 0 {
+				delete(r.held, root)
+			}
+		})
+	}
+}
+
+// shrink releases the least recently used regenerated roots not held by any
+// handed out state until the memory cache is back within its allowance, always
+// retaining the most recent one.
+func (r *stateRegenerator) shrink() {
+	triedb := r.database.TrieDB()
+	for i := 0; i < len(r.roots)-1 && triedb.Size() > r.cacheSize; {
+		root := r.roots[i]
+		if r.held[root] > 0 {
+			i++
+			continue
+		}
+		triedb.Dereference(root, common.Hash{})
+		delete(r.cached, root)
+		r.roots = append(r.roots[:i], r.roots[i+1:]...)
+	}
+	// Preimages are only released on commit, so if they alone exceed the allowance
+	// start over with a fresh database. Any state handed out keeps the old one alive.
+	if triedb.Size() > r.cacheSize && len(r.held) == 0 {
+		r.database = state.NewDatabase(r.db)
+		r.roots, r.cached, r.held = nil, make(map[common.Hash]bool), make(map[common.Hash]int)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newPrunedChain creates a pruning blockchain with the given number of blocks
+// imported, persisting a state checkpoint every interval blocks.
+func newPrunedChain(t *testing.T, blocks int, interval uint64) (*core.BlockChain, ethdb.Database, []*types.Block) {
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := new(core.Genesis).MustCommit(gendb)
+	chain, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), gendb, blocks, nil)
+
+	db, _ := ethdb.NewMemDatabase()
+	new(core.Genesis).MustCommit(db)
+
+	cacheConfig := &core.CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, StateCheckpoint: interval}
+	blockchain, err := core.NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	return blockchain, db, chain
+}
+
+// Tests that historical state is regenerated from the nearest state checkpoint
+// and that the regenerated roots are reused by subsequent requests.
+func TestStateRegeneration(t *testing.T) {
+	chain, db, blocks := newPrunedChain(t, 300, 64)
+	defer chain.Stop()
+
+	if _, err := chain.StateAt(blocks[99].Root()); err == nil {
+		t.Fatalf("pruned state of block #100 available")
+	}
+	regen := newStateRegenerator(chain, db, 64)
+
+	// Regenerating from the checkpoint at #64 needs 36 blocks to be re-executed
+	if _, _, err := regen.StateAt(context.Background(), blocks[99].Header(), 35); err != errStateUnavailable {
+		t.Fatalf("regeneration error mismatch: have %v, want %v", err, errStateUnavailable)
+	}
+	if _, _, err := regen.StateAt(context.Background(), blocks[99].Header(), 36); err != nil {
+		t.Fatalf("failed to regenerate state of block #100: %v", err)
+	}
+	for _, block := range blocks[64:100] {
+		if !regen.cached[block.Root()] {
+			t.Errorf("regenerated state of block #%d not cached", block.NumberU64())
+		}
+	}
+	// Neighbouring blocks should be regenerated from the cached roots
+	if _, _, err := regen.StateAt(context.Background(), blocks[100].Header(), 1); err != nil {
+		t.Fatalf("failed to regenerate state of block #101: %v", err)
+	}
+	statedb, _, err := regen.StateAt(context.Background(), blocks[79].Header(), 0)
+	if err != nil {
+		t.Fatalf("failed to retrieve cached state of block #80: %v", err)
+	}
+	if root := statedb.IntermediateRoot(true); root != blocks[79].Root() {
+		t.Fatalf("state root mismatch: have %x, want %x", root, blocks[79].Root())
+	}
+	if regen.roots[len(regen.roots)-1] != blocks[79].Root() {
+		t.Errorf("reused root not marked as most recently used")
+	}
+}
+
+// Tests that checkpoints missing from the database are persisted while the state
+// is being regenerated.
+func TestStateRegenerationCheckpoints(t *testing.T) {
+	chain, db, blocks := newPrunedChain(t, 300, 0)
+	defer chain.Stop()
+
+	if _, err := state.New(blocks[63].Root(), state.NewDatabase(db)); err == nil {
+		t.Fatalf("state of block #64 persisted without checkpoints")
+	}
+	regen := newStateRegenerator(chain, db, 64)
+	if _, _, err := regen.StateAt(context.Background(), blocks[99].Header(), 100); err != nil {
+		t.Fatalf("failed to regenerate state of block #100: %v", err)
+	}
+	if _, err := state.New(blocks[63].Root(), state.NewDatabase(db)); err != nil {
+		t.Fatalf("state checkpoint of block #64 not persisted: %v", err)
+	}
+	if regen.cached[blocks[63].Root()] {
+		t.Errorf("persisted checkpoint cached in memory")
+	}
+}
+
+// Tests that roots of states still in use are not released from the cache, even
+// if it's over its memory allowance.
+func TestStateRegenerationHeld(t *testing.T) {
+	chain, db, blocks := newPrunedChain(t, 300, 64)
+	defer chain.Stop()
+
+	regen := newStateRegenerator(chain, db, 64)
+	regen.cacheSize = 1
+
+	statedb, release, err := regen.StateAt(context.Background(), blocks[99].Header(), 100)
+	if err != nil {
+		t.Fatalf("failed to regenerate state of block #100: %v", err)
+	}
+	_, held, err := regen.StateAt(context.Background(), blocks[129].Header(), 100)
+	if err != nil {
+		t.Fatalf("failed to regenerate state of block #130: %v", err)
+	}
+	defer held()
+
+	if !regen.cached[blocks[99].Root()] {
+		t.Fatalf("held state of block #100 released")
+	}
+	if regen.cached[blocks[98].Root()] {
+		t.Errorf("unused state of block #99 not released")
+	}
+	if root := statedb.IntermediateRoot(true); root != blocks[99].Root() {
+		t.Fatalf("held state root mismatch: have %x, want %x", root, blocks[99].Root())
+	}
+	// Once released, the root should be dropped by the next regeneration
+	release()
+	release()
+
+	if _, _, err := regen.StateAt(context.Background(), blocks[130].Header(), 1); err != nil {
+		t.Fatalf("failed to regenerate state of block #131: %v", err)
+	}
+	if regen.cached[blocks[99].Root()] {
+		t.Errorf("released state of block #100 still cached")
+	}
+	if !regen.cached[blocks[129].Root()] {
+		t.Errorf("held state of block #130 released")
+	}
+}
+
+// Tests that regenerations are aborted if the request is cancelled, and that
+// waiting for a regeneration of the same state doesn't block other states.
+func TestStateRegenerationCancel(t *testing.T) {
+	chain, db, blocks := newPrunedChain(t, 300, 64)
+	defer chain.Stop()
+
+	regen := newStateRegenerator(chain, db, 64)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := regen.StateAt(ctx, blocks[99].Header(), 100); err != context.Canceled {
+		t.Fatalf("cancelled regeneration error mismatch: have %v, want %v", err, context.Canceled)
+	}
+	if len(regen.pending) != 0 || len(regen.held) != 0 {
+		t.Fatalf("cancelled regeneration not cleaned up: pending %d, held %d", len(regen.pending), len(regen.held))
+	}
+	// Simulate a long running regeneration of block #100
+	regen.pending[blocks[99].Root()] = make(chan struct{})
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, _, err := regen.StateAt(ctx, blocks[99].Header(), 100); err != context.DeadlineExceeded {
+		t.Fatalf("waiting regeneration error mismatch: have %v, want %v", err, context.DeadlineExceeded)
+	}
+	// Other states must be regenerated meanwhile
+	if _, release, err := regen.StateAt(context.Background(), blocks[100].Header(), 100); err != nil {
+		t.Fatalf("failed to regenerate state of block #101: %v", err)
+	} else {
+		release()
+	}
+	// Once the pending regeneration is done, waiters must go ahead
+	done := regen.pending[blocks[99].Root()]
+	delete(regen.pending, blocks[99].Root())
+	close(done)
+
+	if _, release, err := regen.StateAt(context.Background(), blocks[99].Header(), 100); err != nil {
+		t.Fatalf("failed to regenerate state of block #100: %v", err)
+	} else {
+		release()
+	}
+	if len(regen.held) != 0 {
+		t.Errorf("released states still held: %v", regen.held)
+	}
+}
+
+// Tests that eth_call and state queries against pruned blocks regenerate the
+// state within their own limit and release it once the call finishes.
+func TestStateRegenerationCall(t *testing.T) {
+	var (
+		recipient = common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+		contract  = common.HexToAddress("0xc0de")
+		signer    = types.HomesteadSigner{}
+	)
+	// Create a chain crediting the recipient with a wei in each block and a contract
+	// returning the balance of the recipient
+	code := append(append([]byte{byte(vm.PUSH20)}, recipient.Bytes()...),
+		byte(vm.BALANCE), byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN))
+
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			testBank: {Balance: big.NewInt(1000000000000000000)},
+			contract: {Code: code, Balance: new(big.Int)},
+		},
+	}
+	gendb, _ := ethdb.NewMemDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), gendb, 300, func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), recipient, big.NewInt(1), params.TxGas, nil, nil), signer, testBankKey)
+		block.AddTx(tx)
+	})
+	db, _ := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	cacheConfig := &core.CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, StateCheckpoint: 64}
+	chain, err := core.NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	if _, err := chain.StateAt(blocks[99].Root()); err == nil {
+		t.Fatalf("pruned state of block #100 available")
+	}
+	// Regenerating block #100 from the checkpoint at #64 needs 36 blocks, so calls
+	// must fail below that limit irrespective of the tracer limit
+	eth := &Ethereum{
+		config:      &Config{StateRegenLimit: 1024, StateCallLimit: 35},
+		chainConfig: params.TestChainConfig,
+		blockchain:  chain,
+		regen:       newStateRegenerator(chain, db, 64),
+	}
+	api := ethapi.NewPublicBlockChainAPI(&EthApiBackend{eth: eth})
+	args := ethapi.CallArgs{From: testBank, To: &contract}
+
+	if _, err := api.Call(context.Background(), args, rpc.BlockNumber(100)); err != errStateUnavailable {
+		t.Fatalf("call error mismatch: have %v, want %v", err, errStateUnavailable)
+	}
+	eth.config.StateCallLimit = 36
+
+	result, err := api.Call(context.Background(), args, rpc.BlockNumber(100))
+	if err != nil {
+		t.Fatalf("failed to call contract at block #100: %v", err)
+	}
+	if balance := new(big.Int).SetBytes(result); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("call result mismatch: have %v, want %v", balance, 100)
+	}
+	balance, err := api.GetBalance(context.Background(), recipient, rpc.BlockNumber(80))
+	if err != nil {
+		t.Fatalf("failed to retrieve balance at block #80: %v", err)
+	}
+	if balance.Cmp(big.NewInt(80)) != 0 {
+		t.Fatalf("balance mismatch: have %v, want %v", balance, 80)
+	}
+	// Once the calls finished, none of the regenerated roots may be held
+	if held := len(eth.regen.held); held != 0 {
+		t.Errorf("regenerated roots held after calls: have %d, want 0", held)
+	}
+}
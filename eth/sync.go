@@ -47,7 +47,13 @@ func (pm *ProtocolManager) syncTransactions(p *peer) {
 	var txs types.Transactions
 	pending, _ := pm.txpool.Pending()
 	for _, batch := range pending {
-		txs = append(txs, batch...)
+		for _, tx := range batch {
+			// Private transactions are only synced to trusted peers
+			if !p.Trusted() && pm.txpool.IsPrivate(tx.Hash()) {
+				continue
+			}
+			txs = append(txs, tx)
+		}
 	}
 	if len(txs) == 0 {
 		return
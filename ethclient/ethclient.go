@@ -25,6 +25,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -299,6 +300,56 @@ func (ec *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header)
 	return ec.c.EthSubscribe(ctx, ch, "newHeads")
 }
 
+// BlockReceipts is a block along with the receipts (and logs) of all its
+// transactions, as delivered by SubscribeNewBlockReceipts. Uncle headers are not
+// included in the block, only their hash in the header.
+type BlockReceipts struct {
+	Removed  bool // Whether the block was dropped from the canonical chain by a reorg
+	Block    *types.Block
+	Receipts types.Receipts
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (br *BlockReceipts) UnmarshalJSON(input []byte) error {
+	var dec struct {
+		Removed  bool            `json:"removed"`
+		Block    json.RawMessage `json:"block"`
+		Receipts types.Receipts  `json:"receipts"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	var head *types.Header
+	var body rpcBlock
+	if err := json.Unmarshal(dec.Block, &head); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(dec.Block, &body); err != nil {
+		return err
+	}
+	if len(body.Transactions) != len(dec.Receipts) {
+		return fmt.Errorf("server returned %d receipts for %d transactions", len(dec.Receipts), len(body.Transactions))
+	}
+	// Fill the sender cache of transactions in the block.
+	txs := make([]*types.Transaction, len(body.Transactions))
+	for i, tx := range body.Transactions {
+		setSenderFromServer(tx.tx, tx.From, body.Hash)
+		txs[i] = tx.tx
+	}
+	br.Removed = dec.Removed
+	br.Block = types.NewBlockWithHeader(head).WithBody(txs, nil)
+	br.Receipts = dec.Receipts
+	return nil
+}
+
+// SubscribeNewBlockReceipts subscribes to notifications about each new canonical
+// block, delivered together with all its transaction receipts and logs. When the
+// chain is reorganised, a notification with Removed set is sent for each block
+// dropped from the canonical chain before the blocks of the new chain.
+func (ec *Client) SubscribeNewBlockReceipts(ctx context.Context, ch chan<- *BlockReceipts) (ethereum.Subscription, error) {
+	return ec.c.EthSubscribe(ctx, ch, "newBlockReceipts")
+}
+
 // State Access
 
 // NetworkID returns the network ID (also known as the chain ID) for this chain.
@@ -476,6 +527,16 @@ func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) er
 	return ec.c.CallContext(ctx, nil, "eth_sendRawTransaction", common.ToHex(data))
 }
 
+// SignTypedData requests the node to sign the EIP-712 hash of the given structured
+// data with an unlocked account. The returned signature has a V value of 27 or 28.
+func (ec *Client) SignTypedData(ctx context.Context, account common.Address, typedData *accounts.TypedData) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := ec.c.CallContext(ctx, &signature, "eth_signTypedData", account, typedData); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
 func toCallArg(msg ethereum.CallMsg) interface{} {
 	arg := map[string]interface{}{
 		"from": msg.From,
@@ -0,0 +1,195 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// maxBundles is the maximum number of bundles tracked at any point in time.
+	maxBundles = 64
+
+	// maxBundleTxs is the maximum number of transactions allowed in a bundle.
+	maxBundleTxs = 16
+
+	// bundleLifetime is the number of blocks a bundle is attempted for if the
+	// user did not request an explicit deadline.
+	bundleLifetime = 25
+)
+
+var (
+	// errEmptyBundle is returned if a bundle without transactions is submitted.
+	errEmptyBundle = errors.New("empty bundle")
+
+	// errBundleTooLarge is returned if a bundle exceeds the transaction limit.
+	errBundleTooLarge = errors.New("bundle too large")
+
+	// errTooManyBundles is returned if the bundle pool is full.
+	errTooManyBundles = errors.New("too many pending bundles")
+)
+
+// bundle is a group of transactions to be included into a block atomically,
+// back to back and in the given order.
+type bundle struct {
+	hash     common.Hash        // Identifier of the bundle (hash of its transactions)
+	txs      types.Transactions // Transactions to include, in order
+	maxBlock uint64             // Last block number the bundle may be included in
+}
+
+// includedIn returns whether any of the bundle's transactions is in the given
+// set, in which case the bundle can't be included atomically any more.
+func (b *bundle) includedIn(txs map[common.Hash]struct{}) bool {
+	for _, tx := range b.txs {
+		if _, ok := txs[tx.Hash()]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bundlePool tracks the bundles submitted to the local miner.
+type bundlePool struct {
+	bundles []*bundle // Pending bundles, in submission order
+	lock    sync.Mutex
+}
+
+// newBundlePool creates an empty bundle pool.
+func newBundlePool() *bundlePool {
+	return new(bundlePool)
+}
+
+// add inserts a new bundle into the pool, to be attempted until block maxBlock.
+func (p *bundlePool) add(txs types.Transactions, maxBlock uint64) (common.Hash, error) {
+	if len(txs) == 0 {
+		return common.Hash{}, errEmptyBundle
+	}
+	if len(txs) > maxBundleTxs {
+		return common.Hash{}, errBundleTooLarge
+	}
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	blob, _ := rlp.EncodeToBytes(hashes)
+	hash := crypto.Keccak256Hash(blob)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, b := range p.bundles {
+		if b.hash == hash {
+			return hash, nil
+		}
+	}
+	if len(p.bundles) >= maxBundles {
+		return common.Hash{}, errTooManyBundles
+	}
+	p.bundles = append(p.bundles, &bundle{hash: hash, txs: txs, maxBlock: maxBlock})
+	return hash, nil
+}
+
+// remove drops a bundle from the pool, returning whether it was found.
+func (p *bundlePool) remove(hash common.Hash) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i, b := range p.bundles {
+		if b.hash == hash {
+			p.bundles = append(p.bundles[:i], p.bundles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// pending drops all the bundles expired or (even partially) included in the given
+// parent block, and returns the transactions of the remaining ones for a new block.
+func (p *bundlePool) pending(parent *types.Block) []types.Transactions {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	included := make(map[common.Hash]struct{})
+	for _, tx := range parent.Transactions() {
+		included[tx.Hash()] = struct{}{}
+	}
+	var (
+		number  = parent.NumberU64() + 1
+		bundles = p.bundles[:0]
+		pending []types.Transactions
+	)
+	for _, b := range p.bundles {
+		if b.maxBlock < number {
+			continue
+		}
+		if b.includedIn(included) {
+			continue
+		}
+		bundles = append(bundles, b)
+		pending = append(pending, b.txs)
+	}
+	p.bundles = bundles
+	return pending
+}
+
+// bundleTxSet is a candidate set yielding a list of bundles first, and then the
+// transactions of a fallback set.
+type bundleTxSet struct {
+	bundles []types.Transactions
+	txs     TxSet
+}
+
+// Peek implements TxSet, returning the next bundle or fallback transaction.
+func (s *bundleTxSet) Peek() (types.Transactions, bool) {
+	if len(s.bundles) > 0 {
+		return s.bundles[0], true
+	}
+	return s.txs.Peek()
+}
+
+// Shift implements TxSet, moving on to the next bundle or fallback transaction.
+func (s *bundleTxSet) Shift() {
+	if len(s.bundles) > 0 {
+		s.bundles = s.bundles[1:]
+		return
+	}
+	s.txs.Shift()
+}
+
+// Skip implements TxSet, moving on to the next bundle or fallback transaction.
+func (s *bundleTxSet) Skip() {
+	if len(s.bundles) > 0 {
+		s.bundles = s.bundles[1:]
+		return
+	}
+	s.txs.Skip()
+}
+
+// Pop implements TxSet, discarding the current bundle or fallback account.
+func (s *bundleTxSet) Pop() {
+	if len(s.bundles) > 0 {
+		s.bundles = s.bundles[1:]
+		return
+	}
+	s.txs.Pop()
+}
@@ -19,7 +19,9 @@ package miner
 
 import (
 	"fmt"
+	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -180,3 +182,53 @@ func (self *Miner) SetEtherbase(addr common.Address) {
 	self.coinbase = addr
 	self.worker.setEtherbase(addr)
 }
+
+// SetTxOrdering sets the policy selecting the transactions included into newly
+// mined blocks. It takes effect from the next block onwards.
+func (self *Miner) SetTxOrdering(ordering TxOrdering) {
+	self.worker.setTxOrdering(ordering)
+}
+
+// TxOrdering returns the policy currently selecting the transactions included
+// into newly mined blocks.
+func (self *Miner) TxOrdering() TxOrdering {
+	return self.worker.txOrdering()
+}
+
+// SendBundle submits a group of transactions to be included atomically, back to
+// back and in the given order, into any block up to and including maxBlock. If
+// maxBlock is zero, the bundle is attempted for a default number of blocks.
+func (self *Miner) SendBundle(txs types.Transactions, maxBlock uint64) (common.Hash, error) {
+	head := self.eth.BlockChain().CurrentBlock()
+	if maxBlock == 0 {
+		maxBlock = head.NumberU64() + bundleLifetime
+	}
+	if maxBlock <= head.NumberU64() {
+		return common.Hash{}, fmt.Errorf("bundle deadline #%d already passed", maxBlock)
+	}
+	signer := types.MakeSigner(self.worker.config, new(big.Int).Add(head.Number(), common.Big1))
+	for i, tx := range txs {
+		if _, err := types.Sender(signer, tx); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid bundle transaction %d: %v", i, err)
+		}
+	}
+	return self.worker.bundles.add(txs, maxBlock)
+}
+
+// CancelBundle drops a previously submitted bundle, returning whether it was
+// still pending.
+func (self *Miner) CancelBundle(hash common.Hash) bool {
+	return self.worker.bundles.remove(hash)
+}
+
+// SetRecommitInterval sets the interval at which the mining work is recreated to
+// include any newly arrived transactions. Zero disables recommitting.
+func (self *Miner) SetRecommitInterval(interval time.Duration) {
+	self.worker.setRecommitInterval(interval)
+}
+
+// WorkStats returns a summary of the block currently being sealed, or nil if no
+// mining work was created yet.
+func (self *Miner) WorkStats() *WorkStats {
+	return self.worker.workStats()
+}
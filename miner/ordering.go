@@ -0,0 +1,237 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxSet is an ordered stream of candidate transactions the worker fills a block
+// from. Atomic bundles are returned as a single candidate flagged as a bundle,
+// which is either included in full or not at all.
+type TxSet interface {
+	// Peek returns the next candidate (a single transaction or a bundle), or nil
+	// if the set is exhausted. The flag reports whether the candidate is a bundle,
+	// irrespective of the number of transactions in it.
+	Peek() (types.Transactions, bool)
+
+	// Shift moves past the current candidate after it was included into the block,
+	// replacing it with the next one from the same account if there is any.
+	Shift()
+
+	// Skip moves past the current candidate after it failed to be included, the
+	// same way as Shift does.
+	Skip()
+
+	// Pop discards the current candidate along with all subsequent transactions
+	// from the same account.
+	Pop()
+}
+
+// TxOrdering is a policy deciding which of the pending transactions the worker
+// attempts to include into a new block, and in which order.
+type TxOrdering interface {
+	// Name returns a short identifier of the policy.
+	Name() string
+
+	// Order assembles the candidate set from the pending transactions of the pool,
+	// grouped by account and sorted by nonce. The input map is reowned by the
+	// policy, so the caller should not interact any more with it.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions) TxSet
+}
+
+// txIterator is the iteration interface of a price and nonce sorted transaction
+// set, yielding single transactions.
+type txIterator interface {
+	Peek() *types.Transaction
+	Shift()
+	Pop()
+}
+
+// singleTxSet adapts a transaction iterator to a candidate set without bundles.
+type singleTxSet struct {
+	txs txIterator
+}
+
+// Peek implements TxSet, returning the next transaction as a single candidate.
+func (s *singleTxSet) Peek() (types.Transactions, bool) {
+	if tx := s.txs.Peek(); tx != nil {
+		return types.Transactions{tx}, false
+	}
+	return nil, false
+}
+
+// Shift implements TxSet, moving on to the next transaction.
+func (s *singleTxSet) Shift() { s.txs.Shift() }
+
+// Skip implements TxSet, moving on to the next transaction.
+func (s *singleTxSet) Skip() { s.txs.Shift() }
+
+// Pop implements TxSet, dropping all the transactions of the current account.
+func (s *singleTxSet) Pop() { s.txs.Pop() }
+
+// chainedTxSet exhausts a list of candidate sets one after the other.
+type chainedTxSet struct {
+	sets []TxSet
+}
+
+// Peek implements TxSet, returning the next candidate of the first non-empty set.
+func (s *chainedTxSet) Peek() (types.Transactions, bool) {
+	for len(s.sets) > 0 {
+		if txs, bundle := s.sets[0].Peek(); txs != nil {
+			return txs, bundle
+		}
+		s.sets = s.sets[1:]
+	}
+	return nil, false
+}
+
+// Shift implements TxSet, delegating to the currently active set.
+func (s *chainedTxSet) Shift() {
+	if len(s.sets) > 0 {
+		s.sets[0].Shift()
+	}
+}
+
+// Skip implements TxSet, delegating to the currently active set.
+func (s *chainedTxSet) Skip() {
+	if len(s.sets) > 0 {
+		s.sets[0].Skip()
+	}
+}
+
+// Pop implements TxSet, delegating to the currently active set.
+func (s *chainedTxSet) Pop() {
+	if len(s.sets) > 0 {
+		s.sets[0].Pop()
+	}
+}
+
+// priceOrdering is the default policy, including transactions in a profit
+// maximizing order while honouring account nonces.
+type priceOrdering struct{}
+
+// NewPriceOrdering creates the default transaction ordering policy, sorting the
+// transactions by gas price.
+func NewPriceOrdering() TxOrdering {
+	return priceOrdering{}
+}
+
+// Name implements TxOrdering.
+func (priceOrdering) Name() string { return "price" }
+
+// Order implements TxOrdering, sorting all the transactions by price and nonce.
+func (priceOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TxSet {
+	return &singleTxSet{types.NewTransactionsByPriceAndNonce(signer, pending)}
+}
+
+// localsFirstOrdering is a policy including the transactions of the accounts
+// local to the transaction pool before any remote ones.
+type localsFirstOrdering struct {
+	pool *core.TxPool
+}
+
+// NewLocalsFirstOrdering creates a transaction ordering policy prioritising the
+// local accounts of the given pool, sorting by gas price within both groups.
+func NewLocalsFirstOrdering(pool *core.TxPool) TxOrdering {
+	return &localsFirstOrdering{pool: pool}
+}
+
+// Name implements TxOrdering.
+func (o *localsFirstOrdering) Name() string { return "local" }
+
+// Order implements TxOrdering, splitting the pending transactions into a local
+// and a remote set, each sorted by price and nonce.
+func (o *localsFirstOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TxSet {
+	locals := make(map[common.Address]types.Transactions)
+	for _, addr := range o.pool.Locals() {
+		if txs := pending[addr]; len(txs) > 0 {
+			locals[addr] = txs
+			delete(pending, addr)
+		}
+	}
+	return &chainedTxSet{sets: []TxSet{
+		&singleTxSet{types.NewTransactionsByPriceAndNonce(signer, locals)},
+		&singleTxSet{types.NewTransactionsByPriceAndNonce(signer, pending)},
+	}}
+}
+
+// fairOrdering is a policy sorting transactions by gas price, but capping the
+// number of transactions a single account may get into a block.
+type fairOrdering struct {
+	accountCap int
+}
+
+// NewFairOrdering creates a transaction ordering policy sorting by gas price,
+// but allowing at most accountCap transactions per account in a block.
+func NewFairOrdering(accountCap int) TxOrdering {
+	if accountCap < 1 {
+		accountCap = 1
+	}
+	return &fairOrdering{accountCap: accountCap}
+}
+
+// Name implements TxOrdering.
+func (o *fairOrdering) Name() string { return "fair" }
+
+// Order implements TxOrdering, sorting the transactions by price and nonce and
+// cutting off accounts once they reach their cap.
+func (o *fairOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions) TxSet {
+	return &fairTxSet{
+		txs:        types.NewTransactionsByPriceAndNonce(signer, pending),
+		signer:     signer,
+		accountCap: o.accountCap,
+		counts:     make(map[common.Address]int),
+	}
+}
+
+// fairTxSet is a price sorted transaction set limiting the number of candidates
+// yielded for any single account.
+type fairTxSet struct {
+	txs        *types.TransactionsByPriceAndNonce
+	signer     types.Signer
+	accountCap int
+	counts     map[common.Address]int // Number of transactions included per account
+}
+
+// Peek implements TxSet, returning the next transaction as a single candidate.
+func (s *fairTxSet) Peek() (types.Transactions, bool) {
+	if tx := s.txs.Peek(); tx != nil {
+		return types.Transactions{tx}, false
+	}
+	return nil, false
+}
+
+// Shift implements TxSet, moving on to the next transaction of the account, or
+// dropping the account altogether if its included transactions reached the cap.
+func (s *fairTxSet) Shift() {
+	from, _ := types.Sender(s.signer, s.txs.Peek())
+	if s.counts[from]++; s.counts[from] >= s.accountCap {
+		s.txs.Pop()
+		return
+	}
+	s.txs.Shift()
+}
+
+// Skip implements TxSet, moving on to the next transaction of the account without
+// counting the failed one against its cap.
+func (s *fairTxSet) Skip() { s.txs.Shift() }
+
+// Pop implements TxSet, dropping all the transactions of the current account.
+func (s *fairTxSet) Pop() { s.txs.Pop() }
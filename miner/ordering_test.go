@@ -0,0 +1,148 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// orderingTestTxs creates count nonce ordered transactions from a fresh account,
+// all with the given gas price.
+func orderingTestTxs(signer types.Signer, count int, price int64) (*ecdsa.PrivateKey, types.Transactions) {
+	key, _ := crypto.GenerateKey()
+
+	txs := make(types.Transactions, count)
+	for i := 0; i < count; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{}, new(big.Int), 21000, big.NewInt(price), nil)
+		txs[i], _ = types.SignTx(tx, signer, key)
+	}
+	return key, txs
+}
+
+// Tests that the fair ordering caps the number of transactions yielded for any
+// single account, while still yielding all the other accounts.
+func TestFairOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	keyA, txsA := orderingTestTxs(signer, 5, 2)
+	keyB, txsB := orderingTestTxs(signer, 5, 1)
+	addrA, addrB := crypto.PubkeyToAddress(keyA.PublicKey), crypto.PubkeyToAddress(keyB.PublicKey)
+
+	set := NewFairOrdering(2).Order(signer, map[common.Address]types.Transactions{addrA: txsA, addrB: txsB})
+
+	counts := make(map[common.Address]int)
+	for txs, _ := set.Peek(); txs != nil; txs, _ = set.Peek() {
+		from, _ := types.Sender(signer, txs[0])
+		counts[from]++
+		set.Shift()
+	}
+	if counts[addrA] != 2 || counts[addrB] != 2 {
+		t.Fatalf("yielded transaction count mismatch: have %d/%d, want 2/2", counts[addrA], counts[addrB])
+	}
+}
+
+// Tests that the fair ordering only counts included transactions against the
+// cap of an account, not the ones failing to be included.
+func TestFairOrderingSkip(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	key, txs := orderingTestTxs(signer, 5, 1)
+	set := NewFairOrdering(2).Order(signer, map[common.Address]types.Transactions{crypto.PubkeyToAddress(key.PublicKey): txs})
+
+	// Fail the first two transactions, include the rest up to the cap
+	var yielded []uint64
+	for txs, _ := set.Peek(); txs != nil; txs, _ = set.Peek() {
+		yielded = append(yielded, txs[0].Nonce())
+		if txs[0].Nonce() < 2 {
+			set.Skip()
+		} else {
+			set.Shift()
+		}
+	}
+	if len(yielded) != 4 || yielded[3] != 3 {
+		t.Fatalf("yielded nonces mismatch: have %v, want [0 1 2 3]", yielded)
+	}
+}
+
+// Tests that bundles are yielded before any pooled transaction, and that they
+// are dropped from the pool once expired or included.
+func TestBundlePool(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	_, txs := orderingTestTxs(signer, 3, 1)
+
+	pool := newBundlePool()
+	if _, err := pool.add(nil, 10); err != errEmptyBundle {
+		t.Fatalf("empty bundle error mismatch: have %v, want %v", err, errEmptyBundle)
+	}
+	hash, err := pool.add(txs[:2], 2)
+	if err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	if dup, _ := pool.add(txs[:2], 2); dup != hash {
+		t.Fatalf("duplicate bundle hash mismatch: have %x, want %x", dup, hash)
+	}
+	if _, err := pool.add(txs[2:], 5); err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	// Bundles should be yielded in submission order, ahead of the fallback set
+	parent := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(0)})
+	pending := pool.pending(parent)
+	if len(pending) != 2 {
+		t.Fatalf("pending bundle count mismatch: have %d, want 2", len(pending))
+	}
+	key, other := orderingTestTxs(signer, 1, 100)
+	fallback := map[common.Address]types.Transactions{crypto.PubkeyToAddress(key.PublicKey): other}
+
+	set := &bundleTxSet{bundles: pending, txs: &singleTxSet{types.NewTransactionsByPriceAndNonce(signer, fallback)}}
+	for i, want := range []struct {
+		size   int
+		bundle bool
+	}{{2, true}, {1, true}, {1, false}} {
+		txs, bundle := set.Peek()
+		if len(txs) != want.size || bundle != want.bundle {
+			t.Fatalf("candidate %d mismatch: have %d txs (bundle %v), want %d txs (bundle %v)", i, len(txs), bundle, want.size, want.bundle)
+		}
+		set.Shift()
+	}
+	// Bundles past their deadline should be dropped
+	parent = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)})
+	if pending := pool.pending(parent); len(pending) != 1 {
+		t.Fatalf("pending bundle count mismatch after expiry: have %d, want 1", len(pending))
+	}
+	// Bundles included in the parent should be dropped
+	parent = types.NewBlock(&types.Header{Number: big.NewInt(3)}, txs[2:], nil, nil)
+	if pending := pool.pending(parent); len(pending) != 0 {
+		t.Fatalf("pending bundle count mismatch after inclusion: have %d, want 0", len(pending))
+	}
+	// Bundles with any transaction included in the parent should be dropped too
+	if _, err := pool.add(txs, 10); err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	parent = types.NewBlock(&types.Header{Number: big.NewInt(4)}, txs[1:2], nil, nil)
+	if pending := pool.pending(parent); len(pending) != 0 {
+		t.Fatalf("pending bundle count mismatch after partial inclusion: have %d, want 0", len(pending))
+	}
+	if pool.remove(hash) {
+		t.Fatalf("removed already dropped bundle")
+	}
+}
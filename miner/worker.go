@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 	"gopkg.in/fatih/set.v0"
 )
@@ -49,6 +50,16 @@ const (
 	chainHeadChanSize = 10
 	// chainSideChanSize is the size of channel listening to ChainSideEvent.
 	chainSideChanSize = 10
+	// minRecommitInterval is the minimal time interval to recreate the mining
+	// work with any newly arrived transactions.
+	minRecommitInterval = time.Second
+)
+
+var (
+	workFeesGauge    = metrics.NewRegisteredGauge("miner/work/fees", nil) // Fees of the current work in gwei
+	workGasUsedGauge = metrics.NewRegisteredGauge("miner/work/gasused", nil)
+	workTxsGauge     = metrics.NewRegisteredGauge("miner/work/txs", nil)
+	recommitMeter    = metrics.NewRegisteredMeter("miner/recommits", nil)
 )
 
 // Agent can register themself with the worker
@@ -71,6 +82,7 @@ type Work struct {
 	family    *set.Set       // family set (used for checking uncle invalidity)
 	uncles    *set.Set       // uncle set
 	tcount    int            // tx count in cycle
+	fees      *big.Int       // transaction fees earned by the block
 
 	Block *types.Block // the new block
 
@@ -81,6 +93,16 @@ type Work struct {
 	createdAt time.Time
 }
 
+// WorkStats is a summary of the block currently being sealed.
+type WorkStats struct {
+	Number    uint64    // Number of the block being sealed
+	Fees      *big.Int  // Transaction fees earned by the block (excluding rewards)
+	GasUsed   uint64    // Gas consumed by the included transactions
+	GasLimit  uint64    // Gas limit of the block
+	TxCount   int       // Number of included transactions
+	CreatedAt time.Time // Time the work was (re)created
+}
+
 type Result struct {
 	Work  *Work
 	Block *types.Block
@@ -101,6 +123,7 @@ type worker struct {
 	chainHeadSub event.Subscription
 	chainSideCh  chan core.ChainSideEvent
 	chainSideSub event.Subscription
+	recommitCh   chan struct{}
 	wg           sync.WaitGroup
 
 	agents map[Agent]struct{}
@@ -113,6 +136,9 @@ type worker struct {
 
 	coinbase common.Address
 	extra    []byte
+	ordering TxOrdering  // Policy selecting the transactions to include
+	bundles  *bundlePool // Atomic transaction bundles to include before anything else
+	recommit int64       // Interval to recreate the mining work at (0 = disabled, atomic)
 
 	currentMu sync.Mutex
 	current   *Work
@@ -125,6 +151,7 @@ type worker struct {
 	// atomic status counters
 	mining int32
 	atWork int32
+	newTxs int32 // whether transactions arrived since the mining work was created
 }
 
 func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase common.Address, eth Backend, mux *event.TypeMux) *worker {
@@ -136,12 +163,15 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		txCh:           make(chan core.TxPreEvent, txChanSize),
 		chainHeadCh:    make(chan core.ChainHeadEvent, chainHeadChanSize),
 		chainSideCh:    make(chan core.ChainSideEvent, chainSideChanSize),
+		recommitCh:     make(chan struct{}, 1),
 		chainDb:        eth.ChainDb(),
 		recv:           make(chan *Result, resultQueueSize),
 		chain:          eth.BlockChain(),
 		proc:           eth.BlockChain().Validator(),
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
+		ordering:       NewPriceOrdering(),
+		bundles:        newBundlePool(),
 		agents:         make(map[Agent]struct{}),
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
 	}
@@ -170,6 +200,53 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setTxOrdering(ordering TxOrdering) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.ordering = ordering
+}
+
+func (self *worker) txOrdering() TxOrdering {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.ordering
+}
+
+func (self *worker) setRecommitInterval(interval time.Duration) {
+	if interval != 0 && interval < minRecommitInterval {
+		log.Warn("Sanitizing miner recommit interval", "provided", interval, "updated", minRecommitInterval)
+		interval = minRecommitInterval
+	}
+	atomic.StoreInt64(&self.recommit, int64(interval))
+
+	select {
+	case self.recommitCh <- struct{}{}:
+	default:
+	}
+}
+
+func (self *worker) recommitInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&self.recommit))
+}
+
+// workStats returns a summary of the current mining work, or nil if there is none.
+func (self *worker) workStats() *WorkStats {
+	self.currentMu.Lock()
+	defer self.currentMu.Unlock()
+
+	if self.current == nil {
+		return nil
+	}
+	return &WorkStats{
+		Number:    self.current.header.Number.Uint64(),
+		Fees:      new(big.Int).Set(self.current.fees),
+		GasUsed:   self.current.header.GasUsed,
+		GasLimit:  self.current.header.GasLimit,
+		TxCount:   len(self.current.txs),
+		CreatedAt: self.current.createdAt,
+	}
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -245,12 +322,41 @@ func (self *worker) update() {
 	defer self.chainHeadSub.Unsubscribe()
 	defer self.chainSideSub.Unsubscribe()
 
+	// Recreate the mining work periodically to pick up better paying transactions
+	recommit := time.NewTimer(0)
+	defer recommit.Stop()
+	<-recommit.C
+
+	resetRecommit := func() {
+		if !recommit.Stop() {
+			select {
+			case <-recommit.C:
+			default:
+			}
+		}
+		if interval := self.recommitInterval(); interval > 0 {
+			recommit.Reset(interval)
+		}
+	}
 	for {
 		// A real event arrived, process interesting content
 		select {
 		// Handle ChainHeadEvent
 		case <-self.chainHeadCh:
 			self.commitNewWork()
+			resetRecommit()
+
+		// Handle recommit interval changes and expirations
+		case <-self.recommitCh:
+			resetRecommit()
+
+		case <-recommit.C:
+			if atomic.LoadInt32(&self.mining) == 1 && atomic.LoadInt32(&self.newTxs) == 1 {
+				log.Debug("Recommitting mining work with new transactions")
+				recommitMeter.Mark(1)
+				self.commitNewWork()
+			}
+			resetRecommit()
 
 		// Handle ChainSideEvent
 		case ev := <-self.chainSideCh:
@@ -267,11 +373,14 @@ func (self *worker) update() {
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
 				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
 
-				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
+				self.current.commitTransactions(self.mux, &singleTxSet{txset}, self.chain, self.coinbase)
+				updateWorkMetrics(self.current)
 				self.currentMu.Unlock()
 			} else {
+				atomic.StoreInt32(&self.newTxs, 1)
+
 				// If we're mining, but nothing is being processed, wake on new transactions
-				if self.config.Clique != nil && self.config.Clique.Period == 0 {
+				if (self.config.Clique != nil && self.config.Clique.Period == 0) || self.config.Instant != nil {
 					self.commitNewWork()
 				}
 			}
@@ -368,6 +477,7 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		family:    set.New(),
 		uncles:    set.New(),
 		header:    header,
+		fees:      new(big.Int),
 		createdAt: time.Now(),
 	}
 
@@ -396,6 +506,7 @@ func (self *worker) commitNewWork() {
 
 	tstart := time.Now()
 	parent := self.chain.CurrentBlock()
+	atomic.StoreInt32(&self.newTxs, 0)
 
 	tstamp := tstart.Unix()
 	if parent.Time().Cmp(new(big.Int).SetInt64(tstamp)) >= 0 {
@@ -453,7 +564,10 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
+	txs := self.ordering.Order(self.current.signer, pending)
+	if bundles := self.bundles.pending(parent); len(bundles) > 0 {
+		txs = &bundleTxSet{bundles: bundles, txs: txs}
+	}
 	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
 
 	// compute uncles for the new block.
@@ -483,14 +597,23 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to finalize block for sealing", "err", err)
 		return
 	}
+	updateWorkMetrics(work)
+
 	// We only care about logging if we're actually mining.
 	if atomic.LoadInt32(&self.mining) == 1 {
-		log.Info("Commit new mining work", "number", work.Block.Number(), "txs", work.tcount, "uncles", len(uncles), "elapsed", common.PrettyDuration(time.Since(tstart)))
+		log.Info("Commit new mining work", "number", work.Block.Number(), "txs", work.tcount, "uncles", len(uncles), "fees", work.fees, "elapsed", common.PrettyDuration(time.Since(tstart)))
 		self.unconfirmed.Shift(work.Block.NumberU64() - 1)
 	}
 	self.push(work)
 }
 
+// updateWorkMetrics reports the profitability of the given mining work.
+func updateWorkMetrics(work *Work) {
+	workFeesGauge.Update(new(big.Int).Div(work.fees, big.NewInt(params.Shannon)).Int64())
+	workGasUsedGauge.Update(int64(work.header.GasUsed))
+	workTxsGauge.Update(int64(len(work.txs)))
+}
+
 func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	hash := uncle.Hash()
 	if work.uncles.Has(hash) {
@@ -506,7 +629,7 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TxSet, bc *core.BlockChain, coinbase common.Address) {
 	gp := new(core.GasPool).AddGas(env.header.GasLimit)
 
 	var coalescedLogs []*types.Log
@@ -518,10 +641,24 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			break
 		}
 		// Retrieve the next transaction and abort if all done
-		tx := txs.Peek()
-		if tx == nil {
+		candidate, bundle := txs.Peek()
+		if candidate == nil {
 			break
 		}
+		// Bundles are committed all or nothing, skipped for this block if failing
+		if bundle {
+			logs, err := env.commitBundle(candidate, bc, coinbase, gp)
+			if err != nil {
+				log.Trace("Skipping failed transaction bundle", "txs", len(candidate), "err", err)
+				txs.Pop()
+				continue
+			}
+			coalescedLogs = append(coalescedLogs, logs...)
+			txs.Shift()
+			continue
+		}
+		tx := candidate[0]
+
 		// Error may be ignored here. The error has already been checked
 		// during transaction acceptance is the transaction pool.
 		//
@@ -548,7 +685,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		case core.ErrNonceTooLow:
 			// New head notification data race between the transaction pool and miner, shift
 			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
-			txs.Shift()
+			txs.Skip()
 
 		case core.ErrNonceTooHigh:
 			// Reorg notification data race between the transaction pool and miner, skip account =
@@ -565,7 +702,7 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 			// Strange error, discard the transaction and get the next in line (note, the
 			// nonce-too-high clause will prevent us from executing in vain).
 			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
-			txs.Shift()
+			txs.Skip()
 		}
 	}
 
@@ -589,6 +726,41 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 	}
 }
 
+// commitBundle applies a group of transactions on top of the current work. If
+// any of them fails, all the changes done by the bundle are reverted.
+func (env *Work) commitBundle(txs types.Transactions, bc *core.BlockChain, coinbase common.Address, gp *core.GasPool) ([]*types.Log, error) {
+	// Transactions finalise the state, so journal snapshots can't span the bundle
+	var (
+		state    = env.state.Copy()
+		gas      = *gp
+		gasUsed  = env.header.GasUsed
+		fees     = new(big.Int).Set(env.fees)
+		txCount  = len(env.txs)
+		tcount   = env.tcount
+		logs     []*types.Log
+		rollback = func() {
+			env.state, *gp, env.header.GasUsed, env.fees = state, gas, gasUsed, fees
+			env.txs, env.receipts, env.tcount = env.txs[:txCount], env.receipts[:txCount], tcount
+		}
+	)
+	for _, tx := range txs {
+		if tx.Protected() && !env.config.IsEIP155(env.header.Number) {
+			rollback()
+			return nil, fmt.Errorf("replay protected transaction %x before EIP155", tx.Hash())
+		}
+		env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
+
+		err, txlogs := env.commitTransaction(tx, bc, coinbase, gp)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		logs = append(logs, txlogs...)
+		env.tcount++
+	}
+	return logs, nil
+}
+
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, coinbase common.Address, gp *core.GasPool) (error, []*types.Log) {
 	snap := env.state.Snapshot()
 
@@ -599,6 +771,7 @@ func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, c
 	}
 	env.txs = append(env.txs, tx)
 	env.receipts = append(env.receipts, receipt)
+	env.fees.Add(env.fees, new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.GasPrice()))
 
 	return nil, receipt.Logs
 }
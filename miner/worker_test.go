@@ -0,0 +1,59 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the recommit interval is sanitized to the allowed minimum, that it
+// can be disabled, and that every change notifies the update loop without ever
+// blocking the caller.
+func TestRecommitInterval(t *testing.T) {
+	w := &worker{recommitCh: make(chan struct{}, 1)}
+
+	tests := []struct {
+		interval time.Duration
+		want     time.Duration
+	}{
+		{100 * time.Millisecond, minRecommitInterval},
+		{5 * time.Second, 5 * time.Second},
+		{minRecommitInterval, minRecommitInterval},
+		{0, 0},
+	}
+	for i, tt := range tests {
+		w.setRecommitInterval(tt.interval)
+		if have := w.recommitInterval(); have != tt.want {
+			t.Errorf("test %d: interval mismatch: have %v, want %v", i, have, tt.want)
+		}
+		// Only a single notification is queued up for the update loop
+		select {
+		case <-w.recommitCh:
+		default:
+			t.Errorf("test %d: update loop not notified", i)
+		}
+		w.setRecommitInterval(tt.interval)
+		w.setRecommitInterval(tt.interval)
+		<-w.recommitCh
+		select {
+		case <-w.recommitCh:
+			t.Errorf("test %d: duplicate notifications queued", i)
+		default:
+		}
+	}
+}
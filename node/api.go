@@ -19,6 +19,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -75,6 +76,82 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanPeer disconnects and bans a remote node, or all nodes within an IP network,
+// from connecting for the given number of seconds (or the configured duration
+// if omitted). The target may be an enode URL, a node ID, an IP or a CIDR.
+func (api *PrivateAdminAPI) BanPeer(target string, seconds *uint64, reason *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, ipnet, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	var duration time.Duration
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	why := "banned by operator"
+	if reason != nil {
+		why = *reason
+	}
+	if ipnet != nil {
+		err = server.BanNet(ipnet, duration, why)
+	} else {
+		err = server.BanNode(id, duration, why)
+	}
+	return err == nil, err
+}
+
+// UnbanPeer lifts the ban of a remote node or IP network, reporting whether it
+// was banned at all.
+func (api *PrivateAdminAPI) UnbanPeer(target string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	id, ipnet, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	if ipnet != nil {
+		return server.UnbanNet(ipnet)
+	}
+	return server.UnbanNode(id)
+}
+
+// ListBans retrieves all the active node and network bans.
+func (api *PrivateAdminAPI) ListBans() ([]*p2p.BanInfo, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.Bans()
+}
+
+// parseBanTarget parses the target of a ban, which is either a node, given by
+// its enode URL or ID, or an IP network, given by an IP address or a CIDR.
+func parseBanTarget(target string) (discover.NodeID, *net.IPNet, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return discover.NodeID{}, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return discover.NodeID{}, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	if _, ipnet, err := net.ParseCIDR(target); err == nil {
+		return discover.NodeID{}, ipnet, nil
+	}
+	node, err := discover.ParseNode(target)
+	if err != nil {
+		return discover.NodeID{}, nil, fmt.Errorf("invalid ban target, want enode, node ID, IP or CIDR: %v", err)
+	}
+	return node.ID, nil, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
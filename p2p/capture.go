@@ -0,0 +1,246 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// captureVersion is the version of the message capture file format.
+const captureVersion = 1
+
+// CaptureHeader is the first item of a message capture file, describing the
+// remote peer the messages were exchanged with.
+type CaptureHeader struct {
+	Version uint
+	ID      discover.NodeID // Identity of the remote peer
+	Name    string          // Client name advertised by the remote peer
+	Caps    []Cap           // Capabilities advertised by the remote peer
+	Inbound bool            // Whether the connection was initiated by the remote peer
+	Start   uint64          // Capture start time in unix nanoseconds
+}
+
+// CaptureRecord is a single sub-protocol message recorded in a capture file.
+// Message codes are relative to the sub-protocol, as seen by its handler.
+type CaptureRecord struct {
+	Time     uint64 // Time of the exchange in unix nanoseconds
+	Received bool   // Whether the message was received from the peer or sent to it
+	Protocol string
+	Version  uint
+	Code     uint64
+	Size     uint32
+	Payload  []byte
+}
+
+// String implements fmt.Stringer.
+func (r *CaptureRecord) String() string {
+	dir := "sent"
+	if r.Received {
+		dir = "recv"
+	}
+	return fmt.Sprintf("%s %s/%d msg #%d (%d bytes)", dir, r.Protocol, r.Version, r.Code, r.Size)
+}
+
+// msgRecorder writes the decrypted and framed messages exchanged with a single
+// peer into a capture file. Writes are buffered and flushed when the recorder is
+// closed, as a truncated last record is tolerated by the capture readers.
+type msgRecorder struct {
+	file *os.File
+	buf  *bufio.Writer
+	lock sync.Mutex
+	err  error // First write error, recording stops after it
+}
+
+// newMsgRecorder creates a capture file for the given peer in dir and writes the
+// capture header into it.
+func newMsgRecorder(dir string, p *Peer) (*msgRecorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%x-%d.rlpx", p.ID().Bytes()[:8], now.UnixNano())
+
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	header := &CaptureHeader{
+		Version: captureVersion,
+		ID:      p.ID(),
+		Name:    p.Name(),
+		Caps:    p.Caps(),
+		Inbound: p.Inbound(),
+		Start:   uint64(now.UnixNano()),
+	}
+	buf := bufio.NewWriter(file)
+	if err := rlp.Encode(buf, header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &msgRecorder{file: file, buf: buf}, nil
+}
+
+// record writes a message into the capture file. Recording stops silently at
+// the first write error, which is reported when the recorder is closed.
+func (r *msgRecorder) record(received bool, proto *protoRW, code uint64, payload []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	r.err = rlp.Encode(r.buf, &CaptureRecord{
+		Time:     uint64(time.Now().UnixNano()),
+		Received: received,
+		Protocol: proto.Name,
+		Version:  proto.Version,
+		Code:     code,
+		Size:     uint32(len(payload)),
+		Payload:  payload,
+	})
+}
+
+// Close flushes and closes the capture file, returning the first write error
+// encountered during recording, if any.
+func (r *msgRecorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err == nil {
+		r.err = r.buf.Flush()
+	}
+	if err := r.file.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// msgCapturer wraps a sub-protocol MsgReadWriter and records all messages sent
+// or received through it.
+type msgCapturer struct {
+	MsgReadWriter
+
+	recorder *msgRecorder
+	proto    *protoRW
+}
+
+// ReadMsg reads a message from the underlying MsgReadWriter and records it.
+func (c *msgCapturer) ReadMsg() (Msg, error) {
+	msg, err := c.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return msg, err
+	}
+	c.recorder.record(true, c.proto, msg.Code, payload)
+
+	msg.Payload = bytes.NewReader(payload)
+	return msg, nil
+}
+
+// WriteMsg records a message and writes it to the underlying MsgReadWriter.
+func (c *msgCapturer) WriteMsg(msg Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	// Record before writing, as the reply may arrive before the write returns
+	c.recorder.record(false, c.proto, msg.Code, payload)
+
+	msg.Payload = bytes.NewReader(payload)
+	return c.MsgReadWriter.WriteMsg(msg)
+}
+
+// CaptureReader iterates over the records of a message capture file.
+type CaptureReader struct {
+	Header *CaptureHeader
+
+	file   *os.File
+	stream *rlp.Stream
+}
+
+// OpenCapture opens a message capture file and reads its header.
+func OpenCapture(path string) (*CaptureReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stream := rlp.NewStream(bufio.NewReader(file), 0)
+
+	header := new(CaptureHeader)
+	if err := stream.Decode(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid capture header: %v", err)
+	}
+	if header.Version != captureVersion {
+		file.Close()
+		return nil, fmt.Errorf("unsupported capture version %d", header.Version)
+	}
+	return &CaptureReader{Header: header, file: file, stream: stream}, nil
+}
+
+// Next returns the next record of the capture, or io.EOF at the end of it.
+func (r *CaptureReader) Next() (*CaptureRecord, error) {
+	record := new(CaptureRecord)
+	if err := r.stream.Decode(record); err != nil {
+		// A truncated last record means the capture was not cleanly closed
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// Close closes the underlying capture file.
+func (r *CaptureReader) Close() error {
+	return r.file.Close()
+}
+
+// ReadCapture reads all the records of a message capture file.
+func ReadCapture(path string) (*CaptureHeader, []*CaptureRecord, error) {
+	reader, err := OpenCapture(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	var records []*CaptureRecord
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return reader.Header, records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that the messages exchanged with a peer are recorded into a capture file
+// and can be read back.
+func TestMsgCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	proto := Protocol{
+		Name:    "a",
+		Version: 2,
+		Length:  5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+				t.Error(err)
+			}
+			if err := SendItems(rw, 3, "foo"); err != nil {
+				t.Error(err)
+			}
+			return nil
+		},
+	}
+	fd1, fd2 := net.Pipe()
+	c1 := &conn{fd: fd1, transport: newTestTransport(randomID(), fd1), caps: []Cap{proto.cap()}}
+	c2 := &conn{fd: fd2, transport: newTestTransport(randomID(), fd2), caps: []Cap{proto.cap()}}
+	defer c2.close(errors.New("test done"))
+
+	peer := newPeer(c1, []Protocol{proto})
+	if peer.recorder, err = newMsgRecorder(dir, peer); err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := peer.run()
+		errc <- err
+	}()
+	Send(c2, baseProtocolLength+2, []uint{1})
+	if err := ExpectMsg(c2, baseProtocolLength+3, []string{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errc:
+	case <-time.After(2 * time.Second):
+		t.Fatal("peer did not terminate")
+	}
+	if err := peer.recorder.Close(); err != nil {
+		t.Fatalf("failed to close recorder: %v", err)
+	}
+	// Read the capture back and check its contents
+	files, _ := filepath.Glob(filepath.Join(dir, "*.rlpx"))
+	if len(files) != 1 {
+		t.Fatalf("capture file count mismatch: have %d, want 1", len(files))
+	}
+	header, records, err := ReadCapture(files[0])
+	if err != nil {
+		t.Fatalf("failed to read capture: %v", err)
+	}
+	if header.ID != c1.id {
+		t.Errorf("peer id mismatch: have %x, want %x", header.ID, c1.id)
+	}
+	if len(records) != 2 {
+		t.Fatalf("record count mismatch: have %d, want 2", len(records))
+	}
+	want := []struct {
+		received bool
+		code     uint64
+		payload  interface{}
+	}{
+		{true, 2, []uint{1}},
+		{false, 3, []string{"foo"}},
+	}
+	for i, record := range records {
+		payload, _ := rlp.EncodeToBytes(want[i].payload)
+		if record.Received != want[i].received || record.Code != want[i].code || string(record.Payload) != string(payload) {
+			t.Errorf("record %d mismatch: have %v %x", i, record, record.Payload)
+		}
+		if record.Protocol != "a" || record.Version != 2 || record.Size != uint32(len(payload)) {
+			t.Errorf("record %d metadata mismatch: have %v", i, record)
+		}
+	}
+}
@@ -72,6 +72,8 @@ type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
 	netrestrict *netutil.Netlist
+	banned      func(*discover.Node) bool // Reports whether a dynamic candidate is banned, nil if bans are not enforced
+	filter      func(*discover.Node) bool // Reports whether a dynamic candidate is useful, nil accepts all
 
 	lookupRunning bool
 	dialing       map[discover.NodeID]connFlag
@@ -166,7 +168,14 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 
 	var newtasks []task
 	addDial := func(flag connFlag, n *discover.Node) bool {
-		if err := s.checkDial(n, peers); err != nil {
+		err := s.checkDial(n, peers)
+		if err == nil && s.banned != nil && s.banned(n) {
+			err = errBanned
+		}
+		if err == nil && s.filter != nil && !s.filter(n) {
+			err = errFilteredOut
+		}
+		if err != nil {
 			log.Trace("Skipping dial candidate", "id", n.ID, "addr", &net.TCPAddr{IP: n.IP, Port: int(n.TCP)}, "err", err)
 			return false
 		}
@@ -258,6 +267,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errFilteredOut      = errors.New("rejected by protocol filters")
 )
 
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
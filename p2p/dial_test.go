@@ -432,6 +432,68 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that protocol filters are applied to dynamic dial
+// candidates, but not to static nodes.
+func TestDialStateFilter(t *testing.T) {
+	// This table always returns the same random nodes
+	// in the order given below.
+	table := fakeTable{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+		{ID: uintID(3)},
+		{ID: uintID(4)},
+		{ID: uintID(5)},
+	}
+	static := []*discover.Node{{ID: uintID(9)}}
+
+	dialer := newDialState(static, nil, table, 10, nil)
+	dialer.filter = func(n *discover.Node) bool {
+		return n.ID == uintID(2) || n.ID == uintID(4)
+	}
+	runDialTest(t, dialtest{
+		init: dialer,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(9)}},
+					&dialTask{flags: dynDialedConn, dest: table[1]},
+					&dialTask{flags: dynDialedConn, dest: table[3]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+// This test checks that banned nodes are not dialed dynamically, but static
+// nodes are dialed even if banned.
+func TestDialStateBanned(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+		{ID: uintID(3)},
+	}
+	static := []*discover.Node{{ID: uintID(9)}}
+
+	dialer := newDialState(static, nil, table, 10, nil)
+	dialer.banned = func(n *discover.Node) bool {
+		return n.ID == uintID(2) || n.ID == uintID(9)
+	}
+	runDialTest(t, dialtest{
+		init: dialer,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: staticDialedConn, dest: &discover.Node{ID: uintID(9)}},
+					&dialTask{flags: dynDialedConn, dest: table[0]},
+					&dialTask{flags: dynDialedConn, dest: table[2]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*discover.Node{
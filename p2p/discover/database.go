@@ -23,12 +23,14 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"net"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
@@ -56,11 +58,13 @@ type nodeDB struct {
 var (
 	nodeDBVersionKey = []byte("version") // Version of the database to flush if changes
 	nodeDBItemPrefix = []byte("n:")      // Identifier to prefix node entries with
+	nodeDBBanPrefix  = []byte("ban:")    // Identifier to prefix ban entries with (not node scoped to survive expiration)
 
 	nodeDBDiscoverRoot      = ":discover"
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
 	nodeDBDiscoverPong      = nodeDBDiscoverRoot + ":lastpong"
 	nodeDBDiscoverFindFails = nodeDBDiscoverRoot + ":findfail"
+	nodeDBDiscoverRecord    = nodeDBDiscoverRoot + ":enr"
 )
 
 // newNodeDB creates a new node database for storing and retrieving infos about
@@ -303,6 +307,33 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// record retrieves the last known node record of a node.
+func (db *nodeDB) record(id NodeID) *enr.Record {
+	blob, err := db.lvl.Get(makeKey(id, nodeDBDiscoverRecord), nil)
+	if err != nil {
+		return nil
+	}
+	record := new(enr.Record)
+	if err := rlp.DecodeBytes(blob, record); err != nil {
+		log.Warn("Failed to decode node record", "id", id, "err", err)
+		return nil
+	}
+	return record
+}
+
+// updateRecord stores the node record of a node, unless a newer one is already
+// known.
+func (db *nodeDB) updateRecord(id NodeID, record *enr.Record) error {
+	if old := db.record(id); old != nil && old.Seq() > record.Seq() {
+		return nil
+	}
+	blob, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(makeKey(id, nodeDBDiscoverRecord), blob, nil)
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for bootstrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
@@ -363,6 +394,75 @@ func nextNode(it iterator.Iterator) *Node {
 	return nil
 }
 
+// Ban is a denial of connectivity to a remote node or to a whole IP network,
+// persisted in the node database to survive restarts.
+type Ban struct {
+	ID     NodeID     // Banned node, zero for network bans
+	Net    *net.IPNet // Banned IP network, nil for node bans
+	Until  time.Time  // Time at which the ban expires
+	Reason string     // Textual reason of the ban for operators
+}
+
+// banRLP is the database representation of a ban.
+type banRLP struct {
+	ID     NodeID
+	Net    string
+	Until  uint64
+	Reason string
+}
+
+// banKey generates the leveldb key-blob of a ban entry.
+func banKey(ban *Ban) []byte {
+	if ban.Net != nil {
+		return append(append(nodeDBBanPrefix, "ip:"...), ban.Net.String()...)
+	}
+	return append(append(nodeDBBanPrefix, "n:"...), ban.ID[:]...)
+}
+
+// bans retrieves all the bans stored in the database, expired ones included.
+func (db *nodeDB) bans() []*Ban {
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	defer it.Release()
+
+	var bans []*Ban
+	for it.Next() {
+		var enc banRLP
+		if err := rlp.DecodeBytes(it.Value(), &enc); err != nil {
+			log.Warn("Failed to decode ban RLP", "key", string(it.Key()), "err", err)
+			continue
+		}
+		ban := &Ban{ID: enc.ID, Until: time.Unix(int64(enc.Until), 0), Reason: enc.Reason}
+		if enc.Net != "" {
+			_, ipnet, err := net.ParseCIDR(enc.Net)
+			if err != nil {
+				log.Warn("Failed to parse banned network", "net", enc.Net, "err", err)
+				continue
+			}
+			ban.Net = ipnet
+		}
+		bans = append(bans, ban)
+	}
+	return bans
+}
+
+// storeBan inserts - potentially overwriting - a ban into the database.
+func (db *nodeDB) storeBan(ban *Ban) error {
+	enc := banRLP{ID: ban.ID, Until: uint64(ban.Until.Unix()), Reason: ban.Reason}
+	if ban.Net != nil {
+		enc.Net = ban.Net.String()
+	}
+	blob, err := rlp.EncodeToBytes(&enc)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(banKey(ban), blob, nil)
+}
+
+// deleteBan removes a ban from the database.
+func (db *nodeDB) deleteBan(ban *Ban) error {
+	return db.lvl.Delete(banKey(ban), nil)
+}
+
 // close flushes and closes the database files.
 func (db *nodeDB) close() {
 	close(db.quit)
@@ -378,3 +378,50 @@ func TestNodeDBSelfExpiration(t *testing.T) {
 		t.Errorf("self not evacuated")
 	}
 }
+
+func TestNodeDBBans(t *testing.T) {
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	// Ban an expired node and a network, and make sure node expiration keeps them
+	_, ipnet, _ := net.ParseCIDR("10.0.0.0/8")
+	until := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+
+	nodeBan := &Ban{ID: nodeDBExpirationNodes[0].node.ID, Until: until, Reason: "node"}
+	netBan := &Ban{Net: ipnet, Until: until, Reason: "net"}
+
+	if err := db.updateNode(nodeDBExpirationNodes[0].node); err != nil {
+		t.Fatalf("failed to insert node: %v", err)
+	}
+	for _, ban := range []*Ban{nodeBan, netBan} {
+		if err := db.storeBan(ban); err != nil {
+			t.Fatalf("failed to store ban %q: %v", ban.Reason, err)
+		}
+	}
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	if node := db.node(nodeBan.ID); node != nil {
+		t.Errorf("banned node not expired")
+	}
+	bans := db.bans()
+	if len(bans) != 2 {
+		t.Fatalf("ban count mismatch: have %d, want %d", len(bans), 2)
+	}
+	for _, ban := range bans {
+		want := nodeBan
+		if ban.Net != nil {
+			want = netBan
+		}
+		if !reflect.DeepEqual(ban, want) {
+			t.Errorf("ban mismatch: have %+v, want %+v", ban, want)
+		}
+	}
+	// Lift the node ban and make sure only the network one remains
+	if err := db.deleteBan(nodeBan); err != nil {
+		t.Fatalf("failed to delete ban: %v", err)
+	}
+	if bans := db.bans(); len(bans) != 1 || bans[0].Net == nil {
+		t.Errorf("remaining bans mismatch: have %v, want network ban", bans)
+	}
+}
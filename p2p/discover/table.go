@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
 
@@ -101,6 +102,8 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(toid NodeID, addr *net.UDPAddr) (*enr.Record, error)
+	updateRecord(entries []enr.Entry) error
 	close()
 }
 
@@ -218,6 +221,34 @@ func (tab *Table) Close() {
 	}
 }
 
+// Record returns the last known node record of a node, or nil if the node never
+// advertised one. Records are requested from every node the table bonds with.
+func (tab *Table) Record(id NodeID) *enr.Record {
+	return tab.db.record(id)
+}
+
+// SetRecordEntries sets the given entries in the local node record, replacing any
+// existing ones with the same key, and re-signs it.
+func (tab *Table) SetRecordEntries(entries ...enr.Entry) error {
+	return tab.net.updateRecord(entries)
+}
+
+// Bans returns all the bans persisted in the node database.
+func (tab *Table) Bans() []*Ban {
+	return tab.db.bans()
+}
+
+// StoreBan persists a ban in the node database, overwriting any previous ban of
+// the same node or network.
+func (tab *Table) StoreBan(ban *Ban) error {
+	return tab.db.storeBan(ban)
+}
+
+// DeleteBan removes a ban of a node or network from the node database.
+func (tab *Table) DeleteBan(ban *Ban) error {
+	return tab.db.deleteBan(ban)
+}
+
 // setFallbackNodes sets the initial points of contact. These nodes
 // are used to connect to the network if the table is empty and there
 // are no known nodes in the database.
@@ -654,6 +685,22 @@ func (tab *Table) pingpong(w *bondproc, pinged bool, id NodeID, addr *net.UDPAdd
 	// Bonding succeeded, update the node database.
 	w.n = NewNode(id, addr.IP, uint16(addr.Port), tcpPort)
 	close(w.done)
+
+	// Retrieve the node record in the background, old nodes won't reply
+	go tab.fetchRecord(id, addr)
+}
+
+// fetchRecord requests the node record of a bonded node and stores it in the
+// node database.
+func (tab *Table) fetchRecord(id NodeID, addr *net.UDPAddr) {
+	record, err := tab.net.requestENR(id, addr)
+	if err != nil {
+		log.Trace("Node record retrieval failed", "id", id, "addr", addr, "err", err)
+		return
+	}
+	if err := tab.db.updateRecord(id, record); err != nil {
+		log.Warn("Failed to store node record", "id", id, "err", err)
+	}
 }
 
 // ping a remote endpoint and wait for a reply, also updating the node
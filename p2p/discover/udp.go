@@ -23,10 +23,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -44,6 +46,7 @@ var (
 	errTimeout          = errors.New("RPC timeout")
 	errClockWarp        = errors.New("reply deadline too far in the future")
 	errClosed           = errors.New("socket closed")
+	errRecordMismatch   = errors.New("node record identity mismatch")
 )
 
 // Timeouts
@@ -63,6 +66,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -104,6 +109,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest is a query for the node record of the recipient.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte     // Hash of the enrRequest packet.
+		Record   enr.Record // Node record of the sender.
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -167,6 +187,10 @@ type udp struct {
 	addpending chan *pending
 	gotreply   chan reply
 
+	recordMu sync.Mutex
+	record   *enr.Record // Signed node record of the local node
+	entries  []enr.Entry // Extra entries advertised in the local node record
+
 	closing chan struct{}
 	nat     nat.Interface
 
@@ -227,6 +251,7 @@ type Config struct {
 	NetRestrict  *netutil.Netlist  // network whitelist
 	Bootnodes    []*Node           // list of bootstrap nodes
 	Unhandled    chan<- ReadPacket // unhandled packets are sent on this channel
+	Entries      []enr.Entry       // extra entries to advertise in the local node record
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
@@ -254,6 +279,13 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	}
 	// TODO: separate TCP port
 	udp.ourEndpoint = makeEndpoint(realaddr, uint16(realaddr.Port))
+
+	record, err := makeLocalRecord(cfg.PrivateKey, udp.ourEndpoint, cfg.Entries, uint64(time.Now().Unix()))
+	if err != nil {
+		return nil, nil, err
+	}
+	udp.record, udp.entries = record, cfg.Entries
+
 	tab, err := newTable(udp, PubkeyID(&cfg.PrivateKey.PublicKey), realaddr, cfg.NodeDBPath, cfg.Bootnodes)
 	if err != nil {
 		return nil, nil, err
@@ -265,6 +297,68 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	return udp.Table, udp, nil
 }
 
+// makeLocalRecord creates the signed node record of the local node, advertising
+// its endpoint and any extra entries. The sequence number is seeded from the
+// current time, so that records signed after a restart supersede older ones.
+func makeLocalRecord(priv *ecdsa.PrivateKey, endpoint rpcEndpoint, entries []enr.Entry, seq uint64) (*enr.Record, error) {
+	record := new(enr.Record)
+	if ip4 := endpoint.IP.To4(); ip4 != nil {
+		record.Set(enr.IP4(ip4))
+	} else if len(endpoint.IP) == net.IPv6len {
+		record.Set(enr.IP6(endpoint.IP))
+	}
+	record.Set(enr.UDP(endpoint.UDP))
+	record.Set(enr.TCP(endpoint.TCP))
+	for _, entry := range entries {
+		record.Set(entry)
+	}
+	record.SetSeq(seq)
+	if err := record.Sign(priv); err != nil {
+		return nil, fmt.Errorf("can't sign local node record: %v", err)
+	}
+	return record, nil
+}
+
+// localRecord returns the current signed node record of the local node.
+func (t *udp) localRecord() *enr.Record {
+	t.recordMu.Lock()
+	defer t.recordMu.Unlock()
+
+	return t.record
+}
+
+// updateRecord sets the given entries in the local node record, replacing any
+// existing ones with the same key, and re-signs it with a higher sequence number
+// so that nodes holding the old record pick up the new one.
+func (t *udp) updateRecord(entries []enr.Entry) error {
+	t.recordMu.Lock()
+	defer t.recordMu.Unlock()
+
+	merged := append([]enr.Entry{}, t.entries...)
+	for _, entry := range entries {
+		replaced := false
+		for i, old := range merged {
+			if old.ENRKey() == entry.ENRKey() {
+				merged[i], replaced = entry, true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, entry)
+		}
+	}
+	seq := uint64(time.Now().Unix())
+	if seq <= t.record.Seq() {
+		seq = t.record.Seq() + 1
+	}
+	record, err := makeLocalRecord(t.priv, t.ourEndpoint, merged, seq)
+	if err != nil {
+		return err
+	}
+	t.record, t.entries = record, merged
+	return nil
+}
+
 func (t *udp) close() {
 	close(t.closing)
 	t.conn.Close()
@@ -320,6 +414,39 @@ func (t *udp) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node
 	return nodes, err
 }
 
+// requestENR sends an enrRequest to the given node and waits for its signed
+// node record, verifying that it belongs to the node.
+func (t *udp) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	req := &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, hash, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	var record *enr.Record
+	errc := t.pending(toid, enrResponsePacket, func(r interface{}) bool {
+		reply := r.(*enrResponse)
+		if !bytes.Equal(reply.ReplyTok, hash) {
+			return false
+		}
+		record = &reply.Record
+		return true
+	})
+	t.write(toaddr, req.name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	var pubkey enr.Secp256k1
+	if err := record.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != toid {
+		return nil, errRecordMismatch
+	}
+	return record, nil
+}
+
 // pending adds a reply callback to the pending reply queue.
 // see the documentation of type pending for a detailed explanation.
 func (t *udp) pending(id NodeID, ptype byte, callback func(interface{}) bool) <-chan error {
@@ -571,6 +698,10 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -662,6 +793,33 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 
 func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.db.hasBond(fromID) {
+		// No bond exists, don't reply with a packet larger than the request
+		// to an endpoint that might be spoofed (see findnode).
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, &enrResponse{
+		ReplyTok: mac,
+		Record:   *t.localRecord(),
+	})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
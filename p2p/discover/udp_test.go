@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -321,6 +322,105 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// unbonded nodes must not receive the record.
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	// bonded ones get the signed local record.
+	test.table.db.updateBondTime(PubkeyID(&test.remotekey.PublicKey), time.Now())
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	test.waitPacketOut(func(p *enrResponse) {
+		if !bytes.Equal(p.ReplyTok, test.sent[len(test.sent)-1][:macSize]) {
+			t.Errorf("reply token mismatch")
+		}
+		var pubkey enr.Secp256k1
+		if err := p.Record.Load(&pubkey); err != nil {
+			t.Fatalf("can't load public key from record: %v", err)
+		}
+		if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != test.table.self.ID {
+			t.Errorf("record of wrong node returned")
+		}
+		var udp enr.UDP
+		if err := p.Record.Load(&udp); err != nil || uint16(udp) != test.table.self.UDP {
+			t.Errorf("wrong UDP port in record: %d (err %v)", udp, err)
+		}
+	})
+}
+
+func TestUDP_enrRequestUpdated(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	test.table.db.updateBondTime(PubkeyID(&test.remotekey.PublicKey), time.Now())
+	request := func() (record enr.Record) {
+		test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+		test.waitPacketOut(func(p *enrResponse) { record = p.Record })
+		return record
+	}
+	old := request()
+
+	// updated entries must be advertised in a re-signed record superseding the old one.
+	for i := uint(1); i <= 2; i++ {
+		if err := test.table.SetRecordEntries(enr.WithEntry("test", i)); err != nil {
+			t.Fatalf("can't update record: %v", err)
+		}
+		record := request()
+		if record.Seq() <= old.Seq() {
+			t.Errorf("update %d: sequence number not increased: have %d, old %d", i, record.Seq(), old.Seq())
+		}
+		var value uint
+		if err := record.Load(enr.WithEntry("test", &value)); err != nil || value != i {
+			t.Errorf("update %d: wrong entry in record: %d (err %v)", i, value, err)
+		}
+		var pubkey enr.Secp256k1
+		if err := record.Load(&pubkey); err != nil || PubkeyID((*ecdsa.PublicKey)(&pubkey)) != test.table.self.ID {
+			t.Errorf("update %d: record not signed by the local node (err %v)", i, err)
+		}
+		old = record
+	}
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	var (
+		rid     = PubkeyID(&test.remotekey.PublicKey)
+		resultc = make(chan *enr.Record, 1)
+		errc    = make(chan error, 1)
+	)
+	go func() {
+		rec, err := test.udp.requestENR(rid, test.remoteaddr)
+		if err != nil {
+			errc <- err
+		} else {
+			resultc <- rec
+		}
+	}()
+	hash, _ := test.waitPacketOut(func(p *enrRequest) {})
+
+	// a record signed by a different key must be rejected.
+	var record enr.Record
+	record.Set(enr.UDP(30303))
+	if err := record.Sign(newkey()); err != nil {
+		t.Fatal(err)
+	}
+	test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: record})
+	select {
+	case rec := <-resultc:
+		t.Fatalf("foreign record accepted: %v", rec)
+	case err := <-errc:
+		if err != errRecordMismatch {
+			t.Fatalf("wrong error: got %v, want %v", err, errRecordMismatch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("requestENR did not return within 5 seconds")
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	added := make(chan *Node, 1)
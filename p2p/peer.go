@@ -109,6 +109,12 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// reputation tracks misbehaviour reported by the protocols, nil if unused
+	reputation *reputation
+
+	// recorder captures the sub-protocol messages exchanged with the peer if set
+	recorder *msgRecorder
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -155,6 +161,18 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Penalize reports a misbehaviour of the peer with the given severity. Peers
+// accumulating too many penalties are disconnected and banned for a while.
+func (p *Peer) Penalize(severity Severity, reason string) {
+	if p.reputation == nil {
+		return
+	}
+	p.log.Debug("Penalizing peer", "severity", severity, "reason", reason)
+	if p.reputation.report(p.ID(), remoteIP(p.RemoteAddr()), severity, reason, time.Now()) {
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	return fmt.Sprintf("Peer %x %v", p.rw.id[:8], p.RemoteAddr())
@@ -341,6 +359,9 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.wstart = writeStart
 		proto.werr = writeErr
 		var rw MsgReadWriter = proto
+		if p.recorder != nil {
+			rw = &msgCapturer{MsgReadWriter: rw, recorder: p.recorder, proto: proto}
+		}
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name)
 		}
@@ -426,6 +447,11 @@ type PeerInfo struct {
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 }
 
+// Trusted returns whether the peer was configured as a trusted node.
+func (p *Peer) Trusted() bool {
+	return p.rw.is(trustedConn)
+}
+
 // Info gathers and returns a collection of metadata known about a peer.
 func (p *Peer) Info() *PeerInfo {
 	// Gather the protocol capabilities
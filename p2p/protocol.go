@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,18 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes contains protocol specific information for the local node
+	// record, advertised to other nodes through discovery. They can be updated
+	// while the server is running through Server.UpdateRecord.
+	Attributes []enr.Entry
+
+	// DialCandidate is an optional predicate deciding whether a node found
+	// through discovery is worth dialing for this protocol. The record is
+	// nil if the node record of the candidate is not (yet) known. Nodes are
+	// dialed dynamically if any protocol accepts them, protocols without a
+	// predicate accepting all nodes. Static nodes are always dialed.
+	DialCandidate func(id discover.NodeID, record *enr.Record) bool
 }
 
 func (p Protocol) cap() Cap {
@@ -0,0 +1,376 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the peer reputation tracker, scoring misbehaving peers and banning
+// them once they cross a threshold.
+
+package p2p
+
+import (
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// Severity is the weight of a misbehaviour reported against a peer. Penalties
+// accumulate into a decaying score, banning the peer once it reaches the ban
+// threshold (100 by default).
+type Severity float64
+
+const (
+	SeverityLow    Severity = 1   // Slow, useless or unsolicited replies
+	SeverityMedium Severity = 10  // Invalid data that might still be an honest mistake
+	SeverityHigh   Severity = 50  // Provably invalid or malicious data
+	SeverityFatal  Severity = 100 // Immediate ban with the default threshold
+)
+
+const (
+	defaultBanThreshold = 100            // Score at which a peer is banned, unless configured
+	defaultBanDuration  = 24 * time.Hour // Duration of automatic bans, unless configured
+
+	reputationHalfLife = 30 * time.Minute // Time it takes for penalty scores to decay to half
+	reputationIPFactor = 3                // Multiple of the ban threshold at which a whole IP is banned
+	reputationMaxItems = 4096             // Maximum number of scores to track, limiting memory use
+)
+
+// errBanned is returned when a connection to or from a banned node is refused.
+var errBanned = errors.New("banned")
+
+// banDatabase is the persistent storage of the bans, implemented by the
+// discovery table through its node database.
+type banDatabase interface {
+	Bans() []*discover.Ban
+	StoreBan(ban *discover.Ban) error
+	DeleteBan(ban *discover.Ban) error
+}
+
+// BanInfo represents a short summary of an active ban.
+type BanInfo struct {
+	ID     string    `json:"id,omitempty"`  // Banned node identifier, empty for network bans
+	Net    string    `json:"net,omitempty"` // Banned IP network, empty for node bans
+	Until  time.Time `json:"until"`         // Time at which the ban expires
+	Reason string    `json:"reason"`        // Textual reason of the ban
+}
+
+// score is an exponentially decaying penalty score.
+type score struct {
+	value   float64
+	updated time.Time
+}
+
+// decayed returns the value of the score at the given time.
+func (s *score) decayed(now time.Time) float64 {
+	return s.value * math.Exp2(-float64(now.Sub(s.updated))/float64(reputationHalfLife))
+}
+
+// add decays the score up to the given time and adds a penalty to it.
+func (s *score) add(penalty float64, now time.Time) float64 {
+	s.value, s.updated = s.decayed(now)+penalty, now
+	return s.value
+}
+
+// reputation tracks the misbehaviour scores of remote nodes and the IPs they
+// connect from, and maintains the bans issued either automatically when a
+// score crosses the threshold, or manually by the operator.
+type reputation struct {
+	threshold float64       // Score at which a node gets banned
+	duration  time.Duration // Duration of automatic bans
+	db        banDatabase   // Persistent ban storage, nil to keep bans in memory only
+
+	nodes map[discover.NodeID]*score // Penalty scores of individual nodes
+	ips   map[string]*score          // Penalty scores of the IPs nodes connected from
+
+	nodeBans map[discover.NodeID]*discover.Ban // Active bans of individual nodes
+	netBans  map[string]*discover.Ban          // Active bans of IP networks, keyed by CIDR
+
+	lock sync.Mutex
+	log  log.Logger
+}
+
+// newReputation creates a reputation tracker, loading any previously persisted
+// bans that did not expire yet.
+func newReputation(threshold float64, duration time.Duration, db banDatabase, logger log.Logger) *reputation {
+	if threshold <= 0 {
+		threshold = defaultBanThreshold
+	}
+	if duration <= 0 {
+		duration = defaultBanDuration
+	}
+	r := &reputation{
+		threshold: threshold,
+		duration:  duration,
+		db:        db,
+		nodes:     make(map[discover.NodeID]*score),
+		ips:       make(map[string]*score),
+		nodeBans:  make(map[discover.NodeID]*discover.Ban),
+		netBans:   make(map[string]*discover.Ban),
+		log:       logger,
+	}
+	if db != nil {
+		now := time.Now()
+		for _, ban := range db.Bans() {
+			if !ban.Until.After(now) {
+				db.DeleteBan(ban)
+				continue
+			}
+			r.insert(ban)
+		}
+		if len(r.nodeBans)+len(r.netBans) > 0 {
+			logger.Info("Loaded persisted peer bans", "nodes", len(r.nodeBans), "nets", len(r.netBans))
+		}
+	}
+	return r
+}
+
+// insert adds a ban into the in-memory indexes.
+func (r *reputation) insert(ban *discover.Ban) {
+	if ban.Net != nil {
+		r.netBans[ban.Net.String()] = ban
+	} else {
+		r.nodeBans[ban.ID] = ban
+	}
+}
+
+// report adds a penalty to the score of a node and of the IP it's connected
+// from, banning them if their scores cross the thresholds. The returned flag
+// reports whether the node got banned.
+func (r *reputation) report(id discover.NodeID, ip net.IP, severity Severity, reason string, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Bump the node's score and ban it if it misbehaved too much
+	s := r.nodes[id]
+	if s == nil {
+		r.makeRoom(now)
+		s = &score{updated: now}
+		r.nodes[id] = s
+	}
+	banned := false
+	if s.add(float64(severity), now) >= r.threshold {
+		r.log.Warn("Banning misbehaving node", "id", id, "ip", ip, "reason", reason, "duration", r.duration)
+		r.ban(&discover.Ban{ID: id, Until: now.Add(r.duration), Reason: reason})
+		delete(r.nodes, id)
+		banned = true
+	}
+	// Bump the IP's score too, catching nodes that keep changing their identity
+	if ip != nil {
+		key := ip.String()
+		s := r.ips[key]
+		if s == nil {
+			r.makeRoom(now)
+			s = &score{updated: now}
+			r.ips[key] = s
+		}
+		if s.add(float64(severity), now) >= r.threshold*reputationIPFactor {
+			r.log.Warn("Banning misbehaving IP", "ip", ip, "reason", reason, "duration", r.duration)
+			r.ban(&discover.Ban{Net: singleIPNet(ip), Until: now.Add(r.duration), Reason: reason})
+			delete(r.ips, key)
+			banned = true
+		}
+	}
+	return banned
+}
+
+// makeRoom drops the scores that decayed to insignificance if too many are
+// tracked, and random ones if that's not enough.
+func (r *reputation) makeRoom(now time.Time) {
+	if len(r.nodes)+len(r.ips) < reputationMaxItems {
+		return
+	}
+	for id, s := range r.nodes {
+		if s.decayed(now) < float64(SeverityLow) {
+			delete(r.nodes, id)
+		}
+	}
+	for ip, s := range r.ips {
+		if s.decayed(now) < float64(SeverityLow) {
+			delete(r.ips, ip)
+		}
+	}
+	for len(r.nodes)+len(r.ips) >= reputationMaxItems {
+		// Everything is still relevant, sacrifice random node scores
+		for id := range r.nodes {
+			delete(r.nodes, id)
+			break
+		}
+		if len(r.nodes) == 0 {
+			for ip := range r.ips {
+				delete(r.ips, ip)
+				break
+			}
+		}
+	}
+}
+
+// ban inserts a new ban and persists it. The lock must be held.
+func (r *reputation) ban(ban *discover.Ban) {
+	r.insert(ban)
+	if r.db != nil {
+		if err := r.db.StoreBan(ban); err != nil {
+			r.log.Warn("Failed to persist peer ban", "err", err)
+		}
+	}
+}
+
+// banNode bans a node for the given duration, or the default one if zero.
+func (r *reputation) banNode(id discover.NodeID, duration time.Duration, reason string, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if duration <= 0 {
+		duration = r.duration
+	}
+	r.ban(&discover.Ban{ID: id, Until: now.Add(duration), Reason: reason})
+	delete(r.nodes, id)
+}
+
+// banNet bans an IP network for the given duration, or the default one if zero.
+func (r *reputation) banNet(ipnet *net.IPNet, duration time.Duration, reason string, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if duration <= 0 {
+		duration = r.duration
+	}
+	r.ban(&discover.Ban{Net: ipnet, Until: now.Add(duration), Reason: reason})
+}
+
+// unbanNode lifts the ban of a node, also forgiving its past misbehaviour. The
+// returned flag reports whether the node was banned at all.
+func (r *reputation) unbanNode(id discover.NodeID) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.nodes, id)
+	ban, ok := r.nodeBans[id]
+	if ok {
+		delete(r.nodeBans, id)
+		r.forget(ban)
+	}
+	return ok
+}
+
+// unbanNet lifts the ban of an IP network, also forgiving the misbehaviour of
+// the IPs within. The returned flag reports whether the network was banned.
+func (r *reputation) unbanNet(ipnet *net.IPNet) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for ip := range r.ips {
+		if ipnet.Contains(net.ParseIP(ip)) {
+			delete(r.ips, ip)
+		}
+	}
+	ban, ok := r.netBans[ipnet.String()]
+	if ok {
+		delete(r.netBans, ipnet.String())
+		r.forget(ban)
+	}
+	return ok
+}
+
+// forget removes a lifted or expired ban from the persistent storage. The lock
+// must be held.
+func (r *reputation) forget(ban *discover.Ban) {
+	if r.db != nil {
+		if err := r.db.DeleteBan(ban); err != nil {
+			r.log.Warn("Failed to delete peer ban", "err", err)
+		}
+	}
+}
+
+// banned checks whether a node or the IP it connects from is banned.
+func (r *reputation) banned(id discover.NodeID, ip net.IP, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if ban, ok := r.nodeBans[id]; ok {
+		if ban.Until.After(now) {
+			return true
+		}
+		delete(r.nodeBans, id)
+		r.forget(ban)
+	}
+	return ip != nil && r.bannedIP(ip, now)
+}
+
+// bannedIP checks whether an IP falls into any banned network. The lock must
+// be held.
+func (r *reputation) bannedIP(ip net.IP, now time.Time) bool {
+	for key, ban := range r.netBans {
+		if !ban.Until.After(now) {
+			delete(r.netBans, key)
+			r.forget(ban)
+			continue
+		}
+		if ban.Net.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the active bans, sorted by their expiration time.
+func (r *reputation) list(now time.Time) []*BanInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	infos := make([]*BanInfo, 0, len(r.nodeBans)+len(r.netBans))
+	for id, ban := range r.nodeBans {
+		if !ban.Until.After(now) {
+			delete(r.nodeBans, id)
+			r.forget(ban)
+			continue
+		}
+		infos = append(infos, &BanInfo{ID: id.String(), Until: ban.Until, Reason: ban.Reason})
+	}
+	for key, ban := range r.netBans {
+		if !ban.Until.After(now) {
+			delete(r.netBans, key)
+			r.forget(ban)
+			continue
+		}
+		infos = append(infos, &BanInfo{Net: key, Until: ban.Until, Reason: ban.Reason})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Until.Before(infos[j].Until) })
+	return infos
+}
+
+// singleIPNet converts an IP address into a network containing only itself.
+func singleIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// remoteIP extracts the IP address from a network address, or nil if the
+// address is not an IP one (e.g. in-memory pipes used in simulations).
+func remoteIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	return nil
+}
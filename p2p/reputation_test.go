@@ -0,0 +1,158 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// memoryBanDB is an in-memory ban database to test persistence with.
+type memoryBanDB map[string]*discover.Ban
+
+func (db memoryBanDB) key(ban *discover.Ban) string {
+	if ban.Net != nil {
+		return ban.Net.String()
+	}
+	return ban.ID.String()
+}
+
+func (db memoryBanDB) Bans() []*discover.Ban {
+	var bans []*discover.Ban
+	for _, ban := range db {
+		bans = append(bans, ban)
+	}
+	return bans
+}
+
+func (db memoryBanDB) StoreBan(ban *discover.Ban) error {
+	db[db.key(ban)] = ban
+	return nil
+}
+
+func (db memoryBanDB) DeleteBan(ban *discover.Ban) error {
+	delete(db, db.key(ban))
+	return nil
+}
+
+// Tests that penalties accumulate into a ban, but decay if spread out in time.
+func TestReputationThresholdDecay(t *testing.T) {
+	var (
+		rep = newReputation(0, time.Hour, nil, log.New())
+		now = time.Now()
+		ip  = net.IP{10, 0, 0, 1}
+	)
+	// Reports spaced out by many half-lives should never ban
+	slow := discover.NodeID{1}
+	for i := 0; i < 10; i++ {
+		now = now.Add(10 * reputationHalfLife)
+		if rep.report(slow, nil, SeverityHigh, "slow", now) {
+			t.Fatalf("report %d: slowly misbehaving node banned", i)
+		}
+	}
+	// Reports in quick succession should ban on the threshold
+	fast := discover.NodeID{2}
+	if rep.report(fast, ip, SeverityHigh, "fast", now) {
+		t.Fatalf("node banned below threshold")
+	}
+	if rep.banned(fast, nil, now) {
+		t.Fatalf("node reported banned below threshold")
+	}
+	if !rep.report(fast, ip, SeverityHigh, "fast", now) {
+		t.Fatalf("node not banned above threshold")
+	}
+	if !rep.banned(fast, nil, now) {
+		t.Fatalf("node not reported banned")
+	}
+	if rep.banned(discover.NodeID{3}, ip, now) {
+		t.Fatalf("IP banned together with a single node")
+	}
+	// Bans should expire after their duration
+	if rep.banned(fast, nil, now.Add(time.Hour+time.Second)) {
+		t.Fatalf("node still banned after expiration")
+	}
+	if bans := rep.list(now); len(bans) != 0 {
+		t.Fatalf("expired bans still listed: %v", bans)
+	}
+}
+
+// Tests that nodes rotating their identities get their IP banned.
+func TestReputationIPBan(t *testing.T) {
+	var (
+		rep = newReputation(0, time.Hour, nil, log.New())
+		now = time.Now()
+		ip  = net.IP{10, 0, 0, 1}
+	)
+	for i := 0; i < reputationIPFactor; i++ {
+		rep.report(discover.NodeID{byte(i)}, ip, SeverityFatal, "rotating", now)
+	}
+	if !rep.banned(discover.NodeID{0xff}, ip, now) {
+		t.Fatalf("fresh node from misbehaving IP not banned")
+	}
+	if rep.banned(discover.NodeID{0xff}, net.IP{10, 0, 0, 2}, now) {
+		t.Fatalf("neighbouring IP banned")
+	}
+	if !rep.unbanNet(singleIPNet(ip)) {
+		t.Fatalf("IP ban not lifted")
+	}
+	if rep.banned(discover.NodeID{0xff}, ip, now) {
+		t.Fatalf("fresh node banned after lifting IP ban")
+	}
+}
+
+// Tests that bans are persisted and reloaded, skipping expired ones.
+func TestReputationPersistence(t *testing.T) {
+	var (
+		db  = make(memoryBanDB)
+		rep = newReputation(0, 0, db, log.New())
+		now = time.Now()
+	)
+	_, subnet, _ := net.ParseCIDR("192.168.0.0/16")
+
+	rep.banNode(discover.NodeID{1}, time.Hour, "manual", now)
+	rep.banNode(discover.NodeID{2}, time.Second, "short", now.Add(-time.Minute))
+	rep.banNet(subnet, 0, "subnet", now)
+
+	if len(db) != 3 {
+		t.Fatalf("persisted ban count mismatch: have %d, want %d", len(db), 3)
+	}
+	// Reload the bans and check that only the live ones are kept
+	rep = newReputation(0, 0, db, log.New())
+	if !rep.banned(discover.NodeID{1}, nil, now) {
+		t.Errorf("persisted node ban lost")
+	}
+	if rep.banned(discover.NodeID{2}, nil, now) {
+		t.Errorf("expired node ban reloaded")
+	}
+	if !rep.banned(discover.NodeID{3}, net.IP{192, 168, 1, 1}, now) {
+		t.Errorf("persisted network ban lost")
+	}
+	if len(db) != 2 {
+		t.Errorf("expired ban not deleted from database")
+	}
+	// Lift the bans and make sure they are removed from the database too
+	if !rep.unbanNode(discover.NodeID{1}) || !rep.unbanNet(subnet) {
+		t.Fatalf("failed to lift bans")
+	}
+	if len(db) != 0 {
+		t.Errorf("lifted bans left in database: %v", db)
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 )
@@ -107,9 +108,18 @@ type Config struct {
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
 	// NodeDatabase is the path to the database containing the previously seen
-	// live nodes in the network.
+	// live nodes in the network. Peer bans are persisted in it too.
 	NodeDatabase string `toml:",omitempty"`
 
+	// BanThreshold is the misbehaviour score at which a peer gets banned.
+	// Zero defaults to preset values.
+	BanThreshold float64 `toml:",omitempty"`
+
+	// BanDuration is the time for which misbehaving peers are banned.
+	// Zero defaults to preset values. Bans are not enforced on trusted
+	// nodes and on dialed static nodes.
+	BanDuration time.Duration `toml:",omitempty"`
+
 	// Protocols should contain the protocols supported
 	// by the server. Matching protocols are launched for
 	// each peer.
@@ -139,6 +149,10 @@ type Config struct {
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
 
+	// If CaptureDir is set, the sub-protocol messages exchanged with each
+	// peer are recorded into a capture file in the given directory.
+	CaptureDir string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -161,6 +175,7 @@ type Server struct {
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
+	reputation   *reputation
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -336,6 +351,40 @@ func (srv *Server) makeSelf(listener net.Listener, ntab discoverTable) *discover
 	return ntab.Self()
 }
 
+// UpdateRecord sets the given entries in the local node record advertised through
+// discovery, replacing any existing ones with the same key, and re-signs it.
+// Protocols use it to keep their Attributes up to date. It is a no-op if the
+// server is not running or discovery is disabled.
+func (srv *Server) UpdateRecord(entries ...enr.Entry) error {
+	srv.lock.Lock()
+	ntab := srv.ntab
+	srv.lock.Unlock()
+
+	if tab, ok := ntab.(interface {
+		SetRecordEntries(...enr.Entry) error
+	}); ok {
+		return tab.SetRecordEntries(entries...)
+	}
+	return nil
+}
+
+// dialCandidate reports whether any of the running protocols is interested in
+// dialing the given node, based on the node record learned through discovery.
+func (srv *Server) dialCandidate(n *discover.Node) bool {
+	var record *enr.Record
+	if tab, ok := srv.ntab.(interface {
+		Record(discover.NodeID) *enr.Record
+	}); ok {
+		record = tab.Record(n.ID)
+	}
+	for _, p := range srv.Protocols {
+		if p.DialCandidate == nil || p.DialCandidate(n.ID, record) {
+			return true
+		}
+	}
+	return false
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -447,6 +496,7 @@ func (srv *Server) Start() (err error) {
 	}
 
 	// node table
+	var bans banDatabase
 	if !srv.NoDiscovery {
 		cfg := discover.Config{
 			PrivateKey:   srv.PrivateKey,
@@ -456,12 +506,17 @@ func (srv *Server) Start() (err error) {
 			Bootnodes:    srv.BootstrapNodes,
 			Unhandled:    unhandled,
 		}
+		for _, p := range srv.Protocols {
+			cfg.Entries = append(cfg.Entries, p.Attributes...)
+		}
 		ntab, err := discover.ListenUDP(conn, cfg)
 		if err != nil {
 			return err
 		}
-		srv.ntab = ntab
+		srv.ntab, bans = ntab, ntab
 	}
+	// peer reputation, persisting bans in the node database if discovery runs
+	srv.reputation = newReputation(srv.BanThreshold, srv.BanDuration, bans, srv.log)
 
 	if srv.DiscoveryV5 {
 		var (
@@ -484,6 +539,20 @@ func (srv *Server) Start() (err error) {
 
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	// Static nodes are dialed regardless of bans, trusted ones are exempt too
+	trusted := make(map[discover.NodeID]bool, len(srv.TrustedNodes))
+	for _, n := range srv.TrustedNodes {
+		trusted[n.ID] = true
+	}
+	dialer.banned = func(n *discover.Node) bool {
+		return !trusted[n.ID] && srv.reputation.banned(n.ID, n.IP, time.Now())
+	}
+	for _, p := range srv.Protocols {
+		if p.DialCandidate != nil {
+			dialer.filter = srv.dialCandidate
+			break
+		}
+	}
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -636,6 +705,7 @@ running:
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.reputation = srv.reputation
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -711,6 +781,8 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCo
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case !c.is(trustedConn|staticDialedConn) && srv.reputation != nil && srv.reputation.banned(c.id, remoteIP(c.fd.RemoteAddr()), time.Now()):
+		return errBanned
 	default:
 		return nil
 	}
@@ -890,9 +962,23 @@ func (srv *Server) runPeer(p *Peer) {
 		Peer: p.ID(),
 	})
 
+	// record the exchanged messages if requested
+	if srv.CaptureDir != "" {
+		recorder, err := newMsgRecorder(srv.CaptureDir, p)
+		if err != nil {
+			p.log.Warn("Failed to create message capture", "err", err)
+		}
+		p.recorder = recorder
+	}
 	// run the protocol
 	remoteRequested, err := p.run()
 
+	if p.recorder != nil {
+		if err := p.recorder.Close(); err != nil {
+			p.log.Warn("Failed to write message capture", "err", err)
+		}
+	}
+
 	// broadcast peer drop
 	srv.peerFeed.Send(&PeerEvent{
 		Type:  PeerEventTypeDrop,
@@ -905,6 +991,78 @@ func (srv *Server) runPeer(p *Peer) {
 	srv.delpeer <- peerDrop{p, err, remoteRequested}
 }
 
+// BanNode disconnects a node and refuses any connection to or from it until the
+// ban expires. A zero duration uses the configured ban duration.
+func (srv *Server) BanNode(id discover.NodeID, duration time.Duration, reason string) error {
+	rep, err := srv.reputationTracker()
+	if err != nil {
+		return err
+	}
+	rep.banNode(id, duration, reason, time.Now())
+	for _, p := range srv.Peers() {
+		if p.ID() == id {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+	return nil
+}
+
+// BanNet disconnects all the nodes connected from within an IP network, and
+// refuses any connection to or from it until the ban expires. A zero duration
+// uses the configured ban duration.
+func (srv *Server) BanNet(ipnet *net.IPNet, duration time.Duration, reason string) error {
+	rep, err := srv.reputationTracker()
+	if err != nil {
+		return err
+	}
+	rep.banNet(ipnet, duration, reason, time.Now())
+	for _, p := range srv.Peers() {
+		if ip := remoteIP(p.RemoteAddr()); ip != nil && ipnet.Contains(ip) {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+	return nil
+}
+
+// UnbanNode lifts the ban of a node, reporting whether it was banned at all.
+func (srv *Server) UnbanNode(id discover.NodeID) (bool, error) {
+	rep, err := srv.reputationTracker()
+	if err != nil {
+		return false, err
+	}
+	return rep.unbanNode(id), nil
+}
+
+// UnbanNet lifts the ban of an IP network, reporting whether it was banned at
+// all. Only the exact network that was banned can be lifted.
+func (srv *Server) UnbanNet(ipnet *net.IPNet) (bool, error) {
+	rep, err := srv.reputationTracker()
+	if err != nil {
+		return false, err
+	}
+	return rep.unbanNet(ipnet), nil
+}
+
+// Bans returns the currently active node and network bans.
+func (srv *Server) Bans() ([]*BanInfo, error) {
+	rep, err := srv.reputationTracker()
+	if err != nil {
+		return nil, err
+	}
+	return rep.list(time.Now()), nil
+}
+
+// reputationTracker returns the reputation tracker of a running server.
+func (srv *Server) reputationTracker() (*reputation, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil, errServerStopped
+	}
+	return srv.reputation, nil
+}
+
 // NodeInfo represents a short summary of the information known about the host.
 type NodeInfo struct {
 	ID    string `json:"id"`    // Unique node identifier (also the encryption key)
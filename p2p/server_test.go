@@ -366,6 +366,129 @@ func TestServerAtCap(t *testing.T) {
 
 }
 
+func TestServerBannedConn(t *testing.T) {
+	trustedID := randomID()
+	srv := &Server{
+		Config: Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			NoDial:       true,
+			TrustedNodes: []*discover.Node{{ID: trustedID}},
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id discover.NodeID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(id, fd)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, id: id, cont: make(chan error)}
+	}
+	// Ban a node and make sure it's rejected
+	bannedID := randomID()
+	if err := srv.BanNode(bannedID, time.Hour, "test"); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	if err := srv.checkpoint(newconn(bannedID), srv.posthandshake); err != errBanned {
+		t.Errorf("wrong error for banned conn: %v", err)
+	}
+	// Trusted nodes should be allowed even if banned
+	if err := srv.BanNode(trustedID, time.Hour, "test"); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	if err := srv.checkpoint(newconn(trustedID), srv.posthandshake); err != nil {
+		t.Errorf("unexpected error for banned trusted conn: %v", err)
+	}
+	// Lift the ban and make sure the node is accepted again
+	if lifted, err := srv.UnbanNode(bannedID); !lifted || err != nil {
+		t.Fatalf("could not lift ban: %v, %v", lifted, err)
+	}
+	if err := srv.checkpoint(newconn(bannedID), srv.posthandshake); err != nil {
+		t.Errorf("unexpected error for unbanned conn: %v", err)
+	}
+	if bans, _ := srv.Bans(); len(bans) != 1 || bans[0].ID != trustedID.String() {
+		t.Errorf("ban list mismatch: %v", bans)
+	}
+}
+
+// This test checks that banned static nodes are dialed and kept as peers.
+func TestServerDialBannedStatic(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not setup listener: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	connected := make(chan *Peer, 1)
+	remid := randomID()
+	srv := startTestServer(t, remid, func(p *Peer) { connected <- p })
+	defer srv.Stop()
+
+	if err := srv.BanNode(remid, time.Hour, "test"); err != nil {
+		t.Fatalf("could not ban node: %v", err)
+	}
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	srv.AddPeer(&discover.Node{ID: remid, IP: tcpAddr.IP, TCP: uint16(tcpAddr.Port)})
+
+	select {
+	case peer := <-connected:
+		if peer.ID() != remid {
+			t.Errorf("peer has wrong id")
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("banned static node not connected within one second")
+	}
+}
+
+// This test checks that trusted nodes can connect from banned networks.
+func TestServerListenBannedTrusted(t *testing.T) {
+	connected := make(chan *Peer, 1)
+	remid := randomID()
+	srv := &Server{
+		Config: Config{
+			Name:         "test",
+			MaxPeers:     10,
+			ListenAddr:   "127.0.0.1:0",
+			PrivateKey:   newkey(),
+			TrustedNodes: []*discover.Node{{ID: remid}},
+		},
+		newPeerHook:  func(p *Peer) { connected <- p },
+		newTransport: func(fd net.Conn) transport { return newTestTransport(remid, fd) },
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.BanNet(&net.IPNet{IP: net.IP{127, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}, time.Hour, "test"); err != nil {
+		t.Fatalf("could not ban network: %v", err)
+	}
+	conn, err := net.DialTimeout("tcp", srv.ListenAddr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case peer := <-connected:
+		if peer.ID() != remid {
+			t.Errorf("peer has wrong id")
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("trusted node from banned network not accepted within one second")
+	}
+}
+
 func TestServerSetupConn(t *testing.T) {
 	id := randomID()
 	srvkey := newkey()
@@ -478,9 +478,7 @@ type SnapshotAPI struct {
 func (api SnapshotAPI) Snapshot() (map[string][]byte, error) {
 	snapshots := make(map[string][]byte)
 	for name, service := range api.services {
-		if s, ok := service.(interface {
-			Snapshot() ([]byte, error)
-		}); ok {
+		if s, ok := service.(SnapshotService); ok {
 			snap, err := s.Snapshot()
 			if err != nil {
 				return nil, err
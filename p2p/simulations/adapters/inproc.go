@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	mrand "math/rand"
 	"net"
 	"os"
 	"sync"
@@ -41,6 +42,13 @@ type SimAdapter struct {
 	mtx      sync.RWMutex
 	nodes    map[discover.NodeID]*SimNode
 	services map[string]ServiceFunc
+
+	linkMtx     sync.Mutex
+	links       map[linkKey]*simLink
+	defaultLink LinkConfig
+
+	linkRandMtx sync.Mutex // Protects linkRand, independent of the link lock
+	linkRand    *mrand.Rand // Source of the random link loss and jitter
 }
 
 // NewSimAdapter creates a SimAdapter which is capable of running in-memory
@@ -52,6 +60,8 @@ func NewSimAdapter(services map[string]ServiceFunc) *SimAdapter {
 		pipe:     netPipe,
 		nodes:    make(map[discover.NodeID]*SimNode),
 		services: services,
+		links:    make(map[linkKey]*simLink),
+		linkRand: newLinkRand(),
 	}
 }
 
@@ -64,6 +74,8 @@ func NewSocketAdapter(services map[string]ServiceFunc) *SimAdapter {
 		pipe:     socketPipe,
 		nodes:    make(map[discover.NodeID]*SimNode),
 		services: services,
+		links:    make(map[linkKey]*simLink),
+		linkRand: newLinkRand(),
 	}
 }
 
@@ -72,6 +84,8 @@ func NewTCPAdapter(services map[string]ServiceFunc) *SimAdapter {
 		pipe:     tcpPipe,
 		nodes:    make(map[discover.NodeID]*SimNode),
 		services: services,
+		links:    make(map[linkKey]*simLink),
+		linkRand: newLinkRand(),
 	}
 }
 
@@ -106,7 +120,7 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 			PrivateKey:      config.PrivateKey,
 			MaxPeers:        math.MaxInt32,
 			NoDiscovery:     true,
-			Dialer:          s,
+			Dialer:          &simDialer{adapter: s, id: id},
 			EnableMsgEvents: config.EnableMsgEvents,
 		},
 		NoUSB:  true,
@@ -128,8 +142,28 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 }
 
 // Dial implements the p2p.NodeDialer interface by connecting to the node using
-// an in-memory net.Pipe or OS socket connection
+// an in-memory net.Pipe or OS socket connection. As the dialing node is unknown,
+// the connection isn't subject to any simulated link conditions (the nodes of
+// the adapter dial through the link to their peers instead).
 func (s *SimAdapter) Dial(dest *discover.Node) (conn net.Conn, err error) {
+	return s.dial(nil, dest)
+}
+
+// simDialer is the p2p.NodeDialer of a SimNode, connecting it to its peers over
+// their simulated links
+type simDialer struct {
+	adapter *SimAdapter
+	id      discover.NodeID
+}
+
+// Dial implements the p2p.NodeDialer interface
+func (d *simDialer) Dial(dest *discover.Node) (net.Conn, error) {
+	return d.adapter.dial(&d.id, dest)
+}
+
+// dial connects to the destination node, over the link from the source node if
+// it is known
+func (s *SimAdapter) dial(src *discover.NodeID, dest *discover.Node) (conn net.Conn, err error) {
 	node, ok := s.GetNode(dest.ID)
 	if !ok {
 		return nil, fmt.Errorf("unknown node: %s", dest.ID)
@@ -143,6 +177,15 @@ func (s *SimAdapter) Dial(dest *discover.Node) (conn net.Conn, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if src != nil {
+		conn1, conn2, err := s.connectLink(*src, dest.ID, pipe1, pipe2)
+		if err != nil {
+			pipe1.Close()
+			pipe2.Close()
+			return nil, err
+		}
+		pipe1, pipe2 = conn1, conn2
+	}
 	// this is simulated 'listening'
 	// asynchronously call the dialed destintion node's p2p server
 	// to set up connection on the 'listening' side
@@ -232,9 +275,7 @@ func (self *SimNode) Snapshots() (map[string][]byte, error) {
 	}
 	snapshots := make(map[string][]byte)
 	for name, service := range services {
-		if s, ok := service.(interface {
-			Snapshot() ([]byte, error)
-		}); ok {
+		if s, ok := service.(SnapshotService); ok {
 			snap, err := s.Snapshot()
 			if err != nil {
 				return nil, err
@@ -262,7 +303,9 @@ func (self *SimNode) Start(snapshots map[string][]byte) error {
 			if err != nil {
 				return nil, err
 			}
+			self.lock.Lock()
 			self.running[name] = service
+			self.lock.Unlock()
 			return service, nil
 		}
 	}
@@ -0,0 +1,477 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+const (
+	// minRetransmitDelay is the minimum delay of a write lost on a link, modelled
+	// after the minimum retransmission timeout of TCP
+	minRetransmitDelay = 200 * time.Millisecond
+
+	// linkQueueSize is the maximum number of writes in flight on a link
+	// connection before writes block
+	linkQueueSize = 1024
+)
+
+var (
+	errLinkDown   = errors.New("link down")
+	errLinkClosed = errors.New("link connection closed")
+)
+
+// LinkConfig describes the simulated conditions of the link between two nodes.
+// The zero value is a perfect link which delivers data instantly.
+type LinkConfig struct {
+	// Latency is the one way delay of the data sent over the link
+	Latency time.Duration
+
+	// Jitter is the maximum random delay added to the latency of each write,
+	// the data still being delivered in order
+	Jitter time.Duration
+
+	// Bandwidth is the maximum rate in bytes per second at which data is sent
+	// in each direction, with zero meaning unlimited
+	Bandwidth uint64
+
+	// Loss is the probability in the range [0, 1] of a write being lost. As
+	// the connections are reliable streams, lost writes are retransmitted,
+	// delaying them and all the data sent after them
+	Loss float64
+
+	// Down drops all the connections over the link and fails new dials
+	Down bool
+}
+
+// linkConfigJSON is used to encode and decode LinkConfig as JSON, with the
+// latency encoded as a duration string such as "50ms"
+type linkConfigJSON struct {
+	Latency   string  `json:"latency,omitempty"`
+	Jitter    string  `json:"jitter,omitempty"`
+	Bandwidth uint64  `json:"bandwidth,omitempty"`
+	Loss      float64 `json:"loss,omitempty"`
+	Down      bool    `json:"down,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface by encoding the latency
+// and jitter as duration strings
+func (c LinkConfig) MarshalJSON() ([]byte, error) {
+	enc := linkConfigJSON{
+		Bandwidth: c.Bandwidth,
+		Loss:      c.Loss,
+		Down:      c.Down,
+	}
+	if c.Latency != 0 {
+		enc.Latency = c.Latency.String()
+	}
+	if c.Jitter != 0 {
+		enc.Jitter = c.Jitter.String()
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface by decoding the
+// latency from a duration string
+func (c *LinkConfig) UnmarshalJSON(data []byte) error {
+	var dec linkConfigJSON
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+	*c = LinkConfig{
+		Bandwidth: dec.Bandwidth,
+		Loss:      dec.Loss,
+		Down:      dec.Down,
+	}
+	if dec.Latency != "" {
+		latency, err := time.ParseDuration(dec.Latency)
+		if err != nil {
+			return err
+		}
+		c.Latency = latency
+	}
+	if dec.Jitter != "" {
+		jitter, err := time.ParseDuration(dec.Jitter)
+		if err != nil {
+			return err
+		}
+		c.Jitter = jitter
+	}
+	return c.Validate()
+}
+
+// Validate checks that the link parameters are within range
+func (c LinkConfig) Validate() error {
+	if c.Latency < 0 {
+		return fmt.Errorf("negative link latency %v", c.Latency)
+	}
+	if c.Jitter < 0 {
+		return fmt.Errorf("negative link jitter %v", c.Jitter)
+	}
+	if c.Loss < 0 || c.Loss > 1 {
+		return fmt.Errorf("link loss %v out of range [0, 1]", c.Loss)
+	}
+	return nil
+}
+
+// perfect returns whether the link delivers data instantly
+func (c LinkConfig) perfect() bool {
+	return c.Latency == 0 && c.Jitter == 0 && c.Bandwidth == 0 && c.Loss == 0
+}
+
+// linkKey identifies the link between two nodes, regardless of their order
+type linkKey [2]discover.NodeID
+
+func newLinkKey(one, other discover.NodeID) linkKey {
+	if bytes.Compare(one[:], other[:]) > 0 {
+		one, other = other, one
+	}
+	return linkKey{one, other}
+}
+
+// simLink is the link between two nodes of a SimAdapter, tracking the live
+// connections over it so they can be dropped when the link goes down
+type simLink struct {
+	config      *LinkConfig // Conditions of the link, nil for the default ones
+	partitioned bool        // Whether the link crosses a network partition
+	conns       map[*linkConn]struct{}
+}
+
+// newLinkRand creates the source of the random link loss and jitter of an
+// adapter, seeded with the current time
+func newLinkRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// SetLinkSeed seeds the source of the random link loss and jitter, so that a
+// simulation performing the same writes sees the same link behaviour
+func (s *SimAdapter) SetLinkSeed(seed int64) {
+	s.linkRandMtx.Lock()
+	defer s.linkRandMtx.Unlock()
+
+	s.linkRand = rand.New(rand.NewSource(seed))
+}
+
+// linkDelay returns the random extra delay of a write over a link with the
+// given conditions, due to jitter and retransmission if the write gets lost
+func (s *SimAdapter) linkDelay(config LinkConfig) time.Duration {
+	if config.Jitter == 0 && config.Loss == 0 {
+		return 0
+	}
+	s.linkRandMtx.Lock()
+	defer s.linkRandMtx.Unlock()
+
+	var delay time.Duration
+	if config.Jitter > 0 {
+		delay = time.Duration(s.linkRand.Int63n(int64(config.Jitter) + 1))
+	}
+	if config.Loss > 0 && s.linkRand.Float64() < config.Loss {
+		retransmit := 2 * config.Latency
+		if retransmit < minRetransmitDelay {
+			retransmit = minRetransmitDelay
+		}
+		delay += retransmit
+	}
+	return delay
+}
+
+// DefaultLink returns the conditions of the links which weren't configured
+// individually
+func (s *SimAdapter) DefaultLink() LinkConfig {
+	s.linkMtx.Lock()
+	defer s.linkMtx.Unlock()
+
+	return s.defaultLink
+}
+
+// SetDefaultLink sets the conditions of the links which weren't configured
+// individually, applying them to the existing connections as well
+func (s *SimAdapter) SetDefaultLink(config LinkConfig) {
+	s.linkMtx.Lock()
+	s.defaultLink = config
+
+	var drop []*linkConn
+	if config.Down {
+		for _, link := range s.links {
+			if link.config == nil {
+				drop = append(drop, link.connList()...)
+			}
+		}
+	}
+	s.linkMtx.Unlock()
+
+	closeLinkConns(drop)
+}
+
+// Link returns the conditions of the link between two nodes, reporting the
+// link as down if it crosses a network partition
+func (s *SimAdapter) Link(one, other discover.NodeID) LinkConfig {
+	s.linkMtx.Lock()
+	defer s.linkMtx.Unlock()
+
+	link := s.links[newLinkKey(one, other)]
+	if link == nil {
+		return s.defaultLink
+	}
+	return s.linkConfig(link)
+}
+
+// SetLink sets the conditions of the link between two nodes, applying them to
+// the existing connections as well
+func (s *SimAdapter) SetLink(one, other discover.NodeID, config LinkConfig) {
+	s.linkMtx.Lock()
+	link := s.link(newLinkKey(one, other))
+	link.config = &config
+
+	var drop []*linkConn
+	if config.Down {
+		drop = link.connList()
+	}
+	s.linkMtx.Unlock()
+
+	closeLinkConns(drop)
+}
+
+// Partition splits the network into the given groups of nodes by taking down
+// all the links between nodes of different groups. Links of nodes outside the
+// groups are unaffected.
+func (s *SimAdapter) Partition(groups [][]discover.NodeID) {
+	s.linkMtx.Lock()
+	var drop []*linkConn
+	for i := range groups {
+		for j := i + 1; j < len(groups); j++ {
+			for _, one := range groups[i] {
+				for _, other := range groups[j] {
+					if one == other {
+						continue
+					}
+					link := s.link(newLinkKey(one, other))
+					link.partitioned = true
+					drop = append(drop, link.connList()...)
+				}
+			}
+		}
+	}
+	s.linkMtx.Unlock()
+
+	closeLinkConns(drop)
+}
+
+// Heal restores the links taken down by network partitions. The dropped
+// connections are not restored.
+func (s *SimAdapter) Heal() {
+	s.linkMtx.Lock()
+	defer s.linkMtx.Unlock()
+
+	for _, link := range s.links {
+		link.partitioned = false
+	}
+}
+
+// link returns the link with the given key, creating it if needed. The caller
+// must hold linkMtx.
+func (s *SimAdapter) link(key linkKey) *simLink {
+	link := s.links[key]
+	if link == nil {
+		link = &simLink{conns: make(map[*linkConn]struct{})}
+		s.links[key] = link
+	}
+	return link
+}
+
+// linkConfig returns the current conditions of a link. The caller must hold
+// linkMtx.
+func (s *SimAdapter) linkConfig(link *simLink) LinkConfig {
+	config := s.defaultLink
+	if link.config != nil {
+		config = *link.config
+	}
+	if link.partitioned {
+		config.Down = true
+	}
+	return config
+}
+
+// connectLink wraps both ends of a connection between two nodes so that the
+// conditions of their link apply to the data sent in either direction
+func (s *SimAdapter) connectLink(one, other discover.NodeID, pipe1, pipe2 net.Conn) (net.Conn, net.Conn, error) {
+	s.linkMtx.Lock()
+	defer s.linkMtx.Unlock()
+
+	link := s.link(newLinkKey(one, other))
+	if s.linkConfig(link).Down {
+		return nil, nil, errLinkDown
+	}
+	conn1, conn2 := newLinkConn(s, link, pipe1), newLinkConn(s, link, pipe2)
+	link.conns[conn1] = struct{}{}
+	link.conns[conn2] = struct{}{}
+	return conn1, conn2, nil
+}
+
+// connList returns the live connections over the link. The caller must hold
+// linkMtx.
+func (l *simLink) connList() []*linkConn {
+	conns := make([]*linkConn, 0, len(l.conns))
+	for conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// closeLinkConns closes the given link connections
+func closeLinkConns(conns []*linkConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// linkWrite is a chunk of data in flight over a link
+type linkWrite struct {
+	data    []byte
+	deliver time.Time // Time at which the data arrives at the other end
+}
+
+// linkConn is one end of a connection over a simulated link, which delays the
+// data written to it according to the current link conditions. Reads are
+// passed through to the underlying connection.
+type linkConn struct {
+	net.Conn
+	adapter *SimAdapter
+	link    *simLink
+
+	writeLock sync.Mutex
+	wire      time.Time // Time at which the last write is fully transmitted
+	pending   int32     // Number of writes queued but not yet delivered (atomic)
+	queue     chan *linkWrite
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newLinkConn(adapter *SimAdapter, link *simLink, conn net.Conn) *linkConn {
+	c := &linkConn{
+		Conn:    conn,
+		adapter: adapter,
+		link:    link,
+		queue:   make(chan *linkWrite, linkQueueSize),
+		closed:  make(chan struct{}),
+	}
+	go c.deliver()
+	return c
+}
+
+// Write sends data over the link, blocking for the time it takes to transmit
+// it at the link bandwidth. The data arrives at the other end after the link
+// latency and a random jitter, plus a retransmission delay if it gets lost.
+func (c *linkConn) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.adapter.linkMtx.Lock()
+	config := c.adapter.linkConfig(c.link)
+	c.adapter.linkMtx.Unlock()
+
+	select {
+	case <-c.closed:
+		return 0, errLinkClosed
+	default:
+	}
+	// Write directly if the link is perfect and nothing is in flight
+	if config.perfect() && atomic.LoadInt32(&c.pending) == 0 {
+		return c.Conn.Write(b)
+	}
+	now := time.Now()
+	if c.wire.Before(now) {
+		c.wire = now
+	}
+	if config.Bandwidth > 0 {
+		c.wire = c.wire.Add(time.Duration(uint64(len(b)) * uint64(time.Second) / config.Bandwidth))
+	}
+	deliver := c.wire.Add(config.Latency + c.adapter.linkDelay(config))
+	// Wait for the data to be transmitted, then queue it for delivery
+	if wait := c.wire.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-c.closed:
+			timer.Stop()
+			return 0, errLinkClosed
+		}
+	}
+	data := make([]byte, len(b))
+	copy(data, b)
+
+	atomic.AddInt32(&c.pending, 1)
+	select {
+	case c.queue <- &linkWrite{data: data, deliver: deliver}:
+		return len(b), nil
+	case <-c.closed:
+		return 0, errLinkClosed
+	}
+}
+
+// deliver writes the queued data to the underlying connection in order, each
+// chunk once its delivery time is reached
+func (c *linkConn) deliver() {
+	for {
+		select {
+		case w := <-c.queue:
+			if wait := time.Until(w.deliver); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-c.closed:
+					timer.Stop()
+					return
+				}
+			}
+			if _, err := c.Conn.Write(w.data); err != nil {
+				c.Close()
+				return
+			}
+			atomic.AddInt32(&c.pending, -1)
+
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// Close closes the connection, discarding any data in flight
+func (c *linkConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+
+		c.adapter.linkMtx.Lock()
+		delete(c.link.conns, c)
+		c.adapter.linkMtx.Unlock()
+
+		err = c.Conn.Close()
+	})
+	return err
+}
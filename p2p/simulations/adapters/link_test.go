@@ -0,0 +1,166 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package adapters
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// connectTestLink connects two random nodes over a link of the adapter.
+func connectTestLink(t *testing.T, s *SimAdapter) (discover.NodeID, discover.NodeID, net.Conn, net.Conn) {
+	one, other := RandomNodeConfig().ID, RandomNodeConfig().ID
+
+	pipe1, pipe2 := net.Pipe()
+	conn1, conn2, err := s.connectLink(one, other, pipe1, pipe2)
+	if err != nil {
+		t.Fatalf("failed to connect link: %v", err)
+	}
+	return one, other, conn1, conn2
+}
+
+// measureTransfer writes data to one end of a link and returns the time it took
+// to arrive at the other end.
+func measureTransfer(t *testing.T, from, to net.Conn, size int) time.Duration {
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := from.Write(make([]byte, size))
+		errc <- err
+	}()
+	if _, err := io.ReadFull(to, make([]byte, size)); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	return time.Since(start)
+}
+
+// Tests that the conditions of a link apply to the data sent over it, and can be
+// changed while connected.
+func TestLinkConditions(t *testing.T) {
+	s := NewSimAdapter(nil)
+	one, other, conn1, conn2 := connectTestLink(t, s)
+	defer conn1.Close()
+	defer conn2.Close()
+
+	if elapsed := measureTransfer(t, conn1, conn2, 100); elapsed > 50*time.Millisecond {
+		t.Errorf("perfect link too slow: %v", elapsed)
+	}
+	s.SetLink(one, other, LinkConfig{Latency: 100 * time.Millisecond})
+	if elapsed := measureTransfer(t, conn2, conn1, 100); elapsed < 100*time.Millisecond {
+		t.Errorf("latency not applied: transfer took %v", elapsed)
+	}
+	s.SetLink(one, other, LinkConfig{Bandwidth: 10000})
+	if elapsed := measureTransfer(t, conn1, conn2, 2000); elapsed < 200*time.Millisecond {
+		t.Errorf("bandwidth not applied: transfer took %v", elapsed)
+	}
+	s.SetLink(one, other, LinkConfig{Loss: 1})
+	if elapsed := measureTransfer(t, conn1, conn2, 100); elapsed < minRetransmitDelay {
+		t.Errorf("loss not applied: transfer took %v", elapsed)
+	}
+	// Links without own conditions follow the default ones
+	s.SetDefaultLink(LinkConfig{Latency: 50 * time.Millisecond})
+	if have := s.Link(one, RandomNodeConfig().ID); have.Latency != 50*time.Millisecond {
+		t.Errorf("default link mismatch: have %+v", have)
+	}
+}
+
+// Tests that partitions drop the connections across them and fail new dials
+// until healed.
+func TestLinkPartition(t *testing.T) {
+	s := NewSimAdapter(nil)
+	one, other, conn1, conn2 := connectTestLink(t, s)
+	defer conn2.Close()
+
+	s.Partition([][]discover.NodeID{{one}, {other}})
+	if _, err := conn1.Write([]byte{1}); err == nil {
+		t.Error("write succeeded across partition")
+	}
+	if _, err := conn2.Read(make([]byte, 1)); err == nil {
+		t.Error("read succeeded across partition")
+	}
+	if !s.Link(one, other).Down {
+		t.Error("partitioned link not reported down")
+	}
+	pipe1, pipe2 := net.Pipe()
+	if _, _, err := s.connectLink(other, one, pipe1, pipe2); err != errLinkDown {
+		t.Errorf("dial error mismatch: have %v, want %v", err, errLinkDown)
+	}
+	s.Heal()
+	if _, _, err := s.connectLink(other, one, pipe1, pipe2); err != nil {
+		t.Errorf("failed to connect healed link: %v", err)
+	}
+}
+
+// Tests that the random link loss and jitter are reproducible for the same seed,
+// and within the range of the link conditions.
+func TestLinkSeed(t *testing.T) {
+	config := LinkConfig{Latency: 10 * time.Millisecond, Jitter: 50 * time.Millisecond, Loss: 0.5}
+
+	s1, s2 := NewSimAdapter(nil), NewSimAdapter(nil)
+	s1.SetLinkSeed(1)
+	s2.SetLinkSeed(1)
+
+	var lost int
+	for i := 0; i < 1000; i++ {
+		delay := s1.linkDelay(config)
+		if other := s2.linkDelay(config); delay != other {
+			t.Fatalf("delay %d mismatch for same seed: %v != %v", i, delay, other)
+		}
+		if delay < 0 || delay > config.Jitter+minRetransmitDelay {
+			t.Fatalf("delay %d out of range: %v", i, delay)
+		}
+		if delay >= minRetransmitDelay {
+			lost++
+		}
+	}
+	if lost < 400 || lost > 600 {
+		t.Errorf("lost write count mismatch: have %d, want ~500", lost)
+	}
+	if delay := s1.linkDelay(LinkConfig{Latency: time.Second}); delay != 0 {
+		t.Errorf("delay without jitter or loss: %v", delay)
+	}
+}
+
+// Tests that link conditions are encoded as JSON with a readable latency.
+func TestLinkConfigJSON(t *testing.T) {
+	config := LinkConfig{Latency: 50 * time.Millisecond, Jitter: 10 * time.Millisecond, Bandwidth: 1 << 20, Loss: 0.01}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"latency":"50ms","jitter":"10ms","bandwidth":1048576,"loss":0.01}`; string(data) != want {
+		t.Errorf("encoding mismatch: have %s, want %s", data, want)
+	}
+	var dec LinkConfig
+	if err := json.Unmarshal(data, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec != config {
+		t.Errorf("decoding mismatch: have %+v, want %+v", dec, config)
+	}
+	if err := json.Unmarshal([]byte(`{"loss":2}`), &dec); err == nil {
+		t.Error("out of range loss accepted")
+	}
+}
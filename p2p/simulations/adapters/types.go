@@ -73,6 +73,33 @@ type NodeAdapter interface {
 	NewNode(config *NodeConfig) (Node, error)
 }
 
+// LinkController is implemented by NodeAdapters which can simulate the
+// conditions of the network links between their nodes
+type LinkController interface {
+	// DefaultLink returns the conditions of the links which weren't
+	// configured individually
+	DefaultLink() LinkConfig
+
+	// SetDefaultLink sets the conditions of the links which weren't
+	// configured individually
+	SetDefaultLink(config LinkConfig)
+
+	// Link returns the conditions of the link between two nodes
+	Link(one, other discover.NodeID) LinkConfig
+
+	// SetLink sets the conditions of the link between two nodes
+	SetLink(one, other discover.NodeID, config LinkConfig)
+
+	// Partition takes down the links between nodes of different groups
+	Partition(groups [][]discover.NodeID)
+
+	// Heal restores the links taken down by partitions
+	Heal()
+
+	// SetLinkSeed seeds the source of the random link loss and jitter
+	SetLinkSeed(seed int64)
+}
+
 // NodeConfig is the configuration used to start a node in a simulation
 // network
 type NodeConfig struct {
@@ -211,7 +238,19 @@ type ServiceContext struct {
 
 	NodeContext *node.ServiceContext
 	Config      *NodeConfig
-	Snapshot    []byte
+
+	// Snapshot is the state the service contributed to the network snapshot
+	// the node is started from, if any (see SnapshotService)
+	Snapshot []byte
+}
+
+// SnapshotService is an optional interface of services which can contribute
+// their state to network snapshots, so that a network loaded from a snapshot
+// doesn't have to bootstrap it again. The snapshot is passed back to the
+// ServiceFunc in the ServiceContext when the node is started from it.
+type SnapshotService interface {
+	// Snapshot returns the serialized state of the service
+	Snapshot() ([]byte, error)
 }
 
 // RPCDialer is used when initialising services which need to connect to
@@ -85,6 +85,48 @@ func (c *Client) LoadSnapshot(snap *Snapshot) error {
 	return c.Post("/snapshot", snap, nil)
 }
 
+// RunScenario runs a scenario in the network, returning its result once all the
+// steps passed or one of them failed
+func (c *Client) RunScenario(scenario *Scenario) (*ScenarioResult, error) {
+	result := &ScenarioResult{}
+	return result, c.Post("/scenario", scenario, result)
+}
+
+// GetDefaultLink returns the conditions of the links between nodes which
+// weren't configured individually
+func (c *Client) GetDefaultLink() (*adapters.LinkConfig, error) {
+	config := &adapters.LinkConfig{}
+	return config, c.Get("/links", config)
+}
+
+// SetDefaultLink sets the conditions of the links between nodes which weren't
+// configured individually
+func (c *Client) SetDefaultLink(config *adapters.LinkConfig) error {
+	return c.Post("/links", config, nil)
+}
+
+// GetLink returns the conditions of the link between two nodes
+func (c *Client) GetLink(nodeID, peerID string) (*adapters.LinkConfig, error) {
+	config := &adapters.LinkConfig{}
+	return config, c.Get(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID), config)
+}
+
+// SetLink sets the conditions of the link between two nodes
+func (c *Client) SetLink(nodeID, peerID string, config *adapters.LinkConfig) error {
+	return c.Post(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID), config, nil)
+}
+
+// Partition splits the network into the given groups of nodes, dropping all
+// connections between nodes of different groups until the network is healed
+func (c *Client) Partition(groups [][]string) error {
+	return c.Post("/partition", groups, nil)
+}
+
+// Heal restores the links between nodes taken down by partitions
+func (c *Client) Heal() error {
+	return c.Post("/heal", nil, nil)
+}
+
 // SubscribeOpts is a collection of options to use when subscribing to network
 // events
 type SubscribeOpts struct {
@@ -288,6 +330,11 @@ func NewServer(network *Network) *Server {
 	s.GET("/events", s.StreamNetworkEvents)
 	s.GET("/snapshot", s.CreateSnapshot)
 	s.POST("/snapshot", s.LoadSnapshot)
+	s.POST("/scenario", s.RunScenario)
+	s.GET("/links", s.GetDefaultLink)
+	s.POST("/links", s.SetDefaultLink)
+	s.POST("/partition", s.Partition)
+	s.POST("/heal", s.Heal)
 	s.POST("/nodes", s.CreateNode)
 	s.GET("/nodes", s.GetNodes)
 	s.GET("/nodes/:nodeid", s.GetNode)
@@ -295,6 +342,8 @@ func NewServer(network *Network) *Server {
 	s.POST("/nodes/:nodeid/stop", s.StopNode)
 	s.POST("/nodes/:nodeid/conn/:peerid", s.ConnectNode)
 	s.DELETE("/nodes/:nodeid/conn/:peerid", s.DisconnectNode)
+	s.GET("/nodes/:nodeid/link/:peerid", s.GetLink)
+	s.POST("/nodes/:nodeid/link/:peerid", s.SetLink)
 	s.GET("/nodes/:nodeid/rpc", s.NodeRPC)
 
 	return s
@@ -559,6 +608,21 @@ func (s *Server) LoadSnapshot(w http.ResponseWriter, req *http.Request) {
 	s.JSON(w, http.StatusOK, s.network)
 }
 
+// RunScenario runs a scenario in the network, responding with its result
+func (s *Server) RunScenario(w http.ResponseWriter, req *http.Request) {
+	scenario := &Scenario{}
+	if err := json.NewDecoder(req.Body).Decode(scenario); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := scenario.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.JSON(w, http.StatusOK, RunScenario(req.Context(), s.network, scenario))
+}
+
 // CreateNode creates a node in the network using the given configuration
 func (s *Server) CreateNode(w http.ResponseWriter, req *http.Request) {
 	config := &adapters.NodeConfig{}
@@ -647,6 +711,111 @@ func (s *Server) DisconnectNode(w http.ResponseWriter, req *http.Request) {
 	s.JSON(w, http.StatusOK, node.NodeInfo())
 }
 
+// GetDefaultLink returns the conditions of the links which weren't configured
+// individually
+func (s *Server) GetDefaultLink(w http.ResponseWriter, req *http.Request) {
+	links, err := s.network.Links()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	s.JSON(w, http.StatusOK, links.DefaultLink())
+}
+
+// SetDefaultLink sets the conditions of the links which weren't configured
+// individually
+func (s *Server) SetDefaultLink(w http.ResponseWriter, req *http.Request) {
+	links, err := s.network.Links()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	config := adapters.LinkConfig{}
+	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	links.SetDefaultLink(config)
+
+	s.JSON(w, http.StatusOK, config)
+}
+
+// GetLink returns the conditions of the link between a node and a peer node
+func (s *Server) GetLink(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	links, err := s.network.Links()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	s.JSON(w, http.StatusOK, links.Link(node.ID(), peer.ID()))
+}
+
+// SetLink sets the conditions of the link between a node and a peer node
+func (s *Server) SetLink(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	links, err := s.network.Links()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	config := adapters.LinkConfig{}
+	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	links.SetLink(node.ID(), peer.ID(), config)
+
+	s.JSON(w, http.StatusOK, config)
+}
+
+// Partition splits the network into groups of nodes, given as lists of node
+// IDs or names, by taking down the links between nodes of different groups
+func (s *Server) Partition(w http.ResponseWriter, req *http.Request) {
+	links, err := s.network.Links()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	var names [][]string
+	if err := json.NewDecoder(req.Body).Decode(&names); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groups := make([][]discover.NodeID, len(names))
+	for i, group := range names {
+		for _, name := range group {
+			node := s.lookupNode(name)
+			if node == nil {
+				http.Error(w, fmt.Sprintf("unknown node %q", name), http.StatusBadRequest)
+				return
+			}
+			groups[i] = append(groups[i], node.ID())
+		}
+	}
+	links.Partition(groups)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Heal restores the links taken down by partitions
+func (s *Server) Heal(w http.ResponseWriter, req *http.Request) {
+	links, err := s.network.Links()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	links.Heal()
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // Options responds to the OPTIONS HTTP method by returning a 200 OK response
 // with the "Access-Control-Allow-Headers" header set to "Content-Type"
 func (s *Server) Options(w http.ResponseWriter, req *http.Request) {
@@ -709,12 +878,7 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		ctx := context.Background()
 
 		if id := params.ByName("nodeid"); id != "" {
-			var node *Node
-			if nodeID, err := discover.HexID(id); err == nil {
-				node = s.network.GetNode(nodeID)
-			} else {
-				node = s.network.GetNodeByName(id)
-			}
+			node := s.lookupNode(id)
 			if node == nil {
 				http.NotFound(w, req)
 				return
@@ -723,12 +887,7 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		}
 
 		if id := params.ByName("peerid"); id != "" {
-			var peer *Node
-			if peerID, err := discover.HexID(id); err == nil {
-				peer = s.network.GetNode(peerID)
-			} else {
-				peer = s.network.GetNodeByName(id)
-			}
+			peer := s.lookupNode(id)
 			if peer == nil {
 				http.NotFound(w, req)
 				return
@@ -739,3 +898,12 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		handler(w, req.WithContext(ctx))
 	}
 }
+
+// lookupNode returns the node with the given ID or name, or nil if the node
+// does not exist
+func (s *Server) lookupNode(id string) *Node {
+	if nodeID, err := discover.HexID(id); err == nil {
+		return s.network.GetNode(nodeID)
+	}
+	return s.network.GetNodeByName(id)
+}
@@ -18,6 +18,7 @@ package simulations
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http/httptest"
@@ -43,7 +44,7 @@ type testService struct {
 	// peerCount is incremented once a peer handshake has been performed
 	peerCount int64
 
-	peers    map[discover.NodeID]*testPeer
+	peers    map[*p2p.Peer]*testPeer
 	peersMtx sync.Mutex
 
 	// state stores []byte which is used to test creating and loading
@@ -54,7 +55,7 @@ type testService struct {
 func newTestService(ctx *adapters.ServiceContext) (node.Service, error) {
 	svc := &testService{
 		id:    ctx.Config.ID,
-		peers: make(map[discover.NodeID]*testPeer),
+		peers: make(map[*p2p.Peer]*testPeer),
 	}
 	svc.state.Store(ctx.Snapshot)
 	return svc, nil
@@ -63,22 +64,43 @@ func newTestService(ctx *adapters.ServiceContext) (node.Service, error) {
 type testPeer struct {
 	testReady chan struct{}
 	dumReady  chan struct{}
+
+	// closed is closed once the test protocol stops, so that other
+	// protocols don't wait for a handshake which will never happen
+	closed chan struct{}
+
+	// stopped is the number of protocols of the connection which stopped
+	stopped int
 }
 
-func (t *testService) peer(id discover.NodeID) *testPeer {
+// peer returns the state of a peer connection, which is tracked per
+// connection so that peers can disconnect and connect again
+func (t *testService) peer(p *p2p.Peer) *testPeer {
 	t.peersMtx.Lock()
 	defer t.peersMtx.Unlock()
-	if peer, ok := t.peers[id]; ok {
+	if peer, ok := t.peers[p]; ok {
 		return peer
 	}
 	peer := &testPeer{
 		testReady: make(chan struct{}),
 		dumReady:  make(chan struct{}),
+		closed:    make(chan struct{}),
 	}
-	t.peers[id] = peer
+	t.peers[p] = peer
 	return peer
 }
 
+// dropPeer forgets a peer connection once all of its protocols stopped
+func (t *testService) dropPeer(p *p2p.Peer) {
+	t.peersMtx.Lock()
+	defer t.peersMtx.Unlock()
+	if peer := t.peers[p]; peer != nil {
+		if peer.stopped++; peer.stopped == len(t.Protocols()) {
+			delete(t.peers, p)
+		}
+	}
+}
+
 func (t *testService) Protocols() []p2p.Protocol {
 	return []p2p.Protocol{
 		{
@@ -136,7 +158,9 @@ func (t *testService) handshake(rw p2p.MsgReadWriter, code uint64) error {
 }
 
 func (t *testService) RunTest(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-	peer := t.peer(p.ID())
+	peer := t.peer(p)
+	defer t.dropPeer(p)
+	defer close(peer.closed)
 
 	// perform three handshakes with three different message codes,
 	// used to test message sending and filtering
@@ -167,10 +191,15 @@ func (t *testService) RunTest(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 }
 
 func (t *testService) RunDum(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-	peer := t.peer(p.ID())
+	peer := t.peer(p)
+	defer t.dropPeer(p)
 
 	// wait for the test protocol to perform its handshake
-	<-peer.testReady
+	select {
+	case <-peer.testReady:
+	case <-peer.closed:
+		return errors.New("test protocol stopped")
+	}
 
 	// perform a handshake
 	if err := t.handshake(rw, 0); err != nil {
@@ -189,10 +218,15 @@ func (t *testService) RunDum(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 	}
 }
 func (t *testService) RunPrb(p *p2p.Peer, rw p2p.MsgReadWriter) error {
-	peer := t.peer(p.ID())
+	peer := t.peer(p)
+	defer t.dropPeer(p)
 
 	// wait for the dum protocol to perform its handshake
-	<-peer.dumReady
+	select {
+	case <-peer.dumReady:
+	case <-peer.closed:
+		return errors.New("test protocol stopped")
+	}
 
 	// perform a handshake
 	if err := t.handshake(rw, 0); err != nil {
@@ -398,6 +432,70 @@ func startTestNetwork(t *testing.T, client *Client) []string {
 	return nodeIDs
 }
 
+// TestHTTPLinks tests setting link conditions and partitioning the network
+// via the HTTP API
+func TestHTTPLinks(t *testing.T) {
+	// start the server
+	_, s := testHTTPServer(t)
+	defer s.Close()
+
+	// subscribe to events so we can check them later
+	client := NewClient(s.URL)
+	events := make(chan *Event, 100)
+	sub, err := client.SubscribeNetwork(events, SubscribeOpts{})
+	if err != nil {
+		t.Fatalf("error subscribing to network events: %s", err)
+	}
+	defer sub.Unsubscribe()
+
+	// start a simulation network
+	nodeIDs := startTestNetwork(t, client)
+	x := &expectEvents{t, events, sub}
+	x.expect(
+		x.nodeEvent(nodeIDs[0], false),
+		x.nodeEvent(nodeIDs[1], false),
+		x.nodeEvent(nodeIDs[0], true),
+		x.nodeEvent(nodeIDs[1], true),
+		x.connEvent(nodeIDs[0], nodeIDs[1], false),
+		x.connEvent(nodeIDs[0], nodeIDs[1], true),
+	)
+
+	// check the link conditions can be set and retrieved
+	config := &adapters.LinkConfig{Latency: 20 * time.Millisecond, Bandwidth: 1 << 20}
+	if err := client.SetLink(nodeIDs[0], nodeIDs[1], config); err != nil {
+		t.Fatalf("error setting link: %s", err)
+	}
+	link, err := client.GetLink(nodeIDs[1], nodeIDs[0])
+	if err != nil {
+		t.Fatalf("error getting link: %s", err)
+	}
+	if *link != *config {
+		t.Fatalf("expected link %+v, got %+v", config, link)
+	}
+	if err := client.SetDefaultLink(&adapters.LinkConfig{Loss: 0.1}); err != nil {
+		t.Fatalf("error setting default link: %s", err)
+	}
+	if link, err = client.GetDefaultLink(); err != nil || link.Loss != 0.1 {
+		t.Fatalf("expected default link loss 0.1, got %v (err %v)", link, err)
+	}
+
+	// check partitioning the nodes drops their connection until healed
+	if err := client.Partition([][]string{{nodeIDs[0]}, {nodeIDs[1]}}); err != nil {
+		t.Fatalf("error partitioning network: %s", err)
+	}
+	x.expect(x.connEvent(nodeIDs[0], nodeIDs[1], false))
+
+	if link, err = client.GetLink(nodeIDs[0], nodeIDs[1]); err != nil || !link.Down {
+		t.Fatalf("expected partitioned link to be down, got %v (err %v)", link, err)
+	}
+	if err := client.Heal(); err != nil {
+		t.Fatalf("error healing network: %s", err)
+	}
+	if link, err = client.GetLink(nodeIDs[0], nodeIDs[1]); err != nil || link.Down {
+		t.Fatalf("expected healed link to be up, got %v (err %v)", link, err)
+	}
+}
+
 type expectEvents struct {
 	*testing.T
 
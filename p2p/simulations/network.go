@@ -294,6 +294,17 @@ func (self *Network) Disconnect(oneID, otherID discover.NodeID) error {
 	return client.Call(nil, "admin_removePeer", string(conn.other.Addr()))
 }
 
+// Links returns the LinkController of the network's node adapter, which can be
+// used to simulate latency, bandwidth limits, loss and partitions on the links
+// between nodes
+func (self *Network) Links() (adapters.LinkController, error) {
+	links, ok := self.nodeAdapter.(adapters.LinkController)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support link conditions", self.nodeAdapter.Name())
+	}
+	return links, nil
+}
+
 // DidConnect tracks the fact that the "one" node connected to the "other" node
 func (self *Network) DidConnect(one, other discover.NodeID) error {
 	conn, err := self.GetOrCreateConn(one, other)
@@ -654,31 +665,108 @@ func (self *Network) Snapshot() (*Snapshot, error) {
 	return snap, nil
 }
 
-// Load loads a network snapshot
+// Load loads a network snapshot, starting the nodes which were up with the
+// state their services contributed to the snapshot, and connecting them
 func (self *Network) Load(snap *Snapshot) error {
+	_, err := self.load(snap)
+	return err
+}
+
+// LoadAndWait loads a network snapshot like Load, and then waits up to timeout
+// for the connections which were up in the snapshot to be established again
+func (self *Network) LoadAndWait(snap *Snapshot, timeout time.Duration) error {
+	// watch the connection events from before connecting, so none is missed
+	var (
+		events  = make(chan *Event)
+		changed = make(chan struct{}, 1)
+		sub     = self.events.Subscribe(events)
+	)
+	defer sub.Unsubscribe()
+	go func() {
+		for {
+			select {
+			case event := <-events:
+				if event.Type == EventTypeConn && !event.Control {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	pending, err := self.load(snap)
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		for label := range pending {
+			if conn := self.getConnByLabel(label); conn != nil && conn.Up {
+				delete(pending, label)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return fmt.Errorf("timed out waiting for %d snapshot connections", len(pending))
+		}
+	}
+}
+
+// load starts the nodes of a snapshot and connects them, returning the labels
+// of the connections which were up in the snapshot
+func (self *Network) load(snap *Snapshot) (map[string]bool, error) {
 	for _, n := range snap.Nodes {
 		if _, err := self.NewNodeWithConfig(n.Node.Config); err != nil {
-			return err
+			return nil, err
 		}
 		if !n.Node.Up {
 			continue
 		}
 		if err := self.startWithSnapshots(n.Node.Config.ID, n.Snapshots); err != nil {
-			return err
+			return nil, err
 		}
 	}
+	pending := make(map[string]bool)
 	for _, conn := range snap.Conns {
-
 		if !self.GetNode(conn.One).Up || !self.GetNode(conn.Other).Up {
 			//in this case, at least one of the nodes of a connection is not up,
 			//so it would result in the snapshot `Load` to fail
 			continue
 		}
+		if conn.Up {
+			pending[ConnLabel(conn.One, conn.Other)] = true
+		}
 		if err := self.Connect(conn.One, conn.Other); err != nil {
-			return err
+			// services restored from the snapshot may have initiated the
+			// connection already, in which case it only needs to come up
+			if existing := self.GetConn(conn.One, conn.Other); existing == nil {
+				return nil, err
+			}
 		}
 	}
-	return nil
+	return pending, nil
+}
+
+// getConnByLabel returns the connection with the given label, if it exists
+func (self *Network) getConnByLabel(label string) *Conn {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	i, found := self.connMap[label]
+	if !found {
+		return nil
+	}
+	return self.Conns[i]
 }
 
 // Subscribe reads control events from a channel and executes them
@@ -140,6 +140,88 @@ func TestNetworkSimulation(t *testing.T) {
 	}
 }
 
+// TestNetworkSnapshotLoad checks that loading a network snapshot restores the
+// state of the services, and that LoadAndWait waits for the connections which
+// were up to be established before returning
+func TestNetworkSnapshotLoad(t *testing.T) {
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"test": newTestService,
+	})
+	network := NewNetwork(adapter, &NetworkConfig{
+		DefaultService: "test",
+	})
+	defer network.Shutdown()
+
+	// create a chain of three nodes
+	nodeCount := 3
+	ids := make([]discover.NodeID, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		node, err := network.NewNodeWithConfig(adapters.RandomNodeConfig())
+		if err != nil {
+			t.Fatalf("error creating node: %s", err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatalf("error starting node: %s", err)
+		}
+		ids[i] = node.ID()
+	}
+	for i := 1; i < nodeCount; i++ {
+		if err := network.Connect(ids[i-1], ids[i]); err != nil {
+			t.Fatalf("error connecting nodes: %s", err)
+		}
+	}
+	waitConn := func(network *Network, one, other discover.NodeID) {
+		for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+			if conn := network.GetConn(one, other); conn != nil && conn.Up {
+				return
+			}
+		}
+		t.Fatal("timed out waiting for connection to be up")
+	}
+	waitConn(network, ids[0], ids[1])
+	waitConn(network, ids[1], ids[2])
+
+	// set the state of a service, which it contributes to the snapshot
+	client, err := network.GetNode(ids[0]).Client()
+	if err != nil {
+		t.Fatalf("error getting node client: %s", err)
+	}
+	if err := client.Call(nil, "test_setState", []byte("warm")); err != nil {
+		t.Fatalf("error setting service state: %s", err)
+	}
+	snap, err := network.Snapshot()
+	if err != nil {
+		t.Fatalf("error creating snapshot: %s", err)
+	}
+
+	// load the snapshot into a new network and check it is restored
+	loaded := NewNetwork(adapters.NewSimAdapter(adapters.Services{
+		"test": newTestService,
+	}), &NetworkConfig{
+		DefaultService: "test",
+	})
+	defer loaded.Shutdown()
+
+	if err := loaded.LoadAndWait(snap, 10*time.Second); err != nil {
+		t.Fatalf("error loading snapshot: %s", err)
+	}
+	for i := 1; i < nodeCount; i++ {
+		if conn := loaded.GetConn(ids[i-1], ids[i]); conn == nil || !conn.Up {
+			t.Fatalf("expected connection to be up after loading, got %v", conn)
+		}
+	}
+	if client, err = loaded.GetNode(ids[0]).Client(); err != nil {
+		t.Fatalf("error getting node client: %s", err)
+	}
+	var state []byte
+	if err := client.Call(&state, "test_getState"); err != nil {
+		t.Fatalf("error getting service state: %s", err)
+	}
+	if string(state) != "warm" {
+		t.Fatalf("expected service state %q, got %q", "warm", state)
+	}
+}
+
 func triggerChecks(ctx context.Context, ids []discover.NodeID, trigger chan discover.NodeID, interval time.Duration) {
 	tick := time.NewTicker(interval)
 	defer tick.Stop()
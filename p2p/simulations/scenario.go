@@ -0,0 +1,774 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// scenarioCheckInterval is the interval at which unmet node expectations of a
+// scenario step are checked again.
+const scenarioCheckInterval = 100 * time.Millisecond
+
+// Duration is a time.Duration which is encoded in JSON as a string such as
+// "1m30s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Scenario is a declarative description of a simulation run: a sequence of
+// steps, each performing an action on the network and then optionally waiting
+// for an expectation about its outcome to be met.
+//
+// Nodes are referred to by name. Actions without explicit nodes apply to all the
+// nodes in the network, expectations to all the nodes which are up.
+type Scenario struct {
+	Name  string          `json:"name,omitempty"`
+	Steps []*ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a single step of a scenario. The fields used depend on the
+// action, which is one of:
+//
+//   - "create":     create and start Count nodes running Services
+//   - "start":      start Nodes
+//   - "stop":       stop Nodes
+//   - "connect":    connect Nodes in the given Topology ("chain", "ring", "star" or "full")
+//   - "disconnect": drop all the connections between Nodes
+//   - "partition":  drop all the connections between nodes of different Groups
+//   - "heal":       restore the links and connections dropped by the last partition
+//   - "link":       set the Link conditions between all Nodes, or the default ones
+//   - "churn":      stop Count random nodes out of Nodes, restarting them after Duration
+//   - "mocker":     run the Mocker with Count nodes for Duration
+//   - "rpc":        call Method with Params on Nodes, e.g. to inject messages
+//   - "wait":       sleep for Duration
+//
+// A step without an action only waits for its expectation. If the node adapter
+// supports link conditions, partitions take down the links between the groups
+// so that the nodes can't reconnect until healed.
+type ScenarioStep struct {
+	Name   string   `json:"name,omitempty"`
+	Action string   `json:"action,omitempty"`
+	Nodes  []string `json:"nodes,omitempty"`
+
+	Count    int           `json:"count,omitempty"`
+	Services []string      `json:"services,omitempty"`
+	Topology string        `json:"topology,omitempty"`
+	Groups   [][]string    `json:"groups,omitempty"`
+	Mocker   string        `json:"mocker,omitempty"`
+	Duration Duration      `json:"duration,omitempty"`
+	Method   string        `json:"method,omitempty"`
+	Params   []interface{} `json:"params,omitempty"`
+
+	Link *adapters.LinkConfig `json:"link,omitempty"`
+
+	Expect *ScenarioExpect `json:"expect,omitempty"`
+}
+
+// ScenarioExpect is an expectation about the outcome of a scenario step, which
+// must be met by all the selected nodes within the timeout. The fields used
+// depend on the type, which is one of:
+//
+//   - "up":       the nodes are up
+//   - "down":     the nodes are down
+//   - "peers":    the nodes have at least Peers connections
+//   - "received": the nodes received a Protocol message (with Code, if given)
+//   - "rpc":      calling Method with Params on the nodes returns Result
+//
+// or the name of a check registered with RegisterCheck.
+type ScenarioExpect struct {
+	Type    string   `json:"type"`
+	Nodes   []string `json:"nodes,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
+
+	Peers    int             `json:"peers,omitempty"`
+	Protocol string          `json:"protocol,omitempty"`
+	Code     *uint64         `json:"code,omitempty"`
+	Method   string          `json:"method,omitempty"`
+	Params   []interface{}   `json:"params,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+}
+
+// NodeCheck checks whether a node meets an expectation.
+type NodeCheck func(ctx context.Context, id discover.NodeID) (bool, error)
+
+// CheckFactory creates a node check for an expectation, given the state of the
+// network when the check starts.
+type CheckFactory func(net *Network, expect *ScenarioExpect) (NodeCheck, error)
+
+var (
+	checksLock sync.RWMutex
+	checks     = make(map[string]CheckFactory)
+)
+
+// RegisterCheck makes a service specific node check available to scenarios as an
+// expectation type, e.g. to check the health of an overlay network.
+func RegisterCheck(name string, factory CheckFactory) {
+	checksLock.Lock()
+	defer checksLock.Unlock()
+
+	checks[name] = factory
+}
+
+// lookupCheck returns the check factory registered with the given name.
+func lookupCheck(name string) CheckFactory {
+	checksLock.RLock()
+	defer checksLock.RUnlock()
+
+	return checks[name]
+}
+
+// ScenarioResult is the outcome of running a scenario.
+type ScenarioResult struct {
+	Name  string                `json:"name,omitempty"`
+	Steps []*ScenarioStepResult `json:"steps"`
+	Error string                `json:"error,omitempty"` // Error of the first failed step
+}
+
+// Failed returns whether the scenario failed.
+func (r *ScenarioResult) Failed() bool {
+	return r.Error != ""
+}
+
+// ScenarioStepResult is the outcome of a single scenario step.
+type ScenarioStepResult struct {
+	Name     string   `json:"name"`
+	Duration Duration `json:"duration"`
+	Passed   []string `json:"passed,omitempty"` // Nodes which met the expectation
+	Failed   []string `json:"failed,omitempty"` // Nodes which didn't meet the expectation
+	Error    string   `json:"error,omitempty"`
+}
+
+// Validate checks that all the actions and expectations of the scenario are known
+// and have the parameters they need.
+func (s *Scenario) Validate() error {
+	for i, step := range s.Steps {
+		if err := step.validate(); err != nil {
+			return fmt.Errorf("step %d (%s): %v", i, step.name(i), err)
+		}
+	}
+	return nil
+}
+
+// name returns the name of the step, defaulting to its action and expectation.
+func (s *ScenarioStep) name(index int) string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Action != "" && s.Expect != nil:
+		return fmt.Sprintf("#%d %s, expect %s", index, s.Action, s.Expect.Type)
+	case s.Action != "":
+		return fmt.Sprintf("#%d %s", index, s.Action)
+	case s.Expect != nil:
+		return fmt.Sprintf("#%d expect %s", index, s.Expect.Type)
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+func (s *ScenarioStep) validate() error {
+	switch s.Action {
+	case "", "start", "stop", "disconnect", "heal", "wait":
+	case "create":
+		if s.Count <= 0 {
+			return errors.New("no node count")
+		}
+	case "connect":
+		switch s.Topology {
+		case "", "chain", "ring", "star", "full":
+		default:
+			return fmt.Errorf("unknown topology %q", s.Topology)
+		}
+	case "partition":
+		if len(s.Groups) < 2 {
+			return errors.New("less than two partition groups")
+		}
+	case "churn":
+		if s.Count <= 0 {
+			return errors.New("no node count")
+		}
+	case "mocker":
+		if LookupMocker(s.Mocker) == nil {
+			return fmt.Errorf("unknown mocker %q", s.Mocker)
+		}
+		if s.Duration <= 0 {
+			return errors.New("no mocker duration")
+		}
+	case "rpc":
+		if s.Method == "" {
+			return errors.New("no rpc method")
+		}
+	case "link":
+		if s.Link == nil {
+			return errors.New("no link conditions")
+		}
+		if err := s.Link.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown action %q", s.Action)
+	}
+	if s.Expect == nil {
+		return nil
+	}
+	switch s.Expect.Type {
+	case "up", "down", "peers", "received":
+	case "rpc":
+		if s.Expect.Method == "" || len(s.Expect.Result) == 0 {
+			return errors.New("no rpc method or result to expect")
+		}
+	default:
+		if lookupCheck(s.Expect.Type) == nil {
+			return fmt.Errorf("unknown expectation %q", s.Expect.Type)
+		}
+	}
+	if s.Expect.Timeout <= 0 {
+		return errors.New("no expectation timeout")
+	}
+	return nil
+}
+
+// scenarioRunner executes the steps of a scenario in a network.
+type scenarioRunner struct {
+	net *Network
+	sim *Simulation
+
+	partitioned []*Conn // Connections dropped by the last partition
+}
+
+// RunScenario runs a scenario in the given network, stopping at the first step
+// which fails.
+func RunScenario(ctx context.Context, net *Network, scenario *Scenario) *ScenarioResult {
+	result := &ScenarioResult{Name: scenario.Name}
+	if err := scenario.Validate(); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	runner := &scenarioRunner{net: net, sim: NewSimulation(net)}
+	for i, step := range scenario.Steps {
+		res := runner.run(ctx, step)
+		res.Name = step.name(i)
+		result.Steps = append(result.Steps, res)
+
+		if res.Error != "" {
+			log.Warn("Scenario step failed", "scenario", scenario.Name, "step", res.Name, "err", res.Error)
+			result.Error = fmt.Sprintf("step %s: %s", res.Name, res.Error)
+			break
+		}
+		log.Info("Scenario step passed", "scenario", scenario.Name, "step", res.Name, "elapsed", time.Duration(res.Duration))
+	}
+	return result
+}
+
+// run executes a single step and waits for its expectation.
+func (r *scenarioRunner) run(ctx context.Context, step *ScenarioStep) *ScenarioStepResult {
+	var (
+		result = new(ScenarioStepResult)
+		start  = time.Now()
+	)
+	defer func() { result.Duration = Duration(time.Since(start)) }()
+
+	// Set up the expectation before the action, so no event is missed
+	var (
+		check NodeCheck
+		err   error
+	)
+	if step.Expect != nil {
+		var stop func()
+		if check, stop, err = r.check(step.Expect); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		defer stop()
+	}
+	if err := r.act(ctx, step); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if step.Expect == nil {
+		return result
+	}
+	// Wait for all the selected nodes to meet the expectation
+	var ids []discover.NodeID
+	if len(step.Expect.Nodes) > 0 {
+		if ids, err = r.lookup(step.Expect.Nodes); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	} else {
+		ids = r.upNodes()
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(step.Expect.Timeout))
+	defer cancel()
+
+	trigger := make(chan discover.NodeID)
+	go func() {
+		ticker := time.NewTicker(scenarioCheckInterval)
+		defer ticker.Stop()
+		for {
+			for _, id := range ids {
+				select {
+				case trigger <- id:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	res := r.sim.Run(ctx, &Step{
+		Action:  func(context.Context) error { return nil },
+		Trigger: trigger,
+		Expect:  &Expectation{Nodes: ids, Check: check},
+	})
+	for _, id := range ids {
+		if _, ok := res.Passes[id]; ok {
+			result.Passed = append(result.Passed, r.nodeName(id))
+		} else {
+			result.Failed = append(result.Failed, r.nodeName(id))
+		}
+	}
+	if res.Error != nil {
+		if res.Error == context.DeadlineExceeded {
+			result.Error = fmt.Sprintf("expectation %q not met by %d of %d nodes within %v", step.Expect.Type, len(result.Failed), len(ids), time.Duration(step.Expect.Timeout))
+		} else {
+			result.Error = res.Error.Error()
+		}
+	}
+	return result
+}
+
+// act performs the action of a step.
+func (r *scenarioRunner) act(ctx context.Context, step *ScenarioStep) error {
+	switch step.Action {
+	case "":
+		return nil
+
+	case "create":
+		r.net.lock.RLock()
+		offset := len(r.net.Nodes)
+		r.net.lock.RUnlock()
+
+		for i := 0; i < step.Count; i++ {
+			conf := adapters.RandomNodeConfig()
+			conf.Name = fmt.Sprintf("node%02d", offset+i+1)
+			conf.Services = step.Services
+
+			node, err := r.net.NewNodeWithConfig(conf)
+			if err != nil {
+				return err
+			}
+			if err := r.net.Start(node.ID()); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "start", "stop":
+		ids, err := r.selectNodes(step.Nodes)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if node := r.net.GetNode(id); node.Up == (step.Action == "start") {
+				continue
+			}
+			if step.Action == "start" {
+				err = r.net.Start(id)
+			} else {
+				err = r.net.Stop(id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "connect":
+		ids, err := r.selectNodes(step.Nodes)
+		if err != nil {
+			return err
+		}
+		for _, pair := range topologyPairs(ids, step.Topology) {
+			if conn := r.net.GetConn(pair[0], pair[1]); conn != nil && conn.Up {
+				continue
+			}
+			if err := r.net.Connect(pair[0], pair[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "disconnect":
+		ids, err := r.selectNodes(step.Nodes)
+		if err != nil {
+			return err
+		}
+		_, err = r.disconnect(ids, ids)
+		return err
+
+	case "partition":
+		r.partitioned = nil
+		groups := make([][]discover.NodeID, len(step.Groups))
+		for i, names := range step.Groups {
+			ids, err := r.lookup(names)
+			if err != nil {
+				return err
+			}
+			groups[i] = ids
+		}
+		for i := range groups {
+			for j := i + 1; j < len(groups); j++ {
+				dropped, err := r.disconnect(groups[i], groups[j])
+				r.partitioned = append(r.partitioned, dropped...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		// Take down the links across the partition if the adapter supports
+		// it, so that the nodes can't reconnect by themselves
+		if links, err := r.net.Links(); err == nil {
+			links.Partition(groups)
+		}
+		return nil
+
+	case "heal":
+		if links, err := r.net.Links(); err == nil {
+			links.Heal()
+		}
+		for _, conn := range r.partitioned {
+			if c := r.net.GetConn(conn.One, conn.Other); c != nil && c.Up {
+				continue
+			}
+			if err := r.net.Connect(conn.One, conn.Other); err != nil {
+				return err
+			}
+		}
+		r.partitioned = nil
+		return nil
+
+	case "churn":
+		ids, err := r.selectNodes(step.Nodes)
+		if err != nil {
+			return err
+		}
+		var up []discover.NodeID
+		for _, id := range ids {
+			if r.net.GetNode(id).Up {
+				up = append(up, id)
+			}
+		}
+		if len(up) < step.Count {
+			return fmt.Errorf("only %d nodes up to churn", len(up))
+		}
+		var stopped []discover.NodeID
+		for _, i := range rand.Perm(len(up))[:step.Count] {
+			if err := r.net.Stop(up[i]); err != nil {
+				return err
+			}
+			stopped = append(stopped, up[i])
+		}
+		if err := sleep(ctx, time.Duration(step.Duration)); err != nil {
+			return err
+		}
+		for _, id := range stopped {
+			if err := r.net.Start(id); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "mocker":
+		quit := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			LookupMocker(step.Mocker)(r.net, quit, step.Count)
+		}()
+		err := sleep(ctx, time.Duration(step.Duration))
+		close(quit)
+		<-done
+		return err
+
+	case "rpc":
+		ids, err := r.selectNodes(step.Nodes)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			client, err := r.net.GetNode(id).Client()
+			if err != nil {
+				return fmt.Errorf("node %s: %v", r.nodeName(id), err)
+			}
+			if err := client.CallContext(ctx, nil, step.Method, step.Params...); err != nil {
+				return fmt.Errorf("node %s: %v", r.nodeName(id), err)
+			}
+		}
+		return nil
+
+	case "link":
+		links, err := r.net.Links()
+		if err != nil {
+			return err
+		}
+		if len(step.Nodes) == 0 {
+			links.SetDefaultLink(*step.Link)
+			return nil
+		}
+		ids, err := r.lookup(step.Nodes)
+		if err != nil {
+			return err
+		}
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				links.SetLink(ids[i], ids[j], *step.Link)
+			}
+		}
+		return nil
+
+	case "wait":
+		return sleep(ctx, time.Duration(step.Duration))
+	}
+	return fmt.Errorf("unknown action %q", step.Action)
+}
+
+// check creates the node check of an expectation. The returned function releases
+// the resources of the check.
+func (r *scenarioRunner) check(expect *ScenarioExpect) (NodeCheck, func(), error) {
+	noop := func() {}
+
+	switch expect.Type {
+	case "up", "down":
+		up := expect.Type == "up"
+		return func(ctx context.Context, id discover.NodeID) (bool, error) {
+			return r.net.GetNode(id).Up == up, nil
+		}, noop, nil
+
+	case "peers":
+		return func(ctx context.Context, id discover.NodeID) (bool, error) {
+			return r.peerCount(id) >= expect.Peers, nil
+		}, noop, nil
+
+	case "received":
+		var (
+			lock     sync.Mutex
+			received = make(map[discover.NodeID]bool)
+			events   = make(chan *Event, 1024)
+			sub      = r.net.Events().Subscribe(events)
+		)
+		go func() {
+			for {
+				select {
+				case event := <-events:
+					msg := event.Msg
+					if msg == nil || !msg.Received || msg.Protocol != expect.Protocol || (expect.Code != nil && msg.Code != *expect.Code) {
+						continue
+					}
+					lock.Lock()
+					received[msg.Other] = true
+					lock.Unlock()
+				case <-sub.Err():
+					return
+				}
+			}
+		}()
+		return func(ctx context.Context, id discover.NodeID) (bool, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return received[id], nil
+		}, sub.Unsubscribe, nil
+
+	case "rpc":
+		var want interface{}
+		if err := json.Unmarshal(expect.Result, &want); err != nil {
+			return nil, nil, fmt.Errorf("invalid expected rpc result: %v", err)
+		}
+		return func(ctx context.Context, id discover.NodeID) (bool, error) {
+			client, err := r.net.GetNode(id).Client()
+			if err != nil {
+				return false, nil // node is down, check again later
+			}
+			var have interface{}
+			if err := client.CallContext(ctx, &have, expect.Method, expect.Params...); err != nil {
+				return false, nil
+			}
+			return reflect.DeepEqual(have, want), nil
+		}, noop, nil
+	}
+	factory := lookupCheck(expect.Type)
+	if factory == nil {
+		return nil, nil, fmt.Errorf("unknown expectation %q", expect.Type)
+	}
+	check, err := factory(r.net, expect)
+	return check, noop, err
+}
+
+// lookup resolves node names into node ids.
+func (r *scenarioRunner) lookup(names []string) ([]discover.NodeID, error) {
+	ids := make([]discover.NodeID, len(names))
+	for i, name := range names {
+		node := r.net.GetNodeByName(name)
+		if node == nil {
+			return nil, fmt.Errorf("unknown node %q", name)
+		}
+		ids[i] = node.ID()
+	}
+	return ids, nil
+}
+
+// selectNodes resolves node names into node ids, defaulting to all the nodes in
+// the network.
+func (r *scenarioRunner) selectNodes(names []string) ([]discover.NodeID, error) {
+	if len(names) > 0 {
+		return r.lookup(names)
+	}
+	var ids []discover.NodeID
+	for _, node := range r.net.GetNodes() {
+		ids = append(ids, node.ID())
+	}
+	return ids, nil
+}
+
+// upNodes returns the ids of the nodes which are up.
+func (r *scenarioRunner) upNodes() []discover.NodeID {
+	var ids []discover.NodeID
+	for _, node := range r.net.GetNodes() {
+		if node.Up {
+			ids = append(ids, node.ID())
+		}
+	}
+	return ids
+}
+
+// nodeName returns the name of a node.
+func (r *scenarioRunner) nodeName(id discover.NodeID) string {
+	if node := r.net.GetNode(id); node != nil {
+		return node.Config.Name
+	}
+	return id.TerminalString()
+}
+
+// peerCount returns the number of live connections of a node.
+func (r *scenarioRunner) peerCount(id discover.NodeID) int {
+	r.net.lock.RLock()
+	defer r.net.lock.RUnlock()
+
+	count := 0
+	for _, conn := range r.net.Conns {
+		if conn.Up && (conn.One == id || conn.Other == id) {
+			count++
+		}
+	}
+	return count
+}
+
+// disconnect drops all the live connections between nodes of the two sets,
+// returning the dropped ones.
+func (r *scenarioRunner) disconnect(one, other []discover.NodeID) ([]*Conn, error) {
+	var (
+		dropped []*Conn
+		seen    = make(map[*Conn]bool)
+	)
+	for _, a := range one {
+		for _, b := range other {
+			conn := r.net.GetConn(a, b)
+			if a == b || conn == nil || !conn.Up || seen[conn] {
+				continue
+			}
+			seen[conn] = true
+			if err := r.net.Disconnect(conn.One, conn.Other); err != nil {
+				return dropped, err
+			}
+			dropped = append(dropped, &Conn{One: conn.One, Other: conn.Other})
+		}
+	}
+	return dropped, nil
+}
+
+// topologyPairs returns the node pairs to connect to form a topology.
+func topologyPairs(ids []discover.NodeID, topology string) [][2]discover.NodeID {
+	var pairs [][2]discover.NodeID
+	switch topology {
+	case "", "chain":
+		for i := 1; i < len(ids); i++ {
+			pairs = append(pairs, [2]discover.NodeID{ids[i-1], ids[i]})
+		}
+	case "ring":
+		for i := 1; i < len(ids); i++ {
+			pairs = append(pairs, [2]discover.NodeID{ids[i-1], ids[i]})
+		}
+		if len(ids) > 2 {
+			pairs = append(pairs, [2]discover.NodeID{ids[len(ids)-1], ids[0]})
+		}
+	case "star":
+		for i := 1; i < len(ids); i++ {
+			pairs = append(pairs, [2]discover.NodeID{ids[0], ids[i]})
+		}
+	case "full":
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				pairs = append(pairs, [2]discover.NodeID{ids[i], ids[j]})
+			}
+		}
+	}
+	return pairs
+}
+
+// sleep waits for the given duration, or until the context is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
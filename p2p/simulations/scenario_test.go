@@ -0,0 +1,160 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+const testScenario = `{
+	"name": "ring",
+	"steps": [
+		{"action": "link", "link": {"latency": "5ms", "bandwidth": 1048576}},
+		{"action": "create", "count": 4, "expect": {"type": "up", "timeout": "5s"}},
+		{"action": "connect", "topology": "ring", "expect": {"type": "received", "protocol": "test", "code": 0, "timeout": "10s"}},
+		{"expect": {"type": "peers", "peers": 2, "timeout": "10s"}},
+		{"action": "rpc", "nodes": ["node01"], "method": "test_add", "params": [3],
+		 "expect": {"type": "rpc", "nodes": ["node01"], "method": "test_get", "result": 3, "timeout": "5s"}},
+		{"name": "split", "action": "partition", "groups": [["node01", "node02"], ["node03", "node04"]],
+		 "expect": {"type": "peers-in-group", "timeout": "10s"}},
+		{"action": "wait", "duration": "300ms"},
+		{"action": "heal", "expect": {"type": "peers", "peers": 2, "timeout": "10s"}},
+		{"action": "stop", "nodes": ["node04"], "expect": {"type": "down", "nodes": ["node04"], "timeout": "5s"}},
+		{"action": "churn", "nodes": ["node01", "node02", "node03"], "count": 1, "duration": "100ms",
+		 "expect": {"type": "up", "nodes": ["node01", "node02", "node03"], "timeout": "5s"}}
+	]
+}`
+
+// Tests that a scenario is decoded from JSON and run through the HTTP API, with
+// all its expectations met.
+func TestScenario(t *testing.T) {
+	network, s := testHTTPServer(t)
+	defer s.Close()
+	defer network.Shutdown()
+
+	// Register a check which is met once a node is only connected to the nodes
+	// of its own partition group
+	RegisterCheck("peers-in-group", func(net *Network, expect *ScenarioExpect) (NodeCheck, error) {
+		group := func(id discover.NodeID) bool {
+			name := net.GetNode(id).Config.Name
+			return name == "node01" || name == "node02"
+		}
+		return func(ctx context.Context, id discover.NodeID) (bool, error) {
+			var peers []discover.NodeID
+			net.lock.RLock()
+			for _, conn := range net.Conns {
+				if conn.Up && conn.One == id {
+					peers = append(peers, conn.Other)
+				} else if conn.Up && conn.Other == id {
+					peers = append(peers, conn.One)
+				}
+			}
+			net.lock.RUnlock()
+
+			for _, peer := range peers {
+				if group(peer) != group(id) {
+					return false, nil
+				}
+			}
+			return true, nil
+		}, nil
+	})
+
+	scenario := new(Scenario)
+	if err := json.Unmarshal([]byte(testScenario), scenario); err != nil {
+		t.Fatalf("failed to decode scenario: %v", err)
+	}
+	result, err := NewClient(s.URL).RunScenario(scenario)
+	if err != nil {
+		t.Fatalf("failed to run scenario: %v", err)
+	}
+	if result.Failed() {
+		t.Fatalf("scenario failed: %s", result.Error)
+	}
+	if len(result.Steps) != len(scenario.Steps) {
+		t.Fatalf("step result count mismatch: have %d, want %d", len(result.Steps), len(scenario.Steps))
+	}
+	if have := result.Steps[5].Name; have != "split" {
+		t.Errorf("step name mismatch: have %q, want %q", have, "split")
+	}
+	if have := len(result.Steps[3].Passed); have != 4 {
+		t.Errorf("passed node count mismatch: have %d, want 4", have)
+	}
+}
+
+// Tests that a scenario stops at the first unmet expectation, reporting the nodes
+// which failed it.
+func TestScenarioFailure(t *testing.T) {
+	network, s := testHTTPServer(t)
+	defer s.Close()
+	defer network.Shutdown()
+
+	scenario := &Scenario{
+		Steps: []*ScenarioStep{
+			{Action: "create", Count: 3},
+			{Action: "connect", Topology: "chain", Expect: &ScenarioExpect{Type: "peers", Peers: 2, Timeout: Duration(2e9)}},
+			{Action: "stop"},
+		},
+	}
+	result := RunScenario(context.Background(), network, scenario)
+	if !result.Failed() {
+		t.Fatal("scenario didn't fail")
+	}
+	if len(result.Steps) != 2 {
+		t.Fatalf("step result count mismatch: have %d, want 2", len(result.Steps))
+	}
+	step := result.Steps[1]
+	if len(step.Passed) != 1 || step.Passed[0] != "node02" {
+		t.Errorf("passed nodes mismatch: have %v, want [node02]", step.Passed)
+	}
+	if len(step.Failed) != 2 {
+		t.Errorf("failed nodes mismatch: have %v, want 2 nodes", step.Failed)
+	}
+	for _, node := range network.GetNodes() {
+		if !node.Up {
+			t.Errorf("node %s stopped after failed step", node.Config.Name)
+		}
+	}
+}
+
+// Tests that invalid scenarios are rejected before being run.
+func TestScenarioValidation(t *testing.T) {
+	tests := []struct {
+		step *ScenarioStep
+		err  string
+	}{
+		{&ScenarioStep{Action: "explode"}, `unknown action "explode"`},
+		{&ScenarioStep{Action: "create"}, "no node count"},
+		{&ScenarioStep{Action: "connect", Topology: "mesh"}, `unknown topology "mesh"`},
+		{&ScenarioStep{Action: "partition", Groups: [][]string{{"node01"}}}, "less than two partition groups"},
+		{&ScenarioStep{Action: "mocker", Mocker: "probabilistic"}, "no mocker duration"},
+		{&ScenarioStep{Action: "link"}, "no link conditions"},
+		{&ScenarioStep{Expect: &ScenarioExpect{Type: "happy", Timeout: Duration(1)}}, `unknown expectation "happy"`},
+		{&ScenarioStep{Expect: &ScenarioExpect{Type: "up"}}, "no expectation timeout"},
+	}
+	for i, test := range tests {
+		err := (&Scenario{Steps: []*ScenarioStep{test.step}}).Validate()
+		if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, test.err)
+		}
+	}
+}
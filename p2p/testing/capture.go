@@ -0,0 +1,50 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package testing
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// CaptureExchanges converts the records of a message capture into exchanges to
+// replay against the pivot node, with the dummy peer taking the role of the
+// captured remote peer. Every message received from the remote peer becomes a
+// trigger, and the messages sent to it until the next trigger are expected from
+// the pivot node. Only the records of the given protocol are replayed.
+func CaptureExchanges(records []*p2p.CaptureRecord, protocol string, peer discover.NodeID) []Exchange {
+	var exchanges []Exchange
+	for _, record := range records {
+		if record.Protocol != protocol {
+			continue
+		}
+		msg := rlp.RawValue(record.Payload)
+		if record.Received || len(exchanges) == 0 {
+			exchanges = append(exchanges, Exchange{Label: fmt.Sprintf("exchange #%d", len(exchanges))})
+		}
+		exchange := &exchanges[len(exchanges)-1]
+		if record.Received {
+			exchange.Triggers = append(exchange.Triggers, Trigger{Msg: msg, Code: record.Code, Peer: peer})
+		} else {
+			exchange.Expects = append(exchange.Expects, Expect{Msg: msg, Code: record.Code, Peer: peer})
+		}
+	}
+	return exchanges
+}
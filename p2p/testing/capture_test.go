@@ -0,0 +1,82 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package testing
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that captured messages are grouped into exchanges, with every message
+// received from the remote peer starting a new one.
+func TestCaptureExchanges(t *testing.T) {
+	var (
+		peer    = discover.NodeID{1}
+		records = []*p2p.CaptureRecord{
+			{Received: false, Protocol: "test", Code: 0, Payload: []byte{0x80}}, // status sent before any trigger
+			{Received: true, Protocol: "test", Code: 0, Payload: []byte{0x81}},  // status reply
+			{Received: true, Protocol: "other", Code: 5, Payload: []byte{0x82}}, // different protocol, skipped
+			{Received: true, Protocol: "test", Code: 1, Payload: []byte{0x83}},  // request
+			{Received: false, Protocol: "test", Code: 2, Payload: []byte{0x84}}, // response
+			{Received: false, Protocol: "test", Code: 3, Payload: []byte{0x85}}, // announcement
+		}
+	)
+	exchanges := CaptureExchanges(records, "test", peer)
+
+	type msg struct {
+		code    uint64
+		payload []byte
+	}
+	want := []struct {
+		triggers []msg
+		expects  []msg
+	}{
+		{nil, []msg{{0, []byte{0x80}}}},
+		{[]msg{{0, []byte{0x81}}}, nil},
+		{[]msg{{1, []byte{0x83}}}, []msg{{2, []byte{0x84}}, {3, []byte{0x85}}}},
+	}
+	if len(exchanges) != len(want) {
+		t.Fatalf("exchange count mismatch: have %d, want %d", len(exchanges), len(want))
+	}
+	check := func(i int, kind string, code uint64, payload interface{}, target discover.NodeID, want msg) {
+		if code != want.code {
+			t.Errorf("exchange %d: %s code mismatch: have %d, want %d", i, kind, code, want.code)
+		}
+		if raw, ok := payload.(rlp.RawValue); !ok || !bytes.Equal(raw, want.payload) {
+			t.Errorf("exchange %d: %s payload mismatch: have %v, want %x", i, kind, payload, want.payload)
+		}
+		if target != peer {
+			t.Errorf("exchange %d: %s peer mismatch: have %x, want %x", i, kind, target, peer)
+		}
+	}
+	for i, exchange := range exchanges {
+		if len(exchange.Triggers) != len(want[i].triggers) || len(exchange.Expects) != len(want[i].expects) {
+			t.Errorf("exchange %d: message count mismatch: have %d/%d, want %d/%d", i, len(exchange.Triggers), len(exchange.Expects), len(want[i].triggers), len(want[i].expects))
+			continue
+		}
+		for j, trigger := range exchange.Triggers {
+			check(i, "trigger", trigger.Code, trigger.Msg, trigger.Peer, want[i].triggers[j])
+		}
+		for j, expect := range exchange.Expects {
+			check(i, "expect", expect.Code, expect.Msg, expect.Peer, want[i].expects[j])
+		}
+	}
+}
@@ -251,6 +251,33 @@ func TestClientSubscribe(t *testing.T) {
 	}
 }
 
+func TestClientSubscribeFail(t *testing.T) {
+	server := newTestServer("eth", new(NotificationTestService))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	count := 3
+	sub, err := client.EthSubscribe(context.Background(), nc, "failingSubscription", count)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	for i := 0; i < count; i++ {
+		if val := <-nc; val != i {
+			t.Fatalf("value mismatch: got %d, want %d", val, i)
+		}
+	}
+	select {
+	case err := <-sub.Err():
+		if err == nil || err.Error() != "subscription failed" {
+			t.Fatalf("subscription error mismatch: got %v, want %q", err, "subscription failed")
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("subscription not failed within 1s")
+	}
+}
+
 func TestClientSubscribeCustomNamespace(t *testing.T) {
 	namespace := "custom"
 	server := newTestServer(namespace, new(NotificationTestService))
@@ -38,9 +38,11 @@ type Subscription struct {
 	ID        ID
 	namespace string
 	err       chan error // closed on unsubscribe
+	failure   Error      // error to send on activation if failed before
 }
 
-// Err returns a channel that is closed when the client send an unsubscribe request.
+// Err returns a channel that is closed when the client send an unsubscribe request
+// or when the subscription was failed by the server.
 func (s *Subscription) Err() <-chan error {
 	return s.err
 }
@@ -102,6 +104,38 @@ func (n *Notifier) Notify(id ID, data interface{}) error {
 	return nil
 }
 
+// Fail terminates a subscription, sending the error to the client as its last
+// notification so that it can resubscribe. If the subscription is not yet active,
+// the error is sent right after the subscription ID.
+func (n *Notifier) Fail(id ID, err error) error {
+	rpcErr, ok := err.(Error)
+	if !ok {
+		rpcErr = &callbackError{err.Error()}
+	}
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+
+	if sub, found := n.inactive[id]; found {
+		if sub.failure == nil {
+			sub.failure = rpcErr
+			close(sub.err)
+		}
+		return nil
+	}
+	sub, found := n.active[id]
+	if !found {
+		return ErrSubscriptionNotFound
+	}
+	close(sub.err)
+	delete(n.active, id)
+
+	if err := n.codec.Write(n.codec.CreateErrorNotification(string(id), sub.namespace, rpcErr)); err != nil {
+		n.codec.Close()
+		return err
+	}
+	return nil
+}
+
 // Closed returns a channel that is closed when the RPC connection is closed.
 func (n *Notifier) Closed() <-chan interface{} {
 	return n.codec.Closed()
@@ -129,7 +163,14 @@ func (n *Notifier) activate(id ID, namespace string) {
 	defer n.subMu.Unlock()
 	if sub, found := n.inactive[id]; found {
 		sub.namespace = namespace
-		n.active[id] = sub
 		delete(n.inactive, id)
+
+		if sub.failure != nil {
+			if err := n.codec.Write(n.codec.CreateErrorNotification(string(id), namespace, sub.failure)); err != nil {
+				n.codec.Close()
+			}
+			return
+		}
+		n.active[id] = sub
 	}
 }
@@ -0,0 +1,286 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package signer implements a standalone account signing service, approving the
+// requests of remote clients based on a rule set or interactive confirmation.
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ErrRequestDenied is returned if a signing request was rejected either by the
+// rules or by the user.
+var ErrRequestDenied = errors.New("request denied")
+
+// SendTxArgs represents the arguments of a transaction signing request.
+type SendTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+	ChainID  *hexutil.Big    `json:"chainId"`
+}
+
+// toTransaction converts the signing request arguments into a transaction.
+func (args *SendTxArgs) toTransaction() *types.Transaction {
+	if args.To == nil {
+		return types.NewContractCreation(uint64(args.Nonce), (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), args.Data)
+	}
+	return types.NewTransaction(uint64(args.Nonce), *args.To, (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), args.Data)
+}
+
+// SignTxResponse is the result of a transaction signing request.
+type SignTxResponse struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// UI is the user facing interface of the signer, consulted for all the requests
+// the rules do not decide about. Implementations must be safe for concurrent use.
+type UI interface {
+	// ApproveListing asks the user whether the accounts may be disclosed.
+	ApproveListing(accounts []common.Address) (bool, error)
+
+	// ApproveTx asks the user whether a transaction may be signed.
+	ApproveTx(args *SendTxArgs) (bool, error)
+
+	// ApproveSignData asks the user whether an account may sign a message (if
+	// text is set) or an arbitrary digest.
+	ApproveSignData(from common.Address, data []byte, text bool) (bool, error)
+
+	// ApproveSignTypedData asks the user whether an account may sign structured
+	// data.
+	ApproveSignTypedData(from common.Address, typedData *accounts.TypedData) (bool, error)
+
+	// Passphrase requests the password to unlock an account for signing.
+	Passphrase(account accounts.Account) (string, error)
+}
+
+// SignerAPI is the JSON-RPC API exposed to the clients of the signer.
+type SignerAPI struct {
+	chainID *big.Int
+	am      *accounts.Manager
+	rules   *RuleEngine
+	ui      UI
+	lock    sync.Mutex // Serializes interactive requests
+}
+
+// NewSignerAPI creates a signing service backed by the wallets of an account
+// manager, defaulting to the given chain id for transactions.
+func NewSignerAPI(chainID *big.Int, am *accounts.Manager, rules *RuleEngine, ui UI) *SignerAPI {
+	return &SignerAPI{chainID: chainID, am: am, rules: rules, ui: ui}
+}
+
+// approve resolves a rule decision, asking the user if the rules are undecided.
+func (api *SignerAPI) approve(decision Decision, ask func() (bool, error)) error {
+	switch decision {
+	case Approve:
+		return nil
+	case Reject:
+		return ErrRequestDenied
+	}
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	approved, err := ask()
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return ErrRequestDenied
+	}
+	return nil
+}
+
+// List returns the addresses of all the accounts managed by the signer.
+func (api *SignerAPI) List(ctx context.Context) ([]common.Address, error) {
+	var addresses []common.Address
+	for _, wallet := range api.am.Wallets() {
+		for _, account := range wallet.Accounts() {
+			addresses = append(addresses, account.Address)
+		}
+	}
+	err := api.approve(api.rules.ApproveListing(), func() (bool, error) {
+		return api.ui.ApproveListing(addresses)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// SignTransaction signs a transaction on behalf of an account, if allowed by the
+// rules or confirmed by the user.
+func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs) (*SignTxResponse, error) {
+	chainID := api.chainID
+	if args.ChainID != nil {
+		chainID = (*big.Int)(args.ChainID)
+	}
+	if chainID.Cmp(api.chainID) != 0 {
+		return nil, fmt.Errorf("chain id mismatch: have %v, want %v", chainID, api.chainID)
+	}
+	// Count the maximum cost of the transaction against the rules, not just the value
+	now := time.Now()
+	cost := new(big.Int).Mul((*big.Int)(&args.GasPrice), new(big.Int).SetUint64(uint64(args.Gas)))
+	cost.Add(cost, (*big.Int)(&args.Value))
+
+	decision := api.rules.ApproveTx(args.From, args.To, cost, now)
+	err := api.approve(decision, func() (bool, error) {
+		return api.ui.ApproveTx(&args)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Request approved, sign the transaction with the owning wallet
+	signed, err := api.signTx(args.From, args.toTransaction(), chainID)
+	if err != nil {
+		if decision == Approve {
+			api.rules.Release(args.From, cost, now)
+		}
+		return nil, err
+	}
+	if decision != Approve {
+		api.rules.Signed(args.From, cost, now)
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return &SignTxResponse{Raw: raw, Tx: signed}, nil
+}
+
+// signTx signs an approved transaction with the wallet owning the account.
+func (api *SignerAPI) signTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	account := accounts.Account{Address: from}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := wallet.SignTx(account, tx, chainID)
+	if err == keystore.ErrLocked {
+		var passphrase string
+		if passphrase, err = api.passphrase(account); err == nil {
+			signed, err = wallet.SignTxWithPassphrase(account, passphrase, tx, chainID)
+		}
+	}
+	return signed, err
+}
+
+// SignData signs the hash of a message, prefixed the same way as by eth_sign, on
+// behalf of an account if allowed by the rules or confirmed by the user.
+func (api *SignerAPI) SignData(ctx context.Context, from common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	return api.sign(api.rules.ApproveSignData(from), from, data, true)
+}
+
+// SignHash signs an arbitrary 32 byte digest on behalf of an account. As the
+// digest might just as well be that of a transaction, which would bypass all
+// the transaction rules, it always needs to be confirmed by the user.
+func (api *SignerAPI) SignHash(ctx context.Context, from common.Address, hash hexutil.Bytes) (hexutil.Bytes, error) {
+	if len(hash) != common.HashLength {
+		return nil, fmt.Errorf("invalid hash length: have %d, want %d", len(hash), common.HashLength)
+	}
+	return api.sign(Ask, from, hash, false)
+}
+
+// sign produces a signature of a message (if text is set) or of a digest on behalf
+// of an account, once approved.
+func (api *SignerAPI) sign(decision Decision, from common.Address, data []byte, text bool) (hexutil.Bytes, error) {
+	err := api.approve(decision, func() (bool, error) {
+		return api.ui.ApproveSignData(from, data, text)
+	})
+	if err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: from}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	var signature []byte
+	if text {
+		signature, err = wallet.SignText(account, data)
+	} else {
+		signature, err = wallet.SignHash(account, data)
+	}
+	if err == keystore.ErrLocked {
+		var passphrase string
+		if passphrase, err = api.passphrase(account); err == nil {
+			if text {
+				signature, err = wallet.SignTextWithPassphrase(account, passphrase, data)
+			} else {
+				signature, err = wallet.SignHashWithPassphrase(account, passphrase, data)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// SignTypedData signs the EIP-712 hash of structured data on behalf of an account
+// if data signing is allowed by the rules or confirmed by the user.
+func (api *SignerAPI) SignTypedData(ctx context.Context, from common.Address, typedData accounts.TypedData) (hexutil.Bytes, error) {
+	if err := typedData.Validate(); err != nil {
+		return nil, err
+	}
+	err := api.approve(api.rules.ApproveSignData(from), func() (bool, error) {
+		return api.ui.ApproveSignTypedData(from, &typedData)
+	})
+	if err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: from}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := wallet.SignTypedData(account, &typedData)
+	if err == keystore.ErrLocked {
+		var passphrase string
+		if passphrase, err = api.passphrase(account); err == nil {
+			signature, err = wallet.SignTypedDataWithPassphrase(account, passphrase, &typedData)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// passphrase requests the password of a locked account from the user.
+func (api *SignerAPI) passphrase(account accounts.Account) (string, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	return api.ui.Passphrase(account)
+}
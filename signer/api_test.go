@@ -0,0 +1,183 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// testUI is a scripted user interface, answering all requests the same way.
+type testUI struct {
+	approve    bool
+	passphrase string
+	asked      int
+}
+
+func (ui *testUI) ApproveListing([]common.Address) (bool, error) {
+	ui.asked++
+	return ui.approve, nil
+}
+
+func (ui *testUI) ApproveTx(*SendTxArgs) (bool, error) {
+	ui.asked++
+	return ui.approve, nil
+}
+
+func (ui *testUI) ApproveSignData(common.Address, []byte, bool) (bool, error) {
+	ui.asked++
+	return ui.approve, nil
+}
+
+func (ui *testUI) ApproveSignTypedData(common.Address, *accounts.TypedData) (bool, error) {
+	ui.asked++
+	return ui.approve, nil
+}
+
+func (ui *testUI) Passphrase(accounts.Account) (string, error) {
+	return ui.passphrase, nil
+}
+
+func newTestSigner(t *testing.T, rules *Rules, ui UI) (*SignerAPI, common.Address, func()) {
+	dir, err := ioutil.TempDir("", "signer-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount("secret")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	am := accounts.NewManager(ks)
+	return NewSignerAPI(big.NewInt(1), am, newTestEngine(t, rules, ""), ui), account.Address, func() {
+		am.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// Tests that transactions are signed if approved by the rules or the user, and
+// that locked accounts are unlocked with the passphrase provided by the user.
+func TestSignTransaction(t *testing.T) {
+	ui := &testUI{approve: false, passphrase: "secret"}
+	api, from, cleanup := newTestSigner(t, testRules(t), ui)
+	defer cleanup()
+
+	args := SendTxArgs{From: from, To: &testTrusted, Gas: 21000, Value: hexutil.Big(*big.NewInt(10))}
+
+	// Transfers within the rules are signed without asking
+	res, err := api.SignTransaction(context.Background(), args)
+	if err != nil {
+		t.Fatalf("failed to sign approved transaction: %v", err)
+	}
+	if ui.asked != 0 {
+		t.Errorf("user asked about approved transaction")
+	}
+	signer := types.NewEIP155Signer(big.NewInt(1))
+	if sender, err := types.Sender(signer, res.Tx); err != nil || sender != from {
+		t.Errorf("sender mismatch: have %x, want %x (err: %v)", sender, from, err)
+	}
+	// Transfers to denied recipients are rejected without asking
+	args.To = &testDenied
+	if _, err := api.SignTransaction(context.Background(), args); err != ErrRequestDenied {
+		t.Errorf("denied transaction: error mismatch: have %v, want %v", err, ErrRequestDenied)
+	}
+	if ui.asked != 0 {
+		t.Errorf("user asked about denied transaction")
+	}
+	// Other transfers are decided by the user
+	args.To = &testUnknown
+	if _, err := api.SignTransaction(context.Background(), args); err != ErrRequestDenied {
+		t.Errorf("rejected transaction: error mismatch: have %v, want %v", err, ErrRequestDenied)
+	}
+	ui.approve = true
+	if _, err := api.SignTransaction(context.Background(), args); err != nil {
+		t.Errorf("failed to sign confirmed transaction: %v", err)
+	}
+	if ui.asked != 2 {
+		t.Errorf("confirmation count mismatch: have %d, want %d", ui.asked, 2)
+	}
+	// The maximum gas cost counts towards the limits too
+	args.To, args.GasPrice = &testTrusted, hexutil.Big(*big.NewInt(1))
+	ui.approve = false
+	if _, err := api.SignTransaction(context.Background(), args); err != ErrRequestDenied {
+		t.Errorf("costly transaction: error mismatch: have %v, want %v", err, ErrRequestDenied)
+	}
+	if ui.asked != 3 {
+		t.Errorf("confirmation count mismatch: have %d, want %d", ui.asked, 3)
+	}
+	// Transactions for other chains are refused
+	args.ChainID = (*hexutil.Big)(big.NewInt(2))
+	if _, err := api.SignTransaction(context.Background(), args); err == nil {
+		t.Errorf("signed transaction for foreign chain")
+	}
+}
+
+// Tests that data signatures are produced over the prefixed message hash.
+func TestSignData(t *testing.T) {
+	ui := &testUI{approve: true, passphrase: "secret"}
+	api, from, cleanup := newTestSigner(t, nil, ui)
+	defer cleanup()
+
+	sig, err := api.SignData(context.Background(), from, []byte("hello"))
+	if err != nil {
+		t.Fatalf("failed to sign data: %v", err)
+	}
+	hash := crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n5hello"))
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if addr := crypto.PubkeyToAddress(*pubkey); addr != from {
+		t.Errorf("signer mismatch: have %x, want %x", addr, from)
+	}
+	if _, err := api.SignHash(context.Background(), from, []byte{1, 2, 3}); err == nil {
+		t.Errorf("signed malformed hash")
+	}
+	ui.approve = false
+	if _, err := api.SignData(context.Background(), from, []byte("hello")); err != ErrRequestDenied {
+		t.Errorf("rejected signing: error mismatch: have %v, want %v", err, ErrRequestDenied)
+	}
+}
+
+// Tests that arbitrary hashes are never signed without the user's confirmation,
+// even if data signing is allowed by the rules.
+func TestSignHashConfirmation(t *testing.T) {
+	ui := &testUI{approve: false, passphrase: "secret"}
+	api, from, cleanup := newTestSigner(t, &Rules{SignData: true}, ui)
+	defer cleanup()
+
+	hash := crypto.Keccak256([]byte("hello"))
+	if _, err := api.SignData(context.Background(), from, []byte("hello")); err != nil {
+		t.Fatalf("failed to sign approved data: %v", err)
+	}
+	if _, err := api.SignHash(context.Background(), from, hash); err != ErrRequestDenied {
+		t.Errorf("rejected hash signing: error mismatch: have %v, want %v", err, ErrRequestDenied)
+	}
+	if ui.asked != 1 {
+		t.Errorf("confirmation count mismatch: have %d, want %d", ui.asked, 1)
+	}
+}
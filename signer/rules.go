@@ -0,0 +1,260 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Decision is the verdict of the rule engine on a signing request.
+type Decision int
+
+const (
+	// Ask means no rule matched, the request needs interactive confirmation.
+	Ask Decision = iota
+
+	// Approve means the request is allowed by the rules, no confirmation needed.
+	Approve
+
+	// Reject means the request is forbidden by the rules.
+	Reject
+)
+
+// String implements fmt.Stringer.
+func (d Decision) String() string {
+	switch d {
+	case Approve:
+		return "approve"
+	case Reject:
+		return "reject"
+	default:
+		return "ask"
+	}
+}
+
+// RecipientRule is the auto-approval policy of transactions to a recipient.
+type RecipientRule struct {
+	MaxValue *math.HexOrDecimal256 `json:"maxValue"` // Maximum value of a single transaction, including gas (nil = any)
+}
+
+// Rules is a declarative rule set deciding which signing requests are approved
+// automatically, which are rejected and which need confirmation by the user.
+type Rules struct {
+	ListAccounts bool `json:"listAccounts"` // Whether account listings are approved without asking
+	SignData     bool `json:"signData"`     // Whether data signing requests are approved without asking
+
+	Recipients map[common.Address]RecipientRule `json:"recipients"` // Recipients transactions may be auto-approved to
+	Deny       []common.Address                 `json:"deny"`       // Recipients transactions are always rejected to
+	DailyLimit *math.HexOrDecimal256            `json:"dailyLimit"` // Maximum value auto-approved per sender in 24 hours, including gas (nil = any)
+}
+
+// LoadRules parses a rule set from a JSON file.
+func LoadRules(path string) (*Rules, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := new(Rules)
+	if err := json.Unmarshal(blob, rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// spend is a value transfer signed on behalf of an account, along with the
+// maximum gas it may pay for.
+type spend struct {
+	Value *hexutil.Big `json:"value"`
+	Time  time.Time    `json:"time"`
+}
+
+// RuleEngine evaluates signing requests against a rule set, tracking the value
+// signed away by each account to enforce the daily limits.
+type RuleEngine struct {
+	rules   *Rules
+	journal string                     // File to persist the spends into across restarts (empty = disabled)
+	spends  map[common.Address][]spend // Transfers signed within the last day, per sender
+	lock    sync.Mutex
+}
+
+// NewRuleEngine creates a rule engine enforcing the given rule set. A nil set
+// asks the user about every request. If a journal is specified, the transfers
+// counting towards the daily limits are loaded from and persisted into it.
+func NewRuleEngine(rules *Rules, journal string) (*RuleEngine, error) {
+	if rules == nil {
+		rules = new(Rules)
+	}
+	engine := &RuleEngine{
+		rules:   rules,
+		journal: journal,
+		spends:  make(map[common.Address][]spend),
+	}
+	if journal != "" {
+		blob, err := ioutil.ReadFile(journal)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		default:
+			if err := json.Unmarshal(blob, &engine.spends); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return engine, nil
+}
+
+// ApproveListing decides whether the list of accounts may be disclosed.
+func (e *RuleEngine) ApproveListing() Decision {
+	if e.rules.ListAccounts {
+		return Approve
+	}
+	return Ask
+}
+
+// ApproveSignData decides whether an account may sign arbitrary data.
+func (e *RuleEngine) ApproveSignData(from common.Address) Decision {
+	if e.rules.SignData {
+		return Approve
+	}
+	return Ask
+}
+
+// ApproveTx decides whether a transaction from one account to a recipient (nil
+// for contract creations) may be signed, where cost is the value transferred
+// plus the maximum gas fee. Approved transactions are counted towards the daily
+// limit of the sender in the same step, and need to be released if signing them
+// fails afterwards.
+func (e *RuleEngine) ApproveTx(from common.Address, to *common.Address, cost *big.Int, now time.Time) Decision {
+	if to == nil {
+		return Ask
+	}
+	for _, denied := range e.rules.Deny {
+		if denied == *to {
+			return Reject
+		}
+	}
+	rule, ok := e.rules.Recipients[*to]
+	if !ok {
+		return Ask
+	}
+	if rule.MaxValue != nil && cost.Cmp((*big.Int)(rule.MaxValue)) > 0 {
+		return Ask
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.rules.DailyLimit != nil {
+		total := new(big.Int).Add(e.spent(from, now), cost)
+		if total.Cmp((*big.Int)(e.rules.DailyLimit)) > 0 {
+			return Ask
+		}
+	}
+	e.record(from, cost, now)
+	return Approve
+}
+
+// Signed records a transaction confirmed by the user and signed on behalf of an
+// account, counting its cost towards the daily limit.
+func (e *RuleEngine) Signed(from common.Address, cost *big.Int, now time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.record(from, cost, now)
+}
+
+// Release drops a transaction approved by the rules but failed to be signed from
+// the daily limit of its sender.
+func (e *RuleEngine) Release(from common.Address, cost *big.Int, now time.Time) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	spends := e.spends[from]
+	for i, s := range spends {
+		if s.Time.Equal(now) && s.Value.ToInt().Cmp(cost) == 0 {
+			e.spends[from] = append(spends[:i:i], spends[i+1:]...)
+			if len(e.spends[from]) == 0 {
+				delete(e.spends, from)
+			}
+			e.save()
+			return
+		}
+	}
+}
+
+// record appends a transfer to the spends of an account and persists them.
+//
+// Note, this method assumes the engine lock is held!
+func (e *RuleEngine) record(from common.Address, cost *big.Int, now time.Time) {
+	e.spends[from] = append(e.spends[from], spend{Value: (*hexutil.Big)(new(big.Int).Set(cost)), Time: now})
+	e.save()
+}
+
+// save persists the spends into the journal, if enabled. Failures are only
+// logged, as the in memory limits are still enforced.
+//
+// Note, this method assumes the engine lock is held!
+func (e *RuleEngine) save() {
+	if e.journal == "" {
+		return
+	}
+	blob, err := json.Marshal(e.spends)
+	if err != nil {
+		log.Warn("Failed to encode signer spends", "err", err)
+		return
+	}
+	if err := ioutil.WriteFile(e.journal+".new", blob, 0600); err != nil {
+		log.Warn("Failed to write signer spends", "err", err)
+		return
+	}
+	if err := os.Rename(e.journal+".new", e.journal); err != nil {
+		log.Warn("Failed to replace signer spends", "err", err)
+	}
+}
+
+// spent returns the total value signed away by an account in the last 24 hours,
+// dropping any older transfers.
+//
+// Note, this method assumes the engine lock is held!
+func (e *RuleEngine) spent(from common.Address, now time.Time) *big.Int {
+	var (
+		total  = new(big.Int)
+		recent []spend
+	)
+	for _, s := range e.spends[from] {
+		if now.Sub(s.Time) < 24*time.Hour {
+			total.Add(total, s.Value.ToInt())
+			recent = append(recent, s)
+		}
+	}
+	if len(recent) == 0 {
+		delete(e.spends, from)
+	} else {
+		e.spends[from] = recent
+	}
+	return total
+}
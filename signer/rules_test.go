@@ -0,0 +1,185 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package signer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	testSender    = common.HexToAddress("0x0000000000000000000000000000000000000001")
+	testTrusted   = common.HexToAddress("0x0000000000000000000000000000000000000002")
+	testDenied    = common.HexToAddress("0x0000000000000000000000000000000000000003")
+	testUnknown   = common.HexToAddress("0x0000000000000000000000000000000000000004")
+	testRulesJSON = `{
+		"listAccounts": true,
+		"recipients": {
+			"0x0000000000000000000000000000000000000002": {"maxValue": "100"}
+		},
+		"deny": ["0x0000000000000000000000000000000000000003"],
+		"dailyLimit": "0xfa"
+	}`
+)
+
+func testRules(t *testing.T) *Rules {
+	rules := new(Rules)
+	if err := json.Unmarshal([]byte(testRulesJSON), rules); err != nil {
+		t.Fatalf("failed to parse rules: %v", err)
+	}
+	return rules
+}
+
+func newTestEngine(t *testing.T, rules *Rules, journal string) *RuleEngine {
+	engine, err := NewRuleEngine(rules, journal)
+	if err != nil {
+		t.Fatalf("failed to create rule engine: %v", err)
+	}
+	return engine
+}
+
+// Tests that the rule engine approves, rejects or defers requests as configured.
+func TestRuleDecisions(t *testing.T) {
+	engine := newTestEngine(t, testRules(t), "")
+	now := time.Now()
+
+	if d := engine.ApproveListing(); d != Approve {
+		t.Errorf("listing: decision mismatch: have %v, want %v", d, Approve)
+	}
+	if d := engine.ApproveSignData(testSender); d != Ask {
+		t.Errorf("sign data: decision mismatch: have %v, want %v", d, Ask)
+	}
+	tests := []struct {
+		to    *common.Address
+		value int64
+		want  Decision
+	}{
+		{&testTrusted, 100, Approve}, // within the per transaction limit
+		{&testTrusted, 101, Ask},     // above the per transaction limit
+		{&testDenied, 1, Reject},     // denied recipient
+		{&testUnknown, 1, Ask},       // unknown recipient
+		{nil, 0, Ask},                // contract creation
+	}
+	for i, tt := range tests {
+		if d := engine.ApproveTx(testSender, tt.to, big.NewInt(tt.value), now); d != tt.want {
+			t.Errorf("test %d: decision mismatch: have %v, want %v", i, d, tt.want)
+		}
+	}
+	// Nil rules should defer everything to the user
+	engine = newTestEngine(t, nil, "")
+	if d := engine.ApproveListing(); d != Ask {
+		t.Errorf("nil rules listing: decision mismatch: have %v, want %v", d, Ask)
+	}
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(1), now); d != Ask {
+		t.Errorf("nil rules tx: decision mismatch: have %v, want %v", d, Ask)
+	}
+}
+
+// Tests that the daily limit accumulates signed transfers over a rolling 24 hour
+// window.
+func TestRuleDailyLimit(t *testing.T) {
+	engine := newTestEngine(t, testRules(t), "")
+	now := time.Now()
+
+	// Spend 200 of the 250 allowance, a further 100 should need confirmation
+	engine.Signed(testSender, big.NewInt(100), now)
+	engine.Signed(testSender, big.NewInt(100), now.Add(time.Hour))
+
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(100), now.Add(2*time.Hour)); d != Ask {
+		t.Errorf("over limit: decision mismatch: have %v, want %v", d, Ask)
+	}
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(50), now.Add(2*time.Hour)); d != Approve {
+		t.Errorf("within limit: decision mismatch: have %v, want %v", d, Approve)
+	}
+	// Other senders have their own allowance
+	if d := engine.ApproveTx(testUnknown, &testTrusted, big.NewInt(100), now.Add(2*time.Hour)); d != Approve {
+		t.Errorf("other sender: decision mismatch: have %v, want %v", d, Approve)
+	}
+	// Once the first transfer expires, the allowance is freed up
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(100), now.Add(25*time.Hour)); d != Approve {
+		t.Errorf("after expiry: decision mismatch: have %v, want %v", d, Approve)
+	}
+}
+
+// Tests that concurrent requests cannot overdraw the daily limit, as the checks
+// and the reservations of the allowance happen in one step.
+func TestRuleDailyLimitConcurrent(t *testing.T) {
+	engine := newTestEngine(t, testRules(t), "")
+	now := time.Now()
+
+	var (
+		approved int
+		lock     sync.Mutex
+		pend     sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			if engine.ApproveTx(testSender, &testTrusted, big.NewInt(50), now) == Approve {
+				lock.Lock()
+				approved++
+				lock.Unlock()
+			}
+		}()
+	}
+	pend.Wait()
+	if approved != 5 {
+		t.Errorf("approved transfer count mismatch: have %d, want %d", approved, 5)
+	}
+	// Releasing a failed transfer should free up its allowance
+	engine.Release(testSender, big.NewInt(50), now)
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(50), now); d != Approve {
+		t.Errorf("after release: decision mismatch: have %v, want %v", d, Approve)
+	}
+}
+
+// Tests that the transfers counting towards the daily limit survive a restart if
+// a journal is configured.
+func TestRuleDailyLimitPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	journal := filepath.Join(dir, "spends.json")
+	now := time.Now()
+
+	engine := newTestEngine(t, testRules(t), journal)
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(100), now); d != Approve {
+		t.Fatalf("first transfer: decision mismatch: have %v, want %v", d, Approve)
+	}
+	engine.Signed(testSender, big.NewInt(100), now)
+
+	// Restart the engine, the allowance should remain used up
+	engine = newTestEngine(t, testRules(t), journal)
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(100), now); d != Ask {
+		t.Errorf("over limit after restart: decision mismatch: have %v, want %v", d, Ask)
+	}
+	if d := engine.ApproveTx(testSender, &testTrusted, big.NewInt(50), now); d != Approve {
+		t.Errorf("within limit after restart: decision mismatch: have %v, want %v", d, Approve)
+	}
+}
@@ -17,6 +17,7 @@
 package network
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -231,9 +232,8 @@ func toOverlayAddrs(as ...*BzzAddr) (oas []OverlayAddr) {
 	return
 }
 
-// savePeers, savePeer implement persistence callback/
-func (h *Hive) savePeers() error {
-	var peers []*BzzAddr
+// knownPeers returns the addresses of all peers known to the overlay
+func (h *Hive) knownPeers() (peers []*BzzAddr) {
 	h.Overlay.EachAddr(nil, 256, func(pa OverlayAddr, i int, _ bool) bool {
 		if pa == nil {
 			log.Warn(fmt.Sprintf("empty addr: %v", i))
@@ -242,8 +242,29 @@ func (h *Hive) savePeers() error {
 		peers = append(peers, ToAddr(pa))
 		return true
 	})
-	if err := h.Store.Put("peers", peers); err != nil {
+	return peers
+}
+
+// savePeers, savePeer implement persistence callback/
+func (h *Hive) savePeers() error {
+	if err := h.Store.Put("peers", h.knownPeers()); err != nil {
 		return fmt.Errorf("could not save peers: %v", err)
 	}
 	return nil
 }
+
+// Snapshot returns the addresses of the known peers as the state the hive
+// contributes to a simulation network snapshot
+func (h *Hive) Snapshot() ([]byte, error) {
+	return json.Marshal(h.knownPeers())
+}
+
+// LoadSnapshot registers the peer addresses of a snapshot created by Snapshot
+// so that a node restored from it starts with a warm address book
+func (h *Hive) LoadSnapshot(data []byte) error {
+	var as []*BzzAddr
+	if err := json.Unmarshal(data, &as); err != nil {
+		return fmt.Errorf("could not decode hive snapshot: %v", err)
+	}
+	return h.Register(toOverlayAddrs(as...))
+}
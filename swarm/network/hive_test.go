@@ -106,3 +106,35 @@ func TestHiveStatePersistance(t *testing.T) {
 		t.Fatalf("invalid peers loaded")
 	}
 }
+
+func TestHiveSnapshot(t *testing.T) {
+	params := NewHiveParams()
+	addr := RandomAddr()
+	pp := NewHive(params, NewKademlia(addr.OAddr, NewKadParams()), nil)
+
+	peers := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		raddr := RandomAddr()
+		pp.Register([]OverlayAddr{OverlayAddr(raddr)})
+		peers[raddr.String()] = true
+	}
+	snap, err := pp.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restore the address book into a hive with the same base address
+	pp = NewHive(params, NewKademlia(addr.OAddr, NewKadParams()), nil)
+	if err := pp.LoadSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	i := 0
+	pp.Overlay.EachAddr(nil, 256, func(addr OverlayAddr, po int, nn bool) bool {
+		delete(peers, addr.(*BzzAddr).String())
+		i++
+		return true
+	})
+	if len(peers) != 0 || i != 5 {
+		t.Fatalf("invalid peers loaded from snapshot")
+	}
+}
@@ -1 +1,64 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package discovery contains simulations of the swarm overlay peer discovery,
+// and registers the "kademlia-healthy" scenario check for importers.
 package discovery
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/swarm/network"
+)
+
+func init() {
+	simulations.RegisterCheck("kademlia-healthy", KademliaHealthy(network.NewKadParams().MinProxBinSize))
+}
+
+// KademliaHealthy returns a scenario check factory, creating checks which are met
+// by a node once its kademlia table is healthy with respect to the nodes which are
+// up when the check starts, e.g. after churn.
+func KademliaHealthy(minProxBinSize int) simulations.CheckFactory {
+	return func(net *simulations.Network, expect *simulations.ScenarioExpect) (simulations.NodeCheck, error) {
+		var addrs [][]byte
+		for _, node := range net.GetNodes() {
+			if node.Up {
+				addrs = append(addrs, network.ToOverlayAddr(node.ID().Bytes()))
+			}
+		}
+		ppmap := network.NewPeerPotMap(minProxBinSize, addrs)
+
+		return func(ctx context.Context, id discover.NodeID) (bool, error) {
+			node := net.GetNode(id)
+			if node == nil {
+				return false, nil
+			}
+			client, err := node.Client()
+			if err != nil {
+				return false, nil // node is down, check again later
+			}
+			healthy := &network.Health{}
+			addr := common.Bytes2Hex(network.ToOverlayAddr(id.Bytes()))
+			if err := client.CallContext(ctx, &healthy, "hive_healthy", ppmap[addr]); err != nil {
+				return false, err
+			}
+			return healthy.KnowNN && healthy.GotNN && healthy.Full, nil
+		}, nil
+	}
+}
@@ -150,6 +150,36 @@ func TestDiscoveryPersistenceSimulationSimAdapter(t *testing.T) {
 	testDiscoveryPersistenceSimulationSimAdapter(t, *nodeCount, *initCount)
 }
 
+// Tests that a scenario can wait for a healthy kademlia with the registered
+// "kademlia-healthy" check.
+func TestDiscoveryScenarioSimAdapter(t *testing.T) {
+	net := simulations.NewNetwork(adapters.NewSimAdapter(services), &simulations.NetworkConfig{
+		ID:             "0",
+		DefaultService: serviceName,
+	})
+	defer net.Shutdown()
+
+	scenario := &simulations.Scenario{
+		Steps: []*simulations.ScenarioStep{
+			{Action: "create", Count: 8},
+			{Action: "connect", Topology: "full", Expect: &simulations.ScenarioExpect{
+				Type:    "kademlia-healthy",
+				Timeout: simulations.Duration(30 * time.Second),
+			}},
+		},
+	}
+	if err := scenario.Validate(); err != nil {
+		t.Fatalf("invalid scenario: %v", err)
+	}
+	result := simulations.RunScenario(context.Background(), net, scenario)
+	if result.Failed() {
+		t.Fatalf("scenario failed: %s", result.Error)
+	}
+	if passed := len(result.Steps[1].Passed); passed != 8 {
+		t.Errorf("healthy node count mismatch: have %d, want 8", passed)
+	}
+}
+
 func testDiscoveryPersistenceSimulationSimAdapter(t *testing.T, nodes, conns int) {
 	testDiscoveryPersistenceSimulation(t, nodes, conns, adapters.NewSimAdapter(services))
 }
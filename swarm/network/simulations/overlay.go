@@ -19,18 +19,23 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/simulations"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 	"github.com/ethereum/go-ethereum/swarm/network"
+	"github.com/ethereum/go-ethereum/swarm/network/simulations/discovery"
+	"github.com/ethereum/go-ethereum/swarm/state"
 )
 
 var noDiscovery = flag.Bool("no-discovery", false, "disable discovery (useful if you want to load a snapshot)")
 
+// minProxBinSize is the kademlia proximity bin size of the simulated nodes
+const minProxBinSize = 2
+
 type Simulation struct {
 	mtx    sync.Mutex
-	stores map[discover.NodeID]*adapters.SimStateStore
+	stores map[discover.NodeID]*state.InmemoryStore
 }
 
 func NewSimulation() *Simulation {
 	return &Simulation{
-		stores: make(map[discover.NodeID]*adapters.SimStateStore),
+		stores: make(map[discover.NodeID]*state.InmemoryStore),
 	}
 }
 
@@ -39,7 +44,7 @@ func (s *Simulation) NewService(ctx *adapters.ServiceContext) (node.Service, err
 	s.mtx.Lock()
 	store, ok := s.stores[id]
 	if !ok {
-		store = adapters.NewSimStateStore()
+		store = state.NewInmemoryStore()
 		s.stores[id] = store
 	}
 	s.mtx.Unlock()
@@ -47,7 +52,7 @@ func (s *Simulation) NewService(ctx *adapters.ServiceContext) (node.Service, err
 	addr := network.NewAddrFromNodeID(id)
 
 	kp := network.NewKadParams()
-	kp.MinProxBinSize = 2
+	kp.MinProxBinSize = minProxBinSize
 	kp.MaxBinSize = 4
 	kp.MinBinSize = 1
 	kp.MaxRetries = 1000
@@ -67,7 +72,13 @@ func (s *Simulation) NewService(ctx *adapters.ServiceContext) (node.Service, err
 		HiveParams:   hp,
 	}
 
-	return network.NewBzz(config, kad, store, nil, nil), nil
+	bzz := network.NewBzz(config, kad, store, nil, nil)
+	if ctx.Snapshot != nil {
+		if err := bzz.LoadSnapshot(ctx.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+	return bzz, nil
 }
 
 // var server
@@ -78,6 +89,8 @@ func main() {
 
 	log.Root().SetHandler(log.LvlFilterHandler(log.LvlInfo, log.StreamHandler(os.Stderr, log.TerminalFormat(false))))
 
+	simulations.RegisterCheck("kademlia-healthy", discovery.KademliaHealthy(minProxBinSize))
+
 	s := NewSimulation()
 	services := adapters.Services{
 		"overlay": s.NewService,
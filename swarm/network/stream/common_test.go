@@ -19,6 +19,7 @@ package stream
 import (
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -85,6 +86,20 @@ func NewStreamerService(ctx *adapters.ServiceContext) (node.Service, error) {
 	id := ctx.Config.ID
 	addr := toAddr(id)
 	kad := network.NewKademlia(addr.Over(), network.NewKadParams())
+	// register the peers known from the network snapshot, if any
+	if ctx.Snapshot != nil {
+		var addrs []*network.BzzAddr
+		if err := json.Unmarshal(ctx.Snapshot, &addrs); err != nil {
+			return nil, err
+		}
+		var peers []network.OverlayAddr
+		for _, addr := range addrs {
+			peers = append(peers, addr)
+		}
+		if err := kad.Register(peers); err != nil {
+			return nil, err
+		}
+	}
 	stores[id], err = createStoreFunc(id, addr)
 	if err != nil {
 		return nil, err
@@ -103,7 +118,7 @@ func NewStreamerService(ctx *adapters.ServiceContext) (node.Service, error) {
 		waitPeerErrC <- waitForPeers(r, 1*time.Second, peerCount(id))
 	}()
 	dpa := storage.NewDPA(storage.NewNetStore(store, getRetrieveFunc(id)), storage.NewDPAParams())
-	testRegistry := &TestRegistry{Registry: r, dpa: dpa}
+	testRegistry := &TestRegistry{Registry: r, dpa: dpa, kad: kad}
 	registries[id] = testRegistry
 	return testRegistry, nil
 }
@@ -215,6 +230,7 @@ func (rrs *roundRobinStore) Close() {
 type TestRegistry struct {
 	*Registry
 	dpa *storage.DPA
+	kad *network.Kademlia
 }
 
 func (r *TestRegistry) APIs() []rpc.API {
@@ -248,6 +264,21 @@ func (r *TestRegistry) ReadAll(hash common.Hash) (int64, error) {
 	return readAll(r.dpa, hash[:])
 }
 
+// knownPeers returns the addresses of all peers known to the kademlia
+func (r *TestRegistry) knownPeers() (addrs []*network.BzzAddr) {
+	r.kad.EachAddr(nil, 256, func(addr network.OverlayAddr, _ int, _ bool) bool {
+		addrs = append(addrs, network.ToAddr(addr))
+		return true
+	})
+	return addrs
+}
+
+// Snapshot returns the addresses of the known peers as the state the streamer
+// contributes to a simulation network snapshot
+func (r *TestRegistry) Snapshot() ([]byte, error) {
+	return json.Marshal(r.knownPeers())
+}
+
 func (r *TestRegistry) Start(server *p2p.Server) error {
 	return r.Registry.Start(server)
 }
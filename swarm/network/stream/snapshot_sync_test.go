@@ -622,15 +622,36 @@ func initNetWithSnapshot(nodeCount int) (*simulations.Network, error) {
 	for _, n := range snap.Nodes {
 		n.Node.Config.EnableMsgEvents = true
 	}
+	//the snapshot files predate service state, so give each streamer the
+	//addresses of its snapshot peers to restore its kademlia from
+	peers := make(map[discover.NodeID][]*network.BzzAddr)
+	for _, conn := range snap.Conns {
+		peers[conn.One] = append(peers[conn.One], toAddr(conn.Other))
+		peers[conn.Other] = append(peers[conn.Other], toAddr(conn.One))
+	}
+	for i, n := range snap.Nodes {
+		state, err := json.Marshal(peers[n.Node.Config.ID])
+		if err != nil {
+			return nil, err
+		}
+		snap.Nodes[i].Snapshots = map[string][]byte{"streamer": state}
+	}
 
 	log.Info("Waiting for p2p connections to be established...")
 
 	//now we can load the snapshot
-	err = net.Load(&snap)
+	err = net.LoadAndWait(&snap, 2*time.Minute)
 	if err != nil {
 		return nil, err
 	}
 	log.Info("Snapshot loaded")
+
+	//check that the streamers were restored with their known peers
+	for id, addrs := range peers {
+		if known := len(registries[id].knownPeers()); known < len(addrs) {
+			return nil, fmt.Errorf("node %s restored with %d known peers, want at least %d", id.TerminalString(), known, len(addrs))
+		}
+	}
 	return net, nil
 }
 
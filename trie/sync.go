@@ -42,6 +42,7 @@ type request struct {
 	parents []*request // Parent state nodes referencing this entry (notify all upon completion)
 	depth   int        // Depth level within the trie the node is located to prioritise DFS
 	deps    int        // Number of dependencies before allowed to commit this node
+	share   float64    // Fraction of the root trie's key space below this node (0 for sub-tries)
 
 	callback LeafCallback // Callback to invoke if a leaf node it reached on this branch
 }
@@ -76,6 +77,7 @@ type TrieSync struct {
 	membatch *syncMemBatch            // Memory buffer to avoid frequest database writes
 	requests map[common.Hash]*request // Pending requests pertaining to a key hash
 	queue    *prque.Prque             // Priority queue with the pending requests
+	missing  float64                  // Fraction of the root trie's key space not yet retrieved
 }
 
 // NewTrieSync creates a new trie data download scheduler.
@@ -87,6 +89,9 @@ func NewTrieSync(root common.Hash, database DatabaseReader, callback LeafCallbac
 		queue:    prque.New(),
 	}
 	ts.AddSubTrie(root, 0, common.Hash{}, callback)
+	if req := ts.requests[root]; req != nil {
+		req.share, ts.missing = 1, 1
+	}
 	return ts
 }
 
@@ -179,6 +184,8 @@ func (s *TrieSync) Process(results []SyncResult) (bool, int, error) {
 		if request.data != nil {
 			return committed, i, ErrAlreadyProcessed
 		}
+		s.missing -= request.share
+
 		// If the item is a raw entry request, commit directly
 		if request.raw {
 			request.data = item.Data
@@ -232,6 +239,67 @@ func (s *TrieSync) Pending() int {
 	return len(s.requests)
 }
 
+// Coverage returns the fraction of the root trie's key space below nodes that are
+// already retrieved or locally known. Since keys are hashes and thus evenly spread,
+// it's a good estimate of the progress of the sync. Sub-tries (e.g. contract
+// storage) are not accounted for.
+func (s *TrieSync) Coverage() float64 {
+	if s.missing <= 0 {
+		return 1
+	}
+	return 1 - s.missing
+}
+
+// Retrieved returns the trie nodes already retrieved but not yet committed due to
+// missing children. Together with the committed data, they define the frontier of
+// the sync, which can be restored after a restart without retrieving any of them
+// again. The method is meant to be called after flushing the data via Commit.
+func (s *TrieSync) Retrieved() []SyncResult {
+	results := make([]SyncResult, 0, len(s.requests))
+	for hash, req := range s.requests {
+		if req.data != nil && !req.raw {
+			results = append(results, SyncResult{Hash: hash, Data: req.data})
+		}
+	}
+	return results
+}
+
+// Restore feeds previously retrieved nodes into the sync as soon as they are
+// requested, restoring a frontier saved via Retrieved without network access.
+// Nodes not needed any more (e.g. if the root changed) are ignored. The number
+// of nodes restored is returned.
+func (s *TrieSync) Restore(results []SyncResult) (int, error) {
+	cache := make(map[common.Hash][]byte, len(results))
+	for _, result := range results {
+		cache[result.Hash] = result.Data
+	}
+	restored := 0
+	for progress := true; progress && len(cache) > 0; {
+		progress = false
+
+		// Process all the currently requested and cached nodes, putting back the rest
+		var missing []*request
+		for !s.queue.Empty() {
+			hash := s.queue.PopItem().(common.Hash)
+			data, ok := cache[hash]
+			if !ok {
+				missing = append(missing, s.requests[hash])
+				continue
+			}
+			delete(cache, hash)
+			if _, _, err := s.Process([]SyncResult{{Hash: hash, Data: data}}); err != nil {
+				return restored, err
+			}
+			restored++
+			progress = true
+		}
+		for _, req := range missing {
+			s.queue.Push(req.hash, float32(req.depth))
+		}
+	}
+	return restored, nil
+}
+
 // schedule inserts a new state retrieval request into the fetch queue. If there
 // is already a pending request for this node, the new request will be discarded
 // and only a parent reference added to the old one.
@@ -244,6 +312,7 @@ func (s *TrieSync) schedule(req *request) {
 	// Schedule the request for future retrieval
 	s.queue.Push(req.hash, float32(req.depth))
 	s.requests[req.hash] = req
+	s.missing += req.share
 }
 
 // children retrieves all the missing children of a state trie entry for future
@@ -253,6 +322,7 @@ func (s *TrieSync) children(req *request, object node) ([]*request, error) {
 	type child struct {
 		node  node
 		depth int
+		share float64
 	}
 	children := []child{}
 
@@ -261,6 +331,7 @@ func (s *TrieSync) children(req *request, object node) ([]*request, error) {
 		children = []child{{
 			node:  node.Val,
 			depth: req.depth + len(node.Key),
+			share: req.share,
 		}}
 	case *fullNode:
 		for i := 0; i < 17; i++ {
@@ -268,6 +339,7 @@ func (s *TrieSync) children(req *request, object node) ([]*request, error) {
 				children = append(children, child{
 					node:  node.Children[i],
 					depth: req.depth + 1,
+					share: req.share / 16,
 				})
 			}
 		}
@@ -300,6 +372,7 @@ func (s *TrieSync) children(req *request, object node) ([]*request, error) {
 				hash:     hash,
 				parents:  []*request{req},
 				depth:    child.depth,
+				share:    child.share,
 				callback: req.callback,
 			})
 		}
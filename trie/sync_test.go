@@ -362,3 +362,85 @@ func TestIncompleteTrieSync(t *testing.T) {
 		diskdb.Put(key, value)
 	}
 }
+
+// Tests that the retrieved but uncommitted nodes of an interrupted sync can be
+// restored into a new scheduler without retrieving them again.
+func TestTrieSyncRestore(t *testing.T) {
+	// Create a random trie to copy
+	srcDb, srcTrie, srcData := makeTestTrie()
+
+	// Create a destination trie and sync a few batches with the scheduler
+	diskdb, _ := ethdb.NewMemDatabase()
+	triedb := NewDatabase(diskdb)
+	sched := NewTrieSync(srcTrie.Hash(), diskdb, nil)
+
+	if coverage := sched.Coverage(); coverage != 0 {
+		t.Fatalf("initial coverage mismatch: have %v, want 0", coverage)
+	}
+	queue := append([]common.Hash{}, sched.Missing(1)...)
+	for i := 0; i < 8 && len(queue) > 0; i++ {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			data, err := srcDb.Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		queue = append(queue[:0], sched.Missing(1)...)
+	}
+	if index, err := sched.Commit(diskdb); err != nil {
+		t.Fatalf("failed to commit data #%d: %v", index, err)
+	}
+	coverage := sched.Coverage()
+	if coverage <= 0 || coverage >= 1 {
+		t.Fatalf("partial coverage out of bounds: %v", coverage)
+	}
+	retrieved := sched.Retrieved()
+	if len(retrieved) == 0 {
+		t.Fatalf("no retrieved nodes to restore")
+	}
+	// Restore the frontier into a new scheduler and ensure nothing is requested twice
+	sched = NewTrieSync(srcTrie.Hash(), diskdb, nil)
+	if restored, err := sched.Restore(retrieved); err != nil {
+		t.Fatalf("failed to restore frontier: %v", err)
+	} else if restored != len(retrieved) {
+		t.Fatalf("restored node count mismatch: have %d, want %d", restored, len(retrieved))
+	}
+	if have := sched.Coverage(); have != coverage {
+		t.Fatalf("restored coverage mismatch: have %v, want %v", have, coverage)
+	}
+	known := make(map[common.Hash]bool)
+	for _, result := range retrieved {
+		known[result.Hash] = true
+	}
+	queue = append(queue[:0], sched.Missing(0)...)
+	for len(queue) > 0 {
+		results := make([]SyncResult, len(queue))
+		for i, hash := range queue {
+			if known[hash] {
+				t.Fatalf("restored node %x requested again", hash)
+			}
+			data, err := srcDb.Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for %x: %v", hash, err)
+			}
+			results[i] = SyncResult{hash, data}
+		}
+		if _, index, err := sched.Process(results); err != nil {
+			t.Fatalf("failed to process result #%d: %v", index, err)
+		}
+		if index, err := sched.Commit(diskdb); err != nil {
+			t.Fatalf("failed to commit data #%d: %v", index, err)
+		}
+		queue = append(queue[:0], sched.Missing(0)...)
+	}
+	if coverage := sched.Coverage(); coverage != 1 {
+		t.Fatalf("final coverage mismatch: have %v, want 1", coverage)
+	}
+	// Cross check that the two tries are in sync
+	checkTrieContents(t, triedb, srcTrie.Root(), srcData)
+}